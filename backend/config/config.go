@@ -1,6 +1,8 @@
 // config/config.go
 package config
 
+import "time"
+
 // Config holds the application configuration
 type Config struct {
 	// Database connection string
@@ -18,4 +20,22 @@ type Config struct {
 
 	// Logging configuration
 	LogLevel string
+
+	// How often the background worker geocodes records missing coordinates;
+	// zero disables the worker
+	BackfillInterval time.Duration
+
+	// How long a user's permissions are cached between change notifications
+	PermissionCacheTTL time.Duration
+
+	// Public URL of the frontend, used in emailed links
+	AppBaseURL string
+
+	// Key signing the cookie that identifies anonymous devices and the
+	// contact form tokens
+	DeviceCookieSecret []byte
+
+	// Form submissions sent sooner than this after the form was shown are
+	// treated as spam
+	FormMinFillTime time.Duration
 }
@@ -0,0 +1,65 @@
+// geocoding/cache.go
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// CachedGeocoder stores results in the geocode_cache table so that an
+// unchanged address is only sent to the underlying geocoder once
+type CachedGeocoder struct {
+	db   *gorm.DB
+	next Geocoder
+}
+
+// NewCachedGeocoder wraps next with a database-backed cache
+func NewCachedGeocoder(db *gorm.DB, next Geocoder) *CachedGeocoder {
+	return &CachedGeocoder{db: db, next: next}
+}
+
+// Geocode implements Geocoder
+func (g *CachedGeocoder) Geocode(ctx context.Context, address string) (*Result, error) {
+	key := NormalizeAddress(address)
+	if key == "" {
+		return nil, ErrNoResults
+	}
+
+	var entry models.GeocodeCache
+	err := g.db.WithContext(ctx).Where("address_key = ?", key).First(&entry).Error
+	if err == nil {
+		return &Result{
+			Latitude:         entry.Latitude,
+			Longitude:        entry.Longitude,
+			FormattedAddress: entry.FormattedAddress,
+		}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to read geocode cache: %w", err)
+	}
+
+	result, err := g.next.Geocode(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	entry = models.GeocodeCache{
+		AddressKey:       key,
+		Address:          address,
+		Latitude:         result.Latitude,
+		Longitude:        result.Longitude,
+		FormattedAddress: result.FormattedAddress,
+	}
+	if err := g.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+		log.Printf("[GEOCODE] Failed to cache result for %q: %v", address, err)
+	}
+
+	return result, nil
+}
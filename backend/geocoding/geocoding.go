@@ -0,0 +1,43 @@
+// geocoding/geocoding.go
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrNoResults is returned when an address could not be resolved
+var ErrNoResults = errors.New("no geocoding results for address")
+
+// Result is a resolved address
+type Result struct {
+	Latitude         float64
+	Longitude        float64
+	FormattedAddress string
+}
+
+// Geocoder resolves a free-form address to coordinates
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (*Result, error)
+}
+
+// NormalizeAddress lowercases an address and collapses whitespace and
+// punctuation so that trivially different spellings share a cache entry
+func NormalizeAddress(address string) string {
+	fields := strings.FieldsFunc(strings.ToLower(address), func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == ',' || r == '.' || r == '#'
+	})
+	return strings.Join(fields, " ")
+}
+
+// JoinAddress builds a single-line address from its non-empty parts
+func JoinAddress(parts ...string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
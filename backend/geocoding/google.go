@@ -0,0 +1,89 @@
+// geocoding/google.go
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const googleGeocodeURL = "https://maps.googleapis.com/maps/api/geocode/json"
+
+// GoogleGeocoder resolves addresses with the Google Geocoding API
+type GoogleGeocoder struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+// NewGoogleGeocoder creates a geocoder using the given Google Maps API key
+func NewGoogleGeocoder(apiKey string) *GoogleGeocoder {
+	return &GoogleGeocoder{
+		apiKey:  apiKey,
+		baseURL: googleGeocodeURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type googleResponse struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+	Results      []struct {
+		FormattedAddress string `json:"formatted_address"`
+		Geometry         struct {
+			Location struct {
+				Lat float64 `json:"lat"`
+				Lng float64 `json:"lng"`
+			} `json:"location"`
+		} `json:"geometry"`
+	} `json:"results"`
+}
+
+// Geocode implements Geocoder
+func (g *GoogleGeocoder) Geocode(ctx context.Context, address string) (*Result, error) {
+	params := url.Values{}
+	params.Set("address", address)
+	params.Set("region", "us")
+	params.Set("key", g.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build geocoding request: %w", err)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("geocoding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("geocoding request failed with HTTP %d", resp.StatusCode)
+	}
+
+	var body googleResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode geocoding response: %w", err)
+	}
+
+	switch body.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return nil, ErrNoResults
+	default:
+		return nil, fmt.Errorf("geocoding failed with status %s: %s", body.Status, body.ErrorMessage)
+	}
+	if len(body.Results) == 0 {
+		return nil, ErrNoResults
+	}
+
+	first := body.Results[0]
+	return &Result{
+		Latitude:         first.Geometry.Location.Lat,
+		Longitude:        first.Geometry.Location.Lng,
+		FormattedAddress: first.FormattedAddress,
+	}, nil
+}
@@ -0,0 +1,76 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// googleServer answers geocoding requests with status and body
+func googleServer(t *testing.T, status int, body string) *GoogleGeocoder {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("address"); got != "1 Main St, Irvine, CA" {
+			t.Errorf("address = %q", got)
+		}
+		if got := r.URL.Query().Get("key"); got != "key" {
+			t.Errorf("key = %q", got)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	g := NewGoogleGeocoder("key")
+	g.baseURL = server.URL
+	return g
+}
+
+func TestGoogleGeocoder(t *testing.T) {
+	g := googleServer(t, http.StatusOK, `{"status":"OK","results":[
+		{"formatted_address":"1 Main St, Irvine, CA 92618, USA","geometry":{"location":{"lat":33.68,"lng":-117.79}}},
+		{"formatted_address":"1 Main St, Irvine, KY, USA","geometry":{"location":{"lat":37.7,"lng":-83.97}}}
+	]}`)
+
+	result, err := g.Geocode(context.Background(), "1 Main St, Irvine, CA")
+	if err != nil {
+		t.Fatalf("Geocode: %v", err)
+	}
+	want := Result{Latitude: 33.68, Longitude: -117.79, FormattedAddress: "1 Main St, Irvine, CA 92618, USA"}
+	if *result != want {
+		t.Errorf("Geocode = %+v, want the first result %+v", *result, want)
+	}
+}
+
+func TestGoogleGeocoderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"zero results", http.StatusOK, `{"status":"ZERO_RESULTS","results":[]}`, ErrNoResults},
+		{"OK without results", http.StatusOK, `{"status":"OK","results":[]}`, ErrNoResults},
+		{"denied", http.StatusOK, `{"status":"REQUEST_DENIED","error_message":"bad key"}`, nil},
+		{"HTTP error", http.StatusInternalServerError, ``, nil},
+		{"invalid JSON", http.StatusOK, `{`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := googleServer(t, tt.status, tt.body)
+			result, err := g.Geocode(context.Background(), "1 Main St, Irvine, CA")
+			if err == nil {
+				t.Fatalf("Geocode = %+v, want an error", result)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Geocode error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && errors.Is(err, ErrNoResults) {
+				t.Errorf("Geocode error = %v, want a failure other than ErrNoResults", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStaticGeocoder(t *testing.T) {
+	downtown := Result{Latitude: 34.0522, Longitude: -118.2437, FormattedAddress: "Los Angeles, CA, USA"}
+	g := NewStaticGeocoder(map[string]Result{
+		"200 N Spring St, Los Angeles, CA 90012": downtown,
+	})
+	g.Add("1 World Way, Los Angeles, CA", Result{Latitude: 33.9416, Longitude: -118.4085})
+
+	tests := []struct {
+		address string
+		want    *Result
+	}{
+		{"200 N Spring St, Los Angeles, CA 90012", &downtown},
+		{"200 n spring st los angeles ca 90012", &downtown},
+		{"  200 N. Spring St.,\tLos Angeles,  CA 90012 ", &downtown},
+		{"1 WORLD WAY, LOS ANGELES, CA", &Result{Latitude: 33.9416, Longitude: -118.4085}},
+		{"201 N Spring St, Los Angeles, CA 90012", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			got, err := g.Geocode(context.Background(), tt.address)
+			if tt.want == nil {
+				if !errors.Is(err, ErrNoResults) {
+					t.Errorf("Geocode = %+v, %v, want ErrNoResults", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Geocode: %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("Geocode = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+
+	if calls := g.Calls(); calls != len(tests) {
+		t.Errorf("Calls = %d, want %d", calls, len(tests))
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"", ""},
+		{"123 Main St.", "123 main st"},
+		{"123 Main St, Suite #4,\nIrvine, CA", "123 main st suite 4 irvine ca"},
+		{"  123   MAIN\tst ", "123 main st"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeAddress(tt.address); got != tt.want {
+			t.Errorf("NormalizeAddress(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestJoinAddress(t *testing.T) {
+	tests := []struct {
+		parts []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"123 Main St", "Irvine", "CA 92618"}, "123 Main St, Irvine, CA 92618"},
+		{[]string{" 123 Main St ", "", "  ", "CA"}, "123 Main St, CA"},
+	}
+
+	for _, tt := range tests {
+		if got := JoinAddress(tt.parts...); got != tt.want {
+			t.Errorf("JoinAddress(%q) = %q, want %q", tt.parts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/middleware"
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+// Audit Log Handlers
+
+// GetAuditLogs retrieves a page of the audit log, newest first. It filters
+// by entity_type (a table name, e.g. aba_centers) and entity_id, user_id (a
+// user id or "me"), action (create, update or delete), request_id, and from
+// and to as for the inbox.
+func (h *Handler) GetAuditLogs(c *gin.Context) {
+	log.Printf("[GET_AUDIT_LOGS] Request received")
+
+	filter := services.AuditFilter{
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		RequestID:  c.Query("request_id"),
+	}
+	if !parseTimeRange(c, &filter.From, &filter.To) {
+		return
+	}
+
+	switch action := c.Query("action"); action {
+	case "", models.AuditCreate, models.AuditUpdate, models.AuditDelete:
+		filter.Action = action
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be create, update or delete"})
+		return
+	}
+
+	switch actor := c.Query("user_id"); actor {
+	case "":
+	case "me":
+		if user, ok := middleware.CurrentUser(c); ok {
+			filter.ActorID = &user.ID
+		}
+	default:
+		id, err := strconv.Atoi(actor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id must be a user id or me"})
+			return
+		}
+		filter.ActorID = &id
+	}
+
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.svc(c).GetAuditLogs(filter, page)
+	if err != nil {
+		respondPageError(c, "GET_AUDIT_LOGS", "Failed to fetch audit log", err)
+		return
+	}
+
+	respondPage(c, result)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestGetAuditLogs(t *testing.T) {
+	service := &fakeService{}
+	handler := NewHandler(service)
+	r := gin.New()
+	r.GET("/api/v1/admin/audit-logs", handler.GetAuditLogs)
+
+	w := serve(r, http.MethodGet, "/api/v1/admin/audit-logs?entity_type=aba_centers&entity_id=abc&action=update&user_id=7&request_id=req-1&from=2024-01-01", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	filter := service.auditFilter
+	if filter.EntityType != "aba_centers" || filter.EntityID != "abc" || filter.Action != models.AuditUpdate ||
+		filter.RequestID != "req-1" || filter.ActorID == nil || *filter.ActorID != 7 || filter.From == nil {
+		t.Errorf("filter = %+v, want every query parameter passed on", filter)
+	}
+
+	// An anonymous "me" has no user to filter by
+	service.auditFilter.ActorID = nil
+	if w := serve(r, http.MethodGet, "/api/v1/admin/audit-logs?user_id=me", "", nil); w.Code != http.StatusOK || service.auditFilter.ActorID != nil {
+		t.Errorf("user_id=me without a user: status %d, actor %v, want 200 and no actor", w.Code, service.auditFilter.ActorID)
+	}
+
+	for _, query := range []string{"action=rename", "user_id=bob", "from=yesterday"} {
+		if w := serve(r, http.MethodGet, "/api/v1/admin/audit-logs?"+query, "", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
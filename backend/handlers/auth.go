@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/middleware"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+// Authentication Handlers
+
+// Login exchanges an email and password for access and refresh tokens
+func (h *Handler) Login(c *gin.Context) {
+	var request struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email and password are required"})
+		return
+	}
+
+	tokens, err := h.svc(c).Login(request.Email, request.Password, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+			return
+		}
+		log.Printf("[LOGIN] Error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+
+	h.mergeDevicePreferences(c, tokens.User.ID)
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Refresh exchanges a refresh token for a new pair of tokens
+func (h *Handler) Refresh(c *gin.Context) {
+	var request struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	tokens, err := h.svc(c).Refresh(request.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		log.Printf("[REFRESH] Error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout ends a session. The session is picked by the refresh token in the
+// body when one is sent, so it can be revoked after the access token has
+// expired, and by the request's access token otherwise.
+func (h *Handler) Logout(c *gin.Context) {
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	var err error
+	if request.RefreshToken != "" {
+		err = h.svc(c).RevokeRefreshToken(request.RefreshToken)
+	} else if token, ok := middleware.BearerToken(c); ok {
+		err = h.svc(c).Logout(token)
+	} else {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	if err != nil && !errors.Is(err, services.ErrInvalidToken) {
+		log.Printf("[LOGOUT] Error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetPassword sets a user's password with a one-time invitation or
+// password reset token
+func (h *Handler) SetPassword(c *gin.Context) {
+	var request struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token and password are required"})
+		return
+	}
+
+	if err := h.svc(c).SetPasswordWithToken(request.Token, request.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This link is invalid, used or expired"})
+			return
+		}
+		respondWriteError(c, "SET_PASSWORD", "User", "Failed to set password", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Me returns the signed-in user
+func (h *Handler) Me(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+func TestLogout(t *testing.T) {
+	bearer := http.Header{"Authorization": {"Bearer access"}}
+	tests := []struct {
+		name   string
+		body   string
+		header http.Header
+		err    error
+		status int
+		token  string
+	}{
+		{"access token", "", bearer, nil, http.StatusNoContent, "access"},
+		{"refresh token wins", `{"refresh_token": "refresh"}`, bearer, nil, http.StatusNoContent, "refresh"},
+		{"refresh token alone", `{"refresh_token": "refresh"}`, nil, nil, http.StatusNoContent, "refresh"},
+		{"already ended", `{"refresh_token": "refresh"}`, nil, services.ErrInvalidToken, http.StatusNoContent, "refresh"},
+		{"no token", "", nil, nil, http.StatusUnauthorized, ""},
+		{"invalid body", "{", bearer, nil, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{err: tt.err}
+			r := gin.New()
+			r.POST("/api/v1/auth/logout", NewHandler(service).Logout)
+
+			w := serve(r, http.MethodPost, "/api/v1/auth/logout", tt.body, tt.header)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if service.loggedOut != tt.token {
+				t.Errorf("ended session of %q, want %q", service.loggedOut, tt.token)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+// Export Handlers
+
+// exportContentTypes are the response content types of the export formats
+var exportContentTypes = map[string]string{
+	services.ExportCSV:     "text/csv; charset=utf-8",
+	services.ExportGeoJSON: "application/geo+json",
+	services.ExportKML:     "application/vnd.google-earth.kml+xml",
+}
+
+// ExportABACenters streams the ABA centers matching the GetABACenters
+// filters as format=csv (the default), geojson or kml
+func (h *Handler) ExportABACenters(c *gin.Context) {
+	filter, _, ok := h.parsePreferredFilter(c)
+	if !ok {
+		return
+	}
+	h.exportFacilities(c, "EXPORT_ABA_CENTERS", "aba-centers", filter, []string{"aba_centers"})
+}
+
+// ExportResourceCenters streams the resource centers matching the
+// GetResourceCenters filters as format=csv (the default), geojson or kml
+func (h *Handler) ExportResourceCenters(c *gin.Context) {
+	filter, _, ok := h.parsePreferredFilter(c)
+	if !ok {
+		return
+	}
+	h.exportFacilities(c, "EXPORT_RESOURCE_CENTERS", "resource-centers", filter, []string{"resource_centers"})
+}
+
+// ExportNearby streams the facilities SearchNearby finds, taking the same
+// parameters, as format=csv (the default), geojson or kml. Unlike
+// SearchNearby it isn't limited to a page of each type.
+func (h *Handler) ExportNearby(c *gin.Context) {
+	filter, entityTypes, allHidden, ok := h.parseNearbyRequest(c)
+	if !ok {
+		return
+	}
+	for _, entityType := range entityTypes {
+		if !slices.Contains(services.ExportEntityTypes, entityType) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("Unknown type %q", entityType),
+				"allowed": services.ExportEntityTypes,
+			})
+			return
+		}
+	}
+	switch {
+	case allHidden:
+		entityTypes = []string{} // every type is hidden, export none
+	case len(entityTypes) == 0:
+		entityTypes = services.ExportEntityTypes
+	}
+	h.exportFacilities(c, "EXPORT_NEARBY", "nearby-facilities", filter, entityTypes)
+}
+
+// exportFacilities streams an export of entityTypes as the format query
+// parameter asks, named after name and today's date
+func (h *Handler) exportFacilities(c *gin.Context, tag, name string, filter *models.SearchFilter, entityTypes []string) {
+	format := strings.ToLower(c.DefaultQuery("format", services.ExportCSV))
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of " + strings.Join(services.ExportFormats, ", ")})
+		return
+	}
+	log.Printf("[%s] Exporting %s as %s", tag, strings.Join(entityTypes, ", "), format)
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s.%s\"", name, time.Now().Format("2006-01-02"), format))
+	c.Status(http.StatusOK)
+	if err := h.svc(c).ExportFacilities(filter, entityTypes, format, c.Writer); err != nil {
+		// Headers are gone by now; all we can do is log and cut the file short
+		log.Printf("[%s] Error: %v", tag, err)
+	}
+}
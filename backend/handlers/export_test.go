@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+func TestExportFacilities(t *testing.T) {
+	tests := []struct {
+		target      string
+		status      int
+		types       []string
+		format      string
+		contentType string
+		filename    string
+	}{
+		{"/api/v1/aba-centers/export?city=Irvine", http.StatusOK,
+			[]string{"aba_centers"}, services.ExportCSV, "text/csv; charset=utf-8", "aba-centers-"},
+		{"/api/v1/aba-centers/export?format=GeoJSON", http.StatusOK,
+			[]string{"aba_centers"}, services.ExportGeoJSON, "application/geo+json", "aba-centers-"},
+		{"/api/v1/nearby/export?lat=33.6&lng=-117.8&radius=10&format=kml", http.StatusOK,
+			services.ExportEntityTypes, services.ExportKML, "application/vnd.google-earth.kml+xml", "nearby-facilities-"},
+		{"/api/v1/nearby/export?lat=33.6&lng=-117.8&radius=10&types=resources", http.StatusOK,
+			[]string{"resources"}, services.ExportCSV, "text/csv; charset=utf-8", "nearby-facilities-"},
+		{"/api/v1/aba-centers/export?format=pdf", http.StatusBadRequest, nil, "", "", ""},
+		{"/api/v1/nearby/export?lat=33.6&lng=-117.8&radius=10&types=providers", http.StatusBadRequest, nil, "", "", ""},
+		{"/api/v1/nearby/export?format=csv", http.StatusBadRequest, nil, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		service := &fakeService{}
+		handler := NewHandler(service)
+		r := gin.New()
+		r.GET("/api/v1/aba-centers/export", handler.ExportABACenters)
+		r.GET("/api/v1/nearby/export", handler.ExportNearby)
+
+		w := serve(r, http.MethodGet, tt.target, "", nil)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.target, w.Code, tt.status, w.Body)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			if service.exportFormat != "" {
+				t.Errorf("%s: exported as %s, want no export", tt.target, service.exportFormat)
+			}
+			continue
+		}
+		if service.exportFormat != tt.format || !reflect.DeepEqual(service.searched, tt.types) {
+			t.Errorf("%s: exported %v as %s, want %v as %s", tt.target, service.searched, service.exportFormat, tt.types, tt.format)
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.target, got, tt.contentType)
+		}
+		if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, `filename="`+tt.filename) ||
+			!strings.HasSuffix(got, "."+tt.format+`"`) {
+			t.Errorf("%s: Content-Disposition = %q, want an attachment named %s<date>.%s", tt.target, got, tt.filename, tt.format)
+		}
+		if w.Body.String() != "exported" {
+			t.Errorf("%s: body = %q, want the export streamed", tt.target, w.Body)
+		}
+	}
+}
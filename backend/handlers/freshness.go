@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/middleware"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+// Data Freshness Handlers
+
+// VerifyRecord returns a handler marking the facility of entityType named by
+// the id path parameter as verified now by the signed-in user. entity names
+// the facility in error messages.
+func (h *Handler) VerifyRecord(entityType, entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := middleware.CurrentUser(c)
+
+		record, err := h.svc(c).VerifyRecord(entityType, c.Param("id"), user.ID)
+		if err != nil {
+			respondWriteError(c, "VERIFY_RECORD", entity, "Failed to verify "+strings.ToLower(entity), err)
+			return
+		}
+
+		log.Printf("[VERIFY_RECORD] User %d verified %s %s", user.ID, entityType, record.ID)
+		c.JSON(http.StatusOK, record)
+	}
+}
+
+// GetStaleRecords lists facilities not verified within the last days days
+// (a year by default), grouped by type and county. types limits the report
+// to some facility types, e.g. types=aba_centers,providers.
+func (h *Handler) GetStaleRecords(c *gin.Context) {
+	days := services.DefaultStaleDays
+	if raw := c.Query("days"); raw != "" {
+		var err error
+		if days, err = strconv.Atoi(raw); err != nil || days < 1 || days > services.MaxStaleDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be an integer between 1 and %d", services.MaxStaleDays)})
+			return
+		}
+	}
+
+	entityTypes := c.QueryArray("types")
+	if len(entityTypes) == 1 && strings.Contains(entityTypes[0], ",") {
+		entityTypes = strings.Split(entityTypes[0], ",")
+	}
+	for _, entityType := range entityTypes {
+		if !slices.Contains(services.FreshnessEntityTypes, entityType) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("Unknown type %q", entityType),
+				"allowed": services.FreshnessEntityTypes,
+			})
+			return
+		}
+	}
+
+	report, err := h.svc(c).StaleRecords(days, entityTypes)
+	if err != nil {
+		log.Printf("[GET_STALE_RECORDS] Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stale records"})
+		return
+	}
+
+	log.Printf("[GET_STALE_RECORDS] %d records not verified in %d days", report.Total, days)
+	c.JSON(http.StatusOK, report)
+}
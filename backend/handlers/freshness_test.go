@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+func TestGetStaleRecords(t *testing.T) {
+	tests := []struct {
+		query  string
+		status int
+		days   int
+		types  []string
+	}{
+		{"", http.StatusOK, services.DefaultStaleDays, nil},
+		{"days=90&types=aba_centers,providers", http.StatusOK, 90, []string{"aba_centers", "providers"}},
+		{"days=0", http.StatusBadRequest, 0, nil},
+		{"days=soon", http.StatusBadRequest, 0, nil},
+		{"types=hospitals", http.StatusBadRequest, 0, nil},
+	}
+
+	for _, tt := range tests {
+		service := &fakeService{}
+		r := gin.New()
+		r.GET("/stale", NewHandler(service).GetStaleRecords)
+		w := serve(r, http.MethodGet, "/stale?"+tt.query, "", nil)
+		if w.Code != tt.status {
+			t.Errorf("%q: status = %d, want %d", tt.query, w.Code, tt.status)
+			continue
+		}
+		if service.staleDays != tt.days || !reflect.DeepEqual(service.searched, tt.types) {
+			t.Errorf("%q: StaleRecords(%d, %v), want (%d, %v)", tt.query, service.staleDays, service.searched, tt.days, tt.types)
+		}
+	}
+}
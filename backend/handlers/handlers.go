@@ -1,92 +1,87 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"github.com/google/uuid"
 
+	"github.com/alexbeattie/medicalfacilities/middleware"
 	"github.com/alexbeattie/medicalfacilities/models"
 	"github.com/alexbeattie/medicalfacilities/services"
 )
 
 type Handler struct {
-	service *services.Service
-	db      *gorm.DB
+	service Service
 }
 
-func NewHandler(service *services.Service, db *gorm.DB) *Handler {
+func NewHandler(service Service) *Handler {
 	return &Handler{
 		service: service,
-		db:      db,
 	}
 }
 
+// svc returns the service scoped to the request: its queries end with the
+// request, and its writes are recorded in the audit log as made by the
+// signed-in user
+func (h *Handler) svc(c *gin.Context) Service {
+	actor := services.AuditActor{IPAddress: c.ClientIP(), RequestID: middleware.CurrentRequestID(c)}
+	if user, ok := middleware.CurrentUser(c); ok {
+		actor.UserID = &user.ID
+	}
+	return h.service.WithContext(services.WithAuditActor(c.Request.Context(), actor))
+}
+
 // ABA Centers Handlers
 
-// GetABACenters retrieves ABA centers with optional filtering
+// GetABACenters retrieves a page of ABA centers with optional filtering
 func (h *Handler) GetABACenters(c *gin.Context) {
 	log.Printf("[GET_ABA_CENTERS] Request received")
 
-	// Parse query parameters
-	city := c.Query("city")
-	serviceType := c.Query("service_type")
-	insurance := c.Query("insurance")
-	waitlist := c.Query("waitlist") == "true"
-	search := c.Query("search")
-
-	var centers []models.ABACenter
-	query := h.db.Model(&models.ABACenter{})
-
-	// Apply filters
-	if city != "" {
-		query = query.Where("LOWER(city) LIKE ?", "%"+strings.ToLower(city)+"%")
-	}
-
-	if serviceType != "" {
-		query = query.Where("service_type = ?", serviceType)
-	}
-
-	if insurance != "" {
-		query = query.Where("LOWER(insurance_accepted) LIKE ?", "%"+strings.ToLower(insurance)+"%")
+	filter, preferences, ok := h.parsePreferredFilter(c)
+	if !ok {
+		return
 	}
-
-	if waitlist {
-		query = query.Where("waitlist_availability IS NOT NULL AND waitlist_availability != ''")
+	page, ok := parsePage(c)
+	if !ok {
+		return
 	}
-
-	// Text search across name, street, and notes
-	if search != "" {
-		searchTerm := "%" + strings.ToLower(search) + "%"
-		query = query.Where(
-			"LOWER(name) LIKE ? OR LOWER(street) LIKE ? OR LOWER(notes) LIKE ?",
-			searchTerm, searchTerm, searchTerm,
-		)
+	if hiddenByPreferences(preferences, "aba_centers") {
+		respondPage(c, &services.Page[models.ABACenter]{Items: []models.ABACenter{}})
+		return
 	}
 
-	// Execute query
-	if err := query.Find(&centers).Error; err != nil {
-		log.Printf("[GET_ABA_CENTERS] Database error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ABA centers"})
+	result, err := h.svc(c).GetABACenters(filter, page)
+	if err != nil {
+		respondPageError(c, "GET_ABA_CENTERS", "Failed to fetch ABA centers", err)
 		return
 	}
 
-	log.Printf("[GET_ABA_CENTERS] Returning %d centers", len(centers))
-	c.JSON(http.StatusOK, centers)
+	log.Printf("[GET_ABA_CENTERS] Returning %d of %d centers", len(result.Items), result.Total)
+	respondPage(c, result)
 }
 
 // GetABACenter retrieves a single ABA center by ID
 func (h *Handler) GetABACenter(c *gin.Context) {
-	centerID := c.Param("id")
-	log.Printf("[GET_ABA_CENTER] Request for center ID: %s", centerID)
+	idStr := c.Param("id")
+	log.Printf("[GET_ABA_CENTER] Request for ID: %s", idStr)
 
-	var center models.ABACenter
-	if err := h.db.First(&center, "id = ?", centerID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ABA center not found"})
+		return
+	}
+
+	result, err := h.svc(c).GetABACenterByID(id)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "ABA center not found"})
 			return
 		}
@@ -95,7 +90,32 @@ func (h *Handler) GetABACenter(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, center)
+	c.JSON(http.StatusOK, result)
+}
+
+// GetWaitlistHistory lists the waitlist changes of an ABA center, newest
+// first
+func (h *Handler) GetWaitlistHistory(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "ABA center")
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.svc(c).GetWaitlistHistory(id, page)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "ABA center not found"})
+			return
+		}
+		respondPageError(c, "GET_WAITLIST_HISTORY", "Failed to fetch waitlist history", err)
+		return
+	}
+
+	respondPage(c, result)
 }
 
 // CreateABACenter creates a new ABA center
@@ -108,75 +128,129 @@ func (h *Handler) CreateABACenter(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Create(&center).Error; err != nil {
-		log.Printf("[CREATE_ABA_CENTER] Database error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ABA center"})
+	if err := h.svc(c).CreateABACenter(&center); err != nil {
+		respondWriteError(c, "CREATE_ABA_CENTER", "ABA center", "Failed to create ABA center", err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, center)
 }
 
-// Resource Centers Handlers
+// UpdateABACenter replaces an ABA center. The body must include the
+// updated_at value last read by the client.
+func (h *Handler) UpdateABACenter(c *gin.Context) {
+	centerID := c.Param("id")
+	log.Printf("[UPDATE_ABA_CENTER] Request for center ID: %s", centerID)
 
-// GetResourceCenters retrieves resource centers with optional filtering
-func (h *Handler) GetResourceCenters(c *gin.Context) {
-	log.Printf("[GET_RESOURCE_CENTERS] Request received")
+	id, err := uuid.Parse(centerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ABA center not found"})
+		return
+	}
 
-	search := c.Query("search")
-	latStr := c.Query("lat")
-	lngStr := c.Query("lng")
-	radiusStr := c.Query("radius")
+	var center models.ABACenter
+	if err := c.ShouldBindJSON(&center); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+
+	if err := h.svc(c).UpdateABACenter(id, &center); err != nil {
+		respondWriteError(c, "UPDATE_ABA_CENTER", "ABA center", "Failed to update ABA center", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, center)
+}
+
+// PatchABACenter updates the fields present in the body of an ABA center.
+// The body must include the updated_at value last read by the client.
+func (h *Handler) PatchABACenter(c *gin.Context) {
+	centerID := c.Param("id")
+	log.Printf("[PATCH_ABA_CENTER] Request for center ID: %s", centerID)
 
-	var centers []models.ResourceCenter
-	query := h.db.Model(&models.ResourceCenter{}).Preload("Diagnoses")
+	id, err := uuid.Parse(centerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ABA center not found"})
+		return
+	}
 
-	// Text search
-	if search != "" {
-		searchTerm := "%" + strings.ToLower(search) + "%"
-		query = query.Where(
-			"LOWER(name) LIKE ? OR LOWER(description) LIKE ? OR LOWER(address) LIKE ?",
-			searchTerm, searchTerm, searchTerm,
-		)
+	var patch map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
 	}
 
-	// Execute query
-	if err := query.Find(&centers).Error; err != nil {
-		log.Printf("[GET_RESOURCE_CENTERS] Database error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch resource centers"})
+	center, err := h.svc(c).PatchABACenter(id, patch)
+	if err != nil {
+		respondWriteError(c, "PATCH_ABA_CENTER", "ABA center", "Failed to update ABA center", err)
 		return
 	}
 
-	// Filter by distance if location is provided
-	if latStr != "" && lngStr != "" && radiusStr != "" {
-		lat, latErr := strconv.ParseFloat(latStr, 64)
-		lng, lngErr := strconv.ParseFloat(lngStr, 64)
-		radius, radiusErr := strconv.ParseFloat(radiusStr, 64)
+	c.JSON(http.StatusOK, center)
+}
+
+// DeleteABACenter soft-deletes an ABA center
+func (h *Handler) DeleteABACenter(c *gin.Context) {
+	centerID := c.Param("id")
+	log.Printf("[DELETE_ABA_CENTER] Request for center ID: %s", centerID)
 
-		if latErr == nil && lngErr == nil && radiusErr == nil {
-			filteredCenters := make([]models.ResourceCenter, 0)
-			for _, center := range centers {
-				distance := calculateDistance(lat, lng, center.Latitude, center.Longitude)
-				if distance <= radius {
-					filteredCenters = append(filteredCenters, center)
-				}
-			}
-			centers = filteredCenters
-		}
+	id, err := uuid.Parse(centerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ABA center not found"})
+		return
 	}
 
-	log.Printf("[GET_RESOURCE_CENTERS] Returning %d centers", len(centers))
-	c.JSON(http.StatusOK, centers)
+	if err := h.svc(c).DeleteABACenter(id); err != nil {
+		respondWriteError(c, "DELETE_ABA_CENTER", "ABA center", "Failed to delete ABA center", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Resource Centers Handlers
+
+// GetResourceCenters retrieves a page of resource centers with optional filtering
+func (h *Handler) GetResourceCenters(c *gin.Context) {
+	log.Printf("[GET_RESOURCE_CENTERS] Request received")
+
+	filter, preferences, ok := h.parsePreferredFilter(c)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	if hiddenByPreferences(preferences, "resource_centers") {
+		respondPage(c, &services.Page[models.ResourceCenter]{Items: []models.ResourceCenter{}})
+		return
+	}
+
+	result, err := h.svc(c).GetResourceCenters(filter, page)
+	if err != nil {
+		respondPageError(c, "GET_RESOURCE_CENTERS", "Failed to fetch resource centers", err)
+		return
+	}
+
+	log.Printf("[GET_RESOURCE_CENTERS] Returning %d of %d centers", len(result.Items), result.Total)
+	respondPage(c, result)
 }
 
 // GetResourceCenter retrieves a single resource center by ID
 func (h *Handler) GetResourceCenter(c *gin.Context) {
-	centerID := c.Param("id")
-	log.Printf("[GET_RESOURCE_CENTER] Request for center ID: %s", centerID)
+	idStr := c.Param("id")
+	log.Printf("[GET_RESOURCE_CENTER] Request for ID: %s", idStr)
 
-	var center models.ResourceCenter
-	if err := h.db.Preload("Diagnoses").First(&center, "id = ?", centerID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Resource center not found"})
+		return
+	}
+
+	result, err := h.svc(c).GetResourceCenterByID(id)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Resource center not found"})
 			return
 		}
@@ -185,75 +259,52 @@ func (h *Handler) GetResourceCenter(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, center)
+	c.JSON(http.StatusOK, result)
 }
 
 // Resources Handlers
 
-// GetResources retrieves resources with optional filtering
+// GetResources retrieves a page of resources with optional filtering
 func (h *Handler) GetResources(c *gin.Context) {
 	log.Printf("[GET_RESOURCES] Request received")
 
-	search := c.Query("search")
-	diagnosis := c.Query("diagnosis")
-	latStr := c.Query("lat")
-	lngStr := c.Query("lng")
-	radiusStr := c.Query("radius")
-
-	var resources []models.Resource
-	query := h.db.Model(&models.Resource{})
-
-	// Text search
-	if search != "" {
-		searchTerm := "%" + strings.ToLower(search) + "%"
-		query = query.Where(
-			"LOWER(name) LIKE ? OR LOWER(description) LIKE ? OR LOWER(address) LIKE ?",
-			searchTerm, searchTerm, searchTerm,
-		)
+	filter, preferences, ok := h.parsePreferredFilter(c)
+	if !ok {
+		return
 	}
-
-	// Filter by diagnosis
-	if diagnosis != "" {
-		query = query.Where("? = ANY(diagnoses)", diagnosis)
+	page, ok := parsePage(c)
+	if !ok {
+		return
 	}
-
-	// Execute query
-	if err := query.Find(&resources).Error; err != nil {
-		log.Printf("[GET_RESOURCES] Database error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch resources"})
+	if hiddenByPreferences(preferences, "resources") {
+		respondPage(c, &services.Page[models.Resource]{Items: []models.Resource{}})
 		return
 	}
 
-	// Filter by distance if location is provided
-	if latStr != "" && lngStr != "" && radiusStr != "" {
-		lat, latErr := strconv.ParseFloat(latStr, 64)
-		lng, lngErr := strconv.ParseFloat(lngStr, 64)
-		radius, radiusErr := strconv.ParseFloat(radiusStr, 64)
-
-		if latErr == nil && lngErr == nil && radiusErr == nil {
-			filteredResources := make([]models.Resource, 0)
-			for _, resource := range resources {
-				distance := calculateDistance(lat, lng, resource.Latitude, resource.Longitude)
-				if distance <= radius {
-					filteredResources = append(filteredResources, resource)
-				}
-			}
-			resources = filteredResources
-		}
+	result, err := h.svc(c).GetResources(filter, page)
+	if err != nil {
+		respondPageError(c, "GET_RESOURCES", "Failed to fetch resources", err)
+		return
 	}
 
-	log.Printf("[GET_RESOURCES] Returning %d resources", len(resources))
-	c.JSON(http.StatusOK, resources)
+	log.Printf("[GET_RESOURCES] Returning %d of %d resources", len(result.Items), result.Total)
+	respondPage(c, result)
 }
 
 // GetResource retrieves a single resource by ID
 func (h *Handler) GetResource(c *gin.Context) {
-	resourceID := c.Param("id")
-	log.Printf("[GET_RESOURCE] Request for resource ID: %s", resourceID)
+	idStr := c.Param("id")
+	log.Printf("[GET_RESOURCE] Request for ID: %s", idStr)
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+		return
+	}
 
-	var resource models.Resource
-	if err := h.db.First(&resource, "id = ?", resourceID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	result, err := h.svc(c).GetResourceByID(id)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
 			return
 		}
@@ -262,301 +313,478 @@ func (h *Handler) GetResource(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, resource)
+	c.JSON(http.StatusOK, result)
 }
 
 // Regional Centers Handlers
 
-// GetRegionalCenters retrieves regional centers with optional filtering
+// GetRegionalCenters retrieves a page of regional centers with optional filtering
 func (h *Handler) GetRegionalCenters(c *gin.Context) {
 	log.Printf("[GET_REGIONAL_CENTERS] Request received")
 
-	county := c.Query("county")
-	search := c.Query("search")
-	latStr := c.Query("lat")
-	lngStr := c.Query("lng")
-	radiusStr := c.Query("radius")
-
-	var centers []models.RegionalCenter
-	query := h.db.Model(&models.RegionalCenter{})
-
-	// Filter by county
-	if county != "" {
-		query = query.Where("LOWER(county_served) LIKE ?", "%"+strings.ToLower(county)+"%")
+	filter, preferences, ok := h.parsePreferredFilter(c)
+	if !ok {
+		return
 	}
-
-	// Text search
-	if search != "" {
-		searchTerm := "%" + strings.ToLower(search) + "%"
-		query = query.Where(
-			"LOWER(regional_center) LIKE ? OR LOWER(address) LIKE ? OR LOWER(city) LIKE ?",
-			searchTerm, searchTerm, searchTerm,
-		)
+	page, ok := parsePage(c)
+	if !ok {
+		return
 	}
-
-	// Execute query
-	if err := query.Find(&centers).Error; err != nil {
-		log.Printf("[GET_REGIONAL_CENTERS] Database error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch regional centers"})
+	if hiddenByPreferences(preferences, "regional_centers") {
+		respondPage(c, &services.Page[models.RegionalCenter]{Items: []models.RegionalCenter{}})
 		return
 	}
 
-	// Filter by distance if location is provided
-	if latStr != "" && lngStr != "" && radiusStr != "" {
-		lat, latErr := strconv.ParseFloat(latStr, 64)
-		lng, lngErr := strconv.ParseFloat(lngStr, 64)
-		radius, radiusErr := strconv.ParseFloat(radiusStr, 64)
-
-		if latErr == nil && lngErr == nil && radiusErr == nil {
-			filteredCenters := make([]models.RegionalCenter, 0)
-			for _, center := range centers {
-				if center.Latitude != nil && center.Longitude != nil {
-					distance := calculateDistance(lat, lng, *center.Latitude, *center.Longitude)
-					if distance <= radius {
-						filteredCenters = append(filteredCenters, center)
-					}
-				}
-			}
-			centers = filteredCenters
-		}
+	result, err := h.svc(c).GetRegionalCenters(filter, page)
+	if err != nil {
+		respondPageError(c, "GET_REGIONAL_CENTERS", "Failed to fetch regional centers", err)
+		return
 	}
 
-	log.Printf("[GET_REGIONAL_CENTERS] Returning %d centers", len(centers))
-	c.JSON(http.StatusOK, centers)
+	log.Printf("[GET_REGIONAL_CENTERS] Returning %d of %d centers", len(result.Items), result.Total)
+	respondPage(c, result)
 }
 
 // Providers Handlers
 
-// GetProviders retrieves providers with optional filtering
+// GetProviders retrieves a page of providers with optional filtering
 func (h *Handler) GetProviders(c *gin.Context) {
 	log.Printf("[GET_PROVIDERS] Request received")
 
-	search := c.Query("search")
-	area := c.Query("area")
-
-	var providers []models.Provider
-	query := h.db.Model(&models.Provider{})
-
-	// Text search
-	if search != "" {
-		searchTerm := "%" + strings.ToLower(search) + "%"
-		query = query.Where(
-			"LOWER(name) LIKE ? OR LOWER(coverage_areas) LIKE ? OR LOWER(center_based_services) LIKE ?",
-			searchTerm, searchTerm, searchTerm,
-		)
+	filter, preferences, ok := h.parsePreferredFilter(c)
+	if !ok {
+		return
 	}
-
-	// Filter by area
-	if area != "" {
-		query = query.Where("? = ANY(areas)", area)
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	if hiddenByPreferences(preferences, "providers") {
+		respondPage(c, &services.Page[models.Provider]{Items: []models.Provider{}})
+		return
 	}
 
-	// Execute query
-	if err := query.Find(&providers).Error; err != nil {
-		log.Printf("[GET_PROVIDERS] Database error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch providers"})
+	result, err := h.svc(c).GetProviders(filter, page)
+	if err != nil {
+		respondPageError(c, "GET_PROVIDERS", "Failed to fetch providers", err)
 		return
 	}
 
-	log.Printf("[GET_PROVIDERS] Returning %d providers", len(providers))
-	c.JSON(http.StatusOK, providers)
+	log.Printf("[GET_PROVIDERS] Returning %d of %d providers", len(result.Items), result.Total)
+	respondPage(c, result)
 }
 
 // Diagnoses Handlers
 
-// GetDiagnoses retrieves all diagnoses
+// GetDiagnoses retrieves a page of diagnoses
 func (h *Handler) GetDiagnoses(c *gin.Context) {
 	log.Printf("[GET_DIAGNOSES] Request received")
 
-	var diagnoses []models.Diagnosis
-	if err := h.db.Find(&diagnoses).Error; err != nil {
-		log.Printf("[GET_DIAGNOSES] Database error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch diagnoses"})
+	filter, ok := parseFilter(c)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
 		return
 	}
 
-	log.Printf("[GET_DIAGNOSES] Returning %d diagnoses", len(diagnoses))
-	c.JSON(http.StatusOK, diagnoses)
+	result, err := h.svc(c).GetDiagnoses(filter, page)
+	if err != nil {
+		respondPageError(c, "GET_DIAGNOSES", "Failed to fetch diagnoses", err)
+		return
+	}
+
+	log.Printf("[GET_DIAGNOSES] Returning %d of %d diagnoses", len(result.Items), result.Total)
+	respondPage(c, result)
 }
 
-// Form Submissions Handlers
+// maxSearchQueryLength bounds the q parameter of Search
+const maxSearchQueryLength = 200
 
-// CreateFormSubmission creates a new form submission
-func (h *Handler) CreateFormSubmission(c *gin.Context) {
-	log.Printf("[CREATE_FORM_SUBMISSION] Request received")
+// Search finds facilities of every type matching a free-text query, best
+// matches first. Without types, types hidden by the caller's preferences
+// are left out.
+func (h *Handler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q parameter is required"})
+		return
+	}
+	if len(q) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("q must be at most %d characters", maxSearchQueryLength)})
+		return
+	}
 
-	var submission models.FormSubmission
-	if err := c.ShouldBindJSON(&submission); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+	entityTypes := c.QueryArray("types")
+	if len(entityTypes) == 1 && strings.Contains(entityTypes[0], ",") {
+		entityTypes = strings.Split(entityTypes[0], ",")
+	}
+	for _, entityType := range entityTypes {
+		if !slices.Contains(services.SearchEntityTypes, entityType) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("Unknown type %q", entityType),
+				"allowed": services.SearchEntityTypes,
+			})
+			return
+		}
+	}
+
+	limit := services.DefaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+	}
+
+	preferences, ok := h.searchPreferences(c)
+	if !ok {
 		return
 	}
+	if len(entityTypes) == 0 && preferences != nil {
+		entityTypes = services.VisibleEntityTypes(preferences, services.SearchEntityTypes)
+		if len(entityTypes) == 0 {
+			c.JSON(http.StatusOK, gin.H{"query": q, "results": []services.SearchResult{}}) // every type is hidden
+			return
+		}
+	}
 
-	if err := h.db.Create(&submission).Error; err != nil {
-		log.Printf("[CREATE_FORM_SUBMISSION] Database error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create form submission"})
+	results, err := h.svc(c).Search(q, entityTypes, limit)
+	if err != nil {
+		log.Printf("[SEARCH] Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, submission)
+	log.Printf("[SEARCH] %d results for %q", len(results), q)
+	c.JSON(http.StatusOK, gin.H{"query": q, "results": results})
 }
 
 // SearchNearby finds all types of facilities within a specified radius
 func (h *Handler) SearchNearby(c *gin.Context) {
-	latStr := c.Query("lat")
-	lngStr := c.Query("lng")
-	radiusStr := c.Query("radius")
-	entityTypes := c.QueryArray("types") // e.g., ?types=aba_centers&types=resources
-
-	if latStr == "" || lngStr == "" || radiusStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "lat, lng, and radius parameters are required"})
+	filter, entityTypes, allHidden, ok := h.parseNearbyRequest(c)
+	if !ok {
 		return
 	}
-
-	lat, err := strconv.ParseFloat(latStr, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid latitude"})
+	if allHidden {
+		c.JSON(http.StatusOK, gin.H{}) // every type is hidden
 		return
 	}
 
-	lng, err := strconv.ParseFloat(lngStr, 64)
+	log.Printf("[SEARCH_NEARBY] Searching near lat=%f, lng=%f, radius=%f", filter.Latitude, filter.Longitude, filter.MaxDistance)
+
+	result, err := h.svc(c).SearchNearby(filter, entityTypes)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid longitude"})
+		log.Printf("[SEARCH_NEARBY] Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search nearby facilities"})
 		return
 	}
 
-	radius, err := strconv.ParseFloat(radiusStr, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid radius"})
-		return
+	c.JSON(http.StatusOK, result)
+}
+
+// parseNearbyRequest reads the types and filters of a radius search, which
+// requires lat, lng and radius. Without types the caller's preferences pick
+// the visible types, and allHidden reports that they hide every type. It
+// writes a 400 response and returns ok=false when the request is invalid.
+func (h *Handler) parseNearbyRequest(c *gin.Context) (filter *models.SearchFilter, entityTypes []string, allHidden, ok bool) {
+	entityTypes = c.QueryArray("types") // e.g., ?types=aba_centers&types=resources
+	if len(entityTypes) == 1 && strings.Contains(entityTypes[0], ",") {
+		entityTypes = strings.Split(entityTypes[0], ",") // also accept ?types=aba_centers,resources
 	}
 
-	log.Printf("[SEARCH_NEARBY] Searching near lat=%f, lng=%f, radius=%f", lat, lng, radius)
+	if c.Query("lat") == "" || c.Query("lng") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat, lng, and radius parameters are required"})
+		return nil, nil, false, false
+	}
 
-	result := make(map[string]interface{})
+	filter, preferences, ok := h.parsePreferredFilter(c)
+	if !ok {
+		return nil, nil, false, false
+	}
+	if !filter.HasLocation() {
+		if c.Query("radius") == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lat, lng, and radius parameters are required"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lat, lng or radius"})
+		}
+		return nil, nil, false, false
+	}
+	if len(entityTypes) == 0 && preferences != nil {
+		entityTypes = services.VisibleEntityTypes(preferences, services.NearbyEntityTypes)
+		allHidden = len(entityTypes) == 0
+	}
+	return filter, entityTypes, allHidden, true
+}
+
+// hiddenByPreferences reports whether preferences hide entityType, whose
+// list is then empty
+func hiddenByPreferences(preferences *models.UserPreferences, entityType string) bool {
+	return preferences != nil && len(services.VisibleEntityTypes(preferences, []string{entityType})) == 0
+}
 
-	// If no types specified, search all
-	if len(entityTypes) == 0 {
-		entityTypes = []string{"resource_centers", "regional_centers", "resources"}
+// parseFilter reads the filter query parameters shared by the list and search
+// endpoints, writing a 400 response and returning ok=false on an invalid sort
+func parseFilter(c *gin.Context) (filter *models.SearchFilter, ok bool) {
+	filter = readFilter(c)
+	if !parseFacilityFilters(c, filter) {
+		return nil, false
+	}
+	if filter.Sort, ok = parseSort(c, filter.HasLocation()); !ok {
+		return nil, false
 	}
+	return filter, true
+}
 
-	for _, entityType := range entityTypes {
-		switch entityType {
-		case "resource_centers":
-			var centers []models.ResourceCenter
-			h.db.Model(&models.ResourceCenter{}).Preload("Diagnoses").Find(&centers)
-			nearby := make([]models.ResourceCenter, 0)
-			for _, center := range centers {
-				distance := calculateDistance(lat, lng, center.Latitude, center.Longitude)
-				if distance <= radius {
-					nearby = append(nearby, center)
-				}
-			}
-			result["resource_centers"] = nearby
-
-		case "regional_centers":
-			var centers []models.RegionalCenter
-			h.db.Find(&centers)
-			nearby := make([]models.RegionalCenter, 0)
-			for _, center := range centers {
-				if center.Latitude != nil && center.Longitude != nil {
-					distance := calculateDistance(lat, lng, *center.Latitude, *center.Longitude)
-					if distance <= radius {
-						nearby = append(nearby, center)
-					}
-				}
+// parsePreferredFilter is parseFilter with the caller's saved preferences
+// filling in the filters the query leaves out. Preferences apply to
+// signed-in users unless use_preferences=false, and to anonymous devices
+// with use_preferences=true; preferences is nil when none apply.
+func (h *Handler) parsePreferredFilter(c *gin.Context) (filter *models.SearchFilter, preferences *models.UserPreferences, ok bool) {
+	if preferences, ok = h.searchPreferences(c); !ok {
+		return nil, nil, false
+	}
+
+	filter = readFilter(c)
+	if !parseFacilityFilters(c, filter) {
+		return nil, nil, false
+	}
+	if preferences != nil {
+		if c.Query("radius") == "" {
+			lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+			lng, lngErr := strconv.ParseFloat(c.Query("lng"), 64)
+			if latErr == nil && lngErr == nil {
+				filter.Latitude, filter.Longitude, filter.MaxDistance = lat, lng, preferences.PreferredRadius
 			}
-			result["regional_centers"] = nearby
-
-		case "resources":
-			var resources []models.Resource
-			h.db.Find(&resources)
-			nearby := make([]models.Resource, 0)
-			for _, resource := range resources {
-				distance := calculateDistance(lat, lng, resource.Latitude, resource.Longitude)
-				if distance <= radius {
-					nearby = append(nearby, resource)
-				}
+		}
+		if c.Query("waitlist") == "" {
+			filter.WaitlistOnly = preferences.RequireWaitlist
+		}
+		if c.Query("insurance_required") == "" {
+			filter.InsuranceRequired = preferences.RequireInsurance
+		}
+		if c.Query("diagnosis") == "" && len(preferences.PreferredDiagnoses) > 0 {
+			names, err := h.svc(c).DiagnosisNames(preferences.PreferredDiagnoses)
+			if err != nil {
+				log.Printf("[PREFERENCES] Failed to resolve preferred diagnoses: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load preferences"})
+				return nil, nil, false
 			}
-			result["resources"] = nearby
+			filter.Diagnoses = names
 		}
 	}
 
-	c.JSON(http.StatusOK, result)
+	if filter.Sort, ok = parseSort(c, filter.HasLocation()); !ok {
+		return nil, nil, false
+	}
+	return filter, preferences, true
+}
+
+// searchPreferences returns the saved preferences that apply to a search,
+// or nil when none do
+func (h *Handler) searchPreferences(c *gin.Context) (*models.UserPreferences, bool) {
+	use := c.Query("use_preferences")
+	if use != "" && use != "true" && use != "false" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "use_preferences must be true or false"})
+		return nil, false
+	}
+	if use == "false" {
+		return nil, true
+	}
+
+	var (
+		preferences *models.UserPreferences
+		err         error
+	)
+	if user, ok := middleware.CurrentUser(c); ok {
+		preferences, err = h.svc(c).GetUserPreferences(user.ID)
+	} else if deviceID, ok := h.deviceID(c); ok && use == "true" {
+		preferences, err = h.svc(c).GetDevicePreferences(deviceID)
+	}
+	if err != nil {
+		log.Printf("[PREFERENCES] Failed to load search preferences: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load preferences"})
+		return nil, false
+	}
+	return preferences, true
+}
+
+// readFilter reads the filter query parameters without validating them
+func readFilter(c *gin.Context) *models.SearchFilter {
+	filter := &models.SearchFilter{
+		Search:            c.Query("search"),
+		City:              c.Query("city"),
+		County:            c.Query("county"),
+		Insurance:         c.Query("insurance"),
+		Area:              c.Query("area"),
+		ServiceType:       c.Query("service_type"),
+		WaitlistOnly:      c.Query("waitlist") == "true",
+		InsuranceRequired: c.Query("insurance_required") == "true",
+	}
+	if diagnosis := c.Query("diagnosis"); diagnosis != "" {
+		filter.Diagnoses = []string{diagnosis}
+	}
+	if lat, lng, radius, ok := parseRadiusQuery(c); ok {
+		filter.Latitude, filter.Longitude, filter.MaxDistance = lat, lng, radius
+	}
+	return filter
 }
 
-// User Preferences (keeping from original)
-
-// GetUserPreferences retrieves user preferences
-func (h *Handler) GetUserPreferences(c *gin.Context) {
-	userID := c.Param("userId")
-	log.Printf("[GET_USER_PREFERENCES] Request for user: %s", userID)
-
-	var preferences models.UserPreferences
-	if err := h.db.Where("user_id = ?", userID).First(&preferences).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// Return default preferences
-			defaultPrefs := models.UserPreferences{
-				UserID:              userID,
-				MapType:             "roadmap",
-				DefaultZoom:         10,
-				ShowFacilities:      true,
-				ShowABACenters:      true,
-				ShowResourceCenters: true,
-				ShowRegionalCenters: true,
-				ShowProviders:       true,
-				PreferredRadius:     25,
-				RequireWaitlist:     false,
-				RequireInsurance:    false,
+// parseFacilityFilters reads the insurance and waitlist query parameters
+// (carrier_id, plan_id, waitlist_status and max_wait_weeks), writing a 400
+// response and returning false when one is malformed
+func parseFacilityFilters(c *gin.Context, filter *models.SearchFilter) bool {
+	var ok bool
+	if filter.CarrierIDs, ok = parseIDList(c, "carrier_id"); !ok {
+		return false
+	}
+	if filter.PlanIDs, ok = parseIDList(c, "plan_id"); !ok {
+		return false
+	}
+
+	for _, value := range c.QueryArray("waitlist_status") {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.TrimSpace(status)
+			if !slices.Contains(models.WaitlistStatuses, status) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   fmt.Sprintf("Unknown waitlist_status %q", status),
+					"allowed": models.WaitlistStatuses,
+				})
+				return false
 			}
-			c.JSON(http.StatusOK, defaultPrefs)
-			return
+			filter.WaitlistStatuses = append(filter.WaitlistStatuses, status)
 		}
-		log.Printf("[GET_USER_PREFERENCES] Database error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
-		return
 	}
 
-	c.JSON(http.StatusOK, preferences)
+	if raw := c.Query("max_wait_weeks"); raw != "" {
+		weeks, err := strconv.Atoi(raw)
+		if err != nil || weeks < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_wait_weeks must be a non-negative integer"})
+			return false
+		}
+		filter.MaxWaitWeeks = &weeks
+	}
+	return true
 }
 
-// UpdateUserPreferences updates user preferences
-func (h *Handler) UpdateUserPreferences(c *gin.Context) {
-	userID := c.Param("userId")
-	log.Printf("[UPDATE_USER_PREFERENCES] Request for user: %s", userID)
-
-	var requestData models.UserPreferences
-	if err := c.ShouldBindJSON(&requestData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
-		return
+// parseIDList reads a query parameter holding integer ids, given repeatedly
+// or comma-separated, writing a 400 response and returning false when one
+// is malformed
+func parseIDList(c *gin.Context, name string) ([]int, bool) {
+	var ids []int
+	for _, value := range c.QueryArray(name) {
+		for _, part := range strings.Split(value, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || id < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a list of ids", name)})
+				return nil, false
+			}
+			ids = append(ids, id)
+		}
 	}
+	return ids, true
+}
 
-	// Ensure the userID matches
-	requestData.UserID = userID
+// parseRadiusQuery reads the lat, lng and radius query parameters, reporting
+// ok only when all three are present and valid
+func parseRadiusQuery(c *gin.Context) (lat, lng, radius float64, ok bool) {
+	latStr := c.Query("lat")
+	lngStr := c.Query("lng")
+	radiusStr := c.Query("radius")
+	if latStr == "" || lngStr == "" || radiusStr == "" {
+		return 0, 0, 0, false
+	}
 
-	// Upsert the preferences
-	if err := h.db.Where("user_id = ?", userID).Assign(requestData).FirstOrCreate(&requestData).Error; err != nil {
-		log.Printf("[UPDATE_USER_PREFERENCES] Database error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
-		return
+	lat, latErr := strconv.ParseFloat(latStr, 64)
+	lng, lngErr := strconv.ParseFloat(lngStr, 64)
+	radius, radiusErr := strconv.ParseFloat(radiusStr, 64)
+	if latErr != nil || lngErr != nil || radiusErr != nil {
+		return 0, 0, 0, false
 	}
+	return lat, lng, radius, true
+}
 
-	c.JSON(http.StatusOK, requestData)
+// parseSort validates the sort query parameter, writing a 400 response and
+// returning ok=false when it is unknown or needs a location that wasn't given
+func parseSort(c *gin.Context, hasLocation bool) (sortOrder string, ok bool) {
+	sortOrder = c.Query("sort")
+	switch sortOrder {
+	case "", models.SortName:
+		return sortOrder, true
+	case models.SortDistance:
+		if !hasLocation {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort=distance requires lat, lng and radius parameters"})
+			return "", false
+		}
+		return sortOrder, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, expected distance or name"})
+	return "", false
 }
 
-// Helper function to calculate distance between two coordinates using Haversine formula
-func calculateDistance(lat1, lng1, lat2, lng2 float64) float64 {
-	const R = 3959 // Earth's radius in miles
+// parsePage reads the limit and cursor query parameters, writing a 400
+// response and returning ok=false when limit is not a positive integer
+func parsePage(c *gin.Context) (page services.PageRequest, ok bool) {
+	page.Cursor = c.Query("cursor")
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return page, false
+		}
+		page.Limit = limit
+	}
+	return page, true
+}
 
-	lat1Rad := lat1 * math.Pi / 180
-	lat2Rad := lat2 * math.Pi / 180
-	deltaLatRad := (lat2 - lat1) * math.Pi / 180
-	deltaLngRad := (lng2 - lng1) * math.Pi / 180
+// respondPage writes a page envelope with Link headers for the first and
+// next pages
+func respondPage[T any](c *gin.Context, page *services.Page[T]) {
+	links := []string{fmt.Sprintf("<%s>; rel=\"first\"", pageURL(c, ""))}
+	if page.NextCursor != nil {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", pageURL(c, *page.NextCursor)))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+	c.JSON(http.StatusOK, page)
+}
 
-	a := math.Sin(deltaLatRad/2)*math.Sin(deltaLatRad/2) +
-		math.Cos(lat1Rad)*math.Cos(lat2Rad)*
-			math.Sin(deltaLngRad/2)*math.Sin(deltaLngRad/2)
+// respondPageError maps pagination errors to 400 and anything else to 500
+func respondPageError(c *gin.Context, tag, message string, err error) {
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	log.Printf("[%s] Database error: %v", tag, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
 
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+// respondWriteError maps write errors to 400 for invalid fields, 404 for
+// missing records, 409 for stale updates and 500 for anything else
+func respondWriteError(c *gin.Context, tag, entity, message string, err error) {
+	var verr *services.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": verr.Fields})
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": entity + " not found"})
+	case errors.Is(err, services.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": entity + " was modified since it was read, reload and try again"})
+	default:
+		log.Printf("[%s] Database error: %v", tag, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
 
-	return R * c
+// pageURL returns the request URL with its cursor replaced
+func pageURL(c *gin.Context, cursor string) string {
+	u := *c.Request.URL
+	query := u.Query()
+	query.Del("cursor")
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
 }
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/alexbeattie/medicalfacilities/middleware"
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testRouter wires the ABA center, provider and search routes to a handler
+// over service, with the request id and authentication middleware of main.go
+func testRouter(service Service, user *models.User) *gin.Engine {
+	handler := NewHandler(service)
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	api := r.Group("/api/v1", middleware.Authenticate(fakeAuthenticator{user: user}))
+	api.GET("/aba-centers", handler.GetABACenters)
+	api.GET("/aba-centers/:id", handler.GetABACenter)
+	api.POST("/aba-centers", handler.CreateABACenter)
+	api.PUT("/aba-centers/:id", handler.UpdateABACenter)
+	api.GET("/providers", handler.GetProviders)
+	api.GET("/search", handler.Search)
+	return r
+}
+
+func serve(r *gin.Engine, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetABACenters(t *testing.T) {
+	next := "next-page"
+	service := &fakeService{
+		centers: []models.ABACenter{{ID: uuid.New(), Name: "First"}, {ID: uuid.New(), Name: "Second"}},
+		next:    &next,
+	}
+
+	target := "/api/v1/aba-centers?city=Irvine&diagnosis=Autism&lat=33.68&lng=-117.79&radius=10&sort=distance&limit=2"
+	w := serve(testRouter(service, nil), http.MethodGet, target, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var page services.Page[models.ABACenter]
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].Name != "First" {
+		t.Errorf("items = %+v, want the two centers", page.Items)
+	}
+	if page.NextCursor == nil || *page.NextCursor != next {
+		t.Errorf("next_cursor = %v, want %q", page.NextCursor, next)
+	}
+	if link := w.Header().Get("Link"); !strings.Contains(link, "cursor="+next) || !strings.Contains(link, `rel="next"`) {
+		t.Errorf("Link = %q, want a next link with the cursor", link)
+	}
+
+	filter := service.filter
+	if filter.City != "Irvine" || len(filter.Diagnoses) != 1 || filter.Diagnoses[0] != "Autism" {
+		t.Errorf("filter = %+v, want city Irvine and diagnosis Autism", filter)
+	}
+	if filter.Latitude != 33.68 || filter.Longitude != -117.79 || filter.MaxDistance != 10 || filter.Sort != models.SortDistance {
+		t.Errorf("filter = %+v, want the radius search sorted by distance", filter)
+	}
+	if service.page.Limit != 2 {
+		t.Errorf("page.Limit = %d, want 2", service.page.Limit)
+	}
+}
+
+func TestGetABACentersErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		err    error
+		status int
+	}{
+		{"invalid limit", "/api/v1/aba-centers?limit=-1", nil, http.StatusBadRequest},
+		{"unknown sort", "/api/v1/aba-centers?sort=rating", nil, http.StatusBadRequest},
+		{"distance sort without location", "/api/v1/aba-centers?sort=distance", nil, http.StatusBadRequest},
+		{"invalid cursor", "/api/v1/aba-centers?cursor=x", fmt.Errorf("bad page: %w", services.ErrInvalidCursor), http.StatusBadRequest},
+		{"database error", "/api/v1/aba-centers", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(testRouter(&fakeService{err: tt.err}, nil), http.MethodGet, tt.target, "", nil)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestGetABACenter(t *testing.T) {
+	center := models.ABACenter{ID: uuid.New(), Name: "Center"}
+	tests := []struct {
+		name   string
+		id     string
+		err    error
+		status int
+	}{
+		{"found", center.ID.String(), nil, http.StatusOK},
+		{"missing", uuid.NewString(), nil, http.StatusNotFound},
+		{"malformed id", "nope", nil, http.StatusNotFound},
+		{"database error", center.ID.String(), errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{centers: []models.ABACenter{center}, err: tt.err}
+			w := serve(testRouter(service, nil), http.MethodGet, "/api/v1/aba-centers/"+tt.id, "", nil)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestCreateABACenter(t *testing.T) {
+	service := &fakeService{}
+	w := serve(testRouter(service, nil), http.MethodPost, "/api/v1/aba-centers", `{"name": "New Center"}`, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	if service.created == nil || service.created.Name != "New Center" {
+		t.Errorf("created = %+v, want the posted center", service.created)
+	}
+
+	if w := serve(testRouter(&fakeService{}, nil), http.MethodPost, "/api/v1/aba-centers", `{`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("status for malformed JSON = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCreateABACenterAuditActor(t *testing.T) {
+	user := &models.User{ID: 7}
+	tests := []struct {
+		name   string
+		token  string
+		userID *int
+	}{
+		{"signed in", "valid", &user.ID},
+		{"expired token continues anonymously", "expired", nil},
+		{"anonymous", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{}
+			header := http.Header{middleware.RequestIDHeader: {"req-1"}}
+			if tt.token != "" {
+				header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			w := serve(testRouter(service, user), http.MethodPost, "/api/v1/aba-centers", `{"name": "New Center"}`, header)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+			}
+			if service.created == nil || service.created.Name != "New Center" {
+				t.Fatalf("created = %+v, want the posted center", service.created)
+			}
+
+			actor, ok := services.AuditActorFrom(service.ctx)
+			if !ok {
+				t.Fatal("service context carries no audit actor")
+			}
+			if actor.RequestID != "req-1" {
+				t.Errorf("actor.RequestID = %q, want %q", actor.RequestID, "req-1")
+			}
+			if (actor.UserID == nil) != (tt.userID == nil) || (actor.UserID != nil && *actor.UserID != *tt.userID) {
+				t.Errorf("actor.UserID = %v, want %v", actor.UserID, tt.userID)
+			}
+		})
+	}
+}
+
+func TestUpdateABACenterErrorMapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"saved", nil, http.StatusOK},
+		{"validation", &services.ValidationError{Fields: map[string]string{"name": "is required"}}, http.StatusBadRequest},
+		{"not found", fmt.Errorf("ABA center %w", services.ErrNotFound), http.StatusNotFound},
+		{"conflict", fmt.Errorf("ABA center %w", services.ErrConflict), http.StatusConflict},
+		{"database error", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/api/v1/aba-centers/" + uuid.NewString()
+			w := serve(testRouter(&fakeService{err: tt.err}, nil), http.MethodPut, target, `{"name": "Center"}`, nil)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+
+	t.Run("malformed id", func(t *testing.T) {
+		w := serve(testRouter(&fakeService{}, nil), http.MethodPut, "/api/v1/aba-centers/nope", `{}`, nil)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestParseIDList(t *testing.T) {
+	tests := []struct {
+		query    string
+		carriers []int
+		plans    []int
+		status   int
+	}{
+		{"carrier_id=1,2&carrier_id=3&plan_id=4", []int{1, 2, 3}, []int{4}, http.StatusOK},
+		{"plan_id=+5+", nil, []int{5}, http.StatusOK},
+		{"carrier_id=kaiser", nil, nil, http.StatusBadRequest},
+		{"plan_id=0", nil, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		service := &fakeService{}
+		w := serve(testRouter(service, nil), http.MethodGet, "/api/v1/aba-centers?"+tt.query, "", nil)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.query, w.Code, tt.status)
+			continue
+		}
+		if tt.status == http.StatusOK && (!reflect.DeepEqual(service.filter.CarrierIDs, tt.carriers) || !reflect.DeepEqual(service.filter.PlanIDs, tt.plans)) {
+			t.Errorf("%s: carriers %v, plans %v, want %v and %v", tt.query, service.filter.CarrierIDs, service.filter.PlanIDs, tt.carriers, tt.plans)
+		}
+	}
+}
+
+func TestParseWaitlistFilters(t *testing.T) {
+	service := &fakeService{}
+	r := testRouter(service, nil)
+
+	w := serve(r, http.MethodGet, "/api/v1/aba-centers?waitlist_status=open,short&waitlist_status=long&max_wait_weeks=8", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	filter := service.filter
+	if !reflect.DeepEqual(filter.WaitlistStatuses, []string{"open", "short", "long"}) || filter.MaxWaitWeeks == nil || *filter.MaxWaitWeeks != 8 {
+		t.Errorf("filter = %+v, want the three statuses and at most 8 weeks", filter)
+	}
+
+	for _, query := range []string{"waitlist_status=soon", "max_wait_weeks=-1", "max_wait_weeks=two"} {
+		if w := serve(r, http.MethodGet, "/api/v1/aba-centers?"+query, "", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestListsHiddenByPreferences(t *testing.T) {
+	user := &models.User{ID: 7}
+	signedIn := http.Header{"Authorization": {"Bearer valid"}}
+	hideProviders := services.DefaultUserPreferences()
+	hideProviders.ShowProviders = false
+	hideFacilities := services.DefaultUserPreferences()
+	hideFacilities.ShowFacilities = false
+
+	tests := []struct {
+		name        string
+		target      string
+		header      http.Header
+		preferences *models.UserPreferences
+		listed      bool
+	}{
+		{"shown", "/api/v1/providers", signedIn, nil, true},
+		{"hidden type", "/api/v1/providers", signedIn, &hideProviders, false},
+		{"every facility hidden", "/api/v1/aba-centers", signedIn, &hideFacilities, false},
+		{"other type hidden", "/api/v1/aba-centers", signedIn, &hideProviders, true},
+		{"preferences turned off", "/api/v1/providers?use_preferences=false", signedIn, &hideProviders, true},
+		{"anonymous", "/api/v1/providers", nil, &hideProviders, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{preferences: tt.preferences}
+			w := serve(testRouter(service, user), http.MethodGet, tt.target, "", tt.header)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			if service.listed != tt.listed {
+				t.Errorf("listed = %t, want %t", service.listed, tt.listed)
+			}
+
+			var page struct {
+				Items []json.RawMessage `json:"items"`
+				Total int64             `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if !tt.listed && (page.Items == nil || len(page.Items) != 0 || page.Total != 0) {
+				t.Errorf("body = %s, want an empty page", w.Body)
+			}
+		})
+	}
+}
+
+func TestSearchHiddenByPreferences(t *testing.T) {
+	user := &models.User{ID: 7}
+	signedIn := http.Header{"Authorization": {"Bearer valid"}}
+	hideCenters := services.DefaultUserPreferences()
+	hideCenters.ShowABACenters, hideCenters.ShowRegionalCenters = false, false
+	hideFacilities := services.DefaultUserPreferences()
+	hideFacilities.ShowFacilities = false
+
+	tests := []struct {
+		name        string
+		target      string
+		header      http.Header
+		preferences *models.UserPreferences
+		searched    []string
+	}{
+		{"nothing hidden", "/api/v1/search?q=aba", signedIn, nil, services.SearchEntityTypes},
+		{"hidden types", "/api/v1/search?q=aba", signedIn, &hideCenters, []string{"resource_centers", "resources", "providers"}},
+		{"every facility hidden", "/api/v1/search?q=aba", signedIn, &hideFacilities, []string{"resources"}},
+		{"types given", "/api/v1/search?q=aba&types=aba_centers", signedIn, &hideCenters, []string{"aba_centers"}},
+		{"anonymous", "/api/v1/search?q=aba", nil, &hideCenters, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{preferences: tt.preferences}
+			w := serve(testRouter(service, user), http.MethodGet, tt.target, "", tt.header)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			if !slices.Equal(service.searched, tt.searched) {
+				t.Errorf("searched types = %q, want %q", service.searched, tt.searched)
+			}
+		})
+	}
+}
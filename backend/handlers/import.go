@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize bounds the size of an uploaded import file
+const maxImportSize = 10 << 20
+
+// Import Handlers
+
+// ImportFile returns a handler importing a CSV or XLSX file of entityType,
+// sent as the multipart field "file" or as the request body. With
+// dry_run=true nothing is written. It answers with the per-row report: 200
+// when every row is valid, 422 when some failed and nothing was written.
+func (h *Handler) ImportFile(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+		name, data, err := readImportUpload(c)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File must be at most 10 MB"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file: " + err.Error()})
+			return
+		}
+		if len(data) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No file was sent"})
+			return
+		}
+
+		dryRun := c.Query("dry_run") == "true"
+		log.Printf("[IMPORT_FILE] Importing %s from %q (%d bytes, dry run: %t)", entityType, name, len(data), dryRun)
+
+		report, err := h.svc(c).ImportFile(entityType, name, data, dryRun)
+		if err != nil {
+			respondWriteError(c, "IMPORT_FILE", "Import", "Failed to import file", err)
+			return
+		}
+
+		log.Printf("[IMPORT_FILE] %s: %d created, %d updated, %d unchanged, %d failed, committed: %t",
+			entityType, report.Created, report.Updated, report.Unchanged, report.Failed, report.Committed)
+		if report.Failed > 0 {
+			c.JSON(http.StatusUnprocessableEntity, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// readImportUpload reads the uploaded file from the multipart field "file",
+// or else the request body with the name given in the filename query
+// parameter
+func readImportUpload(c *gin.Context) (name string, data []byte, err error) {
+	if c.ContentType() == "multipart/form-data" {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return "", nil, err
+		}
+		file, err := header.Open()
+		if err != nil {
+			return "", nil, err
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+		return header.Filename, data, err
+	}
+
+	data, err = io.ReadAll(c.Request.Body)
+	return c.Query("filename"), data, err
+}
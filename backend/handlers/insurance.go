@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// GetInsuranceCarriers lists insurance carriers and their plans, for use as
+// carrier_id and plan_id filters
+func (h *Handler) GetInsuranceCarriers(c *gin.Context) {
+	log.Printf("[GET_INSURANCE_CARRIERS] Request received")
+
+	filter, ok := parseFilter(c)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.svc(c).GetInsuranceCarriers(filter, page)
+	if err != nil {
+		respondPageError(c, "GET_INSURANCE_CARRIERS", "Failed to fetch insurance carriers", err)
+		return
+	}
+
+	log.Printf("[GET_INSURANCE_CARRIERS] Returning %d of %d carriers", len(result.Items), result.Total)
+	respondPage(c, result)
+}
+
+// CreateInsuranceCarrier creates a new insurance carrier
+func (h *Handler) CreateInsuranceCarrier(c *gin.Context) {
+	log.Printf("[CREATE_INSURANCE_CARRIER] Request received")
+
+	var carrier models.InsuranceCarrier
+	if !bindJSON(c, &carrier) {
+		return
+	}
+
+	if err := h.svc(c).CreateInsuranceCarrier(&carrier); err != nil {
+		respondWriteError(c, "CREATE_INSURANCE_CARRIER", "Insurance carrier", "Failed to create insurance carrier", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, carrier)
+}
+
+// UpdateInsuranceCarrier renames an insurance carrier
+func (h *Handler) UpdateInsuranceCarrier(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Insurance carrier")
+	if !ok {
+		return
+	}
+	log.Printf("[UPDATE_INSURANCE_CARRIER] Request for carrier ID: %d", id)
+
+	var carrier models.InsuranceCarrier
+	if !bindJSON(c, &carrier) {
+		return
+	}
+
+	if err := h.svc(c).UpdateInsuranceCarrier(id, &carrier); err != nil {
+		respondWriteError(c, "UPDATE_INSURANCE_CARRIER", "Insurance carrier", "Failed to update insurance carrier", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, carrier)
+}
+
+// DeleteInsuranceCarrier deletes an insurance carrier and its plans
+func (h *Handler) DeleteInsuranceCarrier(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Insurance carrier")
+	if !ok {
+		return
+	}
+	log.Printf("[DELETE_INSURANCE_CARRIER] Request for carrier ID: %d", id)
+
+	if err := h.svc(c).DeleteInsuranceCarrier(id); err != nil {
+		respondWriteError(c, "DELETE_INSURANCE_CARRIER", "Insurance carrier", "Failed to delete insurance carrier", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateInsurancePlan adds a plan to an insurance carrier
+func (h *Handler) CreateInsurancePlan(c *gin.Context) {
+	carrierID, ok := parseIntParam(c, "id", "Insurance carrier")
+	if !ok {
+		return
+	}
+	log.Printf("[CREATE_INSURANCE_PLAN] Request for carrier ID: %d", carrierID)
+
+	var plan models.InsurancePlan
+	if !bindJSON(c, &plan) {
+		return
+	}
+
+	if err := h.svc(c).CreateInsurancePlan(carrierID, &plan); err != nil {
+		respondWriteError(c, "CREATE_INSURANCE_PLAN", "Insurance carrier", "Failed to create insurance plan", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// UpdateInsurancePlan renames an insurance plan
+func (h *Handler) UpdateInsurancePlan(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Insurance plan")
+	if !ok {
+		return
+	}
+	log.Printf("[UPDATE_INSURANCE_PLAN] Request for plan ID: %d", id)
+
+	var plan models.InsurancePlan
+	if !bindJSON(c, &plan) {
+		return
+	}
+
+	if err := h.svc(c).UpdateInsurancePlan(id, &plan); err != nil {
+		respondWriteError(c, "UPDATE_INSURANCE_PLAN", "Insurance plan", "Failed to update insurance plan", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// DeleteInsurancePlan deletes an insurance plan
+func (h *Handler) DeleteInsurancePlan(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Insurance plan")
+	if !ok {
+		return
+	}
+	log.Printf("[DELETE_INSURANCE_PLAN] Request for plan ID: %d", id)
+
+	if err := h.svc(c).DeleteInsurancePlan(id); err != nil {
+		respondWriteError(c, "DELETE_INSURANCE_PLAN", "Insurance plan", "Failed to delete insurance plan", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetABACenterInsurance replaces the insurance an ABA center accepts with
+// {"carrier_ids": [...], "plan_ids": [...]}
+func (h *Handler) SetABACenterInsurance(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "ABA center")
+	if !ok {
+		return
+	}
+	log.Printf("[SET_ABA_CENTER_INSURANCE] Request for center ID: %s", id)
+
+	var request struct {
+		CarrierIDs []int `json:"carrier_ids"`
+		PlanIDs    []int `json:"plan_ids"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	center, err := h.svc(c).SetABACenterInsurance(id, request.CarrierIDs, request.PlanIDs)
+	if err != nil {
+		respondWriteError(c, "SET_ABA_CENTER_INSURANCE", "ABA center", "Failed to update ABA center insurance", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, center)
+}
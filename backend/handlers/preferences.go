@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/middleware"
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+// DeviceCookie holds the signed ID of an anonymous device, so preferences
+// can be saved before signing in
+const DeviceCookie = "device_id"
+
+// deviceCookieMaxAge is one year, in seconds
+const deviceCookieMaxAge = 365 * 24 * 60 * 60
+
+// User Preferences
+
+// GetMyPreferences returns the preferences of the signed-in user, or of the
+// anonymous device when no one is signed in
+func (h *Handler) GetMyPreferences(c *gin.Context) {
+	var (
+		preferences *models.UserPreferences
+		err         error
+	)
+	if user, ok := middleware.CurrentUser(c); ok {
+		preferences, err = h.svc(c).GetUserPreferences(user.ID)
+	} else if deviceID, ok := h.deviceID(c); ok {
+		preferences, err = h.svc(c).GetDevicePreferences(deviceID)
+	} else {
+		defaults := services.DefaultUserPreferences()
+		preferences = &defaults
+	}
+	if err != nil {
+		log.Printf("[GET_USER_PREFERENCES] Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// UpdateMyPreferences replaces the preferences of the signed-in user, or of
+// the anonymous device when no one is signed in. Omitted fields take their
+// default values.
+func (h *Handler) UpdateMyPreferences(c *gin.Context) {
+	requestData := services.DefaultUserPreferences()
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+
+	var err error
+	if user, ok := middleware.CurrentUser(c); ok {
+		log.Printf("[UPDATE_USER_PREFERENCES] Request for user: %d", user.ID)
+		err = h.svc(c).UpdateUserPreferences(user.ID, &requestData)
+	} else {
+		deviceID := h.issueDeviceID(c)
+		log.Printf("[UPDATE_USER_PREFERENCES] Request for device: %s", deviceID)
+		err = h.svc(c).UpdateDevicePreferences(deviceID, &requestData)
+	}
+	if err != nil {
+		respondWriteError(c, "UPDATE_USER_PREFERENCES", "Preferences", "Failed to update preferences", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, requestData)
+}
+
+// PatchMyPreferences applies a JSON merge patch to the preferences of the
+// signed-in user, or of the anonymous device. Fields set to null return to
+// their defaults.
+func (h *Handler) PatchMyPreferences(c *gin.Context) {
+	var patch map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+
+	var (
+		preferences *models.UserPreferences
+		err         error
+	)
+	if user, ok := middleware.CurrentUser(c); ok {
+		log.Printf("[PATCH_USER_PREFERENCES] Request for user: %d", user.ID)
+		preferences, err = h.svc(c).PatchUserPreferences(user.ID, patch)
+	} else {
+		deviceID := h.issueDeviceID(c)
+		log.Printf("[PATCH_USER_PREFERENCES] Request for device: %s", deviceID)
+		preferences, err = h.svc(c).PatchDevicePreferences(deviceID, patch)
+	}
+	if err != nil {
+		respondWriteError(c, "PATCH_USER_PREFERENCES", "Preferences", "Failed to update preferences", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// mergeDevicePreferences hands the anonymous device's preferences to a user
+// who just signed in, and forgets the device. Failures are logged but don't
+// fail the sign-in.
+func (h *Handler) mergeDevicePreferences(c *gin.Context, userID int) {
+	deviceID, ok := h.deviceID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc(c).MergeDevicePreferences(deviceID, userID); err != nil {
+		log.Printf("[LOGIN] Failed to merge device preferences for user %d: %v", userID, err)
+		return
+	}
+	setDeviceCookie(c, "", -1)
+}
+
+// deviceID returns the device ID from a validly signed device cookie
+func (h *Handler) deviceID(c *gin.Context) (string, bool) {
+	token, err := c.Cookie(DeviceCookie)
+	if err != nil || token == "" {
+		return "", false
+	}
+	deviceID, err := h.svc(c).DeviceIDFromToken(token)
+	if err != nil {
+		log.Printf("[PREFERENCES] Ignoring device cookie with a bad signature from %s", c.ClientIP())
+		return "", false
+	}
+	return deviceID, true
+}
+
+// issueDeviceID returns the device ID from the device cookie, issuing a new
+// cookie when there is no valid one
+func (h *Handler) issueDeviceID(c *gin.Context) string {
+	if deviceID, ok := h.deviceID(c); ok {
+		return deviceID
+	}
+	deviceID, token := h.svc(c).NewDeviceToken()
+	setDeviceCookie(c, token, deviceCookieMaxAge)
+	return deviceID
+}
+
+// setDeviceCookie sets the device cookie, or clears it when maxAge < 0
+func setDeviceCookie(c *gin.Context, token string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(DeviceCookie, token, maxAge, "/", "", secure, true)
+}
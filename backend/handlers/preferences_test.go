@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+// preferencesRouter wires /preferences/me to a handler over service
+func preferencesRouter(service Service) *gin.Engine {
+	handler := NewHandler(service)
+	r := gin.New()
+	r.GET("/preferences/me", handler.GetMyPreferences)
+	r.PUT("/preferences/me", handler.UpdateMyPreferences)
+	r.PATCH("/preferences/me", handler.PatchMyPreferences)
+	return r
+}
+
+func decodePreferences(t *testing.T, body []byte) models.UserPreferences {
+	t.Helper()
+	var preferences models.UserPreferences
+	if err := json.Unmarshal(body, &preferences); err != nil {
+		t.Fatalf("decoding preferences: %v", err)
+	}
+	return preferences
+}
+
+func TestMyPreferencesForDevices(t *testing.T) {
+	service := &fakeService{}
+	r := preferencesRouter(service)
+
+	// Saving without a cookie issues one for a new device
+	w := serve(r, http.MethodPut, "/preferences/me", `{"map_type":"satellite","default_zoom":12}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DeviceCookie || cookies[0].Value != "new-device.signed" || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %+v, want an HttpOnly device cookie", cookies)
+	}
+	if saved := service.devices["new-device"]; saved == nil || saved.MapType != "satellite" {
+		t.Fatalf("device preferences = %+v, want the saved map type", saved)
+	}
+
+	// The cookie then reads them back, and saving again reuses it
+	header := http.Header{"Cookie": {DeviceCookie + "=new-device.signed"}}
+	w = serve(r, http.MethodGet, "/preferences/me", "", header)
+	if got := decodePreferences(t, w.Body.Bytes()); w.Code != http.StatusOK || got.MapType != "satellite" || got.DefaultZoom != 12 {
+		t.Errorf("GET = %d %+v, want the device's preferences", w.Code, got)
+	}
+	w = serve(r, http.MethodPut, "/preferences/me", `{"map_type":"terrain"}`, header)
+	if w.Code != http.StatusOK || len(w.Result().Cookies()) != 0 {
+		t.Errorf("PUT with a cookie = %d, cookies %+v, want no new cookie", w.Code, w.Result().Cookies())
+	}
+
+	// A forged cookie is ignored
+	w = serve(r, http.MethodGet, "/preferences/me", "", http.Header{"Cookie": {DeviceCookie + "=new-device.forged"}})
+	if got := decodePreferences(t, w.Body.Bytes()); w.Code != http.StatusOK || got.MapType != "roadmap" {
+		t.Errorf("GET with a forged cookie = %d %+v, want the defaults", w.Code, got)
+	}
+}
+
+func TestPatchMyPreferences(t *testing.T) {
+	service := &fakeService{}
+	r := preferencesRouter(service)
+
+	w := serve(r, http.MethodPatch, "/preferences/me", `{"map_type":null,"default_zoom":12}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Value != "new-device.signed" {
+		t.Errorf("cookies = %+v, want a new device cookie", cookies)
+	}
+	if len(service.patch) != 2 || string(service.patch["map_type"]) != "null" || string(service.patch["default_zoom"]) != "12" {
+		t.Errorf("patch = %s, want the fields as sent", service.patch)
+	}
+
+	if w := serve(r, http.MethodPatch, "/preferences/me", `["map_type"]`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("array patch status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	service.err = &services.ValidationError{Fields: map[string]string{"default_zoom": "must be between 1 and 21"}}
+	w = serve(r, http.MethodPatch, "/preferences/me", `{"default_zoom":40}`, nil)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "default_zoom") {
+		t.Errorf("invalid patch = %d %s, want %d naming the field", w.Code, w.Body, http.StatusBadRequest)
+	}
+}
+
+func TestPreferencesFillSearchFilters(t *testing.T) {
+	saved := services.DefaultUserPreferences()
+	saved.PreferredRadius = 15
+	saved.RequireWaitlist = true
+	saved.PreferredDiagnoses = []string{"Autism"} // the fake resolves IDs to themselves
+	service := &fakeService{devices: map[string]*models.UserPreferences{"device": &saved}}
+	r := testRouter(service, nil)
+	cookie := http.Header{"Cookie": {DeviceCookie + "=device.signed"}}
+
+	// Anonymous devices opt in to their preferences
+	w := serve(r, http.MethodGet, "/api/v1/aba-centers?lat=33.68&lng=-117.79", "", cookie)
+	if w.Code != http.StatusOK || service.filter.HasLocation() || service.filter.WaitlistOnly || len(service.filter.Diagnoses) != 0 {
+		t.Errorf("without use_preferences: %d, filter %+v, want no preferences applied", w.Code, service.filter)
+	}
+
+	w = serve(r, http.MethodGet, "/api/v1/aba-centers?lat=33.68&lng=-117.79&use_preferences=true", "", cookie)
+	filter := service.filter
+	if w.Code != http.StatusOK || filter.MaxDistance != 15 || !filter.WaitlistOnly || len(filter.Diagnoses) != 1 || filter.Diagnoses[0] != "Autism" {
+		t.Errorf("with use_preferences: %d, filter %+v, want the saved radius, waitlist and diagnosis", w.Code, filter)
+	}
+
+	// The query wins over saved preferences
+	w = serve(r, http.MethodGet, "/api/v1/aba-centers?lat=33.68&lng=-117.79&radius=5&waitlist=false&diagnosis=ADHD&use_preferences=true", "", cookie)
+	filter = service.filter
+	if w.Code != http.StatusOK || filter.MaxDistance != 5 || filter.WaitlistOnly || filter.Diagnoses[0] != "ADHD" {
+		t.Errorf("with query filters: %d, filter %+v, want the query's values", w.Code, filter)
+	}
+
+	if w := serve(r, http.MethodGet, "/api/v1/aba-centers?use_preferences=maybe", "", cookie); w.Code != http.StatusBadRequest {
+		t.Errorf("use_preferences=maybe status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+func searchRouter(service Service) *gin.Engine {
+	handler := NewHandler(service)
+	r := gin.New()
+	r.GET("/api/v1/search", handler.Search)
+	return r
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		query  string
+		status int
+		types  []string
+		limit  int
+	}{
+		{"q=autism", http.StatusOK, nil, services.DefaultSearchLimit},
+		{"q=autism&types=aba_centers,providers&limit=5", http.StatusOK, []string{"aba_centers", "providers"}, 5},
+		{"q=autism&types=aba_centers&types=resources", http.StatusOK, []string{"aba_centers", "resources"}, services.DefaultSearchLimit},
+		{"q=+", http.StatusBadRequest, nil, 0},
+		{"q=" + strings.Repeat("a", maxSearchQueryLength+1), http.StatusBadRequest, nil, 0},
+		{"q=autism&types=hospitals", http.StatusBadRequest, nil, 0},
+		{"q=autism&limit=0", http.StatusBadRequest, nil, 0},
+	}
+
+	for _, tt := range tests {
+		service := &fakeService{}
+		w := serve(searchRouter(service), http.MethodGet, "/api/v1/search?"+tt.query, "", nil)
+		if w.Code != tt.status {
+			t.Errorf("%.40s: status = %d, want %d: %s", tt.query, w.Code, tt.status, w.Body)
+			continue
+		}
+		if !reflect.DeepEqual(service.searched, tt.types) || service.searchLimit != tt.limit {
+			t.Errorf("%.40s: searched %v with limit %d, want %v with %d", tt.query, service.searched, service.searchLimit, tt.types, tt.limit)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/google/uuid"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+// Service is everything the handlers need from the services layer. It is
+// implemented by FromServices and can be faked in handler tests.
+type Service interface {
+	// WithContext returns the service scoped to a request's context, which
+	// carries the audit actor of its writes
+	WithContext(ctx context.Context) Service
+
+	// ABA centers
+	GetABACenters(filter *models.SearchFilter, page services.PageRequest) (*services.Page[models.ABACenter], error)
+	GetABACenterByID(id uuid.UUID) (*models.ABACenter, error)
+	CreateABACenter(center *models.ABACenter) error
+	UpdateABACenter(id uuid.UUID, center *models.ABACenter) error
+	PatchABACenter(id uuid.UUID, patch map[string]json.RawMessage) (*models.ABACenter, error)
+	DeleteABACenter(id uuid.UUID) error
+	GetWaitlistHistory(centerID uuid.UUID, page services.PageRequest) (*services.Page[models.WaitlistChange], error)
+
+	// Resource centers
+	GetResourceCenters(filter *models.SearchFilter, page services.PageRequest) (*services.Page[models.ResourceCenter], error)
+	GetResourceCenterByID(id uuid.UUID) (*models.ResourceCenter, error)
+	CreateResourceCenter(center *models.ResourceCenter) error
+	UpdateResourceCenter(id uuid.UUID, center *models.ResourceCenter) error
+	DeleteResourceCenter(id uuid.UUID) error
+	SetResourceCenterDiagnoses(centerID uuid.UUID, diagnosisIDs []uuid.UUID) (*models.ResourceCenter, error)
+	AddResourceCenterDiagnosis(centerID, diagnosisID uuid.UUID) error
+	RemoveResourceCenterDiagnosis(centerID, diagnosisID uuid.UUID) error
+
+	// Resources
+	GetResources(filter *models.SearchFilter, page services.PageRequest) (*services.Page[models.Resource], error)
+	GetResourceByID(id uuid.UUID) (*models.Resource, error)
+	CreateResource(resource *models.Resource) error
+	UpdateResource(id uuid.UUID, resource *models.Resource) error
+	DeleteResource(id uuid.UUID) error
+
+	// Regional centers
+	GetRegionalCenters(filter *models.SearchFilter, page services.PageRequest) (*services.Page[models.RegionalCenter], error)
+	CreateRegionalCenter(center *models.RegionalCenter) error
+	UpdateRegionalCenter(id int, center *models.RegionalCenter) error
+	DeleteRegionalCenter(id int) error
+
+	// Providers
+	GetProviders(filter *models.SearchFilter, page services.PageRequest) (*services.Page[models.Provider], error)
+	CreateProvider(provider *models.Provider) error
+	UpdateProvider(id int, provider *models.Provider) error
+	DeleteProvider(id int) error
+
+	// Diagnoses
+	GetDiagnoses(filter *models.SearchFilter, page services.PageRequest) (*services.Page[models.Diagnosis], error)
+	CreateDiagnosis(diagnosis *models.Diagnosis) error
+	UpdateDiagnosis(id uuid.UUID, diagnosis *models.Diagnosis) error
+	DeleteDiagnosis(id uuid.UUID) error
+
+	// Insurance
+	GetInsuranceCarriers(filter *models.SearchFilter, page services.PageRequest) (*services.Page[models.InsuranceCarrier], error)
+	CreateInsuranceCarrier(carrier *models.InsuranceCarrier) error
+	UpdateInsuranceCarrier(id int, carrier *models.InsuranceCarrier) error
+	DeleteInsuranceCarrier(id int) error
+	CreateInsurancePlan(carrierID int, plan *models.InsurancePlan) error
+	UpdateInsurancePlan(id int, plan *models.InsurancePlan) error
+	DeleteInsurancePlan(id int) error
+	SetABACenterInsurance(centerID uuid.UUID, carrierIDs, planIDs []int) (*models.ABACenter, error)
+
+	// Form submissions
+	NewFormToken() string
+	CreateFormSubmission(submission *models.FormSubmission, checks services.SubmissionChecks) error
+	GetFormSubmissions(filter services.SubmissionFilter, page services.PageRequest) (*services.Page[models.FormSubmission], error)
+	GetFormSubmissionByID(id int) (*models.FormSubmission, error)
+	PatchFormSubmission(id int, patch map[string]json.RawMessage) (*models.FormSubmission, error)
+	AddSubmissionNote(submissionID, authorID int, body string) (*models.SubmissionNote, error)
+	ExportFormSubmissions(filter services.SubmissionFilter, w io.Writer) error
+
+	// Data freshness
+	VerifyRecord(entityType, id string, userID int) (*services.FreshnessRecord, error)
+	StaleRecords(days int, entityTypes []string) (*services.StaleReport, error)
+
+	// Import
+	ImportFile(entityType, name string, data []byte, dryRun bool) (*services.ImportReport, error)
+
+	// Audit log
+	GetAuditLogs(filter services.AuditFilter, page services.PageRequest) (*services.Page[models.AuditLog], error)
+
+	// Search
+	Search(q string, entityTypes []string, limit int) ([]services.SearchResult, error)
+	SearchNearby(filter *models.SearchFilter, entityTypes []string) (map[string]interface{}, error)
+
+	// Export
+	ExportFacilities(filter *models.SearchFilter, entityTypes []string, format string, w io.Writer) error
+
+	// Authentication
+	Login(email, password, userAgent, ipAddress string) (*services.AuthTokens, error)
+	Refresh(refreshToken string) (*services.AuthTokens, error)
+	Logout(accessToken string) error
+	RevokeRefreshToken(refreshToken string) error
+
+	SetPasswordWithToken(token, password string) error
+
+	// Users, roles and permissions
+	GetUsers(filter *models.SearchFilter, page services.PageRequest) (*services.Page[models.User], error)
+	GetUserByID(id int) (*models.User, error)
+	InviteUser(ctx context.Context, user *models.User, roleIDs []int) error
+	SetUserDisabled(id int, disabled bool) (*models.User, error)
+	ResetUserPassword(ctx context.Context, id int) error
+	SetUserRoles(userID int, roleIDs []int) (*models.User, error)
+	AddUserRole(userID, roleID int) error
+	RemoveUserRole(userID, roleID int) error
+	GetRoles() ([]models.Role, error)
+	CreateRole(role *models.Role) error
+	DeleteRole(id int) error
+	SetRolePermissions(roleID int, permissionIDs []int) (*models.Role, error)
+	GetPermissions() ([]models.Permission, error)
+
+	// User preferences
+	GetUserPreferences(userID int) (*models.UserPreferences, error)
+	UpdateUserPreferences(userID int, preferences *models.UserPreferences) error
+	GetDevicePreferences(deviceID string) (*models.UserPreferences, error)
+	UpdateDevicePreferences(deviceID string, preferences *models.UserPreferences) error
+	PatchUserPreferences(userID int, patch map[string]json.RawMessage) (*models.UserPreferences, error)
+	PatchDevicePreferences(deviceID string, patch map[string]json.RawMessage) (*models.UserPreferences, error)
+	MergeDevicePreferences(deviceID string, userID int) error
+	DiagnosisNames(ids []string) ([]string, error)
+	NewDeviceToken() (deviceID, token string)
+	DeviceIDFromToken(token string) (string, error)
+}
+
+// FromServices adapts a *services.Service to Service
+func FromServices(s *services.Service) Service {
+	return servicesAdapter{s}
+}
+
+// servicesAdapter narrows the return type of WithContext to Service; every
+// other method is promoted from *services.Service
+type servicesAdapter struct {
+	*services.Service
+}
+
+func (a servicesAdapter) WithContext(ctx context.Context) Service {
+	return servicesAdapter{a.Service.WithContext(ctx)}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+// fakeService records the calls handler tests make and answers them from
+// its fields. Methods a test doesn't stub panic through the nil embedded
+// Service.
+type fakeService struct {
+	Service
+
+	ctx    context.Context // of the last WithContext
+	filter *models.SearchFilter
+	page   services.PageRequest
+
+	centers []models.ABACenter
+	next    *string
+	created *models.ABACenter
+	err     error
+
+	devices map[string]*models.UserPreferences // preferences by device ID
+	patch   map[string]json.RawMessage         // of the last preferences PATCH
+	checks  services.SubmissionChecks          // of the last form submission
+
+	submissionFilter services.SubmissionFilter // of the last inbox listing
+	searched         []string                  // entity types of the last Search or StaleRecords
+	searchLimit      int                       // limit of the last Search
+	staleDays        int                       // days of the last StaleRecords
+	auditFilter      services.AuditFilter      // of the last audit log listing
+	exportFormat     string                    // format of the last export
+	loggedOut        string                    // token of the last Logout or RevokeRefreshToken
+
+	preferences *models.UserPreferences // of every signed-in user
+	listed      bool                    // a list query ran
+}
+
+func (f *fakeService) WithContext(ctx context.Context) Service {
+	f.ctx = ctx
+	return f
+}
+
+func (f *fakeService) GetABACenters(filter *models.SearchFilter, page services.PageRequest) (*services.Page[models.ABACenter], error) {
+	f.filter, f.page, f.listed = filter, page, true
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &services.Page[models.ABACenter]{Items: f.centers, NextCursor: f.next, Total: int64(len(f.centers))}, nil
+}
+
+func (f *fakeService) GetABACenterByID(id uuid.UUID) (*models.ABACenter, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	for i := range f.centers {
+		if f.centers[i].ID == id {
+			return &f.centers[i], nil
+		}
+	}
+	return nil, services.ErrNotFound
+}
+
+func (f *fakeService) CreateABACenter(center *models.ABACenter) error {
+	if f.err != nil {
+		return f.err
+	}
+	center.ID = uuid.New()
+	f.created = center
+	return nil
+}
+
+func (f *fakeService) UpdateABACenter(id uuid.UUID, center *models.ABACenter) error {
+	if f.err != nil {
+		return f.err
+	}
+	center.ID = id
+	return nil
+}
+
+func (f *fakeService) GetProviders(filter *models.SearchFilter, page services.PageRequest) (*services.Page[models.Provider], error) {
+	f.filter, f.page, f.listed = filter, page, true
+	return &services.Page[models.Provider]{Items: []models.Provider{{ID: 1, Name: "Provider"}}, Total: 1}, f.err
+}
+
+func (f *fakeService) GetUserPreferences(userID int) (*models.UserPreferences, error) {
+	if f.preferences == nil {
+		defaults := services.DefaultUserPreferences()
+		return &defaults, nil
+	}
+	return f.preferences, nil
+}
+
+func (f *fakeService) GetDevicePreferences(deviceID string) (*models.UserPreferences, error) {
+	if preferences, ok := f.devices[deviceID]; ok {
+		return preferences, nil
+	}
+	defaults := services.DefaultUserPreferences()
+	return &defaults, nil
+}
+
+func (f *fakeService) UpdateDevicePreferences(deviceID string, preferences *models.UserPreferences) error {
+	if f.devices == nil {
+		f.devices = map[string]*models.UserPreferences{}
+	}
+	f.devices[deviceID] = preferences
+	return f.err
+}
+
+// NewDeviceToken issues "new-device", signed by appending ".signed"
+func (f *fakeService) NewDeviceToken() (string, string) {
+	return "new-device", "new-device.signed"
+}
+
+func (f *fakeService) DeviceIDFromToken(token string) (string, error) {
+	deviceID, ok := strings.CutSuffix(token, ".signed")
+	if !ok {
+		return "", services.ErrInvalidToken
+	}
+	return deviceID, nil
+}
+
+func (f *fakeService) PatchDevicePreferences(deviceID string, patch map[string]json.RawMessage) (*models.UserPreferences, error) {
+	f.patch = patch
+	if f.err != nil {
+		return nil, f.err
+	}
+	preferences, _ := f.GetDevicePreferences(deviceID)
+	return preferences, nil
+}
+
+func (f *fakeService) DiagnosisNames(ids []string) ([]string, error) {
+	return ids, nil
+}
+
+func (f *fakeService) NewFormToken() string {
+	return "issued.signature"
+}
+
+func (f *fakeService) CreateFormSubmission(submission *models.FormSubmission, checks services.SubmissionChecks) error {
+	f.checks = checks
+	return f.err
+}
+
+func (f *fakeService) GetFormSubmissions(filter services.SubmissionFilter, page services.PageRequest) (*services.Page[models.FormSubmission], error) {
+	f.submissionFilter = filter
+	return &services.Page[models.FormSubmission]{Items: []models.FormSubmission{}}, f.err
+}
+
+func (f *fakeService) Search(q string, entityTypes []string, limit int) ([]services.SearchResult, error) {
+	f.searched, f.searchLimit = entityTypes, limit
+	return []services.SearchResult{}, f.err
+}
+
+func (f *fakeService) GetAuditLogs(filter services.AuditFilter, page services.PageRequest) (*services.Page[models.AuditLog], error) {
+	f.auditFilter = filter
+	return &services.Page[models.AuditLog]{Items: []models.AuditLog{}}, f.err
+}
+
+func (f *fakeService) ExportFacilities(filter *models.SearchFilter, entityTypes []string, format string, w io.Writer) error {
+	f.filter, f.searched, f.exportFormat = filter, entityTypes, format
+	_, err := io.WriteString(w, "exported")
+	return err
+}
+
+func (f *fakeService) Logout(accessToken string) error {
+	f.loggedOut = accessToken
+	return f.err
+}
+
+func (f *fakeService) RevokeRefreshToken(refreshToken string) error {
+	f.loggedOut = refreshToken
+	return f.err
+}
+
+func (f *fakeService) StaleRecords(days int, entityTypes []string) (*services.StaleReport, error) {
+	f.staleDays, f.searched = days, entityTypes
+	return &services.StaleReport{Days: days, Groups: []services.StaleGroup{}}, f.err
+}
+
+// fakeAuthenticator signs in the user of the token "valid"
+type fakeAuthenticator struct {
+	user *models.User
+}
+
+func (a fakeAuthenticator) Authenticate(accessToken string) (*models.User, error) {
+	if accessToken != "valid" {
+		return nil, services.ErrInvalidToken
+	}
+	return a.user, nil
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/middleware"
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+// Form Submissions Handlers
+
+// GetFormToken hands out the token the contact form sends back with its
+// submission, which records when the form was shown
+func (h *Handler) GetFormToken(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"form_token": h.svc(c).NewFormToken()})
+}
+
+// CreateFormSubmission stores a contact form submission and answers
+// {"status": "received"}. Alongside name, email and message the form sends
+// the hidden honeypot field "website", which people leave empty, and
+// "form_token" from GetFormToken. Submissions without a valid "form_token"
+// are treated as spam.
+func (h *Handler) CreateFormSubmission(c *gin.Context) {
+	log.Printf("[CREATE_FORM_SUBMISSION] Request received")
+
+	var request struct {
+		Name      string `json:"name"`
+		Email     string `json:"email"`
+		Message   string `json:"message"`
+		Website   string `json:"website"`
+		FormToken string `json:"form_token"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+
+	ip := c.ClientIP()
+	submission := models.FormSubmission{
+		Name:      request.Name,
+		Email:     request.Email,
+		Message:   request.Message,
+		IPAddress: &ip,
+	}
+	checks := services.SubmissionChecks{Honeypot: request.Website, FormToken: request.FormToken}
+
+	// Spam gets the same answer as a stored submission so bots can't tell
+	// they were caught
+	err := h.svc(c).CreateFormSubmission(&submission, checks)
+	if err != nil && !errors.Is(err, services.ErrSpam) {
+		respondWriteError(c, "CREATE_FORM_SUBMISSION", "Form submission", "Failed to create form submission", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"status": "received"})
+}
+
+// Admin inbox
+
+// GetFormSubmissions retrieves a page of the inbox, newest first
+func (h *Handler) GetFormSubmissions(c *gin.Context) {
+	log.Printf("[GET_FORM_SUBMISSIONS] Request received")
+
+	filter, ok := parseSubmissionFilter(c)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.svc(c).GetFormSubmissions(filter, page)
+	if err != nil {
+		respondPageError(c, "GET_FORM_SUBMISSIONS", "Failed to fetch form submissions", err)
+		return
+	}
+
+	respondPage(c, result)
+}
+
+// GetFormSubmission retrieves a submission with its notes
+func (h *Handler) GetFormSubmission(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Form submission")
+	if !ok {
+		return
+	}
+
+	submission, err := h.svc(c).GetFormSubmissionByID(id)
+	if err != nil {
+		respondWriteError(c, "GET_FORM_SUBMISSION", "Form submission", "Failed to fetch form submission", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, submission)
+}
+
+// PatchFormSubmission marks a submission read or handled, or assigns it,
+// with {"read": bool, "handled": bool, "assigned_to_id": id|null}
+func (h *Handler) PatchFormSubmission(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Form submission")
+	if !ok {
+		return
+	}
+	log.Printf("[PATCH_FORM_SUBMISSION] Request for submission ID: %d", id)
+
+	var patch map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+
+	submission, err := h.svc(c).PatchFormSubmission(id, patch)
+	if err != nil {
+		respondWriteError(c, "PATCH_FORM_SUBMISSION", "Form submission", "Failed to update form submission", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, submission)
+}
+
+// AddSubmissionNote adds an internal note with {"body": ...}, authored by the
+// signed-in user
+func (h *Handler) AddSubmissionNote(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Form submission")
+	if !ok {
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	var request struct {
+		Body string `json:"body"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	note, err := h.svc(c).AddSubmissionNote(id, user.ID, request.Body)
+	if err != nil {
+		respondWriteError(c, "ADD_SUBMISSION_NOTE", "Form submission", "Failed to add note", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, note)
+}
+
+// ExportFormSubmissions streams the inbox as CSV, taking the same filters
+// as GetFormSubmissions
+func (h *Handler) ExportFormSubmissions(c *gin.Context) {
+	filter, ok := parseSubmissionFilter(c)
+	if !ok {
+		return
+	}
+	log.Printf("[EXPORT_FORM_SUBMISSIONS] Request received")
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"form-submissions-%s.csv\"", time.Now().Format("2006-01-02")))
+	c.Status(http.StatusOK)
+	if err := h.svc(c).ExportFormSubmissions(filter, c.Writer); err != nil {
+		// Headers are gone by now; all we can do is log and cut the file short
+		log.Printf("[EXPORT_FORM_SUBMISSIONS] Error: %v", err)
+	}
+}
+
+// parseSubmissionFilter reads the inbox filters: search, from and to (dates
+// or RFC 3339 times; a date in "to" includes that whole day), status and
+// assigned_to (a user id, "me" or "none"). It writes a 400 response and
+// returns ok=false when one is invalid.
+func parseSubmissionFilter(c *gin.Context) (filter services.SubmissionFilter, ok bool) {
+	filter.Search = c.Query("search")
+	if !parseTimeRange(c, &filter.From, &filter.To) {
+		return filter, false
+	}
+
+	switch status := c.Query("status"); status {
+	case "", services.SubmissionStatusUnread, services.SubmissionStatusOpen, services.SubmissionStatusHandled:
+		filter.Status = status
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be unread, open or handled"})
+		return filter, false
+	}
+
+	switch assignee := c.Query("assigned_to"); assignee {
+	case "":
+	case "none":
+		filter.Unassigned = true
+	case "me":
+		if user, ok := middleware.CurrentUser(c); ok {
+			filter.AssignedToID = &user.ID
+		}
+	default:
+		id, err := strconv.Atoi(assignee)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "assigned_to must be a user id, me or none"})
+			return filter, false
+		}
+		filter.AssignedToID = &id
+	}
+	return filter, true
+}
+
+// parseTimeRange reads the from and to query parameters, dates or RFC 3339
+// times; a date in "to" includes that whole day, so to is exclusive. It
+// writes a 400 response and returns false when one is invalid.
+func parseTimeRange(c *gin.Context, from, to **time.Time) bool {
+	for _, bound := range []struct {
+		name   string
+		target **time.Time
+	}{{"from", from}, {"to", to}} {
+		value := c.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			day, dayErr := time.ParseInLocation("2006-01-02", value, time.Local)
+			if dayErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": bound.name + " must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+				return false
+			}
+			if t = day; bound.name == "to" {
+				t = day.AddDate(0, 0, 1)
+			}
+		}
+		*bound.target = &t
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+func submissionRouter(service Service) *gin.Engine {
+	handler := NewHandler(service)
+	r := gin.New()
+	r.GET("/api/v1/form-token", handler.GetFormToken)
+	r.POST("/api/v1/form-submissions", handler.CreateFormSubmission)
+	r.GET("/api/v1/admin/form-submissions", handler.GetFormSubmissions)
+	return r
+}
+
+func TestGetFormToken(t *testing.T) {
+	w := serve(submissionRouter(&fakeService{}), http.MethodGet, "/api/v1/form-token", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var body struct {
+		FormToken string `json:"form_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.FormToken != "issued.signature" {
+		t.Errorf("body = %s, want the service's token", w.Body)
+	}
+	if cache := w.Header().Get("Cache-Control"); cache != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", cache)
+	}
+}
+
+func TestCreateFormSubmissionChecks(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		checks services.SubmissionChecks
+		status int
+	}{
+		{"stored", `{"name": "Ann", "email": "ann@example.com", "message": "Hi", "form_token": "t"}`,
+			nil, services.SubmissionChecks{FormToken: "t"}, http.StatusCreated},
+		{"client timing is ignored", `{"name": "Ann", "email": "ann@example.com", "message": "Hi", "form_fill_ms": 5000}`,
+			services.ErrSpam, services.SubmissionChecks{}, http.StatusCreated},
+		{"honeypot", `{"name": "Ann", "email": "ann@example.com", "message": "Hi", "website": "x", "form_token": "t"}`,
+			services.ErrSpam, services.SubmissionChecks{Honeypot: "x", FormToken: "t"}, http.StatusCreated},
+		{"invalid", `{"email": "nope", "form_token": "t"}`,
+			&services.ValidationError{Fields: map[string]string{"email": "is invalid"}}, services.SubmissionChecks{FormToken: "t"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{err: tt.err}
+			w := serve(submissionRouter(service), http.MethodPost, "/api/v1/form-submissions", tt.body, nil)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if service.checks != tt.checks {
+				t.Errorf("checks = %+v, want %+v", service.checks, tt.checks)
+			}
+		})
+	}
+}
+
+func TestParseSubmissionFilter(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(2026, 3, 8, 0, 0, 0, 0, time.Local) // the whole of March 7
+	assignee := 4
+
+	tests := []struct {
+		query  string
+		want   services.SubmissionFilter
+		status int
+	}{
+		{"search=ann&status=open&assigned_to=4", services.SubmissionFilter{Search: "ann", Status: "open", AssignedToID: &assignee}, http.StatusOK},
+		{"from=2026-03-01&to=2026-03-07&assigned_to=none", services.SubmissionFilter{From: &from, To: &to, Unassigned: true}, http.StatusOK},
+		{"from=March", services.SubmissionFilter{}, http.StatusBadRequest},
+		{"status=spam", services.SubmissionFilter{}, http.StatusBadRequest},
+		{"assigned_to=someone", services.SubmissionFilter{}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		service := &fakeService{}
+		w := serve(submissionRouter(service), http.MethodGet, "/api/v1/admin/form-submissions?"+tt.query, "", nil)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.query, w.Code, tt.status, w.Body)
+			continue
+		}
+		if !reflect.DeepEqual(service.submissionFilter, tt.want) {
+			t.Errorf("%s: filter = %+v, want %+v", tt.query, service.submissionFilter, tt.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// User and Role Management Handlers
+
+// GetUsers retrieves a page of users with their roles
+func (h *Handler) GetUsers(c *gin.Context) {
+	log.Printf("[GET_USERS] Request received")
+
+	filter, ok := parseFilter(c)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.svc(c).GetUsers(filter, page)
+	if err != nil {
+		respondPageError(c, "GET_USERS", "Failed to fetch users", err)
+		return
+	}
+
+	respondPage(c, result)
+}
+
+// GetUser retrieves a single user by ID
+func (h *Handler) GetUser(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "User")
+	if !ok {
+		return
+	}
+
+	user, err := h.svc(c).GetUserByID(id)
+	if err != nil {
+		respondWriteError(c, "GET_USER", "User", "Failed to fetch user", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// InviteUser creates a user and emails them a link to set their password
+func (h *Handler) InviteUser(c *gin.Context) {
+	log.Printf("[INVITE_USER] Request received")
+
+	var request struct {
+		models.User
+		RoleIDs []int `json:"role_ids"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	user := request.User
+	if err := h.svc(c).InviteUser(c.Request.Context(), &user, request.RoleIDs); err != nil {
+		respondWriteError(c, "INVITE_USER", "User", "Failed to invite user", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// DisableUser disables a user and ends their sessions
+func (h *Handler) DisableUser(c *gin.Context) {
+	h.setUserDisabled(c, true)
+}
+
+// EnableUser re-enables a disabled user
+func (h *Handler) EnableUser(c *gin.Context) {
+	h.setUserDisabled(c, false)
+}
+
+func (h *Handler) setUserDisabled(c *gin.Context, disabled bool) {
+	id, ok := parseIntParam(c, "id", "User")
+	if !ok {
+		return
+	}
+
+	user, err := h.svc(c).SetUserDisabled(id, disabled)
+	if err != nil {
+		respondWriteError(c, "SET_USER_DISABLED", "User", "Failed to update user", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ResetUserPassword ends a user's sessions and emails them a link to choose
+// a new password
+func (h *Handler) ResetUserPassword(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "User")
+	if !ok {
+		return
+	}
+	log.Printf("[RESET_USER_PASSWORD] Request for user ID: %d", id)
+
+	if err := h.svc(c).ResetUserPassword(c.Request.Context(), id); err != nil {
+		respondWriteError(c, "RESET_USER_PASSWORD", "User", "Failed to reset password", err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// SetUserRoles replaces the roles of a user with {"role_ids": [...]}
+func (h *Handler) SetUserRoles(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "User")
+	if !ok {
+		return
+	}
+
+	var request struct {
+		RoleIDs []int `json:"role_ids" binding:"required"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	user, err := h.svc(c).SetUserRoles(id, request.RoleIDs)
+	if err != nil {
+		respondWriteError(c, "SET_USER_ROLES", "User", "Failed to update roles", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// AddUserRole grants a role to a user
+func (h *Handler) AddUserRole(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "User")
+	if !ok {
+		return
+	}
+	roleID, ok := parseIntParam(c, "roleId", "Role")
+	if !ok {
+		return
+	}
+
+	if err := h.svc(c).AddUserRole(id, roleID); err != nil {
+		respondWriteError(c, "ADD_USER_ROLE", "User or role", "Failed to grant role", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveUserRole takes a role away from a user
+func (h *Handler) RemoveUserRole(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "User")
+	if !ok {
+		return
+	}
+	roleID, ok := parseIntParam(c, "roleId", "Role")
+	if !ok {
+		return
+	}
+
+	if err := h.svc(c).RemoveUserRole(id, roleID); err != nil {
+		respondWriteError(c, "REMOVE_USER_ROLE", "User role", "Failed to remove role", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetRoles lists every role with its permissions
+func (h *Handler) GetRoles(c *gin.Context) {
+	roles, err := h.svc(c).GetRoles()
+	if err != nil {
+		respondWriteError(c, "GET_ROLES", "Role", "Failed to fetch roles", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole creates a role
+func (h *Handler) CreateRole(c *gin.Context) {
+	var role models.Role
+	if !bindJSON(c, &role) {
+		return
+	}
+
+	if err := h.svc(c).CreateRole(&role); err != nil {
+		respondWriteError(c, "CREATE_ROLE", "Role", "Failed to create role", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// DeleteRole deletes a role
+func (h *Handler) DeleteRole(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Role")
+	if !ok {
+		return
+	}
+
+	if err := h.svc(c).DeleteRole(id); err != nil {
+		respondWriteError(c, "DELETE_ROLE", "Role", "Failed to delete role", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetRolePermissions replaces the permissions of a role with
+// {"permission_ids": [...]}
+func (h *Handler) SetRolePermissions(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Role")
+	if !ok {
+		return
+	}
+
+	var request struct {
+		PermissionIDs []int `json:"permission_ids" binding:"required"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	role, err := h.svc(c).SetRolePermissions(id, request.PermissionIDs)
+	if err != nil {
+		respondWriteError(c, "SET_ROLE_PERMISSIONS", "Role", "Failed to update permissions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// GetPermissions lists every permission
+func (h *Handler) GetPermissions(c *gin.Context) {
+	permissions, err := h.svc(c).GetPermissions()
+	if err != nil {
+		respondWriteError(c, "GET_PERMISSIONS", "Permission", "Failed to fetch permissions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// Admin write handlers for resource centers, resources, regional centers,
+// providers and diagnoses
+
+// CreateResourceCenter creates a new resource center
+func (h *Handler) CreateResourceCenter(c *gin.Context) {
+	log.Printf("[CREATE_RESOURCE_CENTER] Request received")
+
+	var center models.ResourceCenter
+	if !bindJSON(c, &center) {
+		return
+	}
+
+	if err := h.svc(c).CreateResourceCenter(&center); err != nil {
+		respondWriteError(c, "CREATE_RESOURCE_CENTER", "Resource center", "Failed to create resource center", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, center)
+}
+
+// UpdateResourceCenter replaces a resource center. The body must include the
+// updated_at value last read by the client.
+func (h *Handler) UpdateResourceCenter(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "Resource center")
+	if !ok {
+		return
+	}
+	log.Printf("[UPDATE_RESOURCE_CENTER] Request for center ID: %s", id)
+
+	var center models.ResourceCenter
+	if !bindJSON(c, &center) {
+		return
+	}
+
+	if err := h.svc(c).UpdateResourceCenter(id, &center); err != nil {
+		respondWriteError(c, "UPDATE_RESOURCE_CENTER", "Resource center", "Failed to update resource center", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, center)
+}
+
+// DeleteResourceCenter deletes a resource center
+func (h *Handler) DeleteResourceCenter(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "Resource center")
+	if !ok {
+		return
+	}
+	log.Printf("[DELETE_RESOURCE_CENTER] Request for center ID: %s", id)
+
+	if err := h.svc(c).DeleteResourceCenter(id); err != nil {
+		respondWriteError(c, "DELETE_RESOURCE_CENTER", "Resource center", "Failed to delete resource center", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetResourceCenterDiagnoses replaces the diagnoses linked to a resource
+// center with {"diagnosis_ids": [...]}
+func (h *Handler) SetResourceCenterDiagnoses(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "Resource center")
+	if !ok {
+		return
+	}
+	log.Printf("[SET_RESOURCE_CENTER_DIAGNOSES] Request for center ID: %s", id)
+
+	var request struct {
+		DiagnosisIDs []uuid.UUID `json:"diagnosis_ids" binding:"required"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	center, err := h.svc(c).SetResourceCenterDiagnoses(id, request.DiagnosisIDs)
+	if err != nil {
+		respondWriteError(c, "SET_RESOURCE_CENTER_DIAGNOSES", "Resource center", "Failed to update resource center diagnoses", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, center)
+}
+
+// AddResourceCenterDiagnosis links a diagnosis to a resource center
+func (h *Handler) AddResourceCenterDiagnosis(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "Resource center")
+	if !ok {
+		return
+	}
+	diagnosisID, ok := parseUUIDParam(c, "diagnosisId", "Diagnosis")
+	if !ok {
+		return
+	}
+	log.Printf("[ADD_RESOURCE_CENTER_DIAGNOSIS] Linking diagnosis %s to center %s", diagnosisID, id)
+
+	if err := h.svc(c).AddResourceCenterDiagnosis(id, diagnosisID); err != nil {
+		respondWriteError(c, "ADD_RESOURCE_CENTER_DIAGNOSIS", "Resource center or diagnosis", "Failed to link diagnosis", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveResourceCenterDiagnosis unlinks a diagnosis from a resource center
+func (h *Handler) RemoveResourceCenterDiagnosis(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "Resource center")
+	if !ok {
+		return
+	}
+	diagnosisID, ok := parseUUIDParam(c, "diagnosisId", "Diagnosis")
+	if !ok {
+		return
+	}
+	log.Printf("[REMOVE_RESOURCE_CENTER_DIAGNOSIS] Unlinking diagnosis %s from center %s", diagnosisID, id)
+
+	if err := h.svc(c).RemoveResourceCenterDiagnosis(id, diagnosisID); err != nil {
+		respondWriteError(c, "REMOVE_RESOURCE_CENTER_DIAGNOSIS", "Resource center diagnosis", "Failed to unlink diagnosis", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateResource creates a new resource
+func (h *Handler) CreateResource(c *gin.Context) {
+	log.Printf("[CREATE_RESOURCE] Request received")
+
+	var resource models.Resource
+	if !bindJSON(c, &resource) {
+		return
+	}
+
+	if err := h.svc(c).CreateResource(&resource); err != nil {
+		respondWriteError(c, "CREATE_RESOURCE", "Resource", "Failed to create resource", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, resource)
+}
+
+// UpdateResource replaces a resource. The body must include the updated_at
+// value last read by the client.
+func (h *Handler) UpdateResource(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "Resource")
+	if !ok {
+		return
+	}
+	log.Printf("[UPDATE_RESOURCE] Request for resource ID: %s", id)
+
+	var resource models.Resource
+	if !bindJSON(c, &resource) {
+		return
+	}
+
+	if err := h.svc(c).UpdateResource(id, &resource); err != nil {
+		respondWriteError(c, "UPDATE_RESOURCE", "Resource", "Failed to update resource", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resource)
+}
+
+// DeleteResource deletes a resource
+func (h *Handler) DeleteResource(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "Resource")
+	if !ok {
+		return
+	}
+	log.Printf("[DELETE_RESOURCE] Request for resource ID: %s", id)
+
+	if err := h.svc(c).DeleteResource(id); err != nil {
+		respondWriteError(c, "DELETE_RESOURCE", "Resource", "Failed to delete resource", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateRegionalCenter creates a new regional center
+func (h *Handler) CreateRegionalCenter(c *gin.Context) {
+	log.Printf("[CREATE_REGIONAL_CENTER] Request received")
+
+	var center models.RegionalCenter
+	if !bindJSON(c, &center) {
+		return
+	}
+
+	if err := h.svc(c).CreateRegionalCenter(&center); err != nil {
+		respondWriteError(c, "CREATE_REGIONAL_CENTER", "Regional center", "Failed to create regional center", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, center)
+}
+
+// UpdateRegionalCenter replaces a regional center. The body must include the
+// updated_at value last read by the client.
+func (h *Handler) UpdateRegionalCenter(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Regional center")
+	if !ok {
+		return
+	}
+	log.Printf("[UPDATE_REGIONAL_CENTER] Request for center ID: %d", id)
+
+	var center models.RegionalCenter
+	if !bindJSON(c, &center) {
+		return
+	}
+
+	if err := h.svc(c).UpdateRegionalCenter(id, &center); err != nil {
+		respondWriteError(c, "UPDATE_REGIONAL_CENTER", "Regional center", "Failed to update regional center", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, center)
+}
+
+// DeleteRegionalCenter deletes a regional center
+func (h *Handler) DeleteRegionalCenter(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Regional center")
+	if !ok {
+		return
+	}
+	log.Printf("[DELETE_REGIONAL_CENTER] Request for center ID: %d", id)
+
+	if err := h.svc(c).DeleteRegionalCenter(id); err != nil {
+		respondWriteError(c, "DELETE_REGIONAL_CENTER", "Regional center", "Failed to delete regional center", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateProvider creates a new provider
+func (h *Handler) CreateProvider(c *gin.Context) {
+	log.Printf("[CREATE_PROVIDER] Request received")
+
+	var provider models.Provider
+	if !bindJSON(c, &provider) {
+		return
+	}
+
+	if err := h.svc(c).CreateProvider(&provider); err != nil {
+		respondWriteError(c, "CREATE_PROVIDER", "Provider", "Failed to create provider", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, provider)
+}
+
+// UpdateProvider replaces a provider. The body must include the updated_at
+// value last read by the client.
+func (h *Handler) UpdateProvider(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Provider")
+	if !ok {
+		return
+	}
+	log.Printf("[UPDATE_PROVIDER] Request for provider ID: %d", id)
+
+	var provider models.Provider
+	if !bindJSON(c, &provider) {
+		return
+	}
+
+	if err := h.svc(c).UpdateProvider(id, &provider); err != nil {
+		respondWriteError(c, "UPDATE_PROVIDER", "Provider", "Failed to update provider", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, provider)
+}
+
+// DeleteProvider deletes a provider
+func (h *Handler) DeleteProvider(c *gin.Context) {
+	id, ok := parseIntParam(c, "id", "Provider")
+	if !ok {
+		return
+	}
+	log.Printf("[DELETE_PROVIDER] Request for provider ID: %d", id)
+
+	if err := h.svc(c).DeleteProvider(id); err != nil {
+		respondWriteError(c, "DELETE_PROVIDER", "Provider", "Failed to delete provider", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateDiagnosis creates a new diagnosis
+func (h *Handler) CreateDiagnosis(c *gin.Context) {
+	log.Printf("[CREATE_DIAGNOSIS] Request received")
+
+	var diagnosis models.Diagnosis
+	if !bindJSON(c, &diagnosis) {
+		return
+	}
+
+	if err := h.svc(c).CreateDiagnosis(&diagnosis); err != nil {
+		respondWriteError(c, "CREATE_DIAGNOSIS", "Diagnosis", "Failed to create diagnosis", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, diagnosis)
+}
+
+// UpdateDiagnosis renames a diagnosis
+func (h *Handler) UpdateDiagnosis(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "Diagnosis")
+	if !ok {
+		return
+	}
+	log.Printf("[UPDATE_DIAGNOSIS] Request for diagnosis ID: %s", id)
+
+	var diagnosis models.Diagnosis
+	if !bindJSON(c, &diagnosis) {
+		return
+	}
+
+	if err := h.svc(c).UpdateDiagnosis(id, &diagnosis); err != nil {
+		respondWriteError(c, "UPDATE_DIAGNOSIS", "Diagnosis", "Failed to update diagnosis", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, diagnosis)
+}
+
+// DeleteDiagnosis deletes a diagnosis
+func (h *Handler) DeleteDiagnosis(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id", "Diagnosis")
+	if !ok {
+		return
+	}
+	log.Printf("[DELETE_DIAGNOSIS] Request for diagnosis ID: %s", id)
+
+	if err := h.svc(c).DeleteDiagnosis(id); err != nil {
+		respondWriteError(c, "DELETE_DIAGNOSIS", "Diagnosis", "Failed to delete diagnosis", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindJSON decodes the request body, writing a 400 response and returning
+// false when it isn't valid JSON
+func bindJSON(c *gin.Context, dest interface{}) bool {
+	if err := c.ShouldBindJSON(dest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return false
+	}
+	return true
+}
+
+// parseUUIDParam reads a UUID path parameter, writing a 404 response for
+// entity and returning false when it is malformed
+func parseUUIDParam(c *gin.Context, name, entity string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": entity + " not found"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// parseIntParam reads an integer path parameter, writing a 404 response for
+// entity and returning false when it is malformed
+func parseIntParam(c *gin.Context, name, entity string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": entity + " not found"})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,21 @@
+package mailer
+
+import (
+	"context"
+	"log"
+)
+
+// LogMailer writes messages to the log instead of sending them, for local
+// development
+type LogMailer struct{}
+
+// NewLogMailer creates a LogMailer
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send logs msg
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("[MAIL] To: %s | Subject: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
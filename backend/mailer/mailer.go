@@ -0,0 +1,66 @@
+// Package mailer sends transactional email such as invitations
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config selects and configures a Mailer
+type Config struct {
+	Driver string // "log" (default), "file" or "smtp"
+	From   string
+
+	// file driver
+	Dir string
+
+	// smtp driver
+	SMTPAddr     string // host:port
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// New returns the Mailer selected by cfg.Driver
+func New(cfg Config) (Mailer, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "", "log":
+		return NewLogMailer(), nil
+	case "file":
+		return NewFileMailer(cfg.Dir, cfg.From)
+	case "smtp":
+		return NewSMTPMailer(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	}
+	return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+}
+
+// format renders msg as an RFC 5322 message
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue strips line breaks so a value can't inject extra headers
+func headerValue(v string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(v)
+}
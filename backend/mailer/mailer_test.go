@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"default", Config{}, false},
+		{"log", Config{Driver: "LOG"}, false},
+		{"file", Config{Driver: "file", Dir: t.TempDir()}, false},
+		{"smtp", Config{Driver: "smtp", SMTPAddr: "localhost:25", From: "noreply@example.com"}, false},
+		{"smtp without an address", Config{Driver: "smtp", From: "noreply@example.com"}, true},
+		{"unknown", Config{Driver: "pigeon"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New = %v, %v, want error %t", m, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatStripsHeaderBreaks(t *testing.T) {
+	msg := Message{
+		To:      "user@example.com\r\nBcc: victim@example.com",
+		Subject: "Hello\nX-Injected: yes",
+		Body:    "line one\nline two",
+	}
+	formatted := string(format("noreply@example.com", msg))
+	header, body, _ := strings.Cut(formatted, "\r\n\r\n")
+
+	for _, line := range strings.Split(header, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") || strings.HasPrefix(line, "X-Injected:") {
+			t.Errorf("header line %q was injected through a value", line)
+		}
+	}
+	if body != "line one\r\nline two" {
+		t.Errorf("body = %q, want CRLF line endings", body)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "noreply@example.com")
+	if err != nil {
+		t.Fatalf("NewFileMailer: %v", err)
+	}
+	if err := m.Send(context.Background(), Message{To: "a user@example.com", Subject: "Hi", Body: "Hello"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*-a_user@example.com.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("mail files = %v, %v, want one file named after the recipient", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("reading mail: %v", err)
+	}
+	if !strings.Contains(string(data), "Subject: Hi\r\n") || !strings.HasSuffix(string(data), "\r\n\r\nHello") {
+		t.Errorf("mail = %q, want the subject and body", data)
+	}
+}
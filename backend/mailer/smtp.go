@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+// SMTPMailer sends messages through an SMTP server
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer creates an SMTPMailer for the server at addr (host:port).
+// PLAIN authentication is used when username is set.
+func NewSMTPMailer(addr, username, password, from string) (*SMTPMailer, error) {
+	if addr == "" || from == "" {
+		return nil, errors.New("smtp mailer needs an address and a from address")
+	}
+	m := &SMTPMailer{addr: addr, from: from}
+	if username != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid smtp address %q: %w", addr, err)
+		}
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+// Send delivers msg
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, format(m.from, msg)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -9,6 +11,9 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,10 +22,14 @@ import (
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/alexbeattie/medicalfacilities/config"
 	"github.com/alexbeattie/medicalfacilities/handlers"
+	"github.com/alexbeattie/medicalfacilities/mailer"
+	"github.com/alexbeattie/medicalfacilities/middleware"
 	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/notifier"
 	"github.com/alexbeattie/medicalfacilities/services"
 )
 
@@ -62,19 +71,508 @@ func initDB(dsn string) (*gorm.DB, error) {
 	}
 	log.Printf("Successfully connected to database")
 
-	// Auto-migrate only user preferences (other tables exist in your database)
+	if err := renameLegacyPreferenceColumns(db); err != nil {
+		return nil, err
+	}
+	// The insurance catalog is filled from the legacy text columns when its
+	// table is first created
+	parseInsurance := !db.Migrator().HasTable(&models.InsuranceCarrier{})
+
+	// Auto-migrate tables owned by this service (other tables exist in your database)
 	if err := db.AutoMigrate(
 		&models.UserPreferences{},
+		&models.GeocodeCache{},
+		&models.ProviderArea{},
+		&models.Session{},
+		&models.UserToken{},
+		&models.SubmissionNote{},
+		&models.InsuranceCarrier{},
+		&models.InsurancePlan{},
+		&models.ABACenterCarrier{},
+		&models.ABACenterPlan{},
+		&models.WaitlistChange{},
+		&models.AuditLog{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
+
+	// aba_centers predates geocoding, soft-delete and structured waitlists, so
+	// add those columns in place
+	parseWaitlists := !db.Migrator().HasColumn(&models.ABACenter{}, "waitlist_status")
+	for _, column := range []string{"latitude", "longitude", "deleted_at", "waitlist_status", "waitlist_weeks", "waitlist_updated_at"} {
+		if !db.Migrator().HasColumn(&models.ABACenter{}, column) {
+			if err := db.Migrator().AddColumn(&models.ABACenter{}, column); err != nil {
+				return nil, fmt.Errorf("failed to add aba_centers.%s: %w", column, err)
+			}
+		}
+	}
+	// Facility tables predate verification tracking
+	facilities := []struct {
+		table string
+		model interface{}
+	}{
+		{"aba_centers", &models.ABACenter{}},
+		{"resource_centers", &models.ResourceCenter{}},
+		{"resources", &models.Resource{}},
+		{"regional_centers", &models.RegionalCenter{}},
+		{"providers", &models.Provider{}},
+	}
+	for _, facility := range facilities {
+		for _, column := range []string{"last_verified_at", "verified_by_id"} {
+			if !db.Migrator().HasColumn(facility.model, column) {
+				if err := db.Migrator().AddColumn(facility.model, column); err != nil {
+					return nil, fmt.Errorf("failed to add %s.%s: %w", facility.table, column, err)
+				}
+			}
+		}
+		if !db.Migrator().HasIndex(facility.model, "LastVerifiedAt") {
+			if err := db.Migrator().CreateIndex(facility.model, "LastVerifiedAt"); err != nil {
+				return nil, fmt.Errorf("failed to index %s.last_verified_at: %w", facility.table, err)
+			}
+		}
+		if err := ensureForeignKey(db, facility.table, "fk_"+facility.table+"_verified_by", "verified_by_id", "users", "SET NULL"); err != nil {
+			return nil, err
+		}
+	}
+	// regional_centers and providers predate optimistic concurrency; existing
+	// rows are versioned from the time of the migration
+	for _, facility := range []struct {
+		table string
+		model interface{}
+	}{
+		{"regional_centers", &models.RegionalCenter{}},
+		{"providers", &models.Provider{}},
+	} {
+		if !db.Migrator().HasColumn(facility.model, "updated_at") {
+			if err := db.Migrator().AddColumn(facility.model, "updated_at"); err != nil {
+				return nil, fmt.Errorf("failed to add %s.updated_at: %w", facility.table, err)
+			}
+		}
+	}
+	// form_submissions predates spam checks and the admin inbox
+	for _, column := range []string{"ip_address", "read_at", "handled_at", "assigned_to_id"} {
+		if !db.Migrator().HasColumn(&models.FormSubmission{}, column) {
+			if err := db.Migrator().AddColumn(&models.FormSubmission{}, column); err != nil {
+				return nil, fmt.Errorf("failed to add form_submissions.%s: %w", column, err)
+			}
+		}
+	}
+	if !db.Migrator().HasIndex(&models.FormSubmission{}, "AssignedToID") {
+		if err := db.Migrator().CreateIndex(&models.FormSubmission{}, "AssignedToID"); err != nil {
+			return nil, fmt.Errorf("failed to index form_submissions.assigned_to_id: %w", err)
+		}
+	}
+	for _, fk := range []struct{ table, constraint, column, refTable, onDelete string }{
+		{"form_submissions", "fk_form_submissions_assigned_to", "assigned_to_id", "users", "SET NULL"},
+		{"submission_notes", "fk_submission_notes_submission", "submission_id", "form_submissions", "CASCADE"},
+		{"submission_notes", "fk_submission_notes_author", "author_id", "users", "SET NULL"},
+		{"aba_center_carriers", "fk_aba_center_carriers_center", "aba_center_id", "aba_centers", "CASCADE"},
+		{"aba_center_carriers", "fk_aba_center_carriers_carrier", "carrier_id", "insurance_carriers", "CASCADE"},
+		{"aba_center_plans", "fk_aba_center_plans_center", "aba_center_id", "aba_centers", "CASCADE"},
+		{"aba_center_plans", "fk_aba_center_plans_plan", "plan_id", "insurance_plans", "CASCADE"},
+		{"waitlist_changes", "fk_waitlist_changes_center", "aba_center_id", "aba_centers", "CASCADE"},
+	} {
+		if err := ensureForeignKey(db, fk.table, fk.constraint, fk.column, fk.refTable, fk.onDelete); err != nil {
+			return nil, err
+		}
+	}
+	if !db.Migrator().HasColumn(&models.User{}, "disabled_at") {
+		if err := db.Migrator().AddColumn(&models.User{}, "disabled_at"); err != nil {
+			return nil, fmt.Errorf("failed to add users.disabled_at: %w", err)
+		}
+	}
+	for _, field := range []string{"DeletedAt", "WaitlistStatus"} {
+		if !db.Migrator().HasIndex(&models.ABACenter{}, field) {
+			if err := db.Migrator().CreateIndex(&models.ABACenter{}, field); err != nil {
+				return nil, fmt.Errorf("failed to index aba_centers.%s: %w", field, err)
+			}
+		}
+	}
+	if parseWaitlists {
+		if err := migrateWaitlistText(db); err != nil {
+			return nil, err
+		}
+	}
+	if err := migrateLegacyPreferences(db); err != nil {
+		return nil, err
+	}
+	if parseInsurance {
+		if err := migrateInsuranceText(db); err != nil {
+			return nil, err
+		}
+	}
 	log.Printf("Database migrations completed successfully")
 	return db, nil
 }
 
-func setupRouter(handler *handlers.Handler, service *services.Service, db *gorm.DB) *gin.Engine {
+// renameLegacyPreferenceColumns moves columns of older user_preferences
+// tables aside so AutoMigrate can add their replacements: the free-text
+// user_id becomes legacy_user_id, and the JSON-encoded preferred_diagnoses
+// becomes legacy_preferred_diagnoses
+func renameLegacyPreferenceColumns(db *gorm.DB) error {
+	for _, column := range []string{"user_id", "preferred_diagnoses"} {
+		var dataType string
+		err := db.Raw(`SELECT data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'user_preferences' AND column_name = ?`, column).
+			Scan(&dataType).Error
+		if err != nil {
+			return fmt.Errorf("failed to inspect user_preferences: %w", err)
+		}
+		if dataType != "text" && dataType != "character varying" {
+			continue
+		}
+
+		log.Printf("Migrating user_preferences.%s (%s)", column, dataType)
+		if err := db.Exec(fmt.Sprintf("ALTER TABLE user_preferences RENAME COLUMN %s TO legacy_%s", column, column)).Error; err != nil {
+			return fmt.Errorf("failed to rename user_preferences.%s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// migrateLegacyPreferences carries legacy preferences over to the users they
+// name, dropping those that match no user, converts JSON diagnosis lists to
+// arrays of known diagnosis IDs, and adds the foreign key to users
+func migrateLegacyPreferences(db *gorm.DB) error {
+	var statements []string
+	if db.Migrator().HasColumn(&models.UserPreferences{}, "legacy_user_id") {
+		statements = append(statements,
+			`UPDATE user_preferences SET user_id = users.id FROM users
+				WHERE user_preferences.legacy_user_id ~ '^[0-9]+$' AND users.id = user_preferences.legacy_user_id::int`,
+			`DELETE FROM user_preferences WHERE user_id IS NULL AND device_id IS NULL`,
+			`ALTER TABLE user_preferences DROP COLUMN legacy_user_id`,
+		)
+	}
+	if db.Migrator().HasColumn(&models.UserPreferences{}, "legacy_preferred_diagnoses") {
+		statements = append(statements,
+			`UPDATE user_preferences SET preferred_diagnoses = ARRAY(
+				SELECT diagnoses.id FROM diagnoses
+				WHERE diagnoses.id::text IN (SELECT jsonb_array_elements_text(legacy_preferred_diagnoses::jsonb)))
+			WHERE legacy_preferred_diagnoses ~ '^\s*\[.*\]\s*$'`,
+			`ALTER TABLE user_preferences DROP COLUMN legacy_preferred_diagnoses`,
+		)
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to migrate legacy preferences: %w", err)
+		}
+	}
+
+	return ensureForeignKey(db, "user_preferences", "fk_user_preferences_user", "user_id", "users", "CASCADE")
+}
+
+// migrateWaitlistText sets the waitlist status and estimated wait of ABA
+// centers from their free-text waitlist_availability, once, when the
+// structured columns are added. Text that can't be read leaves the status
+// unknown; waitlist_availability is kept as a note.
+func migrateWaitlistText(db *gorm.DB) error {
+	var centers []models.ABACenter
+	if err := db.Unscoped().Select("id", "waitlist_availability").
+		Where("COALESCE(waitlist_availability, '') <> ''").
+		Find(&centers).Error; err != nil {
+		return fmt.Errorf("failed to read ABA center waitlists: %w", err)
+	}
+
+	counts := map[string]int{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, center := range centers {
+			status, weeks := services.ParseWaitlistText(services.Deref(center.WaitlistAvailability))
+			counts[status]++
+			if status == models.WaitlistUnknown {
+				continue
+			}
+			if err := tx.Model(&models.ABACenter{}).Unscoped().Where("id = ?", center.ID).
+				UpdateColumns(map[string]interface{}{"waitlist_status": status, "waitlist_weeks": weeks}).Error; err != nil {
+				return fmt.Errorf("failed to set waitlist of ABA center %s: %w", center.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[MIGRATE] Parsed waitlists of %d ABA centers: %v", len(centers), counts)
+	return nil
+}
+
+// migrateInsuranceText fills the insurance catalog from the free-text
+// insurance_accepted and medi_cal_plans columns of ABA centers: entries of
+// insurance_accepted become carriers, and entries of medi_cal_plans become
+// plans of the Medi-Cal carrier. It runs once, when the catalog tables are
+// created, so carriers deleted later stay deleted. Only entries naming a
+// known carrier or plan are linked; the text columns are kept as notes.
+func migrateInsuranceText(db *gorm.DB) error {
+	var centers []models.ABACenter
+	if err := db.Unscoped().Select("id", "insurance_accepted", "medi_cal_plans").
+		Where("COALESCE(insurance_accepted, '') <> '' OR COALESCE(medi_cal_plans, '') <> ''").
+		Find(&centers).Error; err != nil {
+		return fmt.Errorf("failed to read ABA center insurance: %w", err)
+	}
+	if len(centers) == 0 {
+		return nil
+	}
+
+	var carriers, plans int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		carrierIDs := map[string]int{} // by lowercased name
+		planIDs := map[string]int{}
+		carrierID := func(name string) (int, error) {
+			key := strings.ToLower(name)
+			if id, ok := carrierIDs[key]; ok {
+				return id, nil
+			}
+			carrier := models.InsuranceCarrier{Name: name}
+			if err := tx.Omit("Plans").Create(&carrier).Error; err != nil {
+				return 0, fmt.Errorf("failed to create insurance carrier %q: %w", name, err)
+			}
+			carrierIDs[key] = carrier.ID
+			carriers++
+			return carrier.ID, nil
+		}
+
+		for _, center := range centers {
+			var centerCarriers, centerPlans []int
+			for _, name := range services.ParseInsuranceNames(services.Deref(center.InsuranceAccepted)) {
+				id, err := carrierID(name)
+				if err != nil {
+					return err
+				}
+				centerCarriers = append(centerCarriers, id)
+			}
+			for _, name := range services.ParseInsuranceNames(services.Deref(center.MediCalPlans)) {
+				mediCalID, err := carrierID(services.MediCalCarrier)
+				if err != nil {
+					return err
+				}
+				if strings.EqualFold(name, services.MediCalCarrier) {
+					centerCarriers = append(centerCarriers, mediCalID)
+					continue
+				}
+				key := strings.ToLower(name)
+				id, ok := planIDs[key]
+				if !ok {
+					plan := models.InsurancePlan{CarrierID: mediCalID, Name: name}
+					if err := tx.Create(&plan).Error; err != nil {
+						return fmt.Errorf("failed to create insurance plan %q: %w", name, err)
+					}
+					id, planIDs[key] = plan.ID, plan.ID
+					plans++
+				}
+				centerPlans = append(centerPlans, id)
+			}
+
+			for _, id := range centerCarriers {
+				link := models.ABACenterCarrier{ABACenterID: center.ID, CarrierID: id}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error; err != nil {
+					return fmt.Errorf("failed to link ABA center carrier: %w", err)
+				}
+			}
+			for _, id := range centerPlans {
+				link := models.ABACenterPlan{ABACenterID: center.ID, PlanID: id}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error; err != nil {
+					return fmt.Errorf("failed to link ABA center plan: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[MIGRATE] Parsed insurance of %d ABA centers into %d carriers and %d plans", len(centers), carriers, plans)
+	return nil
+}
+
+// ensureForeignKey adds a foreign key from table.column to refTable(id)
+// unless a constraint with that name exists. Relations to users are
+// excluded from AutoMigrate, which would otherwise migrate users as well.
+func ensureForeignKey(db *gorm.DB, table, constraint, column, refTable, onDelete string) error {
+	var exists bool
+	if err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = ?)", constraint).Scan(&exists).Error; err != nil {
+		return fmt.Errorf("failed to inspect %s constraints: %w", table, err)
+	}
+	if exists {
+		return nil
+	}
+	err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(id) ON DELETE %s",
+		table, constraint, column, refTable, onDelete)).Error
+	if err != nil {
+		return fmt.Errorf("failed to link %s to %s: %w", table, refTable, err)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated environment value, dropping blanks
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// runImport imports a CSV or XLSX file from the command line, logging the
+// outcome of each row, and reports whether every row was valid
+func runImport(service *services.Service, path, entityType string, dryRun bool) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read import file: %v", err)
+	}
+	report, err := service.ImportFile(entityType, filepath.Base(path), data, dryRun)
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	for _, result := range report.Results {
+		if result.Action != services.ImportFailed {
+			log.Printf("Row %d: %s %s", result.Row, result.Action, result.ID)
+			continue
+		}
+		fields := make([]string, 0, len(result.Errors))
+		for field := range result.Errors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			log.Printf("Row %d: %s %s", result.Row, field, result.Errors[field])
+		}
+	}
+
+	outcome := "committed"
+	switch {
+	case report.DryRun:
+		outcome = "dry run, nothing written"
+	case !report.Committed:
+		outcome = "rolled back, nothing written"
+	}
+	log.Printf("Import of %s complete (%s): %d rows, %d created, %d updated, %d unchanged, %d failed",
+		entityType, outcome, report.Rows, report.Created, report.Updated, report.Unchanged, report.Failed)
+	return report.Failed == 0
+}
+
+// seedPermission guards the development seed endpoint
+const seedPermission = "data:seed"
+
+// adminRoute maps an admin endpoint under /api/v1 to the permission, from
+// the permissions table, that it requires
+type adminRoute struct {
+	Method     string
+	Path       string
+	Permission string
+	Handler    gin.HandlerFunc
+}
+
+func adminRoutes(handler *handlers.Handler) []adminRoute {
+	return []adminRoute{
+		// ABA Centers
+		{http.MethodPost, "/aba-centers", "aba_centers:write", handler.CreateABACenter},
+		{http.MethodPut, "/aba-centers/:id", "aba_centers:write", handler.UpdateABACenter},
+		{http.MethodPatch, "/aba-centers/:id", "aba_centers:write", handler.PatchABACenter},
+		{http.MethodDelete, "/aba-centers/:id", "aba_centers:delete", handler.DeleteABACenter},
+		{http.MethodPut, "/aba-centers/:id/insurance", "aba_centers:write", handler.SetABACenterInsurance},
+		{http.MethodPost, "/aba-centers/:id/verify", "aba_centers:write", handler.VerifyRecord("aba_centers", "ABA center")},
+		{http.MethodPost, "/aba-centers/import", "aba_centers:write", handler.ImportFile(services.ImportABACenters)},
+
+		// Resource Centers
+		{http.MethodPost, "/resource-centers", "resource_centers:write", handler.CreateResourceCenter},
+		{http.MethodPut, "/resource-centers/:id", "resource_centers:write", handler.UpdateResourceCenter},
+		{http.MethodDelete, "/resource-centers/:id", "resource_centers:delete", handler.DeleteResourceCenter},
+		{http.MethodPut, "/resource-centers/:id/diagnoses", "resource_centers:write", handler.SetResourceCenterDiagnoses},
+		{http.MethodPost, "/resource-centers/:id/diagnoses/:diagnosisId", "resource_centers:write", handler.AddResourceCenterDiagnosis},
+		{http.MethodDelete, "/resource-centers/:id/diagnoses/:diagnosisId", "resource_centers:write", handler.RemoveResourceCenterDiagnosis},
+		{http.MethodPost, "/resource-centers/:id/verify", "resource_centers:write", handler.VerifyRecord("resource_centers", "Resource center")},
+
+		// Resources
+		{http.MethodPost, "/resources", "resources:write", handler.CreateResource},
+		{http.MethodPut, "/resources/:id", "resources:write", handler.UpdateResource},
+		{http.MethodDelete, "/resources/:id", "resources:delete", handler.DeleteResource},
+		{http.MethodPost, "/resources/:id/verify", "resources:write", handler.VerifyRecord("resources", "Resource")},
+
+		// Regional Centers
+		{http.MethodPost, "/regional-centers", "regional_centers:write", handler.CreateRegionalCenter},
+		{http.MethodPut, "/regional-centers/:id", "regional_centers:write", handler.UpdateRegionalCenter},
+		{http.MethodDelete, "/regional-centers/:id", "regional_centers:delete", handler.DeleteRegionalCenter},
+		{http.MethodPost, "/regional-centers/:id/verify", "regional_centers:write", handler.VerifyRecord("regional_centers", "Regional center")},
+
+		// Providers
+		{http.MethodPost, "/providers", "providers:write", handler.CreateProvider},
+		{http.MethodPut, "/providers/:id", "providers:write", handler.UpdateProvider},
+		{http.MethodDelete, "/providers/:id", "providers:delete", handler.DeleteProvider},
+		{http.MethodPost, "/providers/:id/verify", "providers:write", handler.VerifyRecord("providers", "Provider")},
+		{http.MethodPost, "/providers/import", "providers:write", handler.ImportFile(services.ImportProviders)},
+
+		// Data freshness
+		{http.MethodGet, "/freshness/stale", "freshness:read", handler.GetStaleRecords},
+
+		// Audit log
+		{http.MethodGet, "/audit-logs", "audit:read", handler.GetAuditLogs},
+
+		// Diagnoses
+		{http.MethodPost, "/diagnoses", "diagnoses:write", handler.CreateDiagnosis},
+		{http.MethodPut, "/diagnoses/:id", "diagnoses:write", handler.UpdateDiagnosis},
+		{http.MethodDelete, "/diagnoses/:id", "diagnoses:delete", handler.DeleteDiagnosis},
+
+		// Insurance
+		{http.MethodPost, "/insurance-carriers", "insurance:write", handler.CreateInsuranceCarrier},
+		{http.MethodPut, "/insurance-carriers/:id", "insurance:write", handler.UpdateInsuranceCarrier},
+		{http.MethodDelete, "/insurance-carriers/:id", "insurance:delete", handler.DeleteInsuranceCarrier},
+		{http.MethodPost, "/insurance-carriers/:id/plans", "insurance:write", handler.CreateInsurancePlan},
+		{http.MethodPut, "/insurance-plans/:id", "insurance:write", handler.UpdateInsurancePlan},
+		{http.MethodDelete, "/insurance-plans/:id", "insurance:delete", handler.DeleteInsurancePlan},
+
+		// Users
+		{http.MethodGet, "/users", "users:manage", handler.GetUsers},
+		{http.MethodGet, "/users/:id", "users:manage", handler.GetUser},
+		{http.MethodPost, "/users", "users:manage", handler.InviteUser},
+		{http.MethodPost, "/users/:id/disable", "users:manage", handler.DisableUser},
+		{http.MethodPost, "/users/:id/enable", "users:manage", handler.EnableUser},
+		{http.MethodPost, "/users/:id/reset-password", "users:manage", handler.ResetUserPassword},
+		{http.MethodPut, "/users/:id/roles", "roles:manage", handler.SetUserRoles},
+		{http.MethodPost, "/users/:id/roles/:roleId", "roles:manage", handler.AddUserRole},
+		{http.MethodDelete, "/users/:id/roles/:roleId", "roles:manage", handler.RemoveUserRole},
+
+		// Contact form inbox
+		{http.MethodGet, "/form-submissions", "submissions:read", handler.GetFormSubmissions},
+		{http.MethodGet, "/form-submissions/export", "submissions:read", handler.ExportFormSubmissions},
+		{http.MethodGet, "/form-submissions/:id", "submissions:read", handler.GetFormSubmission},
+		{http.MethodPatch, "/form-submissions/:id", "submissions:manage", handler.PatchFormSubmission},
+		{http.MethodPost, "/form-submissions/:id/notes", "submissions:manage", handler.AddSubmissionNote},
+
+		// Roles and permissions
+		{http.MethodGet, "/roles", "roles:manage", handler.GetRoles},
+		{http.MethodPost, "/roles", "roles:manage", handler.CreateRole},
+		{http.MethodDelete, "/roles/:id", "roles:manage", handler.DeleteRole},
+		{http.MethodPut, "/roles/:id/permissions", "roles:manage", handler.SetRolePermissions},
+		{http.MethodGet, "/permissions", "roles:manage", handler.GetPermissions},
+	}
+}
+
+// adminPermissions lists the distinct permissions used by admin endpoints
+func adminPermissions(handler *handlers.Handler) []string {
+	seen := map[string]bool{seedPermission: true}
+	names := []string{seedPermission}
+	for _, route := range adminRoutes(handler) {
+		if !seen[route.Permission] {
+			seen[route.Permission] = true
+			names = append(names, route.Permission)
+		}
+	}
+	return names
+}
+
+func setupRouter(handler *handlers.Handler, service *services.Service, db *gorm.DB, formLimiter *middleware.RateLimiter) *gin.Engine {
 	r := gin.Default()
 
+	// Client IPs (rate limits, sessions, submissions) only honour
+	// X-Forwarded-For from these proxies; gin trusts every proxy by default
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		if err := r.SetTrustedProxies(splitList(proxies)); err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES %q: %v", proxies, err)
+		}
+	}
+
 	r.Use(cors.New(cors.Config{
 		AllowOrigins: []string{
 			"http://localhost:5173", // Vite default dev server
@@ -92,6 +590,7 @@ func setupRouter(handler *handlers.Handler, service *services.Service, db *gorm.
 			"GET",
 			"POST",
 			"PUT",
+			"PATCH",
 			"DELETE",
 			"OPTIONS",
 		},
@@ -101,32 +600,49 @@ func setupRouter(handler *handlers.Handler, service *services.Service, db *gorm.
 			"Content-Length",
 			"Accept",
 			"Authorization",
+			middleware.RequestIDHeader,
 		},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "Link", middleware.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// Every request gets an id, echoed in the response and the audit log
+	r.Use(middleware.RequestID())
+
 	// Serve static files for the frontend
 	r.Static("/assets", "./dist/assets")
 	r.Static("/js", "./dist/js")
 	r.Static("/css", "./dist/css")
 	r.StaticFile("/favicon.ico", "./dist/favicon.ico")
 
+	// Authentication attaches the signed-in user to API requests
+	authenticate := middleware.Authenticate(service)
+
 	// API routes for ABA centers and resources
-	api := r.Group("/api/v1")
+	api := r.Group("/api/v1", authenticate)
 	{
+		// Authentication
+		api.POST("/auth/login", handler.Login)
+		api.POST("/auth/refresh", handler.Refresh)
+		api.POST("/auth/logout", handler.Logout)
+		api.POST("/auth/set-password", handler.SetPassword)
+		api.GET("/auth/me", middleware.RequireUser(), handler.Me)
+
 		// User preferences
-		api.GET("/preferences/:userId", handler.GetUserPreferences)
-		api.PUT("/preferences/:userId", handler.UpdateUserPreferences)
+		api.GET("/preferences/me", handler.GetMyPreferences)
+		api.PUT("/preferences/me", handler.UpdateMyPreferences)
+		api.PATCH("/preferences/me", handler.PatchMyPreferences)
 
 		// ABA Centers
 		api.GET("/aba-centers", handler.GetABACenters)
+		api.GET("/aba-centers/export", handler.ExportABACenters)
 		api.GET("/aba-centers/:id", handler.GetABACenter)
-		api.POST("/aba-centers", handler.CreateABACenter) // Admin function
+		api.GET("/aba-centers/:id/waitlist-history", handler.GetWaitlistHistory)
 
 		// Resource Centers
 		api.GET("/resource-centers", handler.GetResourceCenters)
+		api.GET("/resource-centers/export", handler.ExportResourceCenters)
 		api.GET("/resource-centers/:id", handler.GetResourceCenter)
 
 		// Resources
@@ -142,11 +658,22 @@ func setupRouter(handler *handlers.Handler, service *services.Service, db *gorm.
 		// Diagnoses
 		api.GET("/diagnoses", handler.GetDiagnoses)
 
+		// Insurance
+		api.GET("/insurance-carriers", handler.GetInsuranceCarriers)
+
 		// Form submissions
-		api.POST("/form-submissions", handler.CreateFormSubmission)
+		api.GET("/form-token", handler.GetFormToken)
+		api.POST("/form-submissions", middleware.RateLimit(formLimiter), handler.CreateFormSubmission)
 
 		// Search endpoints
+		api.GET("/search", handler.Search)
 		api.GET("/search/nearby", handler.SearchNearby)
+		api.GET("/search/nearby/export", handler.ExportNearby)
+	}
+
+	// Admin functions, each guarded by the permission named in adminRoutes
+	for _, route := range adminRoutes(handler) {
+		api.Handle(route.Method, route.Path, middleware.RequirePermission(service, route.Permission), route.Handler)
 	}
 
 	// Health check endpoint
@@ -159,7 +686,7 @@ func setupRouter(handler *handlers.Handler, service *services.Service, db *gorm.
 	})
 
 	// Seed data endpoint (for development)
-	r.POST("/seed", func(c *gin.Context) {
+	r.POST("/seed", authenticate, middleware.RequirePermission(service, seedPermission), func(c *gin.Context) {
 		if err := service.SeedSampleData(); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to seed data"})
 			return
@@ -193,6 +720,13 @@ func setupRouter(handler *handlers.Handler, service *services.Service, db *gorm.
 }
 
 func main() {
+	backfillOnly := flag.Bool("backfill", false, "geocode records missing coordinates, report and exit")
+	createAdmin := flag.String("create-admin", "", "create or update an admin user with this email, using the ADMIN_PASSWORD password, and exit")
+	importFile := flag.String("import", "", "import a CSV or XLSX file of -import-type records, report each row and exit")
+	importType := flag.String("import-type", services.ImportABACenters, "what -import holds: "+strings.Join(services.ImportEntityTypes, " or "))
+	dryRun := flag.Bool("dry-run", false, "with -import, check the file and report without writing")
+	flag.Parse()
+
 	logFile, err := initLogger()
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
@@ -216,9 +750,81 @@ func main() {
 		log.Fatal("DSN is not set")
 	}
 
+	backfillInterval := time.Hour
+	if v := os.Getenv("GEOCODE_BACKFILL_INTERVAL"); v != "" {
+		if backfillInterval, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("Invalid GEOCODE_BACKFILL_INTERVAL %q: %v", v, err)
+		}
+	}
+
+	permissionCacheTTL := services.DefaultPermissionCacheTTL
+	if v := os.Getenv("PERMISSION_CACHE_TTL"); v != "" {
+		if permissionCacheTTL, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("Invalid PERMISSION_CACHE_TTL %q: %v", v, err)
+		}
+	}
+
+	appBaseURL := os.Getenv("APP_BASE_URL")
+	if appBaseURL == "" {
+		appBaseURL = "http://localhost:5173"
+	}
+
 	cfg := &config.Config{
-		GoogleMapsAPIKey: os.Getenv("GOOGLE_MAPS_API_KEY"),
-		DSN:              os.Getenv("DSN"),
+		GoogleMapsAPIKey:   os.Getenv("GOOGLE_MAPS_API_KEY"),
+		DSN:                os.Getenv("DSN"),
+		BackfillInterval:   backfillInterval,
+		PermissionCacheTTL: permissionCacheTTL,
+		AppBaseURL:         strings.TrimRight(appBaseURL, "/"),
+		DeviceCookieSecret: []byte(os.Getenv("DEVICE_COOKIE_SECRET")),
+		FormMinFillTime:    services.DefaultFormMinFillTime,
+	}
+	if v := os.Getenv("FORM_MIN_FILL_TIME"); v != "" {
+		if cfg.FormMinFillTime, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("Invalid FORM_MIN_FILL_TIME %q: %v", v, err)
+		}
+	}
+	if len(cfg.DeviceCookieSecret) == 0 {
+		log.Printf("DEVICE_COOKIE_SECRET is not set; anonymous preferences will be forgotten on restart")
+		cfg.DeviceCookieSecret = make([]byte, 32)
+		if _, err := rand.Read(cfg.DeviceCookieSecret); err != nil {
+			log.Fatalf("Failed to generate device cookie secret: %v", err)
+		}
+	}
+
+	mail, err := mailer.New(mailer.Config{
+		Driver:       os.Getenv("MAIL_DRIVER"),
+		From:         os.Getenv("MAIL_FROM"),
+		Dir:          os.Getenv("MAIL_DIR"),
+		SMTPAddr:     os.Getenv("SMTP_ADDR"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure mail: %v", err)
+	}
+
+	submissionNotifier, err := notifier.New(notifier.Config{
+		Drivers:       splitList(os.Getenv("SUBMISSION_NOTIFIERS")),
+		File:          os.Getenv("SUBMISSION_NOTIFY_FILE"),
+		EmailTo:       splitList(os.Getenv("SUBMISSION_NOTIFY_EMAIL")),
+		WebhookURL:    os.Getenv("SUBMISSION_WEBHOOK_URL"),
+		WebhookSecret: os.Getenv("SUBMISSION_WEBHOOK_SECRET"),
+	}, mail)
+	if err != nil {
+		log.Fatalf("Failed to configure submission notifications: %v", err)
+	}
+
+	formRateLimit := 5
+	if v := os.Getenv("FORM_RATE_LIMIT"); v != "" {
+		if formRateLimit, err = strconv.Atoi(v); err != nil || formRateLimit < 1 {
+			log.Fatalf("Invalid FORM_RATE_LIMIT %q", v)
+		}
+	}
+	formRateWindow := time.Hour
+	if v := os.Getenv("FORM_RATE_WINDOW"); v != "" {
+		if formRateWindow, err = time.ParseDuration(v); err != nil || formRateWindow <= 0 {
+			log.Fatalf("Invalid FORM_RATE_WINDOW %q", v)
+		}
 	}
 
 	db, err := initDB(cfg.DSN)
@@ -227,9 +833,59 @@ func main() {
 	}
 
 	service := services.NewService(db, cfg)
-	handler := handlers.NewHandler(service, db)
+	service.SetMailer(mail)
+	service.SetNotifier(submissionNotifier)
+	if err := service.InitSpatial(); err != nil {
+		log.Printf("Failed to initialize spatial support: %v", err)
+	}
+	if err := service.InitSearch(); err != nil {
+		log.Printf("Failed to initialize full-text search: %v", err)
+	}
+	if err := service.InitAudit(); err != nil {
+		log.Fatalf("Failed to initialize audit log: %v", err)
+	}
 
-	r := setupRouter(handler, service, db)
+	if *createAdmin != "" {
+		user, err := service.EnsureAdmin(*createAdmin, os.Getenv("ADMIN_PASSWORD"))
+		if err != nil {
+			log.Fatalf("Failed to create admin user: %v", err)
+		}
+		log.Printf("Admin user %d (%s) is ready", user.ID, user.Email)
+		return
+	}
+
+	if *importFile != "" {
+		if !runImport(service, *importFile, *importType, *dryRun) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *backfillOnly {
+		report, err := service.BackfillCoordinates(context.Background())
+		if err != nil {
+			log.Fatalf("Coordinate backfill failed: %v", err)
+		}
+		for _, failure := range report.Failures {
+			log.Printf("Backfill failure: %s", failure)
+		}
+		log.Printf("Coordinate backfill complete: %d parsed, %d geocoded, %d locations set, %d failed",
+			report.Parsed, report.Geocoded, report.Located, report.Failed)
+		return
+	}
+	handler := handlers.NewHandler(handlers.FromServices(service))
+
+	// Make sure every permission used by the route table exists and the
+	// admin role holds it
+	if err := service.EnsurePermissions(adminPermissions(handler)); err != nil {
+		log.Printf("Failed to set up permissions: %v", err)
+	}
+	if err := service.InitPermissionTriggers(); err != nil {
+		log.Printf("Failed to install permission triggers, cached permissions expire after %s: %v",
+			cfg.PermissionCacheTTL, err)
+	}
+
+	r := setupRouter(handler, service, db, middleware.NewRateLimiter(formRateLimit, formRateWindow))
 
 	port := os.Getenv("APP_PORT")
 	if port == "" {
@@ -251,6 +907,14 @@ func main() {
 		}
 	}()
 
+	// Geocode records missing coordinates in the background
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	if cfg.BackfillInterval > 0 {
+		service.StartBackfillWorker(workerCtx, cfg.BackfillInterval)
+	}
+	service.ListenForPermissionChanges(workerCtx)
+
 	// Seed sample data in development
 	if os.Getenv("APP_ENV") != "production" {
 		go func() {
@@ -268,6 +932,7 @@ func main() {
 
 	log.Printf("Received shutdown signal: %v", sig)
 	log.Println("Shutting down server...")
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
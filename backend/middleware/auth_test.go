@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/services"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// fakeAuthenticator signs in user 1 with "admin-token", user 2 with
+// "user-token" and user 3 with "unchecked-token"; "broken" fails as a
+// database error would. Only user 1 may edit, and user 3's permissions
+// can't be loaded.
+type fakeAuthenticator struct{}
+
+func (fakeAuthenticator) Authenticate(accessToken string) (*models.User, error) {
+	switch accessToken {
+	case "admin-token":
+		return &models.User{ID: 1}, nil
+	case "user-token":
+		return &models.User{ID: 2}, nil
+	case "unchecked-token":
+		return &models.User{ID: 3}, nil
+	case "broken":
+		return nil, errors.New("connection refused")
+	}
+	return nil, services.ErrInvalidToken
+}
+
+func (fakeAuthenticator) UserPermissions(userID int) (map[string]bool, error) {
+	switch userID {
+	case 1:
+		return map[string]bool{"facilities:write": true}, nil
+	case 3:
+		return nil, errors.New("connection refused")
+	}
+	return map[string]bool{}, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	r := gin.New()
+	r.Use(Authenticate(fakeAuthenticator{}))
+	r.GET("/public", func(c *gin.Context) {
+		if user, ok := CurrentUser(c); ok {
+			c.String(http.StatusOK, "user %d", user.ID)
+			return
+		}
+		c.String(http.StatusOK, "anonymous")
+	})
+	r.GET("/signed-in", RequireUser(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/admin", RequirePermission(fakeAuthenticator{}, "facilities:write"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		status        int
+		body          string
+	}{
+		{"anonymous", "/public", "", http.StatusOK, "anonymous"},
+		{"signed in", "/public", "Bearer user-token", http.StatusOK, "user 2"},
+		{"lowercase scheme", "/public", "bearer user-token", http.StatusOK, "user 2"},
+		{"other scheme", "/public", "Basic dXNlcjpwYXNz", http.StatusOK, "anonymous"},
+		{"invalid token", "/public", "Bearer expired", http.StatusOK, "anonymous"},
+		{"authenticator failure", "/public", "Bearer broken", http.StatusInternalServerError, ""},
+		{"user required", "/signed-in", "", http.StatusUnauthorized, ""},
+		{"user present", "/signed-in", "Bearer user-token", http.StatusOK, ""},
+		{"user with invalid token", "/signed-in", "Bearer expired", http.StatusUnauthorized, ""},
+		{"permission required", "/admin", "", http.StatusUnauthorized, ""},
+		{"permission missing", "/admin", "Bearer user-token", http.StatusForbidden, ""},
+		{"permission granted", "/admin", "Bearer admin-token", http.StatusOK, ""},
+		{"permission check failure", "/admin", "Bearer unchecked-token", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body, tt.body)
+			}
+		})
+	}
+}
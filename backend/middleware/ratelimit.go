@@ -0,0 +1,95 @@
+// middleware/ratelimit.go
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter allows each key a burst of limit requests, refilled evenly
+// over window (a token bucket per key)
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   float64
+	window  time.Duration
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter creates a RateLimiter allowing limit requests per window
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   float64(limit),
+		window:  window,
+		buckets: map[string]*bucket{},
+		swept:   time.Now(),
+	}
+}
+
+// Allow takes a token from key's bucket, reporting whether there was one and
+// otherwise how long until there will be
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.limit, updated: now}
+		l.buckets[key] = b
+	}
+	rate := l.limit / l.window.Seconds() // tokens per second
+	b.tokens = math.Min(l.limit, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have refilled completely, at most once a window
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.window {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= l.window {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
+
+// RateLimit rejects requests from a client IP over the limiter's rate with
+// 429 and a Retry-After header
+func RateLimit(limiter *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, retryAfter := limiter.Allow(c.ClientIP())
+		if !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			log.Printf("[RATE_LIMIT] %s %s from %s rejected", c.Request.Method, c.FullPath(), c.ClientIP())
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many requests, please try again later",
+				"retry_after": seconds,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(2, time.Minute)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d was limited, want the burst allowed", i+1)
+		}
+	}
+	ok, retryAfter := l.Allow("a")
+	if ok || retryAfter <= 0 || retryAfter > 30*time.Second {
+		t.Errorf("third request = %t, retry after %s, want limited for up to half the window", ok, retryAfter)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("another key was limited")
+	}
+
+	// Tokens refill over the window
+	l.buckets["a"].updated = l.buckets["a"].updated.Add(-31 * time.Second)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("request after half the window was limited, want a refilled token")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	r := gin.New()
+	r.POST("/form", RateLimit(NewRateLimiter(1, time.Minute)), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/form", nil))
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusCreated {
+		t.Fatalf("first request status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	w := send()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("second request = %d, Retry-After %q, want %d after 60 seconds",
+			w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,37 @@
+// middleware/requestid.go
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the id of a request, both ways
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the gin context key of the request id
+const requestIDKey = "requestID"
+
+// validRequestID limits the ids accepted from clients and proxies
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// RequestID gives every request an id, taken from the X-Request-ID header
+// when a proxy already set a sensible one, and echoes it in the response
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// CurrentRequestID returns the id attached by RequestID
+func CurrentRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestRequestID(t *testing.T) {
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, CurrentRequestID(c))
+	})
+
+	tests := []struct {
+		header string
+		keep   bool
+	}{
+		{"", false},
+		{"abc-123.edge:1", true},
+		{"has spaces", false},
+		{strings.Repeat("a", 65), false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		id := w.Body.String()
+		if w.Header().Get(RequestIDHeader) != id {
+			t.Errorf("%q: header %q, want the request id %q echoed", tt.header, w.Header().Get(RequestIDHeader), id)
+		}
+		if tt.keep && id != tt.header {
+			t.Errorf("%q: id = %q, want the client's id kept", tt.header, id)
+		}
+		if _, err := uuid.Parse(id); !tt.keep && err != nil {
+			t.Errorf("%q: id = %q, want a new UUID", tt.header, id)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// StringArray is a one-dimensional Postgres text or uuid array. The pgx
+// driver returns array columns as their text form, which database/sql can't
+// scan into a plain []string.
+type StringArray []string
+
+// Scan implements the sql.Scanner interface, parsing the text form of an
+// array such as {a,"b c"}
+func (a *StringArray) Scan(value interface{}) error {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("cannot scan %T into StringArray", value)
+	}
+
+	if len(text) < 2 || text[0] != '{' || text[len(text)-1] != '}' {
+		return fmt.Errorf("invalid array %q", text)
+	}
+	elements := StringArray{}
+	for rest := text[1 : len(text)-1]; rest != ""; {
+		element, quoted, remainder, err := nextArrayElement(rest)
+		if err != nil {
+			return fmt.Errorf("invalid array %q: %w", text, err)
+		}
+		if !quoted && strings.EqualFold(element, "NULL") {
+			return fmt.Errorf("invalid array %q: NULL elements are not supported", text)
+		}
+		elements = append(elements, element)
+		if remainder == "" {
+			break
+		}
+		if rest = remainder[1:]; rest == "" {
+			return fmt.Errorf("invalid array %q: trailing comma", text)
+		}
+	}
+	*a = elements
+	return nil
+}
+
+// nextArrayElement reads the element at the start of text, returning it
+// unquoted and the remaining text starting at the following comma
+func nextArrayElement(text string) (element string, quoted bool, remainder string, err error) {
+	if text[0] == '{' {
+		return "", false, "", fmt.Errorf("multidimensional arrays are not supported")
+	}
+	if text[0] != '"' {
+		end := strings.IndexByte(text, ',')
+		if end < 0 {
+			end = len(text)
+		}
+		return strings.TrimSpace(text[:end]), false, text[end:], nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '\\':
+			if i++; i == len(text) {
+				return "", true, "", fmt.Errorf("unterminated escape")
+			}
+			b.WriteByte(text[i])
+		case '"':
+			remainder = text[i+1:]
+			if remainder != "" && remainder[0] != ',' {
+				return "", true, "", fmt.Errorf("unexpected %q after quoted element", remainder[0])
+			}
+			return b.String(), true, remainder, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", true, "", fmt.Errorf("unterminated quoted element")
+}
+
+// Value implements the driver.Valuer interface, encoding the array in its
+// text form with every element quoted
+func (a StringArray) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, element := range a {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		for j := 0; j < len(element); j++ {
+			if c := element[j]; c == '"' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(element[j])
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
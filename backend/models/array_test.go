@@ -0,0 +1,80 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStringArrayScan(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  StringArray
+	}{
+		{"NULL", nil, nil},
+		{"empty", "{}", StringArray{}},
+		{"uuid[]", "{3f1c1f0e-8a43-4c4b-9a53-1c1e4f0b2a10,0b7e5c9a-2d4f-4e4e-8d1e-6c2b9f1a3e77}",
+			StringArray{"3f1c1f0e-8a43-4c4b-9a53-1c1e4f0b2a10", "0b7e5c9a-2d4f-4e4e-8d1e-6c2b9f1a3e77"}},
+		{"bytes", []byte("{Autism,ADHD}"), StringArray{"Autism", "ADHD"}},
+		{"quoted", `{"Down syndrome","a,b","say \"hi\"","back\\slash"}`,
+			StringArray{"Down syndrome", "a,b", `say "hi"`, `back\slash`}},
+		{"quoted NULL", `{"NULL"}`, StringArray{"NULL"}},
+		{"empty string", `{""}`, StringArray{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a StringArray
+			if err := a.Scan(tt.value); err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if !reflect.DeepEqual(a, tt.want) {
+				t.Errorf("Scan = %q, want %q", a, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringArrayScanErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"unsupported type", 42},
+		{"not an array", "Autism"},
+		{"NULL element", "{a,NULL}"},
+		{"multidimensional", "{{a,b},{c,d}}"},
+		{"trailing comma", "{a,}"},
+		{"unterminated quote", `{"a}`},
+		{"text after quote", `{"a"b}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a StringArray
+			if err := a.Scan(tt.value); err == nil {
+				t.Errorf("Scan(%v) = %q, want an error", tt.value, a)
+			}
+		})
+	}
+}
+
+func TestStringArrayValueRoundTrip(t *testing.T) {
+	for _, want := range []StringArray{{}, {"Autism", "a,b", `say "hi"`, `back\slash`, "", "NULL", "{x}"}} {
+		value, err := want.Value()
+		if err != nil {
+			t.Fatalf("Value: %v", err)
+		}
+		var got StringArray
+		if err := got.Scan(value); err != nil {
+			t.Fatalf("Scan(%v): %v", value, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip of %v = %q, want %q", value, got, want)
+		}
+	}
+
+	if value, err := StringArray(nil).Value(); value != nil || err != nil {
+		t.Errorf("nil Value = %v, %v, want NULL", value, err)
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreshnessAt(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		at := now.Add(-d)
+		return &at
+	}
+
+	tests := []struct {
+		verifiedAt *time.Time
+		want       string
+	}{
+		{nil, FreshnessUnverified},
+		{ago(0), FreshnessFresh},
+		{ago(FreshFor), FreshnessFresh},
+		{ago(FreshFor + time.Hour), FreshnessAging},
+		{ago(StaleAfter), FreshnessAging},
+		{ago(StaleAfter + time.Hour), FreshnessStale},
+	}
+
+	for _, tt := range tests {
+		if got := FreshnessAt(tt.verifiedAt, now); got != tt.want {
+			t.Errorf("FreshnessAt(%v) = %q, want %q", tt.verifiedAt, got, tt.want)
+		}
+	}
+}
@@ -6,35 +6,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// Custom Point type for PostGIS geometry support (simplified)
-type Point struct {
-	Lat float64 `json:"lat"`
-	Lng float64 `json:"lng"`
-}
-
-// Scan implements the sql.Scanner interface
-func (p *Point) Scan(value interface{}) error {
-	if value == nil {
-		return nil
-	}
-
-	// For now, we'll let PostgreSQL handle the geometry/geography conversion
-	// and just store lat/lng as separate fields in the database queries
-	return nil
-}
-
-// Value implements the driver.Valuer interface
-func (p Point) Value() (driver.Value, error) {
-	if p.Lat == 0 && p.Lng == 0 {
-		return nil, nil
-	}
-	// For now, return nil to avoid complex PostGIS integration
-	// The database already has the geometry data
-	return nil, nil
-}
-
 // ContactInfo represents JSON contact information
 type ContactInfo map[string]interface{}
 
@@ -61,23 +35,135 @@ func (c ContactInfo) Value() (driver.Value, error) {
 	return json.Marshal(c)
 }
 
+// Freshness of a record, from how long ago it was last verified
+const (
+	FreshnessFresh      = "fresh"      // verified within FreshFor
+	FreshnessAging      = "aging"      // verified within StaleAfter
+	FreshnessStale      = "stale"      // verified longer ago
+	FreshnessUnverified = "unverified" // never verified
+)
+
+// Freshness thresholds
+const (
+	FreshFor   = 180 * 24 * time.Hour
+	StaleAfter = 365 * 24 * time.Hour
+)
+
+// Verification records when staff last confirmed a facility's details
+// against the facility itself. It is embedded in every facility model.
+type Verification struct {
+	LastVerifiedAt *time.Time `json:"last_verified_at" gorm:"index"`
+	VerifiedByID   *int       `json:"verified_by_id"` // references users.id
+	Freshness      string     `json:"freshness" gorm:"-"`
+}
+
+// FreshnessAt returns the freshness of a record last verified at verifiedAt
+// as of now
+func FreshnessAt(verifiedAt *time.Time, now time.Time) string {
+	switch {
+	case verifiedAt == nil:
+		return FreshnessUnverified
+	case now.Sub(*verifiedAt) <= FreshFor:
+		return FreshnessFresh
+	case now.Sub(*verifiedAt) <= StaleAfter:
+		return FreshnessAging
+	}
+	return FreshnessStale
+}
+
+// AfterFind sets Freshness on loaded records
+func (v *Verification) AfterFind(*gorm.DB) error {
+	v.Freshness = FreshnessAt(v.LastVerifiedAt, time.Now())
+	return nil
+}
+
+// BeforeCreate drops verification sent with a new record; records are only
+// verified once they exist
+func (v *Verification) BeforeCreate(*gorm.DB) error {
+	v.LastVerifiedAt, v.VerifiedByID = nil, nil
+	return nil
+}
+
+// AfterSave sets Freshness on written records
+func (v *Verification) AfterSave(*gorm.DB) error {
+	v.Freshness = FreshnessAt(v.LastVerifiedAt, time.Now())
+	return nil
+}
+
 // ABACenter represents an ABA therapy center
 type ABACenter struct {
-	ID                   uuid.UUID  `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Name                 string     `json:"name" gorm:"not null"`
-	Street               string     `json:"street" gorm:"not null"`
-	City                 string     `json:"city" gorm:"not null"`
-	Zip                  string     `json:"zip" gorm:"not null"`
-	Phone                string     `json:"phone" gorm:"not null"`
-	ServiceType          string     `json:"service_type" gorm:"not null"`
-	WaitlistAvailability *string    `json:"waitlist_availability"`
-	WaitlistNotes        *string    `json:"waitlist_notes"`
-	DxVerification       *string    `json:"dx_verification"`
-	InsuranceAccepted    *string    `json:"insurance_accepted"`
-	MediCalPlans         *string    `json:"medi_cal_plans"`
-	Notes                *string    `json:"notes"`
-	CreatedAt            *time.Time `json:"created_at"`
-	UpdatedAt            *time.Time `json:"updated_at"`
+	ID                   uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name                 string         `json:"name" gorm:"not null"`
+	Street               string         `json:"street" gorm:"not null"`
+	City                 string         `json:"city" gorm:"not null"`
+	Zip                  string         `json:"zip" gorm:"not null"`
+	Phone                string         `json:"phone" gorm:"not null"`
+	ServiceType          string         `json:"service_type" gorm:"not null"`
+	WaitlistAvailability *string        `json:"waitlist_availability"`
+	WaitlistNotes        *string        `json:"waitlist_notes"`
+	WaitlistStatus       string         `json:"waitlist_status" gorm:"type:varchar(16);not null;default:'unknown';index"`
+	WaitlistWeeks        *int           `json:"waitlist_weeks"`      // estimated wait, for short and long waitlists
+	WaitlistUpdatedAt    *time.Time     `json:"waitlist_updated_at"` // when status or weeks last changed
+	DxVerification       *string        `json:"dx_verification"`
+	InsuranceAccepted    *string        `json:"insurance_accepted"`
+	MediCalPlans         *string        `json:"medi_cal_plans"`
+	Notes                *string        `json:"notes"`
+	Latitude             *float64       `json:"latitude"`
+	Longitude            *float64       `json:"longitude"`
+	Location             *Point         `json:"location" gorm:"type:geography(POINT,4326)"`
+	CreatedAt            *time.Time     `json:"created_at"`
+	UpdatedAt            *time.Time     `json:"updated_at"` // set on every write, used for optimistic concurrency
+	DeletedAt            gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Verification
+
+	// Distance from the search point, only set for radius searches
+	DistanceMiles *float64 `json:"distance_miles,omitempty" gorm:"->;-:migration"`
+
+	// Accepted insurance. A carrier link means the center takes the carrier's
+	// plans in general; a plan link names one plan specifically.
+	Carriers []InsuranceCarrier `json:"carriers" gorm:"many2many:aba_center_carriers;foreignKey:ID;joinForeignKey:aba_center_id;References:ID;joinReferences:carrier_id"`
+	Plans    []InsurancePlan    `json:"plans" gorm:"many2many:aba_center_plans;foreignKey:ID;joinForeignKey:aba_center_id;References:ID;joinReferences:plan_id"`
+}
+
+// Waitlist statuses of an ABA center
+const (
+	WaitlistOpen    = "open"    // taking new clients without a wait
+	WaitlistShort   = "short"   // short waitlist
+	WaitlistLong    = "long"    // long waitlist
+	WaitlistClosed  = "closed"  // not taking new clients
+	WaitlistUnknown = "unknown" // not known
+)
+
+// WaitlistStatuses lists the valid waitlist statuses
+var WaitlistStatuses = []string{WaitlistOpen, WaitlistShort, WaitlistLong, WaitlistClosed, WaitlistUnknown}
+
+// WaitlistChange records a change to the waitlist status or estimated wait
+// of an ABA center
+type WaitlistChange struct {
+	ID             int       `json:"id" gorm:"primaryKey"`
+	ABACenterID    uuid.UUID `json:"aba_center_id" gorm:"type:uuid;not null;index"`
+	Status         string    `json:"status" gorm:"type:varchar(16);not null"`
+	Weeks          *int      `json:"weeks"`
+	PreviousStatus string    `json:"previous_status" gorm:"type:varchar(16);not null"`
+	PreviousWeeks  *int      `json:"previous_weeks"`
+	ChangedAt      time.Time `json:"changed_at" gorm:"not null"`
+}
+
+// InsuranceCarrier is an insurer or public program, e.g. Kaiser Permanente
+// or Medi-Cal
+type InsuranceCarrier struct {
+	ID    int             `json:"id" gorm:"primaryKey"`
+	Name  string          `json:"name" gorm:"type:varchar(255);not null;uniqueIndex"`
+	Plans []InsurancePlan `json:"plans,omitempty" gorm:"foreignKey:CarrierID"`
+}
+
+// InsurancePlan is a plan offered by a carrier, e.g. a Medi-Cal managed care
+// plan
+type InsurancePlan struct {
+	ID        int    `json:"id" gorm:"primaryKey"`
+	CarrierID int    `json:"carrier_id" gorm:"not null;uniqueIndex:idx_insurance_plans_carrier_name"`
+	Name      string `json:"name" gorm:"type:varchar(255);not null;uniqueIndex:idx_insurance_plans_carrier_name"`
 }
 
 // Diagnosis represents a medical diagnosis
@@ -92,7 +178,25 @@ type FormSubmission struct {
 	Name      string    `json:"name" gorm:"type:varchar(255);not null"`
 	Email     string    `json:"email" gorm:"type:varchar(255);not null"`
 	Message   string    `json:"message" gorm:"type:text;not null"`
+	IPAddress *string   `json:"ip_address,omitempty" gorm:"type:varchar(45)"`
 	CreatedAt time.Time `json:"created_at" gorm:"default:CURRENT_TIMESTAMP"`
+
+	// Inbox state, managed by staff
+	ReadAt       *time.Time       `json:"read_at"`
+	HandledAt    *time.Time       `json:"handled_at"`
+	AssignedToID *int             `json:"assigned_to_id" gorm:"index"`
+	AssignedTo   *User            `json:"assigned_to,omitempty" gorm:"foreignKey:AssignedToID;-:migration"`
+	Notes        []SubmissionNote `json:"notes,omitempty" gorm:"foreignKey:SubmissionID;-:migration"`
+}
+
+// SubmissionNote is an internal staff note on a form submission
+type SubmissionNote struct {
+	ID           int       `json:"id" gorm:"primaryKey"`
+	SubmissionID int       `json:"submission_id" gorm:"not null;index"`
+	AuthorID     *int      `json:"author_id"`
+	Author       *User     `json:"author,omitempty" gorm:"foreignKey:AuthorID;-:migration"`
+	Body         string    `json:"body" gorm:"type:text;not null"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 // Permission represents system permissions
@@ -105,32 +209,41 @@ type Permission struct {
 
 // Provider represents service providers
 type Provider struct {
-	ID                  int      `json:"id" gorm:"primaryKey"`
-	Name                string   `json:"name" gorm:"not null"`
-	Phone               *string  `json:"phone"`
-	CoverageAreas       *string  `json:"coverage_areas"`
-	CenterBasedServices *string  `json:"center_based_services"`
-	Areas               []string `json:"areas" gorm:"type:text[]"`
+	ID                  int         `json:"id" gorm:"primaryKey"`
+	Name                string      `json:"name" gorm:"not null"`
+	Phone               *string     `json:"phone"`
+	CoverageAreas       *string     `json:"coverage_areas"`
+	CenterBasedServices *string     `json:"center_based_services"`
+	Areas               StringArray `json:"areas" gorm:"type:text[]"`
+	UpdatedAt           *time.Time  `json:"updated_at" gorm:"default:CURRENT_TIMESTAMP"`
+
+	Verification
 }
 
 // RegionalCenter represents regional centers with geospatial data
 type RegionalCenter struct {
-	ID                       int      `json:"id" gorm:"primaryKey"`
-	RegionalCenter           *string  `json:"regional_center"`
-	OfficeType               *string  `json:"office_type"`
-	Address                  *string  `json:"address"`
-	Suite                    *string  `json:"suite"`
-	City                     *string  `json:"city"`
-	State                    *string  `json:"state"`
-	ZipCode                  *string  `json:"zip_code"`
-	Telephone                *string  `json:"telephone"`
-	Website                  *string  `json:"website"`
-	CountyServed             *string  `json:"county_served"`
-	LosAngelesHealthDistrict *string  `json:"los_angeles_health_district"`
-	LocationCoordinates      *string  `json:"location_coordinates"`
-	Latitude                 *float64 `json:"latitude"`
-	Longitude                *float64 `json:"longitude"`
-	Location                 *Point   `json:"location" gorm:"type:geography(POINT,4326)"`
+	ID                       int        `json:"id" gorm:"primaryKey"`
+	RegionalCenter           *string    `json:"regional_center"`
+	OfficeType               *string    `json:"office_type"`
+	Address                  *string    `json:"address"`
+	Suite                    *string    `json:"suite"`
+	City                     *string    `json:"city"`
+	State                    *string    `json:"state"`
+	ZipCode                  *string    `json:"zip_code"`
+	Telephone                *string    `json:"telephone"`
+	Website                  *string    `json:"website"`
+	CountyServed             *string    `json:"county_served"`
+	LosAngelesHealthDistrict *string    `json:"los_angeles_health_district"`
+	LocationCoordinates      *string    `json:"location_coordinates"`
+	Latitude                 *float64   `json:"latitude"`
+	Longitude                *float64   `json:"longitude"`
+	Location                 *Point     `json:"location" gorm:"type:geography(POINT,4326)"`
+	UpdatedAt                *time.Time `json:"updated_at" gorm:"default:CURRENT_TIMESTAMP"`
+
+	Verification
+
+	// Distance from the search point, only set for radius searches
+	DistanceMiles *float64 `json:"distance_miles,omitempty" gorm:"->;-:migration"`
 }
 
 // ResourceCenter represents resource centers with geospatial data
@@ -145,6 +258,11 @@ type ResourceCenter struct {
 	CreatedAt   *time.Time `json:"created_at" gorm:"default:CURRENT_TIMESTAMP"`
 	UpdatedAt   *time.Time `json:"updated_at" gorm:"default:CURRENT_TIMESTAMP"`
 
+	Verification
+
+	// Distance from the search point, only set for radius searches
+	DistanceMiles *float64 `json:"distance_miles,omitempty" gorm:"->;-:migration"`
+
 	// Many-to-many relationship with diagnoses
 	Diagnoses []Diagnosis `json:"diagnoses" gorm:"many2many:center_diagnoses;foreignKey:ID;joinForeignKey:center_id;References:ID;joinReferences:diagnosis_id"`
 }
@@ -156,12 +274,17 @@ type Resource struct {
 	Description *string     `json:"description"`
 	Latitude    float64     `json:"latitude" gorm:"type:numeric;not null"`
 	Longitude   float64     `json:"longitude" gorm:"type:numeric;not null"`
-	Diagnoses   []string    `json:"diagnoses" gorm:"type:text[]"`
+	Diagnoses   StringArray `json:"diagnoses" gorm:"type:text[]"`
 	Address     *string     `json:"address"`
 	ContactInfo ContactInfo `json:"contact_info" gorm:"type:jsonb"`
 	CreatedAt   time.Time   `json:"created_at" gorm:"default:CURRENT_TIMESTAMP"`
 	UpdatedAt   time.Time   `json:"updated_at" gorm:"default:CURRENT_TIMESTAMP"`
 	Location    *Point      `json:"location" gorm:"type:geography(POINT,4326)"`
+
+	Verification
+
+	// Distance from the search point, only set for radius searches
+	DistanceMiles *float64 `json:"distance_miles,omitempty" gorm:"->;-:migration"`
 }
 
 // Role represents user roles
@@ -177,19 +300,109 @@ type Role struct {
 
 // User represents system users
 type User struct {
-	ID           int       `json:"id" gorm:"primaryKey"`
-	Email        string    `json:"email" gorm:"type:varchar(255);not null;uniqueIndex"`
-	PasswordHash string    `json:"-" gorm:"type:varchar(255);not null"`
-	FirstName    *string   `json:"first_name" gorm:"type:varchar(100)"`
-	LastName     *string   `json:"last_name" gorm:"type:varchar(100)"`
-	Name         *string   `json:"name" gorm:"type:varchar(255)"`
-	CreatedAt    time.Time `json:"created_at" gorm:"default:now()"`
-	UpdatedAt    time.Time `json:"updated_at" gorm:"default:now()"`
+	ID           int        `json:"id" gorm:"primaryKey"`
+	Email        string     `json:"email" gorm:"type:varchar(255);not null;uniqueIndex"`
+	PasswordHash string     `json:"-" gorm:"type:varchar(255);not null"`
+	FirstName    *string    `json:"first_name" gorm:"type:varchar(100)"`
+	LastName     *string    `json:"last_name" gorm:"type:varchar(100)"`
+	Name         *string    `json:"name" gorm:"type:varchar(255)"`
+	DisabledAt   *time.Time `json:"disabled_at"` // disabled users can't sign in
+	CreatedAt    time.Time  `json:"created_at" gorm:"default:now()"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"default:now()"`
 
 	// Many-to-many relationship with roles
 	Roles []Role `json:"roles" gorm:"many2many:user_roles;foreignKey:ID;joinForeignKey:user_id;References:ID;joinReferences:role_id"`
 }
 
+// RoleAdmin is granted every permission used by admin endpoints
+const RoleAdmin = "admin"
+
+// Session is a signed-in user's pair of access and refresh tokens. Only
+// SHA-256 hashes of the tokens are stored.
+type Session struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	UserID           int       `json:"user_id" gorm:"not null;index"`
+	AccessTokenHash  string    `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	AccessExpiresAt  time.Time `json:"access_expires_at" gorm:"not null"`
+	RefreshTokenHash string    `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at" gorm:"not null;index"`
+	UserAgent        string    `json:"user_agent"`
+	IPAddress        string    `json:"ip_address" gorm:"type:varchar(45)"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// User token purposes
+const (
+	TokenPurposeInvite        = "invite"
+	TokenPurposePasswordReset = "password_reset"
+)
+
+// UserToken is a one-time token emailed to a user to set their password,
+// either to accept an invitation or to reset it. Only its SHA-256 hash is
+// stored.
+type UserToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    int        `json:"user_id" gorm:"not null;index"`
+	Purpose   string     `json:"purpose" gorm:"type:varchar(20);not null"`
+	TokenHash string     `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Audit actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditLog records one write to one row made through the API
+type AuditLog struct {
+	ID         int64        `json:"id" gorm:"primaryKey"`
+	ActorID    *int         `json:"actor_id" gorm:"index"` // signed-in user, nil for anonymous requests
+	IPAddress  *string      `json:"ip_address" gorm:"type:varchar(45)"`
+	RequestID  *string      `json:"request_id" gorm:"type:varchar(64);index"`
+	Action     string       `json:"action" gorm:"type:varchar(16);not null"`
+	EntityType string       `json:"entity_type" gorm:"type:varchar(64);not null;index:idx_audit_logs_entity"` // table name
+	EntityID   string       `json:"entity_id" gorm:"type:varchar(128);not null;index:idx_audit_logs_entity"`
+	Changes    AuditChanges `json:"changes" gorm:"type:jsonb"`
+	CreatedAt  time.Time    `json:"created_at" gorm:"not null;index"`
+}
+
+// FieldChange is the value of a field before and after a write
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges maps the JSON names of changed fields to their change
+type AuditChanges map[string]FieldChange
+
+// Scan implements the sql.Scanner interface for AuditChanges
+func (a *AuditChanges) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface for AuditChanges
+func (a AuditChanges) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
 // Junction table models (GORM will create these automatically, but we can define them for explicit control)
 
 // CenterDiagnosis represents the many-to-many relationship between resource centers and diagnoses
@@ -198,6 +411,18 @@ type CenterDiagnosis struct {
 	DiagnosisID uuid.UUID `json:"diagnosis_id" gorm:"type:uuid;primaryKey"`
 }
 
+// ABACenterCarrier links an ABA center to an insurance carrier it accepts
+type ABACenterCarrier struct {
+	ABACenterID uuid.UUID `json:"aba_center_id" gorm:"type:uuid;primaryKey"`
+	CarrierID   int       `json:"carrier_id" gorm:"primaryKey;index"`
+}
+
+// ABACenterPlan links an ABA center to an insurance plan it accepts
+type ABACenterPlan struct {
+	ABACenterID uuid.UUID `json:"aba_center_id" gorm:"type:uuid;primaryKey"`
+	PlanID      int       `json:"plan_id" gorm:"primaryKey;index"`
+}
+
 // RolePermission represents the many-to-many relationship between roles and permissions
 type RolePermission struct {
 	RoleID       int `json:"role_id" gorm:"primaryKey"`
@@ -210,6 +435,27 @@ type UserRole struct {
 	RoleID int `json:"role_id" gorm:"primaryKey"`
 }
 
+// GeocodeCache stores geocoding results keyed by normalized address
+type GeocodeCache struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	AddressKey       string    `json:"address_key" gorm:"type:text;not null;uniqueIndex"`
+	Address          string    `json:"address" gorm:"type:text;not null"`
+	Latitude         float64   `json:"latitude" gorm:"not null"`
+	Longitude        float64   `json:"longitude" gorm:"not null"`
+	FormattedAddress string    `json:"formatted_address"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ProviderArea is a geocoded entry of a provider's coverage areas
+type ProviderArea struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ProviderID int       `json:"provider_id" gorm:"not null;uniqueIndex:idx_provider_areas_provider_area"`
+	Area       string    `json:"area" gorm:"not null;uniqueIndex:idx_provider_areas_provider_area"`
+	Latitude   float64   `json:"latitude" gorm:"not null"`
+	Longitude  float64   `json:"longitude" gorm:"not null"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
 // Search and filter models for API queries
 type SearchFilter struct {
 	Diagnoses         []string `json:"diagnoses"`
@@ -217,14 +463,47 @@ type SearchFilter struct {
 	Latitude          float64  `json:"latitude"`
 	Longitude         float64  `json:"longitude"`
 	ServiceType       string   `json:"service_type"`
-	InsuranceRequired bool     `json:"insurance_required"`
-	WaitlistOnly      bool     `json:"waitlist_only"`
+	InsuranceRequired bool     `json:"insurance_required"` // linked to any carrier or plan
+	WaitlistOnly      bool     `json:"waitlist_only"`      // taking clients: open, short or long waitlist
+	Sort              string   `json:"sort"`               // SortDistance or SortName
+
+	// Text filters, matched case-insensitively as substrings
+	Search    string `json:"search"` // name, address and description columns
+	City      string `json:"city"`
+	County    string `json:"county"`
+	Insurance string `json:"insurance"`
+
+	// Exact coverage area match for providers
+	Area string `json:"area"`
+
+	// ABA centers accepting any of these carriers or plans. A plan also
+	// matches centers that take its carrier in general.
+	CarrierIDs []int `json:"carrier_ids"`
+	PlanIDs    []int `json:"plan_ids"`
+
+	// ABA centers with any of these waitlist statuses, and centers that are
+	// open or expect a wait of at most MaxWaitWeeks when it is set
+	WaitlistStatuses []string `json:"waitlist_statuses"`
+	MaxWaitWeeks     *int     `json:"max_wait_weeks"`
+}
+
+// HasLocation reports whether the filter describes a radius search
+func (f *SearchFilter) HasLocation() bool {
+	return f.Latitude != 0 && f.Longitude != 0 && f.MaxDistance > 0
 }
 
-// UserPreferences for user settings (keeping this from original for compatibility)
+// Sort orders accepted by location-aware list endpoints
+const (
+	SortDistance = "distance"
+	SortName     = "name"
+)
+
+// UserPreferences for user settings. Each row belongs to either a signed-in
+// user or an anonymous device identified by a signed cookie.
 type UserPreferences struct {
-	ID     uint   `json:"id" gorm:"primaryKey"`
-	UserID string `json:"user_id" gorm:"unique;not null"`
+	ID       uint    `json:"id" gorm:"primaryKey"`
+	UserID   *int    `json:"user_id" gorm:"uniqueIndex"`            // references users.id
+	DeviceID *string `json:"-" gorm:"type:varchar(64);uniqueIndex"` // anonymous devices only
 
 	// Map preferences
 	MapType        string `json:"map_type" gorm:"default:'roadmap'"`
@@ -238,15 +517,18 @@ type UserPreferences struct {
 	ShowProviders       bool `json:"show_providers" gorm:"default:true"`
 
 	// Search preferences
-	PreferredRadius    float64 `json:"preferred_radius" gorm:"default:25"` // miles
-	RequireWaitlist    bool    `json:"require_waitlist" gorm:"default:false"`
-	RequireInsurance   bool    `json:"require_insurance" gorm:"default:false"`
-	PreferredDiagnoses string  `json:"preferred_diagnoses"` // JSON array of diagnosis IDs
+	PreferredRadius    float64     `json:"preferred_radius" gorm:"default:25"` // miles
+	RequireWaitlist    bool        `json:"require_waitlist" gorm:"default:false"`
+	RequireInsurance   bool        `json:"require_insurance" gorm:"default:false"`
+	PreferredDiagnoses StringArray `json:"preferred_diagnoses" gorm:"type:uuid[]"` // diagnosis IDs
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Map types accepted in UserPreferences.MapType
+var MapTypes = []string{"roadmap", "satellite", "hybrid", "terrain"}
+
 // Table name overrides
 func (ABACenter) TableName() string {
 	return "aba_centers"
@@ -292,6 +574,14 @@ func (CenterDiagnosis) TableName() string {
 	return "center_diagnoses"
 }
 
+func (ABACenterCarrier) TableName() string {
+	return "aba_center_carriers"
+}
+
+func (ABACenterPlan) TableName() string {
+	return "aba_center_plans"
+}
+
 func (RolePermission) TableName() string {
 	return "role_permissions"
 }
@@ -299,3 +589,11 @@ func (RolePermission) TableName() string {
 func (UserRole) TableName() string {
 	return "user_roles"
 }
+
+func (GeocodeCache) TableName() string {
+	return "geocode_cache"
+}
+
+func (ProviderArea) TableName() string {
+	return "provider_areas"
+}
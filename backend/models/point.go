@@ -0,0 +1,231 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SRID used by every geometry/geography column in the database (WGS 84)
+const SRID = 4326
+
+// EWKB flag bits set on the geometry type word
+const (
+	ewkbZFlag    = 0x80000000
+	ewkbMFlag    = 0x40000000
+	ewkbSRIDFlag = 0x20000000
+	wkbPointType = 1
+)
+
+// Point is a WGS 84 coordinate stored in a PostGIS geometry(POINT,4326) or
+// geography(POINT,4326) column
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// NewPoint builds a point from a latitude/longitude pair
+func NewPoint(lat, lng float64) *Point {
+	return &Point{Lat: lat, Lng: lng}
+}
+
+// Scan implements the sql.Scanner interface. PostGIS returns points as hex
+// encoded EWKB in text mode and as raw (E)WKB in binary mode; GeoJSON and
+// EWKT are accepted too so that ST_AsGeoJSON/ST_AsEWKT selects scan as well.
+func (p *Point) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Point", value)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+
+	switch {
+	case data[0] == 0x00 || data[0] == 0x01:
+		return p.decodeWKB(data)
+	case data[0] == '{':
+		return p.decodeGeoJSON(data)
+	case isHex(data):
+		raw := make([]byte, hex.DecodedLen(len(data)))
+		if _, err := hex.Decode(raw, data); err != nil {
+			return fmt.Errorf("invalid hex WKB: %w", err)
+		}
+		return p.decodeWKB(raw)
+	default:
+		return p.decodeWKT(string(data))
+	}
+}
+
+// Value implements the driver.Valuer interface, encoding the point as EWKT
+// which PostGIS casts to both geometry and geography columns
+func (p Point) Value() (driver.Value, error) {
+	return p.EWKT(), nil
+}
+
+// EWKT returns the point as extended well-known text, e.g.
+// SRID=4326;POINT(-118.24 34.05)
+func (p Point) EWKT() string {
+	return fmt.Sprintf("SRID=%d;POINT(%s %s)", SRID,
+		strconv.FormatFloat(p.Lng, 'f', -1, 64),
+		strconv.FormatFloat(p.Lat, 'f', -1, 64))
+}
+
+// EWKB returns the point as little-endian extended well-known binary
+func (p Point) EWKB() []byte {
+	buf := make([]byte, 25)
+	buf[0] = 0x01
+	binary.LittleEndian.PutUint32(buf[1:], wkbPointType|ewkbSRIDFlag)
+	binary.LittleEndian.PutUint32(buf[5:], SRID)
+	binary.LittleEndian.PutUint64(buf[9:], math.Float64bits(p.Lng))
+	binary.LittleEndian.PutUint64(buf[17:], math.Float64bits(p.Lat))
+	return buf
+}
+
+// decodeWKB parses OGC WKB, ISO WKB (Z/M/ZM type codes) and PostGIS EWKB
+func (p *Point) decodeWKB(data []byte) error {
+	if len(data) < 5 {
+		return fmt.Errorf("WKB too short: %d bytes", len(data))
+	}
+
+	var order binary.ByteOrder
+	switch data[0] {
+	case 0x00:
+		order = binary.BigEndian
+	case 0x01:
+		order = binary.LittleEndian
+	default:
+		return fmt.Errorf("invalid WKB byte order %#x", data[0])
+	}
+
+	typ := order.Uint32(data[1:5])
+	offset := 5
+
+	dims := 2
+	if typ&ewkbZFlag != 0 {
+		dims++
+	}
+	if typ&ewkbMFlag != 0 {
+		dims++
+	}
+	if typ&ewkbSRIDFlag != 0 {
+		if len(data) < offset+4 {
+			return fmt.Errorf("WKB too short for SRID")
+		}
+		offset += 4
+	}
+
+	base := typ &^ (ewkbZFlag | ewkbMFlag | ewkbSRIDFlag)
+	switch base / 1000 {
+	case 1, 2: // ISO Z or M
+		dims++
+	case 3: // ISO ZM
+		dims += 2
+	}
+	if base%1000 != wkbPointType {
+		return fmt.Errorf("unsupported WKB geometry type %d, expected Point", base)
+	}
+
+	if len(data) < offset+dims*8 {
+		return fmt.Errorf("WKB too short for %d coordinates", dims)
+	}
+	x := math.Float64frombits(order.Uint64(data[offset:]))
+	y := math.Float64frombits(order.Uint64(data[offset+8:]))
+
+	// POINT EMPTY is encoded with NaN coordinates
+	if math.IsNaN(x) || math.IsNaN(y) {
+		*p = Point{}
+		return nil
+	}
+
+	p.Lng, p.Lat = x, y
+	return nil
+}
+
+// decodeGeoJSON parses a GeoJSON Point geometry
+func (p *Point) decodeGeoJSON(data []byte) error {
+	var geom struct {
+		Type        string    `json:"type"`
+		Coordinates []float64 `json:"coordinates"`
+	}
+	if err := json.Unmarshal(data, &geom); err != nil {
+		return fmt.Errorf("invalid GeoJSON point: %w", err)
+	}
+	if !strings.EqualFold(geom.Type, "Point") {
+		return fmt.Errorf("unsupported GeoJSON type %q, expected Point", geom.Type)
+	}
+	if len(geom.Coordinates) < 2 {
+		*p = Point{}
+		return nil
+	}
+
+	p.Lng, p.Lat = geom.Coordinates[0], geom.Coordinates[1]
+	return nil
+}
+
+// decodeWKT parses WKT or EWKT such as SRID=4326;POINT(-118.24 34.05)
+func (p *Point) decodeWKT(text string) error {
+	if i := strings.IndexByte(text, ';'); i >= 0 && strings.HasPrefix(strings.ToUpper(text), "SRID=") {
+		text = text[i+1:]
+	}
+
+	upper := strings.ToUpper(strings.TrimSpace(text))
+	if !strings.HasPrefix(upper, "POINT") {
+		return fmt.Errorf("unsupported WKT %q, expected POINT", text)
+	}
+
+	open := strings.IndexByte(upper, '(')
+	end := strings.LastIndexByte(upper, ')')
+	if open < 0 || end < open {
+		if strings.HasSuffix(upper, "EMPTY") {
+			*p = Point{}
+			return nil
+		}
+		return fmt.Errorf("malformed WKT point %q", text)
+	}
+
+	fields := strings.Fields(upper[open+1 : end])
+	if len(fields) < 2 {
+		return fmt.Errorf("malformed WKT point %q", text)
+	}
+	x, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return fmt.Errorf("invalid WKT longitude: %w", err)
+	}
+	y, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid WKT latitude: %w", err)
+	}
+
+	p.Lng, p.Lat = x, y
+	return nil
+}
+
+func isHex(data []byte) bool {
+	if len(data)%2 != 0 {
+		return false
+	}
+	for _, b := range data {
+		if !('0' <= b && b <= '9' || 'a' <= b && b <= 'f' || 'A' <= b && b <= 'F') {
+			return false
+		}
+	}
+	return true
+}
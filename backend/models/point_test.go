@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// wkb encodes a geometry of type typ with coords, optionally with an SRID
+func wkb(order binary.AppendByteOrder, typ uint32, srid *uint32, coords ...float64) []byte {
+	buf := []byte{0x01}
+	if order == binary.BigEndian {
+		buf[0] = 0x00
+	}
+	buf = order.AppendUint32(buf, typ)
+	if srid != nil {
+		buf = order.AppendUint32(buf, *srid)
+	}
+	for _, c := range coords {
+		buf = order.AppendUint64(buf, math.Float64bits(c))
+	}
+	return buf
+}
+
+func TestPointScan(t *testing.T) {
+	srid := uint32(SRID)
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  Point
+	}{
+		{"nil", nil, Point{}},
+		{"empty", []byte("  "), Point{}},
+		{"hex EWKB from PostGIS", "0101000020E6100000000000000000F03F0000000000000040", Point{Lat: 2, Lng: 1}},
+		{"lowercase hex EWKB", []byte("0101000020e6100000000000000000f03f0000000000000040"), Point{Lat: 2, Lng: 1}},
+		{"raw EWKB", NewPoint(34.05, -118.24).EWKB(), Point{Lat: 34.05, Lng: -118.24}},
+		{"big-endian WKB", wkb(binary.BigEndian, wkbPointType, nil, -118.24, 34.05), Point{Lat: 34.05, Lng: -118.24}},
+		{"hex WKB", hex.EncodeToString(wkb(binary.LittleEndian, wkbPointType, nil, 1, 2)), Point{Lat: 2, Lng: 1}},
+		{"EWKB Z", wkb(binary.LittleEndian, wkbPointType|ewkbZFlag|ewkbSRIDFlag, &srid, 1, 2, 3), Point{Lat: 2, Lng: 1}},
+		{"EWKB ZM", wkb(binary.BigEndian, wkbPointType|ewkbZFlag|ewkbMFlag, nil, 1, 2, 3, 4), Point{Lat: 2, Lng: 1}},
+		{"ISO WKB Z", wkb(binary.LittleEndian, 1001, nil, 1, 2, 3), Point{Lat: 2, Lng: 1}},
+		{"ISO WKB ZM", wkb(binary.LittleEndian, 3001, nil, 1, 2, 3, 4), Point{Lat: 2, Lng: 1}},
+		{"WKB POINT EMPTY", wkb(binary.LittleEndian, wkbPointType, nil, math.NaN(), math.NaN()), Point{}},
+		{"WKT", "POINT(-118.24 34.05)", Point{Lat: 34.05, Lng: -118.24}},
+		{"EWKT", "SRID=4326;POINT(-118.24 34.05)", Point{Lat: 34.05, Lng: -118.24}},
+		{"lowercase WKT with Z", "point z (1 2 3)", Point{Lat: 2, Lng: 1}},
+		{"WKT POINT EMPTY", "POINT EMPTY", Point{}},
+		{"GeoJSON", `{"type":"Point","coordinates":[-118.24,34.05]}`, Point{Lat: 34.05, Lng: -118.24}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Point
+			if err := p.Scan(tt.value); err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if p != tt.want {
+				t.Errorf("Scan = %+v, want %+v", p, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointScanErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"unsupported type", 42},
+		{"short WKB", []byte{0x01, 0x01, 0x00}},
+		{"truncated coordinates", wkb(binary.LittleEndian, wkbPointType, nil, 1)},
+		{"missing SRID", []byte{0x01, 0x01, 0x00, 0x00, 0x20}},
+		{"WKB linestring", wkb(binary.LittleEndian, 2, nil, 1, 2, 3, 4)},
+		{"WKT linestring", "LINESTRING(1 2, 3 4)"},
+		{"malformed WKT", "POINT(1)"},
+		{"non-numeric WKT", "POINT(a b)"},
+		{"GeoJSON polygon", `{"type":"Polygon","coordinates":[]}`},
+		{"invalid GeoJSON", `{"type":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Point
+			if err := p.Scan(tt.value); err == nil {
+				t.Errorf("Scan(%v) = %+v, want an error", tt.value, p)
+			}
+		})
+	}
+}
+
+func TestPointValueRoundTrip(t *testing.T) {
+	want := Point{Lat: 34.052235, Lng: -118.243683}
+
+	value, err := want.Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	if value != "SRID=4326;POINT(-118.243683 34.052235)" {
+		t.Errorf("Value = %v", value)
+	}
+
+	var got Point
+	if err := got.Scan(value); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if got != want {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alexbeattie/medicalfacilities/mailer"
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// EmailNotifier emails each submission to a list of recipients
+type EmailNotifier struct {
+	mail mailer.Mailer
+	to   []string
+}
+
+// NewEmailNotifier creates an EmailNotifier sending through mail
+func NewEmailNotifier(mail mailer.Mailer, to []string) (*EmailNotifier, error) {
+	if mail == nil || len(to) == 0 {
+		return nil, errors.New("email notifier needs a mailer and at least one recipient")
+	}
+	return &EmailNotifier{mail: mail, to: to}, nil
+}
+
+// Notify emails submission to every recipient
+func (n *EmailNotifier) Notify(ctx context.Context, submission models.FormSubmission) error {
+	var errs []error
+	for _, to := range n.to {
+		err := n.mail.Send(ctx, mailer.Message{
+			To:      to,
+			Subject: fmt.Sprintf("New message from %s", submission.Name),
+			Body:    summary(submission),
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
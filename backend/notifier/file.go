@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// FileNotifier appends each submission as a JSON line to a file, for local
+// development
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileNotifier creates a FileNotifier appending to path, creating its
+// directory if needed
+func NewFileNotifier(path string) (*FileNotifier, error) {
+	if path == "" {
+		path = filepath.Join("mail", "submissions.jsonl")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create notification directory: %w", err)
+	}
+	return &FileNotifier{path: path}, nil
+}
+
+// Notify appends submission to the file
+func (n *FileNotifier) Notify(ctx context.Context, submission models.FormSubmission) error {
+	line, err := json.Marshal(submission)
+	if err != nil {
+		return fmt.Errorf("failed to encode submission: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package notifier
+
+import (
+	"context"
+	"log"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// LogNotifier writes submissions to the log
+type LogNotifier struct{}
+
+// NewLogNotifier creates a LogNotifier
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// Notify logs submission
+func (n *LogNotifier) Notify(ctx context.Context, submission models.FormSubmission) error {
+	log.Printf("[NOTIFY] Form submission %d from %s <%s>", submission.ID, submission.Name, submission.Email)
+	return nil
+}
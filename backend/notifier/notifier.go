@@ -0,0 +1,91 @@
+// Package notifier tells staff about new form submissions
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alexbeattie/medicalfacilities/mailer"
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// Notifier announces a stored form submission
+type Notifier interface {
+	Notify(ctx context.Context, submission models.FormSubmission) error
+}
+
+// Config selects and configures the notifiers
+type Config struct {
+	Drivers []string // any of "log" (default), "file", "email" and "webhook"
+
+	// file driver
+	File string
+
+	// email driver
+	EmailTo []string
+
+	// webhook driver
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// New returns a Notifier sending through every driver in cfg.Drivers. The
+// email driver sends through mail.
+func New(cfg Config, mail mailer.Mailer) (Notifier, error) {
+	var notifiers Multi
+	for _, driver := range cfg.Drivers {
+		switch strings.ToLower(strings.TrimSpace(driver)) {
+		case "", "log":
+			notifiers = append(notifiers, NewLogNotifier())
+		case "file":
+			n, err := NewFileNotifier(cfg.File)
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, n)
+		case "email":
+			n, err := NewEmailNotifier(mail, cfg.EmailTo)
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, n)
+		case "webhook":
+			n, err := NewWebhookNotifier(cfg.WebhookURL, cfg.WebhookSecret)
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, n)
+		default:
+			return nil, fmt.Errorf("unknown notifier %q", driver)
+		}
+	}
+	if len(notifiers) == 0 {
+		return NewLogNotifier(), nil
+	}
+	if len(notifiers) == 1 {
+		return notifiers[0], nil
+	}
+	return notifiers, nil
+}
+
+// Multi sends through each of its notifiers, carrying on past failures
+type Multi []Notifier
+
+// Notify sends submission through every notifier and joins their errors
+func (m Multi) Notify(ctx context.Context, submission models.FormSubmission) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, submission); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// summary renders submission as plain text
+func summary(submission models.FormSubmission) string {
+	return fmt.Sprintf("Name: %s\nEmail: %s\nReceived: %s\n\n%s\n",
+		submission.Name, submission.Email, submission.CreatedAt.Format("2006-01-02 15:04:05 MST"), submission.Message)
+}
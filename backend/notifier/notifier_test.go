@@ -0,0 +1,164 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/mailer"
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+var submission = models.FormSubmission{ID: 7, Name: "Ann", Email: "ann@example.com", Message: "Hello"}
+
+// recordingMailer keeps the messages it is asked to send
+type recordingMailer struct {
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(ctx context.Context, submission models.FormSubmission) error {
+	return errors.New("unavailable")
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string
+		wantErr bool
+	}{
+		{"default", Config{}, "*notifier.LogNotifier", false},
+		{"email", Config{Drivers: []string{" Email "}, EmailTo: []string{"staff@example.com"}}, "*notifier.EmailNotifier", false},
+		{"several", Config{Drivers: []string{"log", "webhook"}, WebhookURL: "http://example.com"}, "notifier.Multi", false},
+		{"email without recipients", Config{Drivers: []string{"email"}}, "", true},
+		{"webhook without a URL", Config{Drivers: []string{"webhook"}}, "", true},
+		{"unknown", Config{Drivers: []string{"pager"}}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := New(tt.cfg, &recordingMailer{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New = %v, want error %t", err, tt.wantErr)
+			}
+			if got := typeName(n); !tt.wantErr && got != tt.want {
+				t.Errorf("New = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case *LogNotifier:
+		return "*notifier.LogNotifier"
+	case *EmailNotifier:
+		return "*notifier.EmailNotifier"
+	case Multi:
+		return "notifier.Multi"
+	}
+	return "other"
+}
+
+func TestMultiCarriesOn(t *testing.T) {
+	mail := &recordingMailer{}
+	email, err := NewEmailNotifier(mail, []string{"a@example.com", "b@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Multi{failingNotifier{}, email}.Notify(context.Background(), submission)
+	if err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("Notify = %v, want the failure reported", err)
+	}
+	if len(mail.sent) != 2 || mail.sent[1].To != "b@example.com" || !strings.Contains(mail.sent[0].Body, "Hello") {
+		t.Errorf("sent = %+v, want the submission emailed to both recipients", mail.sent)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Signature-256")
+	}))
+	defer server.Close()
+
+	n, err := NewWebhookNotifier(server.URL, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), submission); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var event struct {
+		Event      string                `json:"event"`
+		Submission models.FormSubmission `json:"submission"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.Event != "form_submission.created" || event.Submission.ID != 7 {
+		t.Errorf("body = %s, want the created event", body)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("X-Signature-256 = %q, want %q", signature, want)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	n, _ = NewWebhookNotifier(failing.URL, "")
+	if err := n.Notify(context.Background(), submission); err == nil {
+		t.Error("Notify = nil, want an error for a 502")
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications", "submissions.jsonl")
+	n, err := NewFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := n.Notify(context.Background(), submission); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+		var got models.FormSubmission
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil || got.Email != submission.Email {
+			t.Errorf("line %d = %s, want the submission", lines+1, scanner.Bytes())
+		}
+	}
+	if lines != 2 {
+		t.Errorf("lines = %d, want one per submission", lines)
+	}
+}
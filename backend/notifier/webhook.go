@@ -0,0 +1,68 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// WebhookNotifier posts each submission as JSON to a URL. With a secret,
+// the body's HMAC-SHA256 is sent in X-Signature-256 as "sha256=<hex>".
+type WebhookNotifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a WebhookNotifier posting to url
+func NewWebhookNotifier(url, secret string) (*WebhookNotifier, error) {
+	if url == "" {
+		return nil, errors.New("webhook notifier needs a URL")
+	}
+	return &WebhookNotifier{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Notify posts {"event": "form_submission.created", "submission": {...}}
+func (n *WebhookNotifier) Notify(ctx context.Context, submission models.FormSubmission) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"event":      "form_submission.created",
+		"submission": submission,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode submission: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
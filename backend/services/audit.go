@@ -0,0 +1,415 @@
+// services/audit.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// AuditActor identifies who made the writes of a request
+type AuditActor struct {
+	UserID    *int // nil for anonymous requests
+	IPAddress string
+	RequestID string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor returns a context whose writes are recorded in the audit
+// log as made by actor
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActorFrom returns the actor attached to ctx by WithAuditActor
+func AuditActorFrom(ctx context.Context) (AuditActor, bool) {
+	actor, ok := ctx.Value(auditActorKey{}).(AuditActor)
+	return actor, ok
+}
+
+// WithContext returns a copy of s running its queries with ctx. Writes made
+// under a context from WithAuditActor are recorded in the audit log.
+func (s *Service) WithContext(ctx context.Context) *Service {
+	scoped := *s
+	scoped.db = s.db.WithContext(ctx)
+	return &scoped
+}
+
+// requestContext returns the context s runs its queries with, which
+// WithContext sets to that of the request
+func (s *Service) requestContext() context.Context {
+	if s.db == nil || s.db.Statement.Context == nil {
+		return context.Background()
+	}
+	return s.db.Statement.Context
+}
+
+// unauditedTables hold secrets, caches derived from other rows or their own
+// history, and the audit log itself
+var unauditedTables = map[string]bool{
+	"audit_logs":       true,
+	"sessions":         true,
+	"user_tokens":      true,
+	"geocode_cache":    true,
+	"provider_areas":   true,
+	"waitlist_changes": true,
+}
+
+// auditBeforeKey holds the rows an update or delete is about to change
+const auditBeforeKey = "audit:before"
+
+// InitAudit registers the GORM callbacks recording writes made with an
+// AuditActor: the changed fields of each created, updated or deleted row
+// are compared as JSON and stored as a models.AuditLog. Raw SQL statements
+// aren't recorded. A failure to record is logged; the write itself stands.
+func (s *Service) InitAudit() error {
+	callbacks := s.db.Callback()
+	for _, register := range []func() error{
+		func() error {
+			return callbacks.Create().After("gorm:create").Register("audit:after_create", auditAfter(models.AuditCreate))
+		},
+		func() error {
+			return callbacks.Update().Before("gorm:update").Register("audit:before_update", auditBefore)
+		},
+		func() error {
+			return callbacks.Update().After("gorm:update").Register("audit:after_update", auditAfter(models.AuditUpdate))
+		},
+		func() error {
+			return callbacks.Delete().Before("gorm:delete").Register("audit:before_delete", auditBefore)
+		},
+		func() error {
+			return callbacks.Delete().After("gorm:delete").Register("audit:after_delete", auditAfter(models.AuditDelete))
+		},
+	} {
+		if err := register(); err != nil {
+			return fmt.Errorf("failed to register audit callbacks: %w", err)
+		}
+	}
+	return nil
+}
+
+// auditedActor returns the actor of a statement that should be recorded
+func auditedActor(db *gorm.DB) (AuditActor, bool) {
+	if db.Statement.Schema == nil || unauditedTables[db.Statement.Table] || db.Statement.Context == nil {
+		return AuditActor{}, false
+	}
+	return AuditActorFrom(db.Statement.Context)
+}
+
+// auditBefore keeps the rows an update or delete will change
+func auditBefore(db *gorm.DB) {
+	if _, ok := auditedActor(db); !ok || db.Error != nil {
+		return
+	}
+	rows, err := loadAuditRows(db, statementConditions(db))
+	if err != nil {
+		log.Printf("[AUDIT] Failed to read %s before write: %v", db.Statement.Table, err)
+		return
+	}
+	db.InstanceSet(auditBeforeKey, rows)
+}
+
+// auditAfter records the rows written by a statement
+func auditAfter(action string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		actor, ok := auditedActor(db)
+		if !ok || db.Error != nil || db.Statement.RowsAffected == 0 {
+			return
+		}
+
+		var before, after []auditRow
+		switch action {
+		case models.AuditCreate:
+			after = auditRowsOf(db.Statement.Schema, db.Statement.Context, db.Statement.ReflectValue)
+		case models.AuditUpdate, models.AuditDelete:
+			value, ok := db.InstanceGet(auditBeforeKey)
+			if !ok {
+				return
+			}
+			before = value.([]auditRow)
+			if action == models.AuditUpdate && len(before) > 0 {
+				conditions := make([]clause.Expression, len(before))
+				for i, row := range before {
+					conditions[i] = row.key
+				}
+				var err error
+				if after, err = loadAuditRows(db, []clause.Expression{clause.And(clause.Or(conditions...))}); err != nil {
+					log.Printf("[AUDIT] Failed to read %s after write: %v", db.Statement.Table, err)
+					return
+				}
+			}
+		}
+
+		entries := auditEntries(actor, action, db.Statement.Table, before, after)
+		if len(entries) == 0 {
+			return
+		}
+		if err := db.Session(&gorm.Session{NewDB: true}).Create(&entries).Error; err != nil {
+			log.Printf("[AUDIT] Failed to record %s of %s: %v", action, db.Statement.Table, err)
+		}
+	}
+}
+
+// auditRow is one row of an audited table, as its JSON fields
+type auditRow struct {
+	id     string
+	key    clause.Expression // matches the row by primary key
+	fields map[string]interface{}
+}
+
+// statementConditions returns the WHERE conditions of a statement,
+// including the primary keys of its model, which GORM only adds while
+// executing it. It returns nil when the statement has neither.
+func statementConditions(db *gorm.DB) []clause.Expression {
+	var conditions []clause.Expression
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			conditions = append(conditions, where.Exprs...)
+		}
+	}
+
+	value := db.Statement.ReflectValue
+	var keys []clause.Expression
+	switch value.Kind() {
+	case reflect.Struct:
+		if key, ok := primaryKeyCondition(db.Statement.Schema, db.Statement.Context, value); ok {
+			keys = append(keys, key)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if key, ok := primaryKeyCondition(db.Statement.Schema, db.Statement.Context, reflect.Indirect(value.Index(i))); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) > 0 {
+		// Wrapped so the keys aren't ORed with the conditions before them
+		conditions = append(conditions, clause.And(clause.Or(keys...)))
+	}
+	return conditions
+}
+
+// primaryKeyCondition matches the row of value by its primary key, and
+// reports false when the key isn't set
+func primaryKeyCondition(s *schema.Schema, ctx context.Context, value reflect.Value) (clause.Expression, bool) {
+	if len(s.PrimaryFields) == 0 || value.Kind() != reflect.Struct {
+		return nil, false
+	}
+	equals := make([]clause.Expression, len(s.PrimaryFields))
+	for i, field := range s.PrimaryFields {
+		v, zero := field.ValueOf(ctx, value)
+		if zero {
+			return nil, false
+		}
+		equals[i] = clause.Eq{Column: clause.Column{Table: s.Table, Name: field.DBName}, Value: v}
+	}
+	return clause.And(equals...), true
+}
+
+// loadAuditRows reads the rows of the statement's table matching
+// conditions, soft-deleted or not
+func loadAuditRows(db *gorm.DB, conditions []clause.Expression) ([]auditRow, error) {
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+	s := db.Statement.Schema
+	rows := reflect.New(reflect.SliceOf(s.ModelType))
+	query := db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(reflect.New(s.ModelType).Interface())
+	if err := query.Clauses(clause.Where{Exprs: conditions}).Find(rows.Interface()).Error; err != nil {
+		return nil, err
+	}
+	return auditRowsOf(s, db.Statement.Context, rows.Elem()), nil
+}
+
+// auditRowsOf converts a struct or slice of structs of schema s
+func auditRowsOf(s *schema.Schema, ctx context.Context, value reflect.Value) []auditRow {
+	var values []reflect.Value
+	switch value.Kind() {
+	case reflect.Struct:
+		values = []reflect.Value{value}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			values = append(values, reflect.Indirect(value.Index(i)))
+		}
+	}
+
+	columns := auditedFields(s)
+	rows := make([]auditRow, 0, len(values))
+	for _, v := range values {
+		key, ok := primaryKeyCondition(s, ctx, v)
+		if !ok {
+			continue
+		}
+		ids := make([]string, len(s.PrimaryFields))
+		for i, field := range s.PrimaryFields {
+			id, _ := field.ValueOf(ctx, v)
+			ids[i] = fmt.Sprint(id)
+		}
+
+		// Round trip through JSON so fields are named, and hidden, as in the API
+		var fields map[string]interface{}
+		var encoded []byte
+		var err error
+		if v.CanAddr() {
+			encoded, err = json.Marshal(v.Addr().Interface())
+		} else {
+			encoded, err = json.Marshal(v.Interface())
+		}
+		if err == nil {
+			err = json.Unmarshal(encoded, &fields)
+		}
+		if err != nil {
+			log.Printf("[AUDIT] Failed to encode %s %s: %v", s.Table, strings.Join(ids, ":"), err)
+			continue
+		}
+		for name := range fields {
+			if !columns[name] {
+				delete(fields, name)
+			}
+		}
+		rows = append(rows, auditRow{id: strings.Join(ids, ":"), key: key, fields: fields})
+	}
+	return rows
+}
+
+// auditedFields returns the JSON names of the stored fields of schema s,
+// leaving out computed ones such as distances and associations
+func auditedFields(s *schema.Schema) map[string]bool {
+	names := map[string]bool{}
+	for _, field := range s.Fields {
+		if field.DBName == "" || !(field.Creatable || field.Updatable) {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// auditEntries compares rows before and after a write. Created rows list
+// their set fields, deleted rows the fields they had, and updated rows the
+// fields that changed; updates changing nothing aren't recorded.
+func auditEntries(actor AuditActor, action, table string, before, after []auditRow) []models.AuditLog {
+	now := time.Now()
+	previous := map[string]map[string]interface{}{}
+	for _, row := range before {
+		previous[row.id] = row.fields
+	}
+
+	var entries []models.AuditLog
+	add := func(id string, changes models.AuditChanges) {
+		if len(changes) == 0 {
+			return
+		}
+		entry := models.AuditLog{
+			ActorID:    actor.UserID,
+			Action:     action,
+			EntityType: table,
+			EntityID:   id,
+			Changes:    changes,
+			CreatedAt:  now,
+		}
+		if actor.IPAddress != "" {
+			entry.IPAddress = &actor.IPAddress
+		}
+		if actor.RequestID != "" {
+			entry.RequestID = &actor.RequestID
+		}
+		entries = append(entries, entry)
+	}
+
+	switch action {
+	case models.AuditCreate:
+		for _, row := range after {
+			changes := models.AuditChanges{}
+			for name, value := range row.fields {
+				if value != nil {
+					changes[name] = models.FieldChange{After: value}
+				}
+			}
+			add(row.id, changes)
+		}
+	case models.AuditDelete:
+		for _, row := range before {
+			changes := models.AuditChanges{}
+			for name, value := range row.fields {
+				if value != nil {
+					changes[name] = models.FieldChange{Before: value}
+				}
+			}
+			add(row.id, changes)
+		}
+	default:
+		for _, row := range after {
+			old := previous[row.id]
+			changes := models.AuditChanges{}
+			for name, value := range row.fields {
+				if !reflect.DeepEqual(old[name], value) {
+					changes[name] = models.FieldChange{Before: old[name], After: value}
+				}
+			}
+			add(row.id, changes)
+		}
+	}
+	return entries
+}
+
+// AuditFilter selects entries of the audit log. Zero fields match anything.
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	ActorID    *int
+	Action     string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time // exclusive
+}
+
+var auditLogKeyset = Keyset{Table: "audit_logs", Desc: true}
+
+// GetAuditLogs retrieves a page of the audit log, newest first
+func (s *Service) GetAuditLogs(filter AuditFilter, page PageRequest) (*Page[models.AuditLog], error) {
+	query := s.db.Model(&models.AuditLog{})
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	key := func(entry *models.AuditLog) (interface{}, interface{}) { return nil, entry.ID }
+	entries, err := Paginate(query, auditLogKeyset, page, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch audit log: %w", err)
+	}
+	return entries, nil
+}
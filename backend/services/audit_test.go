@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestAuditEntries(t *testing.T) {
+	userID := 3
+	actor := AuditActor{UserID: &userID, IPAddress: "10.0.0.1", RequestID: "req-1"}
+	row := func(id string, fields map[string]interface{}) auditRow {
+		return auditRow{id: id, fields: fields}
+	}
+
+	created := auditEntries(actor, models.AuditCreate, "aba_centers", nil,
+		[]auditRow{row("a", map[string]interface{}{"name": "Center", "notes": nil})})
+	if len(created) != 1 {
+		t.Fatalf("create entries = %+v, want one", created)
+	}
+	entry := created[0]
+	if entry.EntityID != "a" || entry.EntityType != "aba_centers" || entry.Action != models.AuditCreate ||
+		*entry.ActorID != userID || *entry.IPAddress != "10.0.0.1" || *entry.RequestID != "req-1" {
+		t.Errorf("create entry = %+v, want the row and the actor", entry)
+	}
+	if want := (models.AuditChanges{"name": {After: "Center"}}); !reflect.DeepEqual(entry.Changes, want) {
+		t.Errorf("create changes = %+v, want the set fields only %+v", entry.Changes, want)
+	}
+
+	before := []auditRow{
+		row("a", map[string]interface{}{"name": "Center", "city": "Irvine"}),
+		row("b", map[string]interface{}{"name": "Other", "city": "Tustin"}),
+	}
+	after := []auditRow{
+		row("a", map[string]interface{}{"name": "Renamed", "city": "Irvine"}),
+		row("b", map[string]interface{}{"name": "Other", "city": "Tustin"}),
+	}
+	updated := auditEntries(AuditActor{}, models.AuditUpdate, "aba_centers", before, after)
+	if len(updated) != 1 || updated[0].EntityID != "a" || updated[0].ActorID != nil || updated[0].IPAddress != nil {
+		t.Fatalf("update entries = %+v, want one anonymous entry for the changed row", updated)
+	}
+	if want := (models.AuditChanges{"name": {Before: "Center", After: "Renamed"}}); !reflect.DeepEqual(updated[0].Changes, want) {
+		t.Errorf("update changes = %+v, want %+v", updated[0].Changes, want)
+	}
+
+	deleted := auditEntries(actor, models.AuditDelete, "aba_centers", before[:1], nil)
+	if want := (models.AuditChanges{"name": {Before: "Center"}, "city": {Before: "Irvine"}}); len(deleted) != 1 || !reflect.DeepEqual(deleted[0].Changes, want) {
+		t.Errorf("delete entries = %+v, want the row's last fields %+v", deleted, want)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	base := testService(t)
+	userID := 7
+	s := base.WithContext(WithAuditActor(context.Background(), AuditActor{UserID: &userID, RequestID: "req-1"}))
+
+	center := models.ABACenter{Name: "Center", Street: "1 Main St", City: "Irvine"}
+	if err := s.CreateABACenter(&center); err != nil {
+		t.Fatalf("CreateABACenter: %v", err)
+	}
+	current, err := s.GetABACenterByID(center.ID)
+	if err != nil {
+		t.Fatalf("GetABACenterByID: %v", err)
+	}
+	edit := *current
+	edit.Name = "Renamed"
+	if err := s.UpdateABACenter(center.ID, &edit); err != nil {
+		t.Fatalf("UpdateABACenter: %v", err)
+	}
+	if err := s.DeleteABACenter(center.ID); err != nil {
+		t.Fatalf("DeleteABACenter: %v", err)
+	}
+	// Writes without an actor aren't recorded
+	other := models.ABACenter{Name: "Unaudited", Street: "2 Main St", City: "Irvine"}
+	if err := base.CreateABACenter(&other); err != nil {
+		t.Fatalf("CreateABACenter: %v", err)
+	}
+
+	page, err := s.GetAuditLogs(AuditFilter{EntityType: "aba_centers", RequestID: "req-1"}, PageRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetAuditLogs: %v", err)
+	}
+	var actions []string
+	for _, entry := range page.Items {
+		actions = append(actions, entry.Action)
+		if entry.EntityID != center.ID.String() || entry.ActorID == nil || *entry.ActorID != userID {
+			t.Errorf("entry = %+v, want the center, written by the user", entry)
+		}
+	}
+	if want := []string{models.AuditDelete, models.AuditUpdate, models.AuditCreate}; !reflect.DeepEqual(actions, want) {
+		t.Fatalf("actions = %v, want %v newest first", actions, want)
+	}
+
+	update := page.Items[1].Changes
+	if change, ok := update["name"]; !ok || change.Before != "Center" || change.After != "Renamed" {
+		t.Errorf("update changes = %+v, want the rename", update)
+	}
+	if _, ok := update["city"]; ok {
+		t.Errorf("update changes = %+v, want unchanged fields left out", update)
+	}
+
+	all, err := s.GetAuditLogs(AuditFilter{EntityID: other.ID.String()}, PageRequest{Limit: 10})
+	if err != nil || len(all.Items) != 0 {
+		t.Errorf("entries for the unaudited write = %+v, %v, want none", all, err)
+	}
+}
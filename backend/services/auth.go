@@ -0,0 +1,272 @@
+// services/auth.go
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// Session token lifetimes
+const (
+	AccessTokenTTL  = time.Hour
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// MinPasswordLength is the shortest password accepted by HashPassword
+const MinPasswordLength = 8
+
+var (
+	// ErrInvalidCredentials is returned when an email and password don't match
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidToken is returned for unknown, revoked or expired tokens
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// dummyPasswordHash is compared against when the email is unknown, so a
+// failed login takes as long whether or not the account exists
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// AuthTokens are the tokens issued on login and refresh
+type AuthTokens struct {
+	AccessToken      string       `json:"access_token"`
+	RefreshToken     string       `json:"refresh_token"`
+	TokenType        string       `json:"token_type"`
+	ExpiresAt        time.Time    `json:"expires_at"`
+	RefreshExpiresAt time.Time    `json:"refresh_expires_at"`
+	User             *models.User `json:"user"`
+}
+
+// HashPassword hashes a password with bcrypt
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", &ValidationError{Fields: map[string]string{
+			"password": fmt.Sprintf("must be at least %d characters", MinPasswordLength),
+		}}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Login checks an email and password and starts a new session
+func (s *Service) Login(email, password, userAgent, ipAddress string) (*AuthTokens, error) {
+	var user models.User
+	err := s.db.Preload("Roles").Where("LOWER(email) = LOWER(?)", strings.TrimSpace(email)).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	hash := []byte(user.PasswordHash)
+	if err != nil {
+		hash = dummyPasswordHash
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user.ID == 0 {
+		log.Printf("[AUTH] Failed login for %q from %s", email, ipAddress)
+		return nil, ErrInvalidCredentials
+	}
+	if user.DisabledAt != nil {
+		log.Printf("[AUTH] Disabled user %d tried to sign in from %s", user.ID, ipAddress)
+		return nil, ErrInvalidCredentials
+	}
+
+	// Drop sessions that can no longer be refreshed while we're here
+	if err := s.db.Where("refresh_expires_at < ?", time.Now()).Delete(&models.Session{}).Error; err != nil {
+		log.Printf("[AUTH] Failed to clean up expired sessions: %v", err)
+	}
+
+	session := models.Session{UserID: user.ID, UserAgent: userAgent, IPAddress: ipAddress}
+	tokens, err := issueTokens(&session)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(&session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	log.Printf("[AUTH] User %d signed in from %s", user.ID, ipAddress)
+	tokens.User = &user
+	return tokens, nil
+}
+
+// Refresh exchanges a refresh token for a new pair of tokens. The old
+// tokens stop working.
+func (s *Service) Refresh(refreshToken string) (*AuthTokens, error) {
+	var tokens *AuthTokens
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		err := tx.Where("refresh_token_hash = ? AND refresh_expires_at > ?", hashToken(refreshToken), time.Now()).
+			First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch session: %w", err)
+		}
+
+		if tokens, err = issueTokens(&session); err != nil {
+			return err
+		}
+		if err := tx.Save(&session).Error; err != nil {
+			return fmt.Errorf("failed to refresh session: %w", err)
+		}
+
+		var user models.User
+		if err := tx.Preload("Roles").Where("disabled_at IS NULL").First(&user, session.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("failed to fetch user: %w", err)
+		}
+		tokens.User = &user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Logout ends the session of an access token
+func (s *Service) Logout(accessToken string) error {
+	result := s.db.Where("access_token_hash = ?", hashToken(accessToken)).Delete(&models.Session{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to end session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// RevokeRefreshToken ends the session of a refresh token. Unlike Logout it
+// works after the session's access token has expired.
+func (s *Service) RevokeRefreshToken(refreshToken string) error {
+	result := s.db.Where("refresh_token_hash = ?", hashToken(refreshToken)).Delete(&models.Session{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to end session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// Authenticate returns the user of a valid access token, with their roles.
+// Tokens of disabled users are invalid.
+func (s *Service) Authenticate(accessToken string) (*models.User, error) {
+	var session models.Session
+	err := s.db.Where("access_token_hash = ? AND access_expires_at > ?", hashToken(accessToken), time.Now()).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch session: %w", err)
+	}
+
+	var user models.User
+	if err := s.db.Preload("Roles").Where("disabled_at IS NULL").First(&user, session.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	return &user, nil
+}
+
+// EnsureAdmin creates or updates the user with the given email, sets their
+// password, re-enables them and grants them the admin role
+func (s *Service) EnsureAdmin(email, password string) (*models.User, error) {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		role := models.Role{Name: models.RoleAdmin}
+		if err := tx.Where("name = ?", role.Name).FirstOrCreate(&role).Error; err != nil {
+			return fmt.Errorf("failed to create admin role: %w", err)
+		}
+
+		err := tx.Where("LOWER(email) = LOWER(?)", email).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user = models.User{Email: strings.TrimSpace(email), PasswordHash: hash}
+			if err := tx.Create(&user).Error; err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
+		case err != nil:
+			return fmt.Errorf("failed to fetch user: %w", err)
+		default:
+			if err := tx.Model(&user).Updates(map[string]interface{}{"password_hash": hash, "disabled_at": nil}).Error; err != nil {
+				return fmt.Errorf("failed to set password: %w", err)
+			}
+		}
+
+		if err := tx.Model(&user).Association("Roles").Append(&role); err != nil {
+			return fmt.Errorf("failed to grant admin role: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.InvalidatePermissions(user.ID)
+	return &user, nil
+}
+
+// issueTokens generates a new token pair for session and stores their hashes
+// and expiry times on it
+func issueTokens(session *models.Session) (*AuthTokens, error) {
+	accessToken, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session.AccessTokenHash = hashToken(accessToken)
+	session.AccessExpiresAt = now.Add(AccessTokenTTL)
+	session.RefreshTokenHash = hashToken(refreshToken)
+	session.RefreshExpiresAt = now.Add(RefreshTokenTTL)
+
+	return &AuthTokens{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresAt:        session.AccessExpiresAt,
+		RefreshExpiresAt: session.RefreshExpiresAt,
+	}, nil
+}
+
+// newToken returns 32 random bytes, base64url encoded
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
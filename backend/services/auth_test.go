@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestHashPassword(t *testing.T) {
+	if _, err := HashPassword("short"); invalidFields(t, err)[0] != "password" {
+		t.Errorf("HashPassword of a short password = %v, want a password error", err)
+	}
+	hash, err := HashPassword("long enough")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if hash == "long enough" {
+		t.Error("HashPassword returned the password")
+	}
+}
+
+func TestIssueTokens(t *testing.T) {
+	var session models.Session
+	tokens, err := issueTokens(&session)
+	if err != nil {
+		t.Fatalf("issueTokens: %v", err)
+	}
+	if tokens.AccessToken == tokens.RefreshToken {
+		t.Error("access and refresh tokens are the same")
+	}
+	if session.AccessTokenHash != hashToken(tokens.AccessToken) || session.RefreshTokenHash != hashToken(tokens.RefreshToken) {
+		t.Error("session does not store the hashes of the issued tokens")
+	}
+	if !session.RefreshExpiresAt.After(session.AccessExpiresAt) {
+		t.Errorf("refresh expires %v, want after the access token's %v", session.RefreshExpiresAt, session.AccessExpiresAt)
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	s := testService(t)
+	if _, err := s.EnsureAdmin("Admin@Example.com", "correct horse"); err != nil {
+		t.Fatalf("EnsureAdmin: %v", err)
+	}
+
+	if _, err := s.Login("admin@example.com", "wrong password", "test", "127.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login with a wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := s.Login("nobody@example.com", "correct horse", "test", "127.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login of an unknown email = %v, want ErrInvalidCredentials", err)
+	}
+
+	tokens, err := s.Login(" admin@example.com ", "correct horse", "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	user, err := s.Authenticate(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if len(user.Roles) != 1 || user.Roles[0].Name != models.RoleAdmin {
+		t.Errorf("roles = %+v, want admin", user.Roles)
+	}
+
+	refreshed, err := s.Refresh(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if _, err := s.Authenticate(tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Authenticate with the replaced access token = %v, want ErrInvalidToken", err)
+	}
+	if _, err := s.Refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second Refresh with the same token = %v, want ErrInvalidToken", err)
+	}
+
+	if err := s.Logout(refreshed.AccessToken); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, err := s.Authenticate(refreshed.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Authenticate after logout = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	s := testService(t)
+	if _, err := s.EnsureAdmin("admin@example.com", "correct horse"); err != nil {
+		t.Fatalf("EnsureAdmin: %v", err)
+	}
+	tokens, err := s.Login("admin@example.com", "correct horse", "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if err := s.RevokeRefreshToken(tokens.RefreshToken); err != nil {
+		t.Fatalf("RevokeRefreshToken: %v", err)
+	}
+	if _, err := s.Authenticate(tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Authenticate after revoking = %v, want ErrInvalidToken", err)
+	}
+	if err := s.RevokeRefreshToken(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second RevokeRefreshToken = %v, want ErrInvalidToken", err)
+	}
+}
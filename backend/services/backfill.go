@@ -0,0 +1,278 @@
+// services/backfill.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+const backfillBatchSize = 100
+
+// BackfillReport summarizes one coordinate backfill pass
+type BackfillReport struct {
+	Located  int64    `json:"located"`  // location column set from existing lat/lng
+	Parsed   int      `json:"parsed"`   // coordinates parsed from location_coordinates
+	Geocoded int      `json:"geocoded"` // coordinates looked up by address
+	Failed   int      `json:"failed"`
+	Failures []string `json:"failures"`
+}
+
+func (r *BackfillReport) fail(format string, args ...interface{}) {
+	r.Failed++
+	msg := fmt.Sprintf(format, args...)
+	r.Failures = append(r.Failures, msg)
+	log.Printf("[BACKFILL] %s", msg)
+}
+
+// backfillSkip remembers records that failed during this process so the
+// background worker doesn't retry them every interval
+type backfillSkip map[string]bool
+
+// BackfillCoordinates fills in coordinates for every record lacking them:
+// regional centers are parsed from location_coordinates when possible and
+// geocoded otherwise, ABA centers are geocoded from their address, provider
+// coverage areas are geocoded, and the PostGIS location column is set from
+// latitude/longitude everywhere it is missing.
+func (s *Service) BackfillCoordinates(ctx context.Context) (*BackfillReport, error) {
+	return s.backfillCoordinates(ctx, nil)
+}
+
+func (s *Service) backfillCoordinates(ctx context.Context, skip backfillSkip) (*BackfillReport, error) {
+	report := &BackfillReport{Failures: []string{}}
+	start := time.Now()
+	log.Printf("[BACKFILL] Starting coordinate backfill")
+
+	if err := s.backfillRegionalCenters(ctx, report, skip); err != nil {
+		return report, err
+	}
+	if s.geocoder != nil {
+		if err := s.backfillABACenters(ctx, report, skip); err != nil {
+			return report, err
+		}
+		if err := s.backfillProviderAreas(ctx, report, skip); err != nil {
+			return report, err
+		}
+	} else {
+		log.Printf("[BACKFILL] Geocoding disabled, only parsing location_coordinates")
+	}
+
+	located, err := s.backfillLocations()
+	if err != nil {
+		return report, err
+	}
+	report.Located = located
+
+	log.Printf("[BACKFILL] Finished in %s: %d parsed, %d geocoded, %d locations set, %d failed",
+		time.Since(start).Round(time.Millisecond), report.Parsed, report.Geocoded, report.Located, report.Failed)
+	return report, nil
+}
+
+// StartBackfillWorker runs BackfillCoordinates immediately and then every
+// interval until ctx is cancelled
+func (s *Service) StartBackfillWorker(ctx context.Context, interval time.Duration) {
+	skip := backfillSkip{}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.backfillCoordinates(ctx, skip); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("[BACKFILL] Pass failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				log.Printf("[BACKFILL] Worker stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Service) backfillRegionalCenters(ctx context.Context, report *BackfillReport, skip backfillSkip) error {
+	var centers []models.RegionalCenter
+	query := s.db.WithContext(ctx).Where("latitude IS NULL OR longitude IS NULL")
+
+	return query.FindInBatches(&centers, backfillBatchSize, func(tx *gorm.DB, batch int) error {
+		log.Printf("[BACKFILL] regional_centers batch %d: %d records", batch, len(centers))
+		for i := range centers {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			center := &centers[i]
+			key := fmt.Sprintf("regional_centers:%d", center.ID)
+			if skip[key] {
+				continue
+			}
+
+			source := "parsed"
+			lat, lng, err := regionalCenterCoordinates(center)
+			if err != nil {
+				source = "geocoded"
+				if err = s.GeocodeRegionalCenter(ctx, center); err == nil {
+					lat, lng = *center.Latitude, *center.Longitude
+				}
+			}
+			if err != nil {
+				if errors.Is(err, ErrGeocodingDisabled) {
+					continue
+				}
+				if skip != nil {
+					skip[key] = true
+				}
+				report.fail("regional center %d: %v", center.ID, err)
+				continue
+			}
+
+			if err := s.saveCoordinates(ctx, &models.RegionalCenter{}, center.ID, lat, lng); err != nil {
+				return err
+			}
+			if source == "parsed" {
+				report.Parsed++
+			} else {
+				report.Geocoded++
+			}
+		}
+		return nil
+	}).Error
+}
+
+func (s *Service) backfillABACenters(ctx context.Context, report *BackfillReport, skip backfillSkip) error {
+	var centers []models.ABACenter
+	query := s.db.WithContext(ctx).Where("latitude IS NULL OR longitude IS NULL")
+
+	return query.FindInBatches(&centers, backfillBatchSize, func(tx *gorm.DB, batch int) error {
+		log.Printf("[BACKFILL] aba_centers batch %d: %d records", batch, len(centers))
+		for i := range centers {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			center := &centers[i]
+			key := "aba_centers:" + center.ID.String()
+			if skip[key] {
+				continue
+			}
+
+			if err := s.GeocodeABACenter(ctx, center); err != nil {
+				if skip != nil {
+					skip[key] = true
+				}
+				report.fail("ABA center %s (%s): %v", center.ID, center.Name, err)
+				continue
+			}
+
+			if err := s.saveCoordinates(ctx, &models.ABACenter{}, center.ID, *center.Latitude, *center.Longitude); err != nil {
+				return err
+			}
+			report.Geocoded++
+		}
+		return nil
+	}).Error
+}
+
+func (s *Service) backfillProviderAreas(ctx context.Context, report *BackfillReport, skip backfillSkip) error {
+	var providers []models.Provider
+	query := s.db.WithContext(ctx).
+		Where("(cardinality(areas) > 0 OR COALESCE(coverage_areas, '') <> '')").
+		Where("NOT EXISTS (SELECT 1 FROM provider_areas WHERE provider_areas.provider_id = providers.id)")
+
+	return query.FindInBatches(&providers, backfillBatchSize, func(tx *gorm.DB, batch int) error {
+		log.Printf("[BACKFILL] providers batch %d: %d records", batch, len(providers))
+		for i := range providers {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			provider := &providers[i]
+			key := fmt.Sprintf("providers:%d", provider.ID)
+			if skip[key] {
+				continue
+			}
+
+			if err := s.GeocodeProviderAreas(ctx, provider); err != nil {
+				if skip != nil {
+					skip[key] = true
+				}
+				report.fail("provider %d (%s): %v", provider.ID, provider.Name, err)
+				continue
+			}
+			report.Geocoded++
+		}
+		return nil
+	}).Error
+}
+
+// saveCoordinates writes latitude, longitude and, with PostGIS, location.
+// Filling in coordinates isn't an edit, so UpdateColumns leaves updated_at
+// alone and edits started before the backfill still save.
+func (s *Service) saveCoordinates(ctx context.Context, model interface{}, id interface{}, lat, lng float64) error {
+	updates := map[string]interface{}{
+		"latitude":  lat,
+		"longitude": lng,
+	}
+	if s.postgis {
+		updates["location"] = models.NewPoint(lat, lng)
+	}
+	if err := s.db.WithContext(ctx).Model(model).Where("id = ?", id).UpdateColumns(updates).Error; err != nil {
+		return fmt.Errorf("failed to save coordinates: %w", err)
+	}
+	return nil
+}
+
+// regionalCenterCoordinates parses a regional center's location_coordinates
+func regionalCenterCoordinates(center *models.RegionalCenter) (lat, lng float64, err error) {
+	if center.LocationCoordinates == nil || strings.TrimSpace(*center.LocationCoordinates) == "" {
+		return 0, 0, errors.New("no location_coordinates")
+	}
+	return ParseCoordinates(*center.LocationCoordinates)
+}
+
+var coordinatePattern = regexp.MustCompile(`[-+]?\d+(?:\.\d+)?`)
+
+// ParseCoordinates reads a free-form coordinate string such as
+// "34.0522, -118.2437", "(34.0522,-118.2437)" or "POINT(-118.2437 34.0522)".
+// Plain pairs are read as latitude then longitude unless the first value is
+// out of latitude range, in which case they are swapped.
+func ParseCoordinates(text string) (lat, lng float64, err error) {
+	text = strings.TrimSpace(text)
+	upper := strings.ToUpper(text)
+	if strings.HasPrefix(upper, "POINT") || strings.HasPrefix(upper, "SRID=") {
+		var p models.Point
+		if err := p.Scan(text); err != nil {
+			return 0, 0, err
+		}
+		return p.Lat, p.Lng, validateCoordinates(p.Lat, p.Lng)
+	}
+
+	values := coordinatePattern.FindAllString(text, -1)
+	if len(values) != 2 {
+		return 0, 0, fmt.Errorf("cannot parse coordinates %q", text)
+	}
+	first, _ := strconv.ParseFloat(values[0], 64)
+	second, _ := strconv.ParseFloat(values[1], 64)
+
+	lat, lng = first, second
+	if math.Abs(first) > 90 {
+		lat, lng = second, first
+	}
+	return lat, lng, validateCoordinates(lat, lng)
+}
+
+func validateCoordinates(lat, lng float64) error {
+	if math.Abs(lat) > 90 || math.Abs(lng) > 180 {
+		return fmt.Errorf("coordinates out of range: %f, %f", lat, lng)
+	}
+	if lat == 0 && lng == 0 {
+		return fmt.Errorf("coordinates are 0, 0")
+	}
+	return nil
+}
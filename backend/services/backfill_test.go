@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestParseCoordinates(t *testing.T) {
+	tests := []struct {
+		text     string
+		lat, lng float64
+	}{
+		{"34.0522, -118.2437", 34.0522, -118.2437},
+		{"(34.0522,-118.2437)", 34.0522, -118.2437},
+		{"-118.2437 34.0522", 34.0522, -118.2437},
+		{"POINT(-118.2437 34.0522)", 34.0522, -118.2437},
+		{"SRID=4326;POINT(-118.2437 34.0522)", 34.0522, -118.2437},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			lat, lng, err := ParseCoordinates(tt.text)
+			if err != nil {
+				t.Fatalf("ParseCoordinates: %v", err)
+			}
+			if lat != tt.lat || lng != tt.lng {
+				t.Errorf("ParseCoordinates = %v, %v, want %v, %v", lat, lng, tt.lat, tt.lng)
+			}
+		})
+	}
+
+	for _, text := range []string{"", "34.0522", "1, 2, 3", "0, 0", "95, 200", "POINT(1)"} {
+		if lat, lng, err := ParseCoordinates(text); err == nil {
+			t.Errorf("ParseCoordinates(%q) = %v, %v, want an error", text, lat, lng)
+		}
+	}
+}
+
+func TestSaveCoordinatesKeepsUpdatedAt(t *testing.T) {
+	s := testService(t)
+	name := "North LA County"
+	center := models.RegionalCenter{RegionalCenter: &name}
+	if err := s.CreateRegionalCenter(&center); err != nil {
+		t.Fatalf("CreateRegionalCenter: %v", err)
+	}
+	if err := s.db.First(&center, "id = ?", center.ID).Error; err != nil {
+		t.Fatalf("reading regional center: %v", err)
+	}
+
+	if err := s.saveCoordinates(context.Background(), &models.RegionalCenter{}, center.ID, 34.2, -118.5); err != nil {
+		t.Fatalf("saveCoordinates: %v", err)
+	}
+
+	var saved models.RegionalCenter
+	if err := s.db.First(&saved, "id = ?", center.ID).Error; err != nil {
+		t.Fatalf("reading regional center: %v", err)
+	}
+	if saved.Latitude == nil || *saved.Latitude != 34.2 || saved.Longitude == nil || *saved.Longitude != -118.5 {
+		t.Errorf("coordinates = %v, %v, want 34.2, -118.5", saved.Latitude, saved.Longitude)
+	}
+	if !sameTime(saved.UpdatedAt, center.UpdatedAt) {
+		t.Errorf("updated_at = %v, want it unchanged at %v", saved.UpdatedAt, center.UpdatedAt)
+	}
+
+	// An edit read before the backfill still saves
+	edit := center
+	if err := s.UpdateRegionalCenter(center.ID, &edit); err != nil {
+		t.Errorf("UpdateRegionalCenter after the backfill: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/alexbeattie/medicalfacilities/config"
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// Tests needing Postgres run against the database of TEST_DATABASE_URL,
+// which must have PostGIS available, and are skipped without it. Each test
+// runs in a transaction rolled back when it ends.
+var (
+	testDBOnce sync.Once
+	testDBConn *gorm.DB
+	testDBErr  error
+)
+
+// testService returns a Service over a transaction of the test database,
+// with audit recording enabled
+func testService(t *testing.T) *Service {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	testDBOnce.Do(func() {
+		testDBConn, testDBErr = openTestDB(dsn)
+	})
+	if testDBErr != nil {
+		t.Fatalf("opening test database: %v", testDBErr)
+	}
+
+	tx := testDBConn.Begin()
+	if tx.Error != nil {
+		t.Fatalf("beginning transaction: %v", tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return NewService(tx, &config.Config{DeviceCookieSecret: []byte("test")})
+}
+
+func openTestDB(dsn string) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, err
+	}
+	for _, extension := range []string{`"uuid-ossp"`, "postgis"} {
+		if err := db.Exec("CREATE EXTENSION IF NOT EXISTS " + extension).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := db.AutoMigrate(
+		&models.ABACenter{},
+		&models.Diagnosis{},
+		&models.Resource{},
+		&models.ResourceCenter{},
+		&models.RegionalCenter{},
+		&models.Provider{},
+		&models.ProviderArea{},
+		&models.CenterDiagnosis{},
+		&models.Permission{},
+		&models.Role{},
+		&models.User{},
+		&models.Session{},
+		&models.UserToken{},
+		&models.UserPreferences{},
+		&models.FormSubmission{},
+		&models.SubmissionNote{},
+		&models.InsuranceCarrier{},
+		&models.InsurancePlan{},
+		&models.ABACenterCarrier{},
+		&models.ABACenterPlan{},
+		&models.WaitlistChange{},
+		&models.AuditLog{},
+	); err != nil {
+		return nil, err
+	}
+	if err := NewService(db, &config.Config{}).InitAudit(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,470 @@
+// services/export.go
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// Formats of ExportFacilities
+const (
+	ExportCSV     = "csv"
+	ExportGeoJSON = "geojson"
+	ExportKML     = "kml"
+)
+
+// ExportFormats lists the formats ExportFacilities writes
+var ExportFormats = []string{ExportCSV, ExportGeoJSON, ExportKML}
+
+// ExportEntityTypes lists the entity types ExportFacilities writes
+var ExportEntityTypes = NearbyEntityTypes
+
+// exportRecord is one exported facility. values line up with the columns of
+// its exportTable.
+type exportRecord struct {
+	id       string
+	name     string
+	location *models.Point
+	values   []interface{}
+}
+
+// exportTable describes how one entity type is exported
+type exportTable struct {
+	entityType string
+	label      string // names the KML folder
+	columns    []string
+	each       func(s *Service, filter *models.SearchFilter, fn func(exportRecord) error) error
+}
+
+// exportColumn is a column of an export and how to read it from a row
+type exportColumn[T any] struct {
+	name  string
+	value func(*T) interface{}
+}
+
+// newExportTable builds the exportTable of a list, walking the rows of query
+// in list order
+func newExportTable[T any](entityType, label string, spec listSpec[T], query func(*Service, *models.SearchFilter) *gorm.DB,
+	location func(*T) *models.Point, columns ...exportColumn[T]) exportTable {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+	return exportTable{
+		entityType: entityType,
+		label:      label,
+		columns:    names,
+		each: func(s *Service, filter *models.SearchFilter, fn func(exportRecord) error) error {
+			return listEach(s, query(s, filter), spec, filter, func(row *T) error {
+				record := exportRecord{
+					id:       fmt.Sprint(spec.id(row)),
+					name:     spec.name(row),
+					location: location(row),
+					values:   make([]interface{}, len(columns)),
+				}
+				for i, column := range columns {
+					record.values[i] = column.value(row)
+				}
+				return fn(record)
+			})
+		},
+	}
+}
+
+// pointOf returns a stored location, or else one built from coordinates
+func pointOf(location *models.Point, lat, lng *float64) *models.Point {
+	if location != nil {
+		return location
+	}
+	if lat != nil && lng != nil {
+		return models.NewPoint(*lat, *lng)
+	}
+	return nil
+}
+
+// freshnessColumns are exported for every facility
+func freshnessColumns[T any](verification func(*T) *models.Verification, distance func(*T) *float64) []exportColumn[T] {
+	return []exportColumn[T]{
+		{"distance_miles", func(row *T) interface{} { return distance(row) }},
+		{"last_verified_at", func(row *T) interface{} { return verification(row).LastVerifiedAt }},
+		{"freshness", func(row *T) interface{} { return verification(row).Freshness }},
+	}
+}
+
+var exportTables = map[string]exportTable{
+	"aba_centers": newExportTable("aba_centers", "ABA centers", abaCenterList, (*Service).abaCenterQuery,
+		func(c *models.ABACenter) *models.Point { return pointOf(c.Location, c.Latitude, c.Longitude) },
+		append([]exportColumn[models.ABACenter]{
+			{"id", func(c *models.ABACenter) interface{} { return c.ID }},
+			{"name", func(c *models.ABACenter) interface{} { return c.Name }},
+			{"street", func(c *models.ABACenter) interface{} { return c.Street }},
+			{"city", func(c *models.ABACenter) interface{} { return c.City }},
+			{"zip", func(c *models.ABACenter) interface{} { return c.Zip }},
+			{"phone", func(c *models.ABACenter) interface{} { return c.Phone }},
+			{"service_type", func(c *models.ABACenter) interface{} { return c.ServiceType }},
+			{"waitlist_status", func(c *models.ABACenter) interface{} { return c.WaitlistStatus }},
+			{"waitlist_weeks", func(c *models.ABACenter) interface{} { return c.WaitlistWeeks }},
+			{"waitlist_availability", func(c *models.ABACenter) interface{} { return c.WaitlistAvailability }},
+			{"waitlist_notes", func(c *models.ABACenter) interface{} { return c.WaitlistNotes }},
+			{"dx_verification", func(c *models.ABACenter) interface{} { return c.DxVerification }},
+			{"insurance_accepted", func(c *models.ABACenter) interface{} { return c.InsuranceAccepted }},
+			{"carriers", func(c *models.ABACenter) interface{} {
+				names := make([]string, len(c.Carriers))
+				for i, carrier := range c.Carriers {
+					names[i] = carrier.Name
+				}
+				return names
+			}},
+			{"plans", func(c *models.ABACenter) interface{} {
+				names := make([]string, len(c.Plans))
+				for i, plan := range c.Plans {
+					names[i] = plan.Name
+				}
+				return names
+			}},
+			{"medi_cal_plans", func(c *models.ABACenter) interface{} { return c.MediCalPlans }},
+			{"notes", func(c *models.ABACenter) interface{} { return c.Notes }},
+			{"latitude", func(c *models.ABACenter) interface{} { return c.Latitude }},
+			{"longitude", func(c *models.ABACenter) interface{} { return c.Longitude }},
+		}, freshnessColumns(
+			func(c *models.ABACenter) *models.Verification { return &c.Verification },
+			func(c *models.ABACenter) *float64 { return c.DistanceMiles })...)...),
+
+	"resource_centers": newExportTable("resource_centers", "Resource centers", resourceCenterList, (*Service).resourceCenterQuery,
+		func(c *models.ResourceCenter) *models.Point { return pointOf(c.Location, &c.Latitude, &c.Longitude) },
+		append([]exportColumn[models.ResourceCenter]{
+			{"id", func(c *models.ResourceCenter) interface{} { return c.ID }},
+			{"name", func(c *models.ResourceCenter) interface{} { return c.Name }},
+			{"description", func(c *models.ResourceCenter) interface{} { return c.Description }},
+			{"address", func(c *models.ResourceCenter) interface{} { return c.Address }},
+			{"diagnoses", func(c *models.ResourceCenter) interface{} {
+				names := make([]string, len(c.Diagnoses))
+				for i, diagnosis := range c.Diagnoses {
+					names[i] = diagnosis.Name
+				}
+				return names
+			}},
+			{"latitude", func(c *models.ResourceCenter) interface{} { return c.Latitude }},
+			{"longitude", func(c *models.ResourceCenter) interface{} { return c.Longitude }},
+		}, freshnessColumns(
+			func(c *models.ResourceCenter) *models.Verification { return &c.Verification },
+			func(c *models.ResourceCenter) *float64 { return c.DistanceMiles })...)...),
+
+	"regional_centers": newExportTable("regional_centers", "Regional centers", regionalCenterList, (*Service).regionalCenterQuery,
+		func(c *models.RegionalCenter) *models.Point { return pointOf(c.Location, c.Latitude, c.Longitude) },
+		append([]exportColumn[models.RegionalCenter]{
+			{"id", func(c *models.RegionalCenter) interface{} { return c.ID }},
+			{"regional_center", func(c *models.RegionalCenter) interface{} { return c.RegionalCenter }},
+			{"office_type", func(c *models.RegionalCenter) interface{} { return c.OfficeType }},
+			{"address", func(c *models.RegionalCenter) interface{} { return c.Address }},
+			{"suite", func(c *models.RegionalCenter) interface{} { return c.Suite }},
+			{"city", func(c *models.RegionalCenter) interface{} { return c.City }},
+			{"state", func(c *models.RegionalCenter) interface{} { return c.State }},
+			{"zip_code", func(c *models.RegionalCenter) interface{} { return c.ZipCode }},
+			{"telephone", func(c *models.RegionalCenter) interface{} { return c.Telephone }},
+			{"website", func(c *models.RegionalCenter) interface{} { return c.Website }},
+			{"county_served", func(c *models.RegionalCenter) interface{} { return c.CountyServed }},
+			{"latitude", func(c *models.RegionalCenter) interface{} { return c.Latitude }},
+			{"longitude", func(c *models.RegionalCenter) interface{} { return c.Longitude }},
+		}, freshnessColumns(
+			func(c *models.RegionalCenter) *models.Verification { return &c.Verification },
+			func(c *models.RegionalCenter) *float64 { return c.DistanceMiles })...)...),
+
+	"resources": newExportTable("resources", "Resources", resourceList, (*Service).resourceQuery,
+		func(r *models.Resource) *models.Point { return pointOf(r.Location, &r.Latitude, &r.Longitude) },
+		append([]exportColumn[models.Resource]{
+			{"id", func(r *models.Resource) interface{} { return r.ID }},
+			{"name", func(r *models.Resource) interface{} { return r.Name }},
+			{"description", func(r *models.Resource) interface{} { return r.Description }},
+			{"address", func(r *models.Resource) interface{} { return r.Address }},
+			{"diagnoses", func(r *models.Resource) interface{} { return r.Diagnoses }},
+			{"contact_info", func(r *models.Resource) interface{} { return r.ContactInfo }},
+			{"latitude", func(r *models.Resource) interface{} { return r.Latitude }},
+			{"longitude", func(r *models.Resource) interface{} { return r.Longitude }},
+		}, freshnessColumns(
+			func(r *models.Resource) *models.Verification { return &r.Verification },
+			func(r *models.Resource) *float64 { return r.DistanceMiles })...)...),
+}
+
+// ExportFacilities writes the facilities of entityTypes matching filter to w
+// in format, one type after the other, each in the order of its list
+// endpoint. Rows are loaded in batches and written as they arrive, so
+// exports of any size stream in constant memory. CSV exports of several
+// types add an entity_type column and leave the columns of other types
+// empty.
+func (s *Service) ExportFacilities(filter *models.SearchFilter, entityTypes []string, format string, w io.Writer) error {
+	tables := make([]exportTable, 0, len(entityTypes))
+	for _, entityType := range entityTypes {
+		table, ok := exportTables[entityType]
+		if !ok {
+			return &ValidationError{Fields: map[string]string{
+				"types": "must be some of " + strings.Join(ExportEntityTypes, ", "),
+			}}
+		}
+		tables = append(tables, table)
+	}
+
+	var writer exportWriter
+	buffer := bufio.NewWriter(w)
+	switch format {
+	case ExportCSV:
+		writer = newCSVExport(buffer, tables)
+	case ExportGeoJSON:
+		writer = &geoJSONExport{w: buffer}
+	case ExportKML:
+		writer = &kmlExport{w: buffer}
+	default:
+		return &ValidationError{Fields: map[string]string{"format": "must be one of " + strings.Join(ExportFormats, ", ")}}
+	}
+
+	if err := writer.begin(); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err := writer.table(table); err != nil {
+			return err
+		}
+		err := table.each(s, filter, func(record exportRecord) error {
+			return writer.record(table, record)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", table.entityType, err)
+		}
+	}
+	if err := writer.end(); err != nil {
+		return err
+	}
+	return buffer.Flush()
+}
+
+// exportWriter writes one export format
+type exportWriter interface {
+	begin() error
+	table(table exportTable) error // starts the records of another type
+	record(table exportTable, record exportRecord) error
+	end() error
+}
+
+// exportText formats an exported value as text, empty for null
+func exportText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *string:
+		return Deref(v)
+	case *int:
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case []string:
+		return strings.Join(v, "; ")
+	case models.ContactInfo:
+		if len(v) == 0 {
+			return ""
+		}
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// csvExport writes one row per facility under a header naming the columns
+// of every exported type
+type csvExport struct {
+	w          *csv.Writer
+	header     []string
+	index      map[string]int // column position in header
+	entityType bool           // whether the first column is entity_type
+}
+
+func newCSVExport(w io.Writer, tables []exportTable) *csvExport {
+	export := &csvExport{w: csv.NewWriter(w), index: map[string]int{}, entityType: len(tables) > 1}
+	if export.entityType {
+		export.header = append(export.header, "entity_type")
+	}
+	for _, table := range tables {
+		for _, column := range table.columns {
+			if _, ok := export.index[column]; !ok {
+				export.index[column] = len(export.header)
+				export.header = append(export.header, column)
+			}
+		}
+	}
+	return export
+}
+
+func (e *csvExport) begin() error {
+	return e.w.Write(e.header)
+}
+
+func (e *csvExport) table(exportTable) error {
+	return nil
+}
+
+func (e *csvExport) record(table exportTable, record exportRecord) error {
+	row := make([]string, len(e.header))
+	if e.entityType {
+		row[0] = table.entityType
+	}
+	for i, column := range table.columns {
+		text := exportText(record.values[i])
+		switch record.values[i].(type) {
+		case int, *int, float64, *float64:
+		default:
+			// Numbers may start with a minus; text mustn't run as a formula
+			text = csvSafe([]string{text})[0]
+		}
+		row[e.index[column]] = text
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvExport) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// geoJSONExport writes a FeatureCollection with a Point feature per
+// facility; facilities without coordinates have a null geometry
+type geoJSONExport struct {
+	w        io.Writer
+	features int
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id"`
+	Geometry   *geoJSONPoint          `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // longitude, latitude
+}
+
+func (e *geoJSONExport) begin() error {
+	_, err := io.WriteString(e.w, `{"type":"FeatureCollection","features":[`)
+	return err
+}
+
+func (e *geoJSONExport) table(exportTable) error {
+	return nil
+}
+
+func (e *geoJSONExport) record(table exportTable, record exportRecord) error {
+	feature := geoJSONFeature{
+		Type:       "Feature",
+		ID:         record.id,
+		Properties: map[string]interface{}{"entity_type": table.entityType},
+	}
+	if record.location != nil {
+		feature.Geometry = &geoJSONPoint{Type: "Point", Coordinates: [2]float64{record.location.Lng, record.location.Lat}}
+	}
+	for i, column := range table.columns {
+		feature.Properties[column] = record.values[i]
+	}
+	data, err := json.Marshal(feature)
+	if err != nil {
+		return err
+	}
+
+	if e.features > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.features++
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *geoJSONExport) end() error {
+	_, err := io.WriteString(e.w, "]}\n")
+	return err
+}
+
+// kmlExport writes a KML document for Google Earth with a folder per type
+// and a placemark per facility, carrying the columns as extended data
+type kmlExport struct {
+	w      io.Writer
+	folder bool // a Folder element is open
+	err    error
+}
+
+// write writes the concatenated parts, escaping those that are values
+func (e *kmlExport) write(parts ...string) {
+	for i, part := range parts {
+		if e.err != nil {
+			return
+		}
+		if i%2 == 1 {
+			e.err = xml.EscapeText(e.w, []byte(part))
+		} else {
+			_, e.err = io.WriteString(e.w, part)
+		}
+	}
+}
+
+func (e *kmlExport) begin() error {
+	e.write(xml.Header + `<kml xmlns="http://www.opengis.net/kml/2.2"><Document><name>Medical facilities</name>` + "\n")
+	return e.err
+}
+
+func (e *kmlExport) table(table exportTable) error {
+	if e.folder {
+		e.write("</Folder>\n")
+	}
+	e.folder = true
+	e.write("<Folder><name>", table.label, "</name>\n")
+	return e.err
+}
+
+func (e *kmlExport) record(table exportTable, record exportRecord) error {
+	e.write(`<Placemark id="`, table.entityType+"-"+record.id, `"><name>`, record.name, "</name><ExtendedData>")
+	for i, column := range table.columns {
+		e.write(`<Data name="`, column, `"><value>`, exportText(record.values[i]), "</value></Data>")
+	}
+	e.write("</ExtendedData>")
+	if record.location != nil {
+		e.write("<Point><coordinates>",
+			strconv.FormatFloat(record.location.Lng, 'f', -1, 64)+","+strconv.FormatFloat(record.location.Lat, 'f', -1, 64),
+			"</coordinates></Point>")
+	}
+	e.write("</Placemark>\n")
+	return e.err
+}
+
+func (e *kmlExport) end() error {
+	if e.folder {
+		e.write("</Folder>")
+	}
+	e.write("</Document></kml>\n")
+	return e.err
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// withExportTable registers a table of fixed records for one test
+func withExportTable(t *testing.T, entityType string, columns []string, records ...exportRecord) {
+	t.Helper()
+	exportTables[entityType] = exportTable{
+		entityType: entityType,
+		label:      "Test " + entityType,
+		columns:    columns,
+		each: func(s *Service, filter *models.SearchFilter, fn func(exportRecord) error) error {
+			for _, record := range records {
+				if err := fn(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	t.Cleanup(func() { delete(exportTables, entityType) })
+}
+
+func testExport(t *testing.T, entityTypes []string, format string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := (&Service{}).ExportFacilities(nil, entityTypes, format, &buf); err != nil {
+		t.Fatalf("ExportFacilities(%s): %v", format, err)
+	}
+	return buf.String()
+}
+
+func TestExportFormats(t *testing.T) {
+	weeks := 4
+	withExportTable(t, "test_centers", []string{"name", "waitlist_weeks", "notes"},
+		exportRecord{id: "1", name: "Center & Co", location: models.NewPoint(33.6, -117.8),
+			values: []interface{}{"Center & Co", &weeks, "=HYPERLINK()"}},
+		exportRecord{id: "2", name: "Unmapped", values: []interface{}{"Unmapped", (*int)(nil), nil}})
+	withExportTable(t, "test_resources", []string{"name", "diagnoses"},
+		exportRecord{id: "3", name: "Resource", values: []interface{}{"Resource", []string{"Autism", "ADHD"}}})
+
+	t.Run("csv", func(t *testing.T) {
+		rows, err := csv.NewReader(strings.NewReader(testExport(t, []string{"test_centers", "test_resources"}, ExportCSV))).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := [][]string{
+			{"entity_type", "name", "waitlist_weeks", "notes", "diagnoses"},
+			{"test_centers", "Center & Co", "4", "'=HYPERLINK()", ""},
+			{"test_centers", "Unmapped", "", "", ""},
+			{"test_resources", "Resource", "", "", "Autism; ADHD"},
+		}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("rows = %q, want %q", rows, want)
+		}
+
+		// A single type has no entity_type column
+		rows, _ = csv.NewReader(strings.NewReader(testExport(t, []string{"test_resources"}, ExportCSV))).ReadAll()
+		if want := [][]string{{"name", "diagnoses"}, {"Resource", "Autism; ADHD"}}; !reflect.DeepEqual(rows, want) {
+			t.Errorf("single type rows = %q, want %q", rows, want)
+		}
+	})
+
+	t.Run("geojson", func(t *testing.T) {
+		var collection struct {
+			Type     string
+			Features []geoJSONFeature
+		}
+		if err := json.Unmarshal([]byte(testExport(t, []string{"test_centers", "test_resources"}, ExportGeoJSON)), &collection); err != nil {
+			t.Fatal(err)
+		}
+		if collection.Type != "FeatureCollection" || len(collection.Features) != 3 {
+			t.Fatalf("collection = %+v, want 3 features", collection)
+		}
+		first := collection.Features[0]
+		if first.ID != "1" || first.Geometry == nil || first.Geometry.Coordinates != [2]float64{-117.8, 33.6} {
+			t.Errorf("first feature = %+v, want a point at longitude, latitude", first)
+		}
+		if first.Properties["entity_type"] != "test_centers" || first.Properties["waitlist_weeks"] != float64(4) {
+			t.Errorf("first properties = %v, want the type and typed values", first.Properties)
+		}
+		if collection.Features[1].Geometry != nil {
+			t.Errorf("unmapped geometry = %+v, want null", collection.Features[1].Geometry)
+		}
+
+		// No records is still a valid collection
+		if got := testExport(t, []string{}, ExportGeoJSON); got != `{"type":"FeatureCollection","features":[]}`+"\n" {
+			t.Errorf("empty export = %s", got)
+		}
+	})
+
+	t.Run("kml", func(t *testing.T) {
+		var document struct {
+			Folders []struct {
+				Name       string `xml:"name"`
+				Placemarks []struct {
+					ID          string `xml:"id,attr"`
+					Name        string `xml:"name"`
+					Coordinates string `xml:"Point>coordinates"`
+					Data        []struct {
+						Name  string `xml:"name,attr"`
+						Value string `xml:"value"`
+					} `xml:"ExtendedData>Data"`
+				} `xml:"Placemark"`
+			} `xml:"Document>Folder"`
+		}
+		if err := xml.Unmarshal([]byte(testExport(t, []string{"test_centers", "test_resources"}, ExportKML)), &document); err != nil {
+			t.Fatal(err)
+		}
+		if len(document.Folders) != 2 || document.Folders[0].Name != "Test test_centers" || len(document.Folders[0].Placemarks) != 2 {
+			t.Fatalf("folders = %+v, want one per type", document.Folders)
+		}
+		placemark := document.Folders[0].Placemarks[0]
+		if placemark.ID != "test_centers-1" || placemark.Name != "Center & Co" || placemark.Coordinates != "-117.8,33.6" {
+			t.Errorf("placemark = %+v, want the escaped name at longitude,latitude", placemark)
+		}
+		if len(placemark.Data) != 3 || placemark.Data[1].Name != "waitlist_weeks" || placemark.Data[1].Value != "4" {
+			t.Errorf("data = %+v, want a Data element per column", placemark.Data)
+		}
+		if document.Folders[0].Placemarks[1].Coordinates != "" {
+			t.Errorf("unmapped placemark = %+v, want no point", document.Folders[0].Placemarks[1])
+		}
+	})
+}
+
+func TestExportErrors(t *testing.T) {
+	var buf bytes.Buffer
+	var verr *ValidationError
+	if err := (&Service{}).ExportFacilities(nil, []string{"users"}, ExportCSV, &buf); !errors.As(err, &verr) || verr.Fields["types"] == "" {
+		t.Errorf("unknown type: %v, want a types validation error", err)
+	}
+	if err := (&Service{}).ExportFacilities(nil, []string{"aba_centers"}, "pdf", &buf); !errors.As(err, &verr) || verr.Fields["format"] == "" {
+		t.Errorf("unknown format: %v, want a format validation error", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %q before failing, want nothing", buf.String())
+	}
+}
+
+func TestExportABACenters(t *testing.T) {
+	s := testService(t)
+	lat, lng := 33.68, -117.82
+	for _, center := range []models.ABACenter{
+		{Name: "Irvine Center", Street: "1 Main St", City: "Irvine", Latitude: &lat, Longitude: &lng},
+		{Name: "Tustin Center", Street: "2 Main St", City: "Tustin"},
+	} {
+		if err := s.CreateABACenter(&center); err != nil {
+			t.Fatalf("CreateABACenter: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.ExportFacilities(&models.SearchFilter{City: "Irvine"}, []string{"aba_centers"}, ExportCSV, &buf); err != nil {
+		t.Fatalf("ExportFacilities: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0][0] != "id" || rows[1][1] != "Irvine Center" {
+		t.Errorf("rows = %q, want the header and the Irvine center", rows)
+	}
+}
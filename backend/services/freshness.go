@@ -0,0 +1,213 @@
+// services/freshness.go
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// DefaultStaleDays is how many days without verification make a record
+// stale in StaleRecords when no other window is given
+const DefaultStaleDays = int(models.StaleAfter / (24 * time.Hour))
+
+// MaxStaleDays bounds the window of StaleRecords
+const MaxStaleDays = 3650
+
+// FreshnessEntityTypes are the facility types whose verification is tracked
+var FreshnessEntityTypes = []string{"aba_centers", "resource_centers", "resources", "regional_centers", "providers"}
+
+// verifiedTable describes how one facility type is verified and reported
+type verifiedTable struct {
+	entityType string
+	entity     string // singular name used in errors
+	table      string
+	model      func() interface{}
+	name       string // name column
+	uuidID     bool
+	located    bool // has a location column, used to find the county
+	softDelete bool
+}
+
+var verifiedTables = []verifiedTable{
+	{
+		entityType: "aba_centers", entity: "ABA center", table: "aba_centers", name: "name",
+		model: func() interface{} { return &models.ABACenter{} }, uuidID: true, located: true, softDelete: true,
+	},
+	{
+		entityType: "resource_centers", entity: "resource center", table: "resource_centers", name: "name",
+		model: func() interface{} { return &models.ResourceCenter{} }, uuidID: true, located: true,
+	},
+	{
+		entityType: "resources", entity: "resource", table: "resources", name: "name",
+		model: func() interface{} { return &models.Resource{} }, uuidID: true, located: true,
+	},
+	{
+		entityType: "regional_centers", entity: "regional center", table: "regional_centers", name: "regional_center",
+		model: func() interface{} { return &models.RegionalCenter{} },
+	},
+	{
+		entityType: "providers", entity: "provider", table: "providers", name: "name",
+		model: func() interface{} { return &models.Provider{} },
+	},
+}
+
+// FreshnessRecord is the verification state of one facility
+type FreshnessRecord struct {
+	EntityType string  `json:"entity_type"`
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	County     *string `json:"county"`
+	models.Verification
+}
+
+// StaleGroup holds the stale records of one facility type in one county.
+// County is nil for records whose county isn't known.
+type StaleGroup struct {
+	EntityType string            `json:"entity_type"`
+	County     *string           `json:"county"`
+	Count      int               `json:"count"`
+	Records    []FreshnessRecord `json:"records"`
+}
+
+// StaleReport lists the facilities not verified since VerifiedBefore
+type StaleReport struct {
+	Days           int          `json:"days"`
+	VerifiedBefore time.Time    `json:"verified_before"`
+	Total          int          `json:"total"`
+	Groups         []StaleGroup `json:"groups"`
+}
+
+func findVerifiedTable(entityType string) (verifiedTable, bool) {
+	for _, t := range verifiedTables {
+		if t.entityType == entityType {
+			return t, true
+		}
+	}
+	return verifiedTable{}, false
+}
+
+// countyExpr is the county of a row: county_served for regional centers and,
+// with PostGIS, the county served by the nearest regional center office for
+// located facilities. Providers only list coverage areas, so theirs is NULL.
+func (s *Service) countyExpr(t verifiedTable) string {
+	switch {
+	case t.table == "regional_centers":
+		return "NULLIF(county_served, '')"
+	case t.located && s.postgis:
+		return fmt.Sprintf(`(SELECT NULLIF(rc.county_served, '') FROM regional_centers rc
+			WHERE rc.location IS NOT NULL AND %s.location IS NOT NULL
+			ORDER BY rc.location::geography <-> %s.location::geography LIMIT 1)`, t.table, t.table)
+	}
+	return "NULL::text"
+}
+
+// freshnessSelect selects the FreshnessRecord columns of t's rows matching
+// where
+func (s *Service) freshnessSelect(t verifiedTable, where string) string {
+	if t.softDelete {
+		where += " AND deleted_at IS NULL"
+	}
+	return fmt.Sprintf(`SELECT '%s' AS entity_type, id::text AS id, coalesce(%s, '') AS name, %s AS county,
+	last_verified_at, verified_by_id
+FROM %s
+WHERE %s`, t.entityType, t.name, s.countyExpr(t), t.table, where)
+}
+
+// VerifyRecord marks a facility as verified now by the given user. It
+// doesn't count as an edit, so updated_at and optimistic concurrency are
+// left alone.
+func (s *Service) VerifyRecord(entityType, id string, userID int) (*FreshnessRecord, error) {
+	t, ok := findVerifiedTable(entityType)
+	if !ok {
+		return nil, &ValidationError{Fields: map[string]string{"type": "must be one of " + strings.Join(FreshnessEntityTypes, ", ")}}
+	}
+	var key interface{}
+	if t.uuidID {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", t.entity, ErrNotFound)
+		}
+		key = parsed
+	} else {
+		parsed, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", t.entity, ErrNotFound)
+		}
+		key = parsed
+	}
+
+	// UpdateColumns leaves updated_at alone and skips soft-deleted rows
+	result := s.db.Model(t.model()).Where("id = ?", key).
+		UpdateColumns(map[string]interface{}{"last_verified_at": time.Now(), "verified_by_id": userID})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to verify %s: %w", t.entity, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%s %w", t.entity, ErrNotFound)
+	}
+
+	var record FreshnessRecord
+	if err := s.db.Raw(s.freshnessSelect(t, "id = ?"), key).Scan(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", t.entity, err)
+	}
+	record.Freshness = models.FreshnessAt(record.LastVerifiedAt, time.Now())
+	return &record, nil
+}
+
+// StaleRecords reports the facilities of the given types (all when empty)
+// never verified or last verified more than days ago, grouped by type and
+// county. Groups follow the order of FreshnessEntityTypes, then county with
+// unknown counties last; within a group, never verified records come first,
+// then the longest unverified.
+func (s *Service) StaleRecords(days int, entityTypes []string) (*StaleReport, error) {
+	if days <= 0 {
+		days = DefaultStaleDays
+	} else if days > MaxStaleDays {
+		days = MaxStaleDays
+	}
+	now := time.Now()
+	report := &StaleReport{Days: days, VerifiedBefore: now.AddDate(0, 0, -days), Groups: []StaleGroup{}}
+
+	wanted := map[string]bool{}
+	for _, entityType := range entityTypes {
+		wanted[entityType] = true
+	}
+	for _, t := range verifiedTables {
+		if len(wanted) > 0 && !wanted[t.entityType] {
+			continue
+		}
+
+		var records []FreshnessRecord
+		query := s.freshnessSelect(t, "(last_verified_at IS NULL OR last_verified_at < ?)") +
+			"\nORDER BY county NULLS LAST, last_verified_at NULLS FIRST, name"
+		if err := s.db.Raw(query, report.VerifiedBefore).Scan(&records).Error; err != nil {
+			return nil, fmt.Errorf("failed to find stale %s: %w", t.entityType, err)
+		}
+
+		for _, record := range records {
+			record.Freshness = models.FreshnessAt(record.LastVerifiedAt, now)
+			last := len(report.Groups) - 1
+			if last < 0 || report.Groups[last].EntityType != t.entityType || !sameString(report.Groups[last].County, record.County) {
+				report.Groups = append(report.Groups, StaleGroup{EntityType: t.entityType, County: record.County})
+				last++
+			}
+			report.Groups[last].Records = append(report.Groups[last].Records, record)
+			report.Groups[last].Count++
+		}
+		report.Total += len(records)
+	}
+	return report, nil
+}
+
+func sameString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
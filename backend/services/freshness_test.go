@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestCountyExpr(t *testing.T) {
+	tests := []struct {
+		entityType string
+		postgis    bool
+		want       string
+	}{
+		{"regional_centers", true, "NULLIF(county_served, '')"},
+		{"aba_centers", true, "ORDER BY rc.location::geography <-> aba_centers.location::geography LIMIT 1"},
+		{"aba_centers", false, "NULL::text"},
+		{"providers", true, "NULL::text"},
+	}
+
+	for _, tt := range tests {
+		table, ok := findVerifiedTable(tt.entityType)
+		if !ok {
+			t.Fatalf("no verified table for %s", tt.entityType)
+		}
+		s := &Service{postgis: tt.postgis}
+		if got := s.countyExpr(table); !strings.Contains(got, tt.want) {
+			t.Errorf("countyExpr(%s) with postgis=%t = %s, want %s", tt.entityType, tt.postgis, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyRecord(t *testing.T) {
+	s := testService(t)
+	user, err := s.EnsureAdmin("admin@example.com", "correct horse")
+	if err != nil {
+		t.Fatalf("EnsureAdmin: %v", err)
+	}
+	verified := models.ABACenter{Name: "Verified", Street: "1 Main St", City: "Irvine"}
+	stale := models.ABACenter{Name: "Stale", Street: "2 Main St", City: "Irvine"}
+	for _, center := range []*models.ABACenter{&verified, &stale} {
+		if err := s.CreateABACenter(center); err != nil {
+			t.Fatalf("CreateABACenter: %v", err)
+		}
+	}
+	before, err := s.GetABACenterByID(verified.ID)
+	if err != nil {
+		t.Fatalf("GetABACenterByID: %v", err)
+	}
+	if before.Freshness != models.FreshnessUnverified {
+		t.Errorf("new center freshness = %q, want unverified", before.Freshness)
+	}
+
+	record, err := s.VerifyRecord("aba_centers", verified.ID.String(), user.ID)
+	if err != nil {
+		t.Fatalf("VerifyRecord: %v", err)
+	}
+	if record.Freshness != models.FreshnessFresh || record.VerifiedByID == nil || *record.VerifiedByID != user.ID {
+		t.Errorf("record = %+v, want fresh and verified by the user", record)
+	}
+	after, err := s.GetABACenterByID(verified.ID)
+	if err != nil {
+		t.Fatalf("GetABACenterByID: %v", err)
+	}
+	if !sameTime(after.UpdatedAt, before.UpdatedAt) {
+		t.Errorf("updated_at = %v, want %v left alone by verifying", after.UpdatedAt, before.UpdatedAt)
+	}
+
+	if _, err := s.VerifyRecord("hospitals", verified.ID.String(), user.ID); invalidFields(t, err)[0] != "type" {
+		t.Errorf("unknown type = %v, want a type error", err)
+	}
+	if _, err := s.VerifyRecord("aba_centers", "42", user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("malformed id = %v, want ErrNotFound", err)
+	}
+
+	report, err := s.StaleRecords(30, []string{"aba_centers"})
+	if err != nil {
+		t.Fatalf("StaleRecords: %v", err)
+	}
+	if report.Days != 30 || report.Total != 1 || len(report.Groups) != 1 || report.Groups[0].Records[0].ID != stale.ID.String() {
+		t.Errorf("report = %+v, want only the unverified center", report)
+	}
+	if record := report.Groups[0].Records[0]; record.Freshness != models.FreshnessUnverified {
+		t.Errorf("stale record freshness = %q, want unverified", record.Freshness)
+	}
+}
@@ -0,0 +1,146 @@
+// services/geocoding.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"github.com/alexbeattie/medicalfacilities/geocoding"
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// All facilities are in California; ABA centers and provider areas don't
+// store a state so it is added to the geocoded address
+const defaultState = "CA"
+
+const geocodeTimeout = 15 * time.Second
+
+// ErrGeocodingDisabled is returned when no geocoder is configured
+var ErrGeocodingDisabled = errors.New("geocoding is not configured")
+
+// SetGeocoder replaces the geocoder, e.g. with a geocoding.StaticGeocoder in
+// tests or offline development
+func (s *Service) SetGeocoder(g geocoding.Geocoder) {
+	s.geocoder = g
+}
+
+// geocode resolves address, giving up after geocodeTimeout or when ctx ends
+func (s *Service) geocode(ctx context.Context, address string) (*geocoding.Result, error) {
+	if s.geocoder == nil {
+		return nil, ErrGeocodingDisabled
+	}
+	ctx, cancel := context.WithTimeout(ctx, geocodeTimeout)
+	defer cancel()
+	return s.geocoder.Geocode(ctx, address)
+}
+
+// ABACenterAddress returns the single-line address used to geocode a center
+func ABACenterAddress(center *models.ABACenter) string {
+	return geocoding.JoinAddress(center.Street, center.City, defaultState+" "+center.Zip)
+}
+
+// RegionalCenterAddress returns the single-line address used to geocode a
+// regional center
+func RegionalCenterAddress(center *models.RegionalCenter) string {
+	state := defaultState
+	if center.State != nil && *center.State != "" {
+		state = *center.State
+	}
+	return geocoding.JoinAddress(Deref(center.Address), Deref(center.City), state+" "+Deref(center.ZipCode))
+}
+
+// GeocodeABACenter fills in the center's coordinates from its address. The
+// center is updated in memory only; callers persist it.
+func (s *Service) GeocodeABACenter(ctx context.Context, center *models.ABACenter) error {
+	result, err := s.geocode(ctx, ABACenterAddress(center))
+	if err != nil {
+		return err
+	}
+	center.Latitude = &result.Latitude
+	center.Longitude = &result.Longitude
+	center.Location = models.NewPoint(result.Latitude, result.Longitude)
+	return nil
+}
+
+// GeocodeRegionalCenter fills in the regional center's coordinates from its
+// address. The center is updated in memory only; callers persist it.
+func (s *Service) GeocodeRegionalCenter(ctx context.Context, center *models.RegionalCenter) error {
+	result, err := s.geocode(ctx, RegionalCenterAddress(center))
+	if err != nil {
+		return err
+	}
+	center.Latitude = &result.Latitude
+	center.Longitude = &result.Longitude
+	center.Location = models.NewPoint(result.Latitude, result.Longitude)
+	return nil
+}
+
+// ProviderCoverageAreas lists the distinct coverage areas of a provider,
+// from Areas or, when that is empty, the comma separated CoverageAreas text
+func ProviderCoverageAreas(provider *models.Provider) []string {
+	areas := provider.Areas
+	if len(areas) == 0 && provider.CoverageAreas != nil {
+		areas = strings.Split(*provider.CoverageAreas, ",")
+	}
+
+	seen := make(map[string]bool, len(areas))
+	result := make([]string, 0, len(areas))
+	for _, area := range areas {
+		area = strings.TrimSpace(area)
+		key := strings.ToLower(area)
+		if area == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, area)
+	}
+	return result
+}
+
+// GeocodeProviderAreas geocodes each of a provider's coverage areas and
+// stores them in provider_areas. Areas that can't be resolved are skipped and
+// reported in the returned error.
+func (s *Service) GeocodeProviderAreas(ctx context.Context, provider *models.Provider) error {
+	var failed []string
+	for _, area := range ProviderCoverageAreas(provider) {
+		result, err := s.geocode(ctx, geocoding.JoinAddress(area, defaultState))
+		if errors.Is(err, ErrGeocodingDisabled) || ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", area, err))
+			continue
+		}
+
+		providerArea := models.ProviderArea{
+			ProviderID: provider.ID,
+			Area:       area,
+			Latitude:   result.Latitude,
+			Longitude:  result.Longitude,
+		}
+		if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "provider_id"}, {Name: "area"}},
+			DoUpdates: clause.AssignmentColumns([]string{"latitude", "longitude", "updated_at"}),
+		}).Create(&providerArea).Error; err != nil {
+			return fmt.Errorf("failed to save provider area: %w", err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to geocode %d area(s): %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Deref returns the string s points to, or "" when it is nil
+func Deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/geocoding"
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestGeocodeABACenter(t *testing.T) {
+	g := geocoding.NewStaticGeocoder(map[string]geocoding.Result{
+		"123 Main St, Irvine, CA 92618": {Latitude: 33.6846, Longitude: -117.8265},
+	})
+	s := &Service{}
+	s.SetGeocoder(g)
+
+	center := models.ABACenter{Street: "123 Main St", City: "Irvine", Zip: "92618"}
+	if err := s.GeocodeABACenter(context.Background(), &center); err != nil {
+		t.Fatalf("GeocodeABACenter: %v", err)
+	}
+	if center.Latitude == nil || *center.Latitude != 33.6846 || center.Longitude == nil || *center.Longitude != -117.8265 {
+		t.Errorf("coordinates = %v, %v, want 33.6846, -117.8265", center.Latitude, center.Longitude)
+	}
+	if center.Location == nil || *center.Location != (models.Point{Lat: 33.6846, Lng: -117.8265}) {
+		t.Errorf("Location = %+v, want the geocoded point", center.Location)
+	}
+
+	unknown := models.ABACenter{Street: "1 Nowhere Rd", City: "Irvine", Zip: "92618"}
+	if err := s.GeocodeABACenter(context.Background(), &unknown); !errors.Is(err, geocoding.ErrNoResults) {
+		t.Errorf("GeocodeABACenter of an unknown address = %v, want ErrNoResults", err)
+	}
+	if unknown.Latitude != nil || unknown.Location != nil {
+		t.Errorf("unknown address got coordinates %v, %+v", unknown.Latitude, unknown.Location)
+	}
+
+	if err := (&Service{}).GeocodeABACenter(context.Background(), &center); !errors.Is(err, ErrGeocodingDisabled) {
+		t.Errorf("GeocodeABACenter without a geocoder = %v, want ErrGeocodingDisabled", err)
+	}
+}
+
+// waitingGeocoder answers once its context ends
+type waitingGeocoder struct{}
+
+func (waitingGeocoder) Geocode(ctx context.Context, address string) (*geocoding.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestGeocodeStopsWithCaller(t *testing.T) {
+	s := &Service{}
+	s.SetGeocoder(waitingGeocoder{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	center := models.ABACenter{Street: "1 Main St", City: "Irvine", Zip: "92618"}
+	if err := s.GeocodeABACenter(ctx, &center); !errors.Is(err, context.Canceled) {
+		t.Errorf("GeocodeABACenter after the caller gave up = %v, want context.Canceled", err)
+	}
+	if err := s.GeocodeProviderAreas(ctx, &models.Provider{Areas: []string{"Irvine"}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("GeocodeProviderAreas after the caller gave up = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,517 @@
+// services/import.go
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/spreadsheet"
+)
+
+// Entity types ImportFile accepts
+const (
+	ImportABACenters = "aba_centers"
+	ImportProviders  = "providers"
+)
+
+// ImportEntityTypes lists the entity types ImportFile accepts
+var ImportEntityTypes = []string{ImportABACenters, ImportProviders}
+
+// MaxImportRows bounds the data rows of one import
+const MaxImportRows = 5000
+
+// What an import did, or in a dry run would do, with a row
+const (
+	ImportCreated   = "create"
+	ImportUpdated   = "update"
+	ImportUnchanged = "unchanged"
+	ImportFailed    = "error"
+)
+
+// ImportReport describes the outcome of an import row by row. Nothing is
+// written unless Committed is set, which needs every row to be valid and
+// DryRun to be off.
+type ImportReport struct {
+	EntityType string            `json:"entity_type"`
+	DryRun     bool              `json:"dry_run"`
+	Committed  bool              `json:"committed"`
+	Rows       int               `json:"rows"`
+	Created    int               `json:"created"`
+	Updated    int               `json:"updated"`
+	Unchanged  int               `json:"unchanged"`
+	Failed     int               `json:"failed"`
+	Results    []ImportRowResult `json:"results"`
+}
+
+// ImportRowResult is the outcome of one data row. Errors are keyed by column
+// name, or by "row" for problems with the row as a whole.
+type ImportRowResult struct {
+	Row    int               `json:"row"`
+	Action string            `json:"action"`
+	ID     string            `json:"id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// importKind is how the text of a cell is read
+type importKind int
+
+const (
+	importText         importKind = iota // string, empty allowed
+	importOptionalText                   // string, empty is null
+	importInt                            // whole number, empty is null
+	importFloat                          // number, empty is null
+	importList                           // comma or semicolon separated
+)
+
+// importColumns are the columns of each entity type, keyed by JSON name
+var importColumns = map[string]map[string]importKind{
+	ImportABACenters: {
+		"name":                  importText,
+		"street":                importText,
+		"city":                  importText,
+		"zip":                   importText,
+		"phone":                 importText,
+		"service_type":          importText,
+		"waitlist_availability": importOptionalText,
+		"waitlist_notes":        importOptionalText,
+		"waitlist_status":       importText,
+		"waitlist_weeks":        importInt,
+		"dx_verification":       importOptionalText,
+		"insurance_accepted":    importOptionalText,
+		"medi_cal_plans":        importOptionalText,
+		"notes":                 importOptionalText,
+		"latitude":              importFloat,
+		"longitude":             importFloat,
+	},
+	ImportProviders: {
+		"name":                  importText,
+		"phone":                 importOptionalText,
+		"coverage_areas":        importOptionalText,
+		"center_based_services": importOptionalText,
+		"areas":                 importList,
+	},
+}
+
+// importKeys are the columns of the natural key each import upserts by.
+// Providers have no address, so they are matched by name alone.
+var importKeys = map[string][]string{
+	ImportABACenters: {"name", "street", "zip"},
+	ImportProviders:  {"name"},
+}
+
+// importRow is a data row read against the header
+type importRow struct {
+	number int
+	values map[string]interface{} // JSON values keyed by column
+	errors map[string]string
+}
+
+// ImportFile upserts the rows of a CSV or XLSX file into ABA centers or
+// providers. The first row names the columns by their JSON names; only
+// columns in the file are written, so a file can update some fields of
+// existing records. Rows are matched to records by their natural key, name
+// + street + zip for ABA centers and name for providers, ignoring case.
+//
+// All rows are written in one transaction, which is rolled back in a dry
+// run or when any row fails, so an import either succeeds as a whole or
+// changes nothing. Problems with the file itself are returned as a
+// ValidationError on "file". Coordinates left out are filled in by the
+// backfill worker.
+func (s *Service) ImportFile(entityType, name string, data []byte, dryRun bool) (*ImportReport, error) {
+	columns, ok := importColumns[entityType]
+	if !ok {
+		return nil, &ValidationError{Fields: map[string]string{
+			"entity_type": "must be one of " + strings.Join(ImportEntityTypes, ", "),
+		}}
+	}
+	sheet, err := spreadsheet.Read(name, data)
+	if err != nil {
+		return nil, &ValidationError{Fields: map[string]string{"file": err.Error()}}
+	}
+	rows, err := readImportRows(sheet, columns, importKeys[entityType])
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{EntityType: entityType, DryRun: dryRun, Rows: len(rows), Results: make([]ImportRowResult, 0, len(rows))}
+	errRollback := errors.New("import rolled back")
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		seen := map[string]int{}
+		for _, row := range rows {
+			result := ImportRowResult{Row: row.number, Errors: row.errors}
+			if key := importKey(row, importKeys[entityType]); len(result.Errors) == 0 {
+				if first, ok := seen[key]; ok {
+					result.Errors = map[string]string{"row": fmt.Sprintf("repeats the %s of row %d", strings.Join(importKeys[entityType], ", "), first)}
+				}
+				seen[key] = row.number
+			}
+
+			if len(result.Errors) == 0 {
+				// Each row gets a savepoint so a failed write doesn't
+				// abort the rest of the import
+				err := tx.Transaction(func(rowTx *gorm.DB) error {
+					var err error
+					if entityType == ImportABACenters {
+						result.Action, result.ID, err = s.importABACenter(rowTx, row)
+					} else {
+						result.Action, result.ID, err = s.importProvider(rowTx, row)
+					}
+					return err
+				})
+				var verr *ValidationError
+				switch {
+				case errors.As(err, &verr):
+					result.Errors = verr.Fields
+				case err != nil:
+					result.Errors = map[string]string{"row": err.Error()}
+				}
+			}
+
+			switch {
+			case len(result.Errors) > 0:
+				result.Action, result.ID = ImportFailed, ""
+				report.Failed++
+			case result.Action == ImportCreated:
+				report.Created++
+			case result.Action == ImportUpdated:
+				report.Updated++
+			default:
+				report.Unchanged++
+			}
+			report.Results = append(report.Results, result)
+		}
+
+		if dryRun || report.Failed > 0 {
+			return errRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errRollback) {
+		return nil, fmt.Errorf("failed to import %s: %w", entityType, err)
+	}
+	report.Committed = err == nil
+	return report, nil
+}
+
+// readImportRows matches the header of sheet against columns and reads the
+// data rows below it
+func readImportRows(sheet []spreadsheet.Row, columns map[string]importKind, required []string) ([]importRow, error) {
+	if len(sheet) == 0 {
+		return nil, &ValidationError{Fields: map[string]string{"file": "is empty"}}
+	}
+	if len(sheet)-1 > MaxImportRows {
+		return nil, &ValidationError{Fields: map[string]string{"file": fmt.Sprintf("has more than %d rows", MaxImportRows)}}
+	}
+
+	var problems []string
+	header := make([]string, len(sheet[0].Cells))
+	present := map[string]bool{}
+	for i, cell := range sheet[0].Cells {
+		column := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(cell)), " ", "_")
+		switch _, known := columns[column]; {
+		case column == "":
+		case !known:
+			problems = append(problems, fmt.Sprintf("unknown column %q", cell))
+		case present[column]:
+			problems = append(problems, fmt.Sprintf("repeated column %q", cell))
+		default:
+			header[i] = column
+			present[column] = true
+		}
+	}
+	for _, column := range required {
+		if !present[column] {
+			problems = append(problems, fmt.Sprintf("missing column %q", column))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Fields: map[string]string{"file": strings.Join(problems, "; ")}}
+	}
+
+	rows := make([]importRow, 0, len(sheet)-1)
+	for _, line := range sheet[1:] {
+		if strings.TrimSpace(strings.Join(line.Cells, "")) == "" {
+			continue
+		}
+		row := importRow{number: line.Number, values: map[string]interface{}{}}
+		for column := range present {
+			row.values[column] = nil
+		}
+		for i, cell := range line.Cells {
+			if i >= len(header) || header[i] == "" {
+				if strings.TrimSpace(cell) != "" {
+					row.addError("row", fmt.Sprintf("has a value in column %d, which has no header", i+1))
+				}
+				continue
+			}
+			value, err := parseImportCell(columns[header[i]], strings.TrimSpace(cell))
+			if err != nil {
+				row.addError(header[i], err.Error())
+				continue
+			}
+			row.values[header[i]] = value
+		}
+		for column, kind := range columns {
+			if kind == importText && present[column] && row.values[column] == nil {
+				row.values[column] = ""
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, &ValidationError{Fields: map[string]string{"file": "has no rows below the header"}}
+	}
+	return rows, nil
+}
+
+func (r *importRow) addError(field, problem string) {
+	if r.errors == nil {
+		r.errors = map[string]string{}
+	}
+	r.errors[field] = problem
+}
+
+// parseImportCell reads the text of a cell as kind
+func parseImportCell(kind importKind, text string) (interface{}, error) {
+	if text == "" {
+		if kind == importText {
+			return "", nil
+		}
+		return nil, nil
+	}
+	switch kind {
+	case importInt:
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil || n != float64(int(n)) {
+			return nil, errors.New("must be a whole number")
+		}
+		return int(n), nil
+	case importFloat:
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return n, nil
+	case importList:
+		items := []string{}
+		for _, item := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ';' }) {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	}
+	return text, nil
+}
+
+// importKey is the natural key of a row, compared ignoring case and spacing
+func importKey(row importRow, keys []string) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		text, _ := row.values[key].(string)
+		parts[i] = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// applyImportRow overlays the values of row onto record and reports whether
+// any of them changed it
+func applyImportRow(record interface{}, row importRow) (bool, error) {
+	before, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	var current map[string]interface{}
+	if err := json.Unmarshal(before, &current); err != nil {
+		return false, err
+	}
+
+	data, err := json.Marshal(row.values)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, record); err != nil {
+		return false, &ValidationError{Fields: map[string]string{"row": err.Error()}}
+	}
+
+	after, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	var updated map[string]interface{}
+	if err := json.Unmarshal(after, &updated); err != nil {
+		return false, err
+	}
+	for column := range row.values {
+		if !reflect.DeepEqual(current[column], updated[column]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// importABACenter creates or updates the ABA center of row
+func (s *Service) importABACenter(tx *gorm.DB, row importRow) (action, id string, err error) {
+	name, _ := row.values["name"].(string)
+	street, _ := row.values["street"].(string)
+	zip, _ := row.values["zip"].(string)
+	var matches []models.ABACenter
+	if err := tx.Where("LOWER(TRIM(name)) = LOWER(?) AND LOWER(TRIM(street)) = LOWER(?) AND TRIM(zip) = ?",
+		strings.TrimSpace(name), strings.TrimSpace(street), strings.TrimSpace(zip)).
+		Limit(2).Find(&matches).Error; err != nil {
+		return "", "", fmt.Errorf("failed to look up ABA center: %w", err)
+	}
+	if len(matches) > 1 {
+		return "", "", &ValidationError{Fields: map[string]string{"row": "matches more than one existing ABA center"}}
+	}
+
+	if len(matches) == 0 {
+		center := models.ABACenter{WaitlistStatus: models.WaitlistUnknown}
+		if _, err := applyImportRow(&center, row); err != nil {
+			return "", "", err
+		}
+		if center.WaitlistStatus == "" {
+			center.WaitlistStatus = models.WaitlistUnknown
+		}
+		if err := ValidateABACenter(&center); err != nil {
+			return "", "", err
+		}
+		if center.Latitude != nil && center.Longitude != nil {
+			center.Location = models.NewPoint(*center.Latitude, *center.Longitude)
+		}
+		waitlistKnown := center.WaitlistStatus != models.WaitlistUnknown || center.WaitlistWeeks != nil
+		if waitlistKnown {
+			now := time.Now()
+			center.WaitlistUpdatedAt = &now
+		}
+
+		if err := tx.Omit(s.writeOmits()...).Create(&center).Error; err != nil {
+			return "", "", fmt.Errorf("failed to create ABA center: %w", err)
+		}
+		if waitlistKnown {
+			if err := recordWaitlistChange(tx, &center, models.WaitlistUnknown, nil); err != nil {
+				return "", "", err
+			}
+		}
+		return ImportCreated, center.ID.String(), nil
+	}
+
+	current := matches[0]
+	center := current
+	changed, err := applyImportRow(&center, row)
+	if err != nil {
+		return "", "", err
+	}
+	if !changed {
+		return ImportUnchanged, current.ID.String(), nil
+	}
+
+	columns := make([]string, 0, len(row.values)+3)
+	for field := range row.values {
+		columns = append(columns, abaCenterFields[field])
+	}
+	if center.WaitlistStatus == "" {
+		center.WaitlistStatus = models.WaitlistUnknown
+	}
+	// A status without an estimated wait drops the previous estimate
+	_, weeksGiven := row.values["waitlist_weeks"]
+	if _, statusGiven := row.values["waitlist_status"]; statusGiven && !weeksGiven &&
+		center.WaitlistStatus != models.WaitlistShort && center.WaitlistStatus != models.WaitlistLong {
+		center.WaitlistWeeks = nil
+		columns = append(columns, "waitlist_weeks")
+	}
+	if err := ValidateABACenter(&center); err != nil {
+		return "", "", err
+	}
+
+	if !sameFloat(current.Latitude, center.Latitude) || !sameFloat(current.Longitude, center.Longitude) {
+		center.Location = nil
+		if center.Latitude != nil && center.Longitude != nil {
+			center.Location = models.NewPoint(*center.Latitude, *center.Longitude)
+		}
+		if s.postgis {
+			columns = append(columns, "location")
+		}
+	}
+	waitlistDiffers := waitlistChanged(&current, &center)
+	if waitlistDiffers {
+		now := time.Now()
+		center.WaitlistUpdatedAt = &now
+		columns = append(columns, "waitlist_updated_at")
+	}
+
+	if err := tx.Model(&center).Select(columns).Updates(&center).Error; err != nil {
+		return "", "", fmt.Errorf("failed to update ABA center: %w", err)
+	}
+	if waitlistDiffers {
+		if err := recordWaitlistChange(tx, &center, current.WaitlistStatus, current.WaitlistWeeks); err != nil {
+			return "", "", err
+		}
+	}
+	return ImportUpdated, center.ID.String(), nil
+}
+
+// importProvider creates or updates the provider of row. Providers whose
+// areas change lose their geocoded areas, which the backfill worker then
+// geocodes again.
+func (s *Service) importProvider(tx *gorm.DB, row importRow) (action, id string, err error) {
+	name, _ := row.values["name"].(string)
+	var matches []models.Provider
+	if err := tx.Where("LOWER(TRIM(name)) = LOWER(?)", strings.TrimSpace(name)).
+		Limit(2).Find(&matches).Error; err != nil {
+		return "", "", fmt.Errorf("failed to look up provider: %w", err)
+	}
+	if len(matches) > 1 {
+		return "", "", &ValidationError{Fields: map[string]string{"row": "matches more than one existing provider"}}
+	}
+
+	if len(matches) == 0 {
+		var provider models.Provider
+		if _, err := applyImportRow(&provider, row); err != nil {
+			return "", "", err
+		}
+		if err := ValidateProvider(&provider); err != nil {
+			return "", "", err
+		}
+		if err := tx.Omit(s.writeOmits()...).Create(&provider).Error; err != nil {
+			return "", "", fmt.Errorf("failed to create provider: %w", err)
+		}
+		return ImportCreated, strconv.Itoa(provider.ID), nil
+	}
+
+	current := matches[0]
+	provider := current
+	changed, err := applyImportRow(&provider, row)
+	if err != nil {
+		return "", "", err
+	}
+	if !changed {
+		return ImportUnchanged, strconv.Itoa(current.ID), nil
+	}
+	if err := ValidateProvider(&provider); err != nil {
+		return "", "", err
+	}
+
+	columns := make([]string, 0, len(row.values))
+	for field := range row.values {
+		columns = append(columns, field)
+	}
+	if err := tx.Model(&provider).Select(columns).Updates(&provider).Error; err != nil {
+		return "", "", fmt.Errorf("failed to update provider: %w", err)
+	}
+	if !slices.Equal(current.Areas, provider.Areas) {
+		if err := tx.Where("provider_id = ?", provider.ID).Delete(&models.ProviderArea{}).Error; err != nil {
+			return "", "", fmt.Errorf("failed to clear provider areas: %w", err)
+		}
+	}
+	return ImportUpdated, strconv.Itoa(provider.ID), nil
+}
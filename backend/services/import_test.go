@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/spreadsheet"
+)
+
+func TestParseImportCell(t *testing.T) {
+	tests := []struct {
+		kind    importKind
+		text    string
+		want    interface{}
+		wantErr string
+	}{
+		{importText, "", "", ""},
+		{importText, "Irvine", "Irvine", ""},
+		{importOptionalText, "", nil, ""},
+		{importOptionalText, "Call first", "Call first", ""},
+		{importInt, "", nil, ""},
+		{importInt, "12", 12, ""},
+		{importInt, "12.0", 12, ""},
+		{importInt, "12.5", nil, "must be a whole number"},
+		{importInt, "twelve", nil, "must be a whole number"},
+		{importFloat, "", nil, ""},
+		{importFloat, "-117.8265", -117.8265, ""},
+		{importFloat, "north", nil, "must be a number"},
+		{importList, "", nil, ""},
+		{importList, "Irvine, Tustin; ; Orange ", []string{"Irvine", "Tustin", "Orange"}, ""},
+		{importList, " , ", []string{}, ""},
+	}
+
+	for _, tt := range tests {
+		got, err := parseImportCell(tt.kind, tt.text)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("parseImportCell(%d, %q) error = %v, want %q", tt.kind, tt.text, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseImportCell(%d, %q) = %#v, %v, want %#v", tt.kind, tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func sheet(rows ...[]string) []spreadsheet.Row {
+	result := make([]spreadsheet.Row, len(rows))
+	for i, cells := range rows {
+		result[i] = spreadsheet.Row{Number: i + 1, Cells: cells}
+	}
+	return result
+}
+
+func TestReadImportRowsHeaderErrors(t *testing.T) {
+	columns := importColumns[ImportABACenters]
+	required := importKeys[ImportABACenters]
+
+	tests := []struct {
+		name  string
+		sheet []spreadsheet.Row
+		want  string
+	}{
+		{"empty file", nil, "is empty"},
+		{"header only", sheet([]string{"name", "street", "zip"}), "has no rows below the header"},
+		{"blank rows only", sheet([]string{"name", "street", "zip"}, []string{"", " "}), "has no rows below the header"},
+		{"unknown column", sheet([]string{"name", "street", "zip", "fax"}, []string{"A", "B", "1"}), `unknown column "fax"`},
+		{"repeated column", sheet([]string{"name", "Street", "zip", "street"}, []string{"A", "B", "1", "C"}), `repeated column "street"`},
+		{"missing key column", sheet([]string{"Name", "City"}, []string{"A", "Irvine"}), `missing column "street"; missing column "zip"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readImportRows(tt.sheet, columns, required)
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("readImportRows error = %v, want a ValidationError", err)
+			}
+			if !strings.Contains(verr.Fields["file"], tt.want) {
+				t.Errorf("file error = %q, want it to contain %q", verr.Fields["file"], tt.want)
+			}
+		})
+	}
+
+	t.Run("too many rows", func(t *testing.T) {
+		rows := make([]spreadsheet.Row, MaxImportRows+2)
+		_, err := readImportRows(rows, columns, required)
+		var verr *ValidationError
+		if !errors.As(err, &verr) || !strings.Contains(verr.Fields["file"], "has more than") {
+			t.Errorf("readImportRows error = %v, want a row limit error", err)
+		}
+	})
+}
+
+func TestReadImportRows(t *testing.T) {
+	rows, err := readImportRows(sheet(
+		[]string{" Name ", "Street", "ZIP", "Waitlist Weeks", "latitude", "notes", ""},
+		[]string{"Bright Futures", "1 Main St", "92618", "6", "33.68", "", ""},
+		[]string{"", "", ""},
+		[]string{"Sunrise", "2 Oak Ave", "92780", "soon", "north", "Call", "extra"},
+	), importColumns[ImportABACenters], importKeys[ImportABACenters])
+	if err != nil {
+		t.Fatalf("readImportRows: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2 (the blank row is skipped)", len(rows))
+	}
+
+	first := rows[0]
+	if first.number != 2 || first.errors != nil {
+		t.Errorf("first row = %d with errors %v, want row 2 without errors", first.number, first.errors)
+	}
+	wantValues := map[string]interface{}{
+		"name": "Bright Futures", "street": "1 Main St", "zip": "92618",
+		"waitlist_weeks": 6, "latitude": 33.68, "notes": nil,
+	}
+	if !reflect.DeepEqual(first.values, wantValues) {
+		t.Errorf("first row values = %#v, want %#v", first.values, wantValues)
+	}
+
+	second := rows[1]
+	wantErrors := map[string]string{
+		"waitlist_weeks": "must be a whole number",
+		"latitude":       "must be a number",
+		"row":            "has a value in column 7, which has no header",
+	}
+	if second.number != 4 || !reflect.DeepEqual(second.errors, wantErrors) {
+		t.Errorf("second row = %d with errors %v, want row 4 with %v", second.number, second.errors, wantErrors)
+	}
+	if second.values["notes"] != "Call" {
+		t.Errorf("second row notes = %#v, want %q", second.values["notes"], "Call")
+	}
+}
@@ -0,0 +1,394 @@
+// services/insurance.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// MediCalCarrier is the carrier that Medi-Cal managed care plans belong to
+const MediCalCarrier = "Medi-Cal"
+
+var insuranceCarrierList = listSpec[models.InsuranceCarrier]{
+	table:    "insurance_carriers",
+	nameExpr: "insurance_carriers.name",
+	preloads: []string{"Plans"},
+	name:     func(c *models.InsuranceCarrier) string { return c.Name },
+	id:       func(c *models.InsuranceCarrier) interface{} { return c.ID },
+}
+
+// acceptsInsurance matches ABA centers that accept any of carrierIDs and any
+// of planIDs. A center linked to a carrier accepts all of its plans, and a
+// center linked to a plan accepts its carrier.
+func acceptsInsurance(carrierIDs, planIDs []int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(carrierIDs) > 0 {
+			db = db.Where(`EXISTS (SELECT 1 FROM aba_center_carriers
+					WHERE aba_center_id = aba_centers.id AND carrier_id IN ?)
+				OR EXISTS (SELECT 1 FROM aba_center_plans JOIN insurance_plans ON insurance_plans.id = aba_center_plans.plan_id
+					WHERE aba_center_plans.aba_center_id = aba_centers.id AND insurance_plans.carrier_id IN ?)`,
+				carrierIDs, carrierIDs)
+		}
+		if len(planIDs) > 0 {
+			db = db.Where(`EXISTS (SELECT 1 FROM aba_center_plans
+					WHERE aba_center_id = aba_centers.id AND plan_id IN ?)
+				OR EXISTS (SELECT 1 FROM aba_center_carriers JOIN insurance_plans ON insurance_plans.carrier_id = aba_center_carriers.carrier_id
+					WHERE aba_center_carriers.aba_center_id = aba_centers.id AND insurance_plans.id IN ?)`,
+				planIDs, planIDs)
+		}
+		return db
+	}
+}
+
+// GetInsuranceCarriers retrieves a page of insurance carriers with their plans
+func (s *Service) GetInsuranceCarriers(filter *models.SearchFilter, page PageRequest) (*Page[models.InsuranceCarrier], error) {
+	query := s.db.Model(&models.InsuranceCarrier{}).Scopes(
+		textFilter(filter.Search, "name"),
+	)
+
+	carriers, err := listPage(s, query, insuranceCarrierList, filter, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch insurance carriers: %w", err)
+	}
+	return carriers, nil
+}
+
+// CreateInsuranceCarrier creates a new insurance carrier with a unique name
+func (s *Service) CreateInsuranceCarrier(carrier *models.InsuranceCarrier) error {
+	carrier.Name = strings.TrimSpace(carrier.Name)
+	if err := ValidateInsuranceCarrier(carrier); err != nil {
+		return err
+	}
+	if err := s.checkCarrierName(s.db, carrier.Name, 0); err != nil {
+		return err
+	}
+	if err := s.db.Omit(clause.Associations).Create(carrier).Error; err != nil {
+		return fmt.Errorf("failed to create insurance carrier: %w", err)
+	}
+	carrier.Plans = []models.InsurancePlan{}
+	return nil
+}
+
+// UpdateInsuranceCarrier renames an insurance carrier. On success carrier
+// holds the stored record with its plans.
+func (s *Service) UpdateInsuranceCarrier(id int, carrier *models.InsuranceCarrier) error {
+	carrier.Name = strings.TrimSpace(carrier.Name)
+	if err := ValidateInsuranceCarrier(carrier); err != nil {
+		return err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkCarrierName(tx, carrier.Name, id); err != nil {
+			return err
+		}
+		result := tx.Model(&models.InsuranceCarrier{}).Where("id = ?", id).Update("name", carrier.Name)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update insurance carrier: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("insurance carrier %w", ErrNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Preload("Plans").First(carrier, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to fetch insurance carrier: %w", err)
+	}
+	return nil
+}
+
+// DeleteInsuranceCarrier deletes an insurance carrier and its plans,
+// unlinking them from ABA centers
+func (s *Service) DeleteInsuranceCarrier(id int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		plans := tx.Model(&models.InsurancePlan{}).Select("id").Where("carrier_id = ?", id)
+		if err := tx.Where("plan_id IN (?)", plans).Delete(&models.ABACenterPlan{}).Error; err != nil {
+			return fmt.Errorf("failed to unlink insurance plans: %w", err)
+		}
+		if err := tx.Where("carrier_id = ?", id).Delete(&models.ABACenterCarrier{}).Error; err != nil {
+			return fmt.Errorf("failed to unlink insurance carrier: %w", err)
+		}
+		if err := tx.Where("carrier_id = ?", id).Delete(&models.InsurancePlan{}).Error; err != nil {
+			return fmt.Errorf("failed to delete insurance plans: %w", err)
+		}
+		return deleteRecord(tx, &models.InsuranceCarrier{}, "insurance carrier", id)
+	})
+}
+
+// CreateInsurancePlan adds a plan to a carrier; plan names are unique per
+// carrier
+func (s *Service) CreateInsurancePlan(carrierID int, plan *models.InsurancePlan) error {
+	plan.Name = strings.TrimSpace(plan.Name)
+	plan.CarrierID = carrierID
+	if err := ValidateInsurancePlan(plan); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.InsuranceCarrier{}, "id = ?", carrierID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("insurance carrier %w", ErrNotFound)
+			}
+			return fmt.Errorf("failed to fetch insurance carrier: %w", err)
+		}
+		if err := s.checkPlanName(tx, carrierID, plan.Name, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(plan).Error; err != nil {
+			return fmt.Errorf("failed to create insurance plan: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateInsurancePlan renames an insurance plan. Its carrier can't change.
+func (s *Service) UpdateInsurancePlan(id int, plan *models.InsurancePlan) error {
+	plan.Name = strings.TrimSpace(plan.Name)
+	if err := ValidateInsurancePlan(plan); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.InsurancePlan
+		if err := tx.First(&current, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("insurance plan %w", ErrNotFound)
+			}
+			return fmt.Errorf("failed to fetch insurance plan: %w", err)
+		}
+		if err := s.checkPlanName(tx, current.CarrierID, plan.Name, id); err != nil {
+			return err
+		}
+		if err := tx.Model(&current).Update("name", plan.Name).Error; err != nil {
+			return fmt.Errorf("failed to update insurance plan: %w", err)
+		}
+		*plan = current
+		return nil
+	})
+}
+
+// DeleteInsurancePlan deletes an insurance plan, unlinking it from ABA
+// centers
+func (s *Service) DeleteInsurancePlan(id int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("plan_id = ?", id).Delete(&models.ABACenterPlan{}).Error; err != nil {
+			return fmt.Errorf("failed to unlink insurance plan: %w", err)
+		}
+		return deleteRecord(tx, &models.InsurancePlan{}, "insurance plan", id)
+	})
+}
+
+// SetABACenterInsurance replaces the carriers and plans an ABA center
+// accepts and returns the updated center
+func (s *Service) SetABACenterInsurance(centerID uuid.UUID, carrierIDs, planIDs []int) (*models.ABACenter, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.ABACenter{}, "id = ?", centerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("ABA center %w", ErrNotFound)
+			}
+			return fmt.Errorf("failed to fetch ABA center: %w", err)
+		}
+
+		carrierIDs, planIDs = uniqueInts(carrierIDs), uniqueInts(planIDs)
+		var verr ValidationError
+		if err := checkIDsExist(tx, &models.InsuranceCarrier{}, carrierIDs, "carrier_ids", "contains unknown carriers", &verr); err != nil {
+			return err
+		}
+		if err := checkIDsExist(tx, &models.InsurancePlan{}, planIDs, "plan_ids", "contains unknown plans", &verr); err != nil {
+			return err
+		}
+		if err := verr.err(); err != nil {
+			return err
+		}
+
+		if err := tx.Where("aba_center_id = ?", centerID).Delete(&models.ABACenterCarrier{}).Error; err != nil {
+			return fmt.Errorf("failed to unlink ABA center carriers: %w", err)
+		}
+		if err := tx.Where("aba_center_id = ?", centerID).Delete(&models.ABACenterPlan{}).Error; err != nil {
+			return fmt.Errorf("failed to unlink ABA center plans: %w", err)
+		}
+		return linkABACenterInsurance(tx, centerID, carrierIDs, planIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetABACenterByID(centerID)
+}
+
+// linkABACenterInsurance links a center to carriers and plans, skipping
+// links that already exist
+func linkABACenterInsurance(tx *gorm.DB, centerID uuid.UUID, carrierIDs, planIDs []int) error {
+	if len(carrierIDs) > 0 {
+		links := make([]models.ABACenterCarrier, len(carrierIDs))
+		for i, carrierID := range carrierIDs {
+			links[i] = models.ABACenterCarrier{ABACenterID: centerID, CarrierID: carrierID}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+			return fmt.Errorf("failed to link ABA center carriers: %w", err)
+		}
+	}
+	if len(planIDs) > 0 {
+		links := make([]models.ABACenterPlan, len(planIDs))
+		for i, planID := range planIDs {
+			links[i] = models.ABACenterPlan{ABACenterID: centerID, PlanID: planID}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+			return fmt.Errorf("failed to link ABA center plans: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkIDsExist adds problem for field to verr unless every id names a row
+// of model
+func checkIDsExist(tx *gorm.DB, model interface{}, ids []int, field, problem string, verr *ValidationError) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var found int64
+	if err := tx.Model(model).Where("id IN ?", ids).Count(&found).Error; err != nil {
+		return fmt.Errorf("failed to check %s: %w", field, err)
+	}
+	if found != int64(len(ids)) {
+		verr.add(field, problem)
+	}
+	return nil
+}
+
+func uniqueInts(values []int) []int {
+	seen := make(map[int]bool, len(values))
+	unique := make([]int, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// checkCarrierName reports a validation error when another carrier already
+// uses name, ignoring case
+func (s *Service) checkCarrierName(tx *gorm.DB, name string, id int) error {
+	var count int64
+	if err := tx.Model(&models.InsuranceCarrier{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check insurance carrier name: %w", err)
+	}
+	if count > 0 {
+		return &ValidationError{Fields: map[string]string{"name": "is already used by another carrier"}}
+	}
+	return nil
+}
+
+// checkPlanName reports a validation error when another plan of the carrier
+// already uses name, ignoring case
+func (s *Service) checkPlanName(tx *gorm.DB, carrierID int, name string, id int) error {
+	var count int64
+	if err := tx.Model(&models.InsurancePlan{}).
+		Where("carrier_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", carrierID, name, id).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check insurance plan name: %w", err)
+	}
+	if count > 0 {
+		return &ValidationError{Fields: map[string]string{"name": "is already used by another plan of this carrier"}}
+	}
+	return nil
+}
+
+// Parsing free-text insurance lists
+
+// insuranceSeparators splits free-text lists on commas, semicolons, pipes,
+// line breaks and the words "and" and "or"
+var insuranceSeparators = regexp.MustCompile(`(?i)[,;|\n\r]+|\s+(?:and|or)\s+`)
+
+// insuranceRemarks matches parenthetical remarks, including one left open at
+// the end of the text, which may themselves hold separators
+var insuranceRemarks = regexp.MustCompile(`\([^)]*(?:\)|$)`)
+
+// insuranceNoise strips bullets and trailing periods
+var insuranceNoise = regexp.MustCompile(`^[\s\-*•]+|[\s.]+$`)
+
+// insuranceAliases maps spellings found in the legacy text columns to
+// canonical names, checked in order against the lowercased entry
+var insuranceAliases = []struct {
+	match     func(entry string) bool
+	canonical string
+}{
+	{exactly("medi-cal", "medi cal", "medical", "medicaid"), MediCalCarrier},
+	{containing("blue cross blue shield", "bcbs"), "Blue Cross Blue Shield"},
+	{containing("anthem", "blue cross"), "Anthem Blue Cross"},
+	{containing("blue shield"), "Blue Shield of California"},
+	{containing("kaiser"), "Kaiser Permanente"},
+	{containing("aetna"), "Aetna"},
+	{containing("cigna"), "Cigna"},
+	{containing("united health", "unitedhealth", "uhc"), "UnitedHealthcare"},
+	{containing("optum"), "Optum"},
+	{containing("tricare"), "TRICARE"},
+	{containing("magellan"), "Magellan Health"},
+	{containing("beacon"), "Beacon Health Options"},
+	{containing("health net"), "Health Net"},
+	{containing("l.a. care", "la care"), "L.A. Care"},
+	{containing("molina"), "Molina Healthcare"},
+	{containing("cenpatico", "centene"), "Centene"},
+	{containing("caloptima"), "CalOptima"},
+	{containing("inland empire health", "iehp"), "Inland Empire Health Plan"},
+	{containing("partnership health"), "Partnership HealthPlan of California"},
+	{containing("gold coast health"), "Gold Coast Health Plan"},
+}
+
+func exactly(names ...string) func(string) bool {
+	return func(entry string) bool {
+		for _, name := range names {
+			if entry == strings.ToLower(name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func containing(fragments ...string) func(string) bool {
+	return func(entry string) bool {
+		for _, fragment := range fragments {
+			if strings.Contains(entry, fragment) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ParseInsuranceNames splits a free-text insurance list such as
+// "Kaiser, Anthem Blue Cross (PPO, HMO); Medi-Cal" into canonical carrier
+// or plan names, dropping duplicates. Only entries matching a known alias
+// are returned; anything else, like "Most major insurance", stays in the
+// text for people to read.
+func ParseInsuranceNames(text string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, entry := range insuranceSeparators.Split(insuranceRemarks.ReplaceAllString(text, " "), -1) {
+		entry = strings.Join(strings.Fields(insuranceNoise.ReplaceAllString(entry, "")), " ")
+		lower := strings.ToLower(entry)
+		for _, alias := range insuranceAliases {
+			if alias.match(lower) {
+				if !seen[alias.canonical] {
+					seen[alias.canonical] = true
+					names = append(names, alias.canonical)
+				}
+				break
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestParseInsuranceNames(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"N/A", nil},
+		{"Most major insurance", nil},
+		{"Kaiser", []string{"Kaiser Permanente"}},
+		{"Kaiser, Anthem Blue Cross (PPO only); Medi-Cal", []string{"Kaiser Permanente", "Anthem Blue Cross", MediCalCarrier}},
+		{"Anthem (PPO, HMO), Aetna", []string{"Anthem Blue Cross", "Aetna"}},
+		{"Aetna (PPO, HMO", []string{"Aetna"}},
+		{"• Cigna.\n• UHC\n• United Healthcare", []string{"Cigna", "UnitedHealthcare"}},
+		{"Tricare and Optum or Magellan", []string{"TRICARE", "Optum", "Magellan Health"}},
+		{"BCBS | Blue Shield | Regional center funding", []string{"Blue Cross Blue Shield", "Blue Shield of California"}},
+		{"medical, private pay, self-pay", []string{MediCalCarrier}},
+		{"CalOptima; IEHP; Some local plan", []string{"CalOptima", "Inland Empire Health Plan"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := ParseInsuranceNames(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseInsuranceNames(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestABACenterInsurance(t *testing.T) {
+	s := testService(t)
+	kaiser, aetna := models.InsuranceCarrier{Name: "Kaiser Permanente"}, models.InsuranceCarrier{Name: "Aetna"}
+	for _, carrier := range []*models.InsuranceCarrier{&kaiser, &aetna} {
+		if err := s.CreateInsuranceCarrier(carrier); err != nil {
+			t.Fatalf("CreateInsuranceCarrier: %v", err)
+		}
+	}
+	if err := s.CreateInsuranceCarrier(&models.InsuranceCarrier{Name: " kaiser permanente "}); invalidFields(t, err)[0] != "name" {
+		t.Errorf("duplicate carrier = %v, want a name error", err)
+	}
+	hmo := models.InsurancePlan{Name: "HMO"}
+	if err := s.CreateInsurancePlan(kaiser.ID, &hmo); err != nil {
+		t.Fatalf("CreateInsurancePlan: %v", err)
+	}
+
+	var centers [3]models.ABACenter
+	for i := range centers {
+		centers[i] = models.ABACenter{Name: "Center", Street: "1 Main St", City: "Irvine"}
+		if err := s.CreateABACenter(&centers[i]); err != nil {
+			t.Fatalf("CreateABACenter: %v", err)
+		}
+	}
+	// The first takes Kaiser in general, the second only its HMO
+	if _, err := s.SetABACenterInsurance(centers[0].ID, []int{kaiser.ID, kaiser.ID}, nil); err != nil {
+		t.Fatalf("SetABACenterInsurance: %v", err)
+	}
+	center, err := s.SetABACenterInsurance(centers[1].ID, nil, []int{hmo.ID})
+	if err != nil {
+		t.Fatalf("SetABACenterInsurance: %v", err)
+	}
+	if len(center.Plans) != 1 || len(center.Carriers) != 0 {
+		t.Errorf("center = %+v, want the HMO plan only", center)
+	}
+	if _, err := s.SetABACenterInsurance(centers[2].ID, []int{-1}, []int{-1}); !reflect.DeepEqual(invalidFields(t, err), []string{"carrier_ids", "plan_ids"}) {
+		t.Errorf("unknown ids = %v, want carrier_ids and plan_ids errors", err)
+	}
+
+	matching := func(filter models.SearchFilter) []uuid.UUID {
+		t.Helper()
+		page, err := s.GetABACenters(&filter, PageRequest{Limit: 10})
+		if err != nil {
+			t.Fatalf("GetABACenters: %v", err)
+		}
+		var ids []uuid.UUID
+		for _, center := range page.Items {
+			ids = append(ids, center.ID)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+		return ids
+	}
+	both := []uuid.UUID{centers[0].ID, centers[1].ID}
+	sort.Slice(both, func(i, j int) bool { return both[i].String() < both[j].String() })
+
+	if got := matching(models.SearchFilter{CarrierIDs: []int{kaiser.ID}}); !reflect.DeepEqual(got, both) {
+		t.Errorf("centers taking Kaiser = %v, want %v", got, both)
+	}
+	if got := matching(models.SearchFilter{PlanIDs: []int{hmo.ID}}); !reflect.DeepEqual(got, both) {
+		t.Errorf("centers taking the HMO = %v, want %v", got, both)
+	}
+	if got := matching(models.SearchFilter{CarrierIDs: []int{aetna.ID}}); len(got) != 0 {
+		t.Errorf("centers taking Aetna = %v, want none", got)
+	}
+	if got := matching(models.SearchFilter{InsuranceRequired: true}); !reflect.DeepEqual(got, both) {
+		t.Errorf("centers taking insurance = %v, want %v", got, both)
+	}
+
+	// Deleting a carrier takes its plans and links with it
+	if err := s.DeleteInsuranceCarrier(kaiser.ID); err != nil {
+		t.Fatalf("DeleteInsuranceCarrier: %v", err)
+	}
+	if got := matching(models.SearchFilter{InsuranceRequired: true}); len(got) != 0 {
+		t.Errorf("centers taking insurance after the delete = %v, want none", got)
+	}
+	if err := s.UpdateInsurancePlan(hmo.ID, &models.InsurancePlan{Name: "PPO"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("updating a deleted plan = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,207 @@
+// services/lists.go
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// listSpec describes how a model is searched, ordered and paginated
+type listSpec[T any] struct {
+	table    string
+	nameExpr string   // SQL name used for models.SortName
+	preloads []string // associations loaded for each page
+
+	name func(*T) string
+	id   func(*T) interface{}
+
+	// Only set for models with a location
+	coords func(*T) (lat, lng *float64)
+	dist   func(*T) **float64
+}
+
+// textFilter matches term case-insensitively against any of columns
+func textFilter(term string, columns ...string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if term == "" {
+			return db
+		}
+		like := "%" + strings.ToLower(term) + "%"
+		conditions := make([]string, len(columns))
+		args := make([]interface{}, len(columns))
+		for i, column := range columns {
+			conditions[i] = "LOWER(" + column + ") LIKE ?"
+			args[i] = like
+		}
+		return db.Where(strings.Join(conditions, " OR "), args...)
+	}
+}
+
+// listQuery prepares a filtered list query for spec: it applies the radius
+// filter and returns the scopes loading each row (preloads, distances), the
+// ordering and the cursor key of a row
+func listQuery[T any](s *Service, query *gorm.DB, spec listSpec[T], filter *models.SearchFilter) (*gorm.DB, []func(*gorm.DB) *gorm.DB, Keyset, func(*T) (interface{}, interface{})) {
+	hasLocation := filter.HasLocation()
+
+	var scopes []func(*gorm.DB) *gorm.DB
+	for _, association := range spec.preloads {
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB { return db.Preload(association) })
+	}
+	if hasLocation {
+		query = query.Scopes(s.WithinRadius(spec.table, filter.Latitude, filter.Longitude, filter.MaxDistance))
+		scopes = append(scopes, s.WithDistance(spec.table, filter.Latitude, filter.Longitude))
+	}
+
+	keyset := s.Keyset(spec.table, spec.nameExpr, filter.Sort, hasLocation, filter.Latitude, filter.Longitude)
+	key := func(row *T) (interface{}, interface{}) {
+		var distance *float64
+		if spec.dist != nil {
+			distance = *spec.dist(row)
+		}
+		return keyset.Key(spec.name(row), distance), spec.id(row)
+	}
+	return query, scopes, keyset, key
+}
+
+// listPage runs a filtered list query for spec: it applies the radius filter,
+// selects distances, orders by the requested keyset and loads one page. When
+// PostGIS is unavailable radius searches are filtered and ordered in Go.
+func listPage[T any](s *Service, query *gorm.DB, spec listSpec[T], filter *models.SearchFilter, page PageRequest) (*Page[T], error) {
+	query, scopes, keyset, key := listQuery(s, query, spec, filter)
+	if !filter.HasLocation() || s.postgis {
+		return Paginate(query, keyset, page, key, scopes...)
+	}
+
+	filtered, err := filterByDistance(s, query.Scopes(scopes...).Scopes(keyset.Order), spec, filter)
+	if err != nil {
+		return nil, err
+	}
+	return PaginateSlice(filtered, keyset, page, key)
+}
+
+// listBatchSize is how many rows listEach loads at a time
+const listBatchSize = 500
+
+// listEach calls fn with every row of a filtered list query for spec in list
+// order, loading listBatchSize rows at a time. Without PostGIS radius
+// searches are filtered in Go, which loads every row of the query first.
+func listEach[T any](s *Service, query *gorm.DB, spec listSpec[T], filter *models.SearchFilter, fn func(*T) error) error {
+	query, scopes, keyset, key := listQuery(s, query, spec, filter)
+	if filter.HasLocation() && !s.postgis {
+		rows, err := filterByDistance(s, query.Scopes(scopes...).Scopes(keyset.Order), spec, filter)
+		if err != nil {
+			return err
+		}
+		for i := range rows {
+			if err := fn(&rows[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var last *cursor
+	for {
+		batchQuery := query.Session(&gorm.Session{}).Scopes(scopes...).Scopes(keyset.Order)
+		if last != nil {
+			batchQuery = keyset.after(batchQuery, last)
+		}
+		var batch []T
+		if err := batchQuery.Limit(listBatchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if len(batch) < listBatchSize {
+			return nil
+		}
+		k, id := key(&batch[len(batch)-1])
+		last = &cursor{Key: k, ID: id}
+	}
+}
+
+// filterByDistance loads the rows of query within the radius of filter,
+// setting their distances, for when PostGIS is unavailable
+func filterByDistance[T any](s *Service, query *gorm.DB, spec listSpec[T], filter *models.SearchFilter) ([]T, error) {
+	var rows []T
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	filtered := make([]T, 0)
+	for i := range rows {
+		lat, lng := spec.coords(&rows[i])
+		if lat == nil || lng == nil {
+			continue
+		}
+		distance := calculateDistance(filter.Latitude, filter.Longitude, *lat, *lng)
+		if distance <= filter.MaxDistance {
+			*spec.dist(&rows[i]) = &distance
+			filtered = append(filtered, rows[i])
+		}
+	}
+	if s.sortByDistance(filter.Sort) {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return **spec.dist(&filtered[i]) < **spec.dist(&filtered[j])
+		})
+	}
+	return filtered, nil
+}
+
+var abaCenterList = listSpec[models.ABACenter]{
+	table:    "aba_centers",
+	nameExpr: "aba_centers.name",
+	preloads: []string{"Carriers", "Plans"},
+	name:     func(c *models.ABACenter) string { return c.Name },
+	id:       func(c *models.ABACenter) interface{} { return c.ID },
+	coords:   func(c *models.ABACenter) (*float64, *float64) { return c.Latitude, c.Longitude },
+	dist:     func(c *models.ABACenter) **float64 { return &c.DistanceMiles },
+}
+
+var resourceCenterList = listSpec[models.ResourceCenter]{
+	table:    "resource_centers",
+	nameExpr: "resource_centers.name",
+	preloads: []string{"Diagnoses"},
+	name:     func(c *models.ResourceCenter) string { return c.Name },
+	id:       func(c *models.ResourceCenter) interface{} { return c.ID },
+	coords:   func(c *models.ResourceCenter) (*float64, *float64) { return &c.Latitude, &c.Longitude },
+	dist:     func(c *models.ResourceCenter) **float64 { return &c.DistanceMiles },
+}
+
+var resourceList = listSpec[models.Resource]{
+	table:    "resources",
+	nameExpr: "resources.name",
+	name:     func(r *models.Resource) string { return r.Name },
+	id:       func(r *models.Resource) interface{} { return r.ID },
+	coords:   func(r *models.Resource) (*float64, *float64) { return &r.Latitude, &r.Longitude },
+	dist:     func(r *models.Resource) **float64 { return &r.DistanceMiles },
+}
+
+var regionalCenterList = listSpec[models.RegionalCenter]{
+	table:    "regional_centers",
+	nameExpr: "COALESCE(regional_centers.regional_center, '')",
+	name:     func(c *models.RegionalCenter) string { return Deref(c.RegionalCenter) },
+	id:       func(c *models.RegionalCenter) interface{} { return c.ID },
+	coords:   func(c *models.RegionalCenter) (*float64, *float64) { return c.Latitude, c.Longitude },
+	dist:     func(c *models.RegionalCenter) **float64 { return &c.DistanceMiles },
+}
+
+var providerList = listSpec[models.Provider]{
+	table:    "providers",
+	nameExpr: "providers.name",
+	name:     func(p *models.Provider) string { return p.Name },
+	id:       func(p *models.Provider) interface{} { return p.ID },
+}
+
+var diagnosisList = listSpec[models.Diagnosis]{
+	table:    "diagnoses",
+	nameExpr: "diagnoses.name",
+	name:     func(d *models.Diagnosis) string { return d.Name },
+	id:       func(d *models.Diagnosis) interface{} { return d.ID },
+}
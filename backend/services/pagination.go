@@ -0,0 +1,231 @@
+// services/pagination.go
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// Page size limits for list endpoints
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded or
+// no longer matches a row
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageRequest asks for one page of a keyset-paginated list
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+func (p PageRequest) limit() int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultPageLimit
+	case p.Limit > MaxPageLimit:
+		return MaxPageLimit
+	}
+	return p.Limit
+}
+
+// Page is one page of a list endpoint
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+	Total      int64   `json:"total"`
+}
+
+// cursor marks the last row of a page: the sort order it was issued for, its
+// sort key and its id
+type cursor struct {
+	Sort string      `json:"s,omitempty"`
+	Key  interface{} `json:"k,omitempty"`
+	ID   interface{} `json:"id"`
+}
+
+func encodeCursor(sort string, key, id interface{}) *string {
+	data, _ := json.Marshal(cursor{Sort: sort, Key: key, ID: id})
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return &encoded
+}
+
+func decodeCursor(encoded, sort string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cur cursor
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cur); err != nil || cur.ID == nil || cur.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	cur.Key = normalizeNumber(cur.Key)
+	cur.ID = normalizeNumber(cur.ID)
+	return &cur, nil
+}
+
+// normalizeNumber turns json.Number into int64 or float64 so it binds as a
+// numeric query parameter
+func normalizeNumber(v interface{}) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// Keyset is the ordering of a list, which doubles as its pagination key.
+// Rows are ordered by Expr and then id; an empty Expr orders by id alone.
+type Keyset struct {
+	Table string
+	Sort  string // models.SortName, models.SortDistance or "" for id order
+	Expr  string
+	Args  []interface{}
+	Desc  bool // largest first, e.g. newest first for id order
+}
+
+// Keyset returns the ordering for a list on table: by nameExpr for
+// models.SortName, closest first for radius searches and by id otherwise.
+// Without PostGIS distance ordering is left to Go and Expr is empty.
+func (s *Service) Keyset(table, nameExpr, sortOrder string, hasLocation bool, lat, lng float64) Keyset {
+	switch {
+	case sortOrder == models.SortName:
+		return Keyset{Table: table, Sort: models.SortName, Expr: nameExpr}
+	case hasLocation && s.postgis:
+		return Keyset{
+			Table: table,
+			Sort:  models.SortDistance,
+			Expr:  fmt.Sprintf("ST_Distance(%s.location::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography) / %f", table, metersPerMile),
+			Args:  []interface{}{lng, lat},
+		}
+	case hasLocation:
+		return Keyset{Table: table, Sort: models.SortDistance}
+	}
+	return Keyset{Table: table}
+}
+
+// Order applies the keyset ordering to a query
+func (k Keyset) Order(db *gorm.DB) *gorm.DB {
+	direction := ""
+	if k.Desc {
+		direction = " DESC"
+	}
+	if k.Expr == "" {
+		return db.Order(k.Table + ".id" + direction)
+	}
+	return db.Clauses(clause.OrderBy{Expression: clause.Expr{
+		SQL:                fmt.Sprintf("%s%s, %s.id%s", k.Expr, direction, k.Table, direction),
+		Vars:               k.Args,
+		WithoutParentheses: true,
+	}})
+}
+
+// Key returns the cursor key of a row with the given name and distance
+func (k Keyset) Key(name string, distance *float64) interface{} {
+	switch k.Sort {
+	case models.SortName:
+		return name
+	case models.SortDistance:
+		if distance != nil {
+			return *distance
+		}
+	}
+	return nil
+}
+
+func (k Keyset) after(db *gorm.DB, cur *cursor) *gorm.DB {
+	comparison := ">"
+	if k.Desc {
+		comparison = "<"
+	}
+	if k.Expr == "" {
+		return db.Where(fmt.Sprintf("%s.id %s ?", k.Table, comparison), cur.ID)
+	}
+	args := append(append([]interface{}{}, k.Args...), cur.Key, cur.ID)
+	return db.Where(fmt.Sprintf("(%s, %s.id) %s (?, ?)", k.Expr, k.Table, comparison), args...)
+}
+
+// Paginate counts the rows matched by query and loads the page following
+// page.Cursor in keyset order. scopes (preloads, extra selects) only apply to
+// the page query; key returns the cursor key and id of a row.
+func Paginate[T any](query *gorm.DB, keyset Keyset, page PageRequest, key func(*T) (interface{}, interface{}), scopes ...func(*gorm.DB) *gorm.DB) (*Page[T], error) {
+	result := &Page[T]{Items: []T{}}
+	if err := query.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	pageQuery := query.Session(&gorm.Session{}).Scopes(scopes...).Scopes(keyset.Order)
+	if page.Cursor != "" {
+		cur, err := decodeCursor(page.Cursor, keyset.Sort)
+		if err != nil {
+			return nil, err
+		}
+		if keyset.Expr != "" && cur.Key == nil {
+			return nil, ErrInvalidCursor
+		}
+		pageQuery = keyset.after(pageQuery, cur)
+	}
+
+	limit := page.limit()
+	if err := pageQuery.Limit(limit + 1).Find(&result.Items).Error; err != nil {
+		return nil, err
+	}
+
+	if len(result.Items) > limit {
+		result.Items = result.Items[:limit]
+		k, id := key(&result.Items[limit-1])
+		result.NextCursor = encodeCursor(keyset.Sort, k, id)
+	}
+	return result, nil
+}
+
+// PaginateSlice pages rows that were already filtered and ordered in Go,
+// e.g. radius searches without PostGIS. The cursor must name a row in items.
+func PaginateSlice[T any](items []T, keyset Keyset, page PageRequest, key func(*T) (interface{}, interface{})) (*Page[T], error) {
+	start := 0
+	if page.Cursor != "" {
+		cur, err := decodeCursor(page.Cursor, keyset.Sort)
+		if err != nil {
+			return nil, err
+		}
+		start = -1
+		for i := range items {
+			if _, id := key(&items[i]); fmt.Sprint(id) == fmt.Sprint(cur.ID) {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	result := &Page[T]{Items: []T{}, Total: int64(len(items))}
+	end := start + page.limit()
+	if end < len(items) {
+		result.Items = items[start:end]
+		k, id := key(&items[end-1])
+		result.NextCursor = encodeCursor(keyset.Sort, k, id)
+	} else {
+		result.Items = items[start:]
+	}
+	return result, nil
+}
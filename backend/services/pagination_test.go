@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		sort    string
+		key, id interface{}
+	}{
+		{"by id", "", nil, int64(42)},
+		{"by name", models.SortName, "Bright Steps", "0b7e6a43-6f0e-4f4c-9a55-1d0c6c1a9f41"},
+		{"by distance", models.SortDistance, 2.75, int64(7)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur, err := decodeCursor(*encodeCursor(tt.sort, tt.key, tt.id), tt.sort)
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if cur.Key != tt.key || cur.ID != tt.id {
+				t.Errorf("decoded key %#v and id %#v, want %#v and %#v", cur.Key, cur.ID, tt.key, tt.id)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		sort    string
+	}{
+		{"not base64", "!!!", ""},
+		{"not JSON", "bm90IGpzb24", ""},
+		{"no id", "e30", ""},
+		{"other sort order", *encodeCursor(models.SortName, "A", 1), models.SortDistance},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.encoded, tt.sort); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor(%q) = %v, want ErrInvalidCursor", tt.encoded, err)
+			}
+		})
+	}
+}
+
+func TestPageRequestLimit(t *testing.T) {
+	for limit, want := range map[int]int{-1: DefaultPageLimit, 0: DefaultPageLimit, 10: 10, MaxPageLimit + 1: MaxPageLimit} {
+		if got := (PageRequest{Limit: limit}).limit(); got != want {
+			t.Errorf("limit of %d = %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	db := dryRunDB(t)
+
+	tests := []struct {
+		name        string
+		postgis     bool
+		sortOrder   string
+		hasLocation bool
+		wantSort    string
+		wantOrder   string
+		wantAfter   string
+	}{
+		{"by id", true, "", false, "", "ORDER BY resources.id", "resources.id > $1"},
+		{"by name", true, models.SortName, true, models.SortName, "ORDER BY resources.name, resources.id", "(resources.name, resources.id) > ($1, $2)"},
+		{"by distance", true, "", true, models.SortDistance, "ORDER BY ST_Distance(", ", resources.id) > ($3, $4)"},
+		{"by distance without PostGIS", false, "", true, models.SortDistance, "ORDER BY resources.id", "resources.id > $1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{db: db, postgis: tt.postgis}
+			keyset := s.Keyset("resources", "resources.name", tt.sortOrder, tt.hasLocation, 34.05, -118.24)
+			if keyset.Sort != tt.wantSort {
+				t.Errorf("Sort = %q, want %q", keyset.Sort, tt.wantSort)
+			}
+
+			stmt := keyset.after(db.Model(&models.Resource{}).Scopes(keyset.Order), &cursor{Key: "A", ID: 1}).
+				Find(&[]models.Resource{}).Statement
+			if sql := stmt.SQL.String(); !strings.Contains(sql, tt.wantOrder) || !strings.Contains(sql, tt.wantAfter) {
+				t.Errorf("SQL = %s, want %q and %q", sql, tt.wantOrder, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestPaginateSlice(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	key := func(i *int) (interface{}, interface{}) { return nil, *i }
+	keyset := Keyset{Table: "items"}
+
+	var got []int
+	page := PageRequest{Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > len(items) {
+			t.Fatal("paging did not end")
+		}
+		result, err := PaginateSlice(items, keyset, page, key)
+		if err != nil {
+			t.Fatalf("PaginateSlice: %v", err)
+		}
+		if result.Total != int64(len(items)) {
+			t.Errorf("Total = %d, want %d", result.Total, len(items))
+		}
+		got = append(got, result.Items...)
+		if result.NextCursor == nil {
+			break
+		}
+		page.Cursor = *result.NextCursor
+	}
+	if !reflect.DeepEqual(got, items) {
+		t.Errorf("pages = %v, want every item once %v", got, items)
+	}
+
+	if _, err := PaginateSlice(items, keyset, PageRequest{Cursor: *encodeCursor("", nil, 9)}, key); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("PaginateSlice with a cursor for a missing row = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestKeysetDesc(t *testing.T) {
+	db := dryRunDB(t)
+	keyset := Keyset{Table: "form_submissions", Desc: true}
+
+	stmt := keyset.after(db.Model(&models.FormSubmission{}).Scopes(keyset.Order), &cursor{ID: 9}).
+		Find(&[]models.FormSubmission{}).Statement
+	if sql := stmt.SQL.String(); !strings.Contains(sql, "form_submissions.id < $1") || !strings.Contains(sql, "ORDER BY form_submissions.id DESC") {
+		t.Errorf("SQL = %s, want newest first after the cursor", sql)
+	}
+}
@@ -0,0 +1,226 @@
+// services/permissions.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// DefaultPermissionCacheTTL bounds how long a user's permissions are cached
+// when no change notification arrives
+const DefaultPermissionCacheTTL = 5 * time.Minute
+
+// permissionsChannel is the Postgres NOTIFY channel raised by changes to
+// user_roles (payload: user id) and role_permissions (payload: "*")
+const permissionsChannel = "permissions_changed"
+
+// permissionCache holds the permission names of each user
+type permissionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int]permissionEntry
+}
+
+type permissionEntry struct {
+	names    map[string]bool
+	loadedAt time.Time
+}
+
+func newPermissionCache(ttl time.Duration) *permissionCache {
+	if ttl <= 0 {
+		ttl = DefaultPermissionCacheTTL
+	}
+	return &permissionCache{ttl: ttl, entries: map[int]permissionEntry{}}
+}
+
+func (c *permissionCache) get(userID int) (map[string]bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[userID]
+	if !ok || time.Since(entry.loadedAt) > c.ttl {
+		return nil, false
+	}
+	return entry.names, true
+}
+
+func (c *permissionCache) put(userID int, names map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[userID] = permissionEntry{names: names, loadedAt: time.Now()}
+}
+
+func (c *permissionCache) invalidate(userID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+}
+
+func (c *permissionCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[int]permissionEntry{}
+}
+
+// UserPermissions returns the names of the permissions granted to a user
+// through their roles
+func (s *Service) UserPermissions(userID int) (map[string]bool, error) {
+	if names, ok := s.permissions.get(userID); ok {
+		return names, nil
+	}
+
+	var rows []string
+	err := s.db.Table("permissions").
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Pluck("permissions.name", &rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load permissions: %w", err)
+	}
+
+	names := make(map[string]bool, len(rows))
+	for _, name := range rows {
+		names[name] = true
+	}
+	s.permissions.put(userID, names)
+	return names, nil
+}
+
+// InvalidatePermissions drops the cached permissions of a user
+func (s *Service) InvalidatePermissions(userID int) {
+	s.permissions.invalidate(userID)
+}
+
+// InvalidateAllPermissions drops every cached permission set, e.g. after a
+// role's permissions change
+func (s *Service) InvalidateAllPermissions() {
+	s.permissions.invalidateAll()
+}
+
+// EnsurePermissions creates any of the named permissions that are missing
+// and grants all of them to the admin role
+func (s *Service) EnsurePermissions(names []string) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		admin := models.Role{Name: models.RoleAdmin}
+		if err := tx.Where("name = ?", admin.Name).FirstOrCreate(&admin).Error; err != nil {
+			return fmt.Errorf("failed to create admin role: %w", err)
+		}
+
+		for _, name := range names {
+			permission := models.Permission{Name: name}
+			if err := tx.Where("name = ?", name).FirstOrCreate(&permission).Error; err != nil {
+				return fmt.Errorf("failed to create permission %s: %w", name, err)
+			}
+			grant := models.RolePermission{RoleID: admin.ID, PermissionID: permission.ID}
+			if err := tx.Where(&grant).FirstOrCreate(&grant).Error; err != nil {
+				return fmt.Errorf("failed to grant permission %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.InvalidateAllPermissions()
+	return nil
+}
+
+// InitPermissionTriggers installs triggers raising a NOTIFY on
+// permissionsChannel whenever user_roles or role_permissions change, so
+// edits made outside the API also invalidate cached permissions
+func (s *Service) InitPermissionTriggers() error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION notify_permissions_changed() RETURNS trigger AS $$
+BEGIN
+	IF TG_TABLE_NAME = 'user_roles' THEN
+		IF TG_OP <> 'INSERT' THEN
+			PERFORM pg_notify('` + permissionsChannel + `', OLD.user_id::text);
+		END IF;
+		IF TG_OP <> 'DELETE' THEN
+			PERFORM pg_notify('` + permissionsChannel + `', NEW.user_id::text);
+		END IF;
+	ELSE
+		PERFORM pg_notify('` + permissionsChannel + `', '*');
+	END IF;
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql`,
+	}
+	for _, table := range []string{"user_roles", "role_permissions"} {
+		trigger := table + "_notify_permissions"
+		statements = append(statements,
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", trigger, table),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT OR UPDATE OR DELETE ON %s "+
+				"FOR EACH ROW EXECUTE FUNCTION notify_permissions_changed()", trigger, table),
+		)
+	}
+
+	for _, statement := range statements {
+		if err := s.db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to install permission triggers: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListenForPermissionChanges invalidates cached permissions as change
+// notifications arrive, reconnecting until ctx is cancelled. Missed
+// notifications while disconnected are covered by clearing the cache on
+// every reconnect.
+func (s *Service) ListenForPermissionChanges(ctx context.Context) {
+	go func() {
+		for {
+			err := s.listenForPermissionChanges(ctx)
+			if ctx.Err() != nil {
+				log.Printf("[AUTHZ] Permission listener stopped")
+				return
+			}
+			log.Printf("[AUTHZ] Permission listener disconnected, retrying: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Second):
+			}
+		}
+	}()
+}
+
+func (s *Service) listenForPermissionChanges(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, s.cfg.DSN)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+permissionsChannel); err != nil {
+		return err
+	}
+	s.InvalidateAllPermissions()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if notification.Payload == "*" {
+			s.InvalidateAllPermissions()
+			continue
+		}
+		userID, err := strconv.Atoi(notification.Payload)
+		if err != nil {
+			log.Printf("[AUTHZ] Ignoring notification %q", notification.Payload)
+			continue
+		}
+		s.InvalidatePermissions(userID)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestPermissionCache(t *testing.T) {
+	cache := newPermissionCache(time.Minute)
+	names := map[string]bool{"facilities:write": true}
+
+	if _, ok := cache.get(1); ok {
+		t.Fatal("empty cache returned an entry")
+	}
+	cache.put(1, names)
+	cache.put(2, names)
+	if got, ok := cache.get(1); !ok || !got["facilities:write"] {
+		t.Errorf("get = %v, %t, want the stored permissions", got, ok)
+	}
+
+	cache.invalidate(1)
+	if _, ok := cache.get(1); ok {
+		t.Error("invalidated entry is still cached")
+	}
+	if _, ok := cache.get(2); !ok {
+		t.Error("invalidating one user dropped another")
+	}
+
+	cache.invalidateAll()
+	if _, ok := cache.get(2); ok {
+		t.Error("entry survived invalidateAll")
+	}
+
+	cache.entries[3] = permissionEntry{names: names, loadedAt: time.Now().Add(-2 * time.Minute)}
+	if _, ok := cache.get(3); ok {
+		t.Error("entry older than the TTL was returned")
+	}
+
+	if ttl := newPermissionCache(0).ttl; ttl != DefaultPermissionCacheTTL {
+		t.Errorf("default TTL = %v, want %v", ttl, DefaultPermissionCacheTTL)
+	}
+}
+
+func TestUserPermissionsCacheInvalidation(t *testing.T) {
+	s := testService(t)
+	user, err := s.EnsureAdmin("admin@example.com", "correct horse")
+	if err != nil {
+		t.Fatalf("EnsureAdmin: %v", err)
+	}
+	if err := s.EnsurePermissions([]string{"facilities:write"}); err != nil {
+		t.Fatalf("EnsurePermissions: %v", err)
+	}
+
+	permissions, err := s.UserPermissions(user.ID)
+	if err != nil {
+		t.Fatalf("UserPermissions: %v", err)
+	}
+	if !permissions["facilities:write"] {
+		t.Errorf("permissions = %v, want facilities:write granted through the admin role", permissions)
+	}
+
+	// A grant made behind the service's back is only seen once the user's
+	// cached permissions are dropped
+	var admin models.Role
+	if err := s.db.Where("name = ?", models.RoleAdmin).First(&admin).Error; err != nil {
+		t.Fatalf("reading admin role: %v", err)
+	}
+	permission := models.Permission{Name: "users:manage"}
+	if err := s.db.Create(&permission).Error; err != nil {
+		t.Fatalf("creating permission: %v", err)
+	}
+	if err := s.db.Create(&models.RolePermission{RoleID: admin.ID, PermissionID: permission.ID}).Error; err != nil {
+		t.Fatalf("granting permission: %v", err)
+	}
+
+	if permissions, _ := s.UserPermissions(user.ID); permissions["users:manage"] {
+		t.Error("UserPermissions reloaded before the cache was invalidated")
+	}
+	s.InvalidatePermissions(user.ID)
+	if permissions, _ := s.UserPermissions(user.ID); !permissions["users:manage"] {
+		t.Errorf("permissions after invalidation = %v, want users:manage", permissions)
+	}
+}
@@ -0,0 +1,348 @@
+// services/preferences.go
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// DefaultUserPreferences returns the preferences of someone who hasn't saved
+// any
+func DefaultUserPreferences() models.UserPreferences {
+	return models.UserPreferences{
+		MapType:             "roadmap",
+		DefaultZoom:         10,
+		ShowFacilities:      true,
+		ShowABACenters:      true,
+		ShowResourceCenters: true,
+		ShowRegionalCenters: true,
+		ShowProviders:       true,
+		PreferredRadius:     25,
+		RequireWaitlist:     false,
+		RequireInsurance:    false,
+	}
+}
+
+// GetUserPreferences retrieves the preferences of a signed-in user, or the
+// defaults if they haven't saved any
+func (s *Service) GetUserPreferences(userID int) (*models.UserPreferences, error) {
+	preferences, err := s.findPreferences(s.db, "user_id", userID)
+	if err != nil || preferences != nil {
+		return preferences, err
+	}
+	defaults := DefaultUserPreferences()
+	defaults.UserID = &userID
+	return &defaults, nil
+}
+
+// GetDevicePreferences retrieves the preferences of an anonymous device, or
+// the defaults if it hasn't saved any
+func (s *Service) GetDevicePreferences(deviceID string) (*models.UserPreferences, error) {
+	preferences, err := s.findPreferences(s.db, "device_id", deviceID)
+	if err != nil || preferences != nil {
+		return preferences, err
+	}
+	defaults := DefaultUserPreferences()
+	return &defaults, nil
+}
+
+// preferenceFields are the writable JSON fields of UserPreferences
+var preferenceFields = map[string]bool{
+	"map_type":              true,
+	"default_zoom":          true,
+	"show_facilities":       true,
+	"show_aba_centers":      true,
+	"show_resource_centers": true,
+	"show_regional_centers": true,
+	"show_providers":        true,
+	"preferred_radius":      true,
+	"require_waitlist":      true,
+	"require_insurance":     true,
+	"preferred_diagnoses":   true,
+}
+
+// UpdateUserPreferences replaces the preferences of a signed-in user
+func (s *Service) UpdateUserPreferences(userID int, preferences *models.UserPreferences) error {
+	preferences.UserID, preferences.DeviceID = &userID, nil
+	return s.savePreferences("user_id", userID, preferences)
+}
+
+// UpdateDevicePreferences replaces the preferences of an anonymous device
+func (s *Service) UpdateDevicePreferences(deviceID string, preferences *models.UserPreferences) error {
+	preferences.UserID, preferences.DeviceID = nil, &deviceID
+	return s.savePreferences("device_id", deviceID, preferences)
+}
+
+// PatchUserPreferences applies a JSON merge patch (RFC 7396) to the
+// preferences of a signed-in user. A null field is reset to its default.
+func (s *Service) PatchUserPreferences(userID int, patch map[string]json.RawMessage) (*models.UserPreferences, error) {
+	current, err := s.GetUserPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := applyPreferencesPatch(current, patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UpdateUserPreferences(userID, preferences); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+// PatchDevicePreferences applies a JSON merge patch to the preferences of an
+// anonymous device
+func (s *Service) PatchDevicePreferences(deviceID string, patch map[string]json.RawMessage) (*models.UserPreferences, error) {
+	current, err := s.GetDevicePreferences(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := applyPreferencesPatch(current, patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UpdateDevicePreferences(deviceID, preferences); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+// applyPreferencesPatch returns current with the fields of patch applied.
+// Arrays are replaced, not merged, as merge patch requires.
+func applyPreferencesPatch(current *models.UserPreferences, patch map[string]json.RawMessage) (*models.UserPreferences, error) {
+	defaults, _ := json.Marshal(DefaultUserPreferences())
+	var defaultFields map[string]json.RawMessage
+	_ = json.Unmarshal(defaults, &defaultFields)
+
+	var verr ValidationError
+	fields := make(map[string]json.RawMessage, len(patch))
+	for field, value := range patch {
+		if !preferenceFields[field] {
+			verr.add(field, "is not a writable field")
+			continue
+		}
+		if string(value) == "null" {
+			value = defaultFields[field]
+		}
+		fields[field] = value
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	preferences := *current
+	for field, value := range fields {
+		data, _ := json.Marshal(map[string]json.RawMessage{field: value})
+		if err := json.Unmarshal(data, &preferences); err != nil {
+			verr.add(field, "has the wrong type")
+		}
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+// MergeDevicePreferences hands the preferences saved on an anonymous device
+// to a user who just signed in on it. If the user already has preferences,
+// whichever were saved last win. The device's row is removed either way.
+func (s *Service) MergeDevicePreferences(deviceID string, userID int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		device, err := s.findPreferences(tx, "device_id", deviceID)
+		if err != nil || device == nil {
+			return err
+		}
+		user, err := s.findPreferences(tx, "user_id", userID)
+		if err != nil {
+			return err
+		}
+
+		if user == nil {
+			err := tx.Model(device).Updates(map[string]interface{}{"user_id": userID, "device_id": nil}).Error
+			if err != nil {
+				return fmt.Errorf("failed to merge preferences: %w", err)
+			}
+			log.Printf("[PREFERENCES] Device preferences %d adopted by user %d", device.ID, userID)
+			return nil
+		}
+
+		if device.UpdatedAt.After(user.UpdatedAt) {
+			merged := *device
+			merged.ID, merged.UserID, merged.DeviceID, merged.CreatedAt = user.ID, &userID, nil, user.CreatedAt
+			if err := tx.Delete(device).Error; err != nil {
+				return fmt.Errorf("failed to merge preferences: %w", err)
+			}
+			if err := tx.Select("*").Omit("id", "created_at").Updates(&merged).Error; err != nil {
+				return fmt.Errorf("failed to merge preferences: %w", err)
+			}
+			log.Printf("[PREFERENCES] Newer device preferences %d replaced those of user %d", device.ID, userID)
+			return nil
+		}
+
+		if err := tx.Delete(device).Error; err != nil {
+			return fmt.Errorf("failed to merge preferences: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *Service) findPreferences(tx *gorm.DB, column string, value interface{}) (*models.UserPreferences, error) {
+	var preferences models.UserPreferences
+	err := tx.Where(column+" = ?", value).First(&preferences).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch preferences: %w", err)
+	}
+	return &preferences, nil
+}
+
+// savePreferences writes every field of preferences to the row owned by
+// column = value, creating it if needed. Select("*") keeps false and zero
+// values from being replaced by the column defaults.
+func (s *Service) savePreferences(column string, value interface{}, preferences *models.UserPreferences) error {
+	if err := ValidateUserPreferences(preferences); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		diagnoses, err := checkPreferredDiagnoses(tx, preferences.PreferredDiagnoses)
+		if err != nil {
+			return err
+		}
+		preferences.PreferredDiagnoses = diagnoses
+
+		existing, err := s.findPreferences(tx, column, value)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			preferences.ID = 0
+			if err := tx.Select("*").Create(preferences).Error; err != nil {
+				return fmt.Errorf("failed to create preferences: %w", err)
+			}
+			return nil
+		}
+
+		preferences.ID, preferences.CreatedAt = existing.ID, existing.CreatedAt
+		if err := tx.Select("*").Omit("id", "created_at").Updates(preferences).Error; err != nil {
+			return fmt.Errorf("failed to update preferences: %w", err)
+		}
+		return nil
+	})
+}
+
+// checkPreferredDiagnoses returns ids without duplicates, in their
+// canonical form, or a ValidationError naming those not in the diagnoses
+// table
+func checkPreferredDiagnoses(tx *gorm.DB, ids []string) ([]string, error) {
+	unique := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return nil, &ValidationError{Fields: map[string]string{
+				"preferred_diagnoses": fmt.Sprintf("%q is not a diagnosis ID", id),
+			}}
+		}
+		if canonical := parsed.String(); !seen[canonical] {
+			seen[canonical] = true
+			unique = append(unique, canonical)
+		}
+	}
+	if len(unique) == 0 {
+		return unique, nil
+	}
+
+	var found []string
+	if err := tx.Model(&models.Diagnosis{}).Where("id IN ?", unique).Pluck("id::text", &found).Error; err != nil {
+		return nil, fmt.Errorf("failed to check diagnoses: %w", err)
+	}
+	known := make(map[string]bool, len(found))
+	for _, id := range found {
+		known[id] = true
+	}
+	var missing []string
+	for _, id := range unique {
+		if !known[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &ValidationError{Fields: map[string]string{
+			"preferred_diagnoses": "unknown diagnoses: " + strings.Join(missing, ", "),
+		}}
+	}
+	return unique, nil
+}
+
+// VisibleEntityTypes returns the entity types in types that preferences
+// don't hide. ShowFacilities hides every facility type at once.
+func VisibleEntityTypes(preferences *models.UserPreferences, types []string) []string {
+	shown := map[string]bool{
+		"aba_centers":      preferences.ShowFacilities && preferences.ShowABACenters,
+		"resource_centers": preferences.ShowFacilities && preferences.ShowResourceCenters,
+		"regional_centers": preferences.ShowFacilities && preferences.ShowRegionalCenters,
+		"providers":        preferences.ShowFacilities && preferences.ShowProviders,
+	}
+	visible := make([]string, 0, len(types))
+	for _, entityType := range types {
+		if show, ok := shown[entityType]; !ok || show {
+			visible = append(visible, entityType)
+		}
+	}
+	return visible
+}
+
+// DiagnosisNames returns the names of the diagnoses with the given IDs,
+// skipping any that no longer exist
+func (s *Service) DiagnosisNames(ids []string) ([]string, error) {
+	var names []string
+	if len(ids) == 0 {
+		return names, nil
+	}
+	if err := s.db.Model(&models.Diagnosis{}).Where("id IN ?", ids).Order("name").Pluck("name", &names).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch diagnoses: %w", err)
+	}
+	return names, nil
+}
+
+// Anonymous devices
+
+// NewDeviceToken returns a new anonymous device ID and the signed token
+// identifying it, to be stored in a cookie
+func (s *Service) NewDeviceToken() (deviceID, token string) {
+	deviceID = uuid.NewString()
+	return deviceID, deviceID + "." + s.sign(deviceID)
+}
+
+// DeviceIDFromToken checks the signature of a device token and returns the
+// device ID it carries
+func (s *Service) DeviceIDFromToken(token string) (string, error) {
+	deviceID, signature, ok := strings.Cut(token, ".")
+	if !ok || deviceID == "" || !hmac.Equal([]byte(signature), []byte(s.sign(deviceID))) {
+		return "", ErrInvalidToken
+	}
+	return deviceID, nil
+}
+
+// sign returns the HMAC of value under the device cookie secret, which
+// also signs contact form tokens
+func (s *Service) sign(value string) string {
+	mac := hmac.New(sha256.New, s.cfg.DeviceCookieSecret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/config"
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestDeviceToken(t *testing.T) {
+	s := &Service{cfg: &config.Config{DeviceCookieSecret: []byte("secret")}}
+	deviceID, token := s.NewDeviceToken()
+	if got, err := s.DeviceIDFromToken(token); err != nil || got != deviceID {
+		t.Fatalf("DeviceIDFromToken(%q) = %q, %v, want %q", token, got, err, deviceID)
+	}
+
+	other := &Service{cfg: &config.Config{DeviceCookieSecret: []byte("other")}}
+	_, signature, _ := strings.Cut(token, ".")
+	for _, bad := range []string{
+		"",
+		deviceID,
+		"." + signature,
+		"another-device." + signature,
+		token + "x",
+	} {
+		if got, err := s.DeviceIDFromToken(bad); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("DeviceIDFromToken(%q) = %q, %v, want ErrInvalidToken", bad, got, err)
+		}
+	}
+	if got, err := other.DeviceIDFromToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("DeviceIDFromToken with another secret = %q, %v, want ErrInvalidToken", got, err)
+	}
+}
+
+func TestApplyPreferencesPatch(t *testing.T) {
+	current := DefaultUserPreferences()
+	current.MapType = "satellite"
+	current.ShowProviders = false
+	current.PreferredDiagnoses = []string{"a", "b"}
+
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(`{"map_type":null,"default_zoom":14,"preferred_diagnoses":["c"]}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	got, err := applyPreferencesPatch(&current, patch)
+	if err != nil {
+		t.Fatalf("applyPreferencesPatch: %v", err)
+	}
+
+	want := current
+	want.MapType = "roadmap" // null resets to the default
+	want.DefaultZoom = 14
+	want.PreferredDiagnoses = []string{"c"} // arrays are replaced
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("patched = %+v, want %+v", *got, want)
+	}
+	if current.MapType != "satellite" || current.DefaultZoom != 10 {
+		t.Errorf("current = %+v, want it left unchanged", current)
+	}
+}
+
+func TestApplyPreferencesPatchErrors(t *testing.T) {
+	tests := []struct {
+		patch  string
+		fields []string
+	}{
+		{`{"id":5}`, []string{"id"}},
+		{`{"user_id":2,"map_type":"hybrid"}`, []string{"user_id"}},
+		{`{"default_zoom":"close"}`, []string{"default_zoom"}},
+		{`{"show_providers":1,"preferred_diagnoses":"a"}`, []string{"preferred_diagnoses", "show_providers"}},
+	}
+
+	for _, tt := range tests {
+		var patch map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+			t.Fatal(err)
+		}
+		current := DefaultUserPreferences()
+		_, err := applyPreferencesPatch(&current, patch)
+		if fields := invalidFields(t, err); !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("applyPreferencesPatch(%s) fields = %v, want %v", tt.patch, fields, tt.fields)
+		}
+	}
+}
+
+func TestValidateUserPreferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*models.UserPreferences)
+		fields []string
+	}{
+		{"defaults", func(p *models.UserPreferences) {}, nil},
+		{"unknown map type", func(p *models.UserPreferences) { p.MapType = "globe" }, []string{"map_type"}},
+		{"zoom too far out", func(p *models.UserPreferences) { p.DefaultZoom = 0 }, []string{"default_zoom"}},
+		{"zoom too far in", func(p *models.UserPreferences) { p.DefaultZoom = MaxZoom + 1 }, []string{"default_zoom"}},
+		{"no radius", func(p *models.UserPreferences) { p.PreferredRadius = 0 }, []string{"preferred_radius"}},
+		{"radius too large", func(p *models.UserPreferences) { p.PreferredRadius = MaxPreferredRadius + 1 }, []string{"preferred_radius"}},
+		{"diagnosis names", func(p *models.UserPreferences) { p.PreferredDiagnoses = []string{"Autism"} }, []string{"preferred_diagnoses"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preferences := DefaultUserPreferences()
+			tt.modify(&preferences)
+			err := ValidateUserPreferences(&preferences)
+			if tt.fields == nil {
+				if err != nil {
+					t.Errorf("ValidateUserPreferences = %v, want nil", err)
+				}
+				return
+			}
+			if fields := invalidFields(t, err); !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestVisibleEntityTypes(t *testing.T) {
+	all := []string{"aba_centers", "resource_centers", "regional_centers", "providers", "resources"}
+	tests := []struct {
+		name   string
+		modify func(*models.UserPreferences)
+		want   []string
+	}{
+		{"defaults", func(p *models.UserPreferences) {}, all},
+		{"providers hidden", func(p *models.UserPreferences) { p.ShowProviders = false },
+			[]string{"aba_centers", "resource_centers", "regional_centers", "resources"}},
+		{"facilities hidden", func(p *models.UserPreferences) { p.ShowFacilities = false }, []string{"resources"}},
+	}
+
+	for _, tt := range tests {
+		preferences := DefaultUserPreferences()
+		tt.modify(&preferences)
+		if got := VisibleEntityTypes(&preferences, all); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: VisibleEntityTypes = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSavedPreferencesRoundTrip(t *testing.T) {
+	s := testService(t)
+	autism, adhd := models.Diagnosis{Name: "Autism"}, models.Diagnosis{Name: "ADHD"}
+	if err := s.db.Create(&autism).Error; err != nil {
+		t.Fatalf("creating diagnosis: %v", err)
+	}
+	if err := s.db.Create(&adhd).Error; err != nil {
+		t.Fatalf("creating diagnosis: %v", err)
+	}
+
+	preferences := DefaultUserPreferences()
+	preferences.ShowProviders = false
+	preferences.PreferredDiagnoses = []string{adhd.ID.String(), autism.ID.String(), adhd.ID.String()}
+	if err := s.UpdateUserPreferences(7, &preferences); err != nil {
+		t.Fatalf("UpdateUserPreferences: %v", err)
+	}
+
+	want := models.StringArray{adhd.ID.String(), autism.ID.String()}
+	saved, err := s.findPreferences(s.db, "user_id", 7)
+	if err != nil {
+		t.Fatalf("findPreferences: %v", err)
+	}
+	if saved == nil {
+		t.Fatal("findPreferences found no row")
+	}
+	if !reflect.DeepEqual(saved.PreferredDiagnoses, want) {
+		t.Errorf("PreferredDiagnoses = %q, want %q", saved.PreferredDiagnoses, want)
+	}
+	if saved.ShowProviders {
+		t.Error("ShowProviders = true, want the saved false")
+	}
+
+	// A device's preferences survive being handed to a user
+	device := DefaultUserPreferences()
+	device.PreferredDiagnoses = []string{autism.ID.String()}
+	if err := s.UpdateDevicePreferences("device-1", &device); err != nil {
+		t.Fatalf("UpdateDevicePreferences: %v", err)
+	}
+	if err := s.MergeDevicePreferences("device-1", 8); err != nil {
+		t.Fatalf("MergeDevicePreferences: %v", err)
+	}
+	merged, err := s.GetUserPreferences(8)
+	if err != nil {
+		t.Fatalf("GetUserPreferences: %v", err)
+	}
+	if !reflect.DeepEqual(merged.PreferredDiagnoses, models.StringArray{autism.ID.String()}) {
+		t.Errorf("merged PreferredDiagnoses = %q, want the device's", merged.PreferredDiagnoses)
+	}
+}
@@ -0,0 +1,205 @@
+// services/search.go
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Result limits for Search
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// searchVectorColumn is the generated tsvector column added to each
+// searchable table
+const searchVectorColumn = "search_vector"
+
+// SearchResult is one ranked match from Search
+type SearchResult struct {
+	EntityType string   `json:"entity_type"`
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Snippet    string   `json:"snippet"` // HTML-escaped, matches wrapped in <mark>
+	Rank       float64  `json:"rank"`
+	Latitude   *float64 `json:"latitude,omitempty"`
+	Longitude  *float64 `json:"longitude,omitempty"`
+}
+
+// searchField is a text column of a searchable table and its tsvector
+// weight: A for names, B for descriptions, C for addresses and D for the rest
+type searchField struct {
+	column string
+	weight string
+}
+
+// searchTable describes how one entity type is searched
+type searchTable struct {
+	entityType string
+	table      string
+	name       string // name column, also matched by trigram similarity
+	fields     []searchField
+	coords     bool
+	softDelete bool
+}
+
+// SearchEntityTypes are the entity types searched by Search
+var SearchEntityTypes = []string{"aba_centers", "resource_centers", "resources", "regional_centers", "providers"}
+
+var searchTables = []searchTable{
+	{
+		entityType: "aba_centers", table: "aba_centers", name: "name", coords: true, softDelete: true,
+		fields: []searchField{
+			{"name", "A"}, {"service_type", "B"}, {"notes", "B"},
+			{"street", "C"}, {"city", "C"}, {"zip", "C"},
+			{"insurance_accepted", "D"}, {"medi_cal_plans", "D"},
+		},
+	},
+	{
+		entityType: "resource_centers", table: "resource_centers", name: "name", coords: true,
+		fields: []searchField{{"name", "A"}, {"description", "B"}, {"address", "C"}},
+	},
+	{
+		entityType: "resources", table: "resources", name: "name", coords: true,
+		fields: []searchField{{"name", "A"}, {"description", "B"}, {"address", "C"}},
+	},
+	{
+		entityType: "regional_centers", table: "regional_centers", name: "regional_center", coords: true,
+		fields: []searchField{
+			{"regional_center", "A"}, {"office_type", "B"},
+			{"address", "C"}, {"city", "C"}, {"county_served", "C"},
+		},
+	},
+	{
+		entityType: "providers", table: "providers", name: "name",
+		fields: []searchField{{"name", "A"}, {"center_based_services", "B"}, {"coverage_areas", "C"}},
+	},
+}
+
+// vectorExpr builds the weighted tsvector of a row
+func (t searchTable) vectorExpr() string {
+	parts := make([]string, len(t.fields))
+	for i, field := range t.fields {
+		parts[i] = fmt.Sprintf("setweight(to_tsvector('english', coalesce(%s, '')), '%s')", field.column, field.weight)
+	}
+	return strings.Join(parts, " || ")
+}
+
+// snippetExpr is the HTML-escaped text snippets are cut from: every field
+// but the name, which is returned on its own
+func (t searchTable) snippetExpr() string {
+	var columns []string
+	for _, field := range t.fields {
+		if field.column != t.name {
+			columns = append(columns, "coalesce("+field.column+", '')")
+		}
+	}
+	text := "concat_ws(' · ', " + strings.Join(columns, ", ") + ")"
+	return "replace(replace(replace(" + text + ", '&', '&amp;'), '<', '&lt;'), '>', '&gt;')"
+}
+
+// InitSearch adds a generated, GIN-indexed tsvector column to each
+// searchable table and, when pg_trgm is available, trigram indexes on their
+// names for fuzzy matching. Without the columns Search computes vectors on
+// the fly; without pg_trgm it matches words only.
+func (s *Service) InitSearch() error {
+	if err := s.db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		log.Printf("[SEARCH] pg_trgm unavailable, fuzzy matching disabled: %v", err)
+		s.trigram = false
+	} else {
+		s.trigram = true
+	}
+
+	s.searchIndexed = true
+	for _, t := range searchTables {
+		statements := []string{
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s tsvector GENERATED ALWAYS AS (%s) STORED",
+				t.table, searchVectorColumn, t.vectorExpr()),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_search_vector ON %s USING GIN (%s)",
+				t.table, t.table, searchVectorColumn),
+		}
+		for _, statement := range statements {
+			if err := s.db.Exec(statement).Error; err != nil {
+				s.searchIndexed = false
+				return fmt.Errorf("failed to index %s for search: %w", t.table, err)
+			}
+		}
+
+		if s.trigram {
+			index := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s_trgm ON %s USING GIN (%s gin_trgm_ops)",
+				t.table, t.name, t.table, t.name)
+			if err := s.db.Exec(index).Error; err != nil {
+				return fmt.Errorf("failed to create trigram index on %s: %w", t.table, err)
+			}
+		}
+	}
+	log.Printf("[SEARCH] Full-text search ready (fuzzy matching: %t)", s.trigram)
+	return nil
+}
+
+// Search finds entities of the given types (all when empty) matching q by
+// full-text search, and by name similarity when pg_trgm is available.
+// Results from all types are mixed and ranked best first.
+func (s *Service) Search(q string, entityTypes []string, limit int) ([]SearchResult, error) {
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	} else if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+	wanted := map[string]bool{}
+	for _, entityType := range entityTypes {
+		wanted[entityType] = true
+	}
+
+	var parts []string
+	var args []interface{}
+	for _, t := range searchTables {
+		if len(wanted) > 0 && !wanted[t.entityType] {
+			continue
+		}
+
+		vector := searchVectorColumn
+		if !s.searchIndexed {
+			vector = "(" + t.vectorExpr() + ")"
+		}
+		rank := fmt.Sprintf("ts_rank(%s, search_query.query)", vector)
+		match := fmt.Sprintf("%s @@ search_query.query", vector)
+		if s.trigram {
+			rank += fmt.Sprintf(" + word_similarity(?, coalesce(%s, ''))", t.name)
+			match = fmt.Sprintf("(%s OR ? <%% %s)", match, t.name)
+			args = append(args, q, q)
+		}
+		coords := "NULL::float8, NULL::float8"
+		if t.coords {
+			coords = "latitude::float8, longitude::float8"
+		}
+		where := match
+		if t.softDelete {
+			where += " AND deleted_at IS NULL"
+		}
+
+		parts = append(parts, fmt.Sprintf(`SELECT '%s' AS entity_type, id::text AS id, coalesce(%s, '') AS name,
+	ts_headline('english', %s, search_query.query,
+		'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5, FragmentDelimiter=" … "') AS snippet,
+	%s AS rank, %s
+FROM %s, search_query
+WHERE %s`, t.entityType, t.name, t.snippetExpr(), rank, coords, t.table, where))
+	}
+	if len(parts) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	sql := "WITH search_query AS (SELECT websearch_to_tsquery('english', ?) AS query)\n" +
+		"SELECT entity_type, id, name, snippet, rank, latitude, longitude FROM (\n" +
+		strings.Join(parts, "\nUNION ALL\n") +
+		"\n) AS results (entity_type, id, name, snippet, rank, latitude, longitude)\nORDER BY rank DESC, name LIMIT ?"
+	args = append(append([]interface{}{q}, args...), limit)
+
+	results := []SearchResult{}
+	if err := s.db.Raw(sql, args...).Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	return results, nil
+}
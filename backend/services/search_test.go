@@ -0,0 +1,66 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestSearchTableExpressions(t *testing.T) {
+	table := searchTable{
+		table: "resources", name: "name",
+		fields: []searchField{{"name", "A"}, {"description", "B"}, {"address", "C"}},
+	}
+
+	want := "setweight(to_tsvector('english', coalesce(name, '')), 'A') || " +
+		"setweight(to_tsvector('english', coalesce(description, '')), 'B') || " +
+		"setweight(to_tsvector('english', coalesce(address, '')), 'C')"
+	if got := table.vectorExpr(); got != want {
+		t.Errorf("vectorExpr = %s, want %s", got, want)
+	}
+
+	snippet := table.snippetExpr()
+	if strings.Contains(snippet, "coalesce(name") || !strings.Contains(snippet, "coalesce(description, ''), coalesce(address, '')") {
+		t.Errorf("snippetExpr = %s, want every field but the name", snippet)
+	}
+	if !strings.Contains(snippet, "'<', '&lt;'") || !strings.HasPrefix(snippet, "replace(replace(replace(") {
+		t.Errorf("snippetExpr = %s, want the text HTML-escaped", snippet)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := testService(t)
+	notes := "Early intervention for <autism> spectrum disorder"
+	center := models.ABACenter{Name: "Sunrise Center", Street: "1 Main St", City: "Irvine", Notes: &notes}
+	if err := s.CreateABACenter(&center); err != nil {
+		t.Fatalf("CreateABACenter: %v", err)
+	}
+	named := models.ResourceCenter{Name: "Autism Family Resource Center", Latitude: 33.7, Longitude: -117.8}
+	if err := s.CreateResourceCenter(&named); err != nil {
+		t.Fatalf("CreateResourceCenter: %v", err)
+	}
+
+	results, err := s.Search("autism", []string{"aba_centers", "resource_centers"}, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Search = %+v, want both matches", results)
+	}
+	// A match in the name outranks one in the notes
+	if results[0].EntityType != "resource_centers" || results[1].ID != center.ID.String() {
+		t.Errorf("Search = %+v, want the named match first", results)
+	}
+	if snippet := results[1].Snippet; !strings.Contains(snippet, "<mark>") || strings.Contains(snippet, "<autism") {
+		t.Errorf("snippet = %q, want the match marked and the text escaped", snippet)
+	}
+
+	if err := s.DeleteABACenter(center.ID); err != nil {
+		t.Fatalf("DeleteABACenter: %v", err)
+	}
+	results, err = s.Search("autism", []string{"aba_centers"}, 10)
+	if err != nil || len(results) != 0 {
+		t.Errorf("Search after deleting = %+v, %v, want no results", results, err)
+	}
+}
@@ -2,107 +2,367 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
 	"github.com/alexbeattie/medicalfacilities/config"
+	"github.com/alexbeattie/medicalfacilities/geocoding"
+	"github.com/alexbeattie/medicalfacilities/mailer"
 	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/notifier"
 )
 
 type Service struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db            *gorm.DB
+	cfg           *config.Config
+	postgis       bool
+	searchIndexed bool // tables have generated search_vector columns
+	trigram       bool // pg_trgm is installed
+	geocoder      geocoding.Geocoder
+	mailer        mailer.Mailer
+	notifier      notifier.Notifier
+	permissions   *permissionCache
 }
 
 func NewService(db *gorm.DB, cfg *config.Config) *Service {
-	return &Service{
-		db:  db,
-		cfg: cfg,
+	s := &Service{
+		db:          db,
+		cfg:         cfg,
+		mailer:      mailer.NewLogMailer(),
+		notifier:    notifier.NewLogNotifier(),
+		permissions: newPermissionCache(cfg.PermissionCacheTTL),
 	}
+	if cfg.GoogleMapsAPIKey != "" {
+		s.geocoder = geocoding.NewCachedGeocoder(db, geocoding.NewGoogleGeocoder(cfg.GoogleMapsAPIKey))
+	}
+	return s
 }
 
+// ErrNotFound is wrapped by errors returned when a record doesn't exist
+var ErrNotFound = errors.New("not found")
+
 // ABA Centers Services
 
-// GetABACenters retrieves ABA centers with filtering
-func (s *Service) GetABACenters(filter *models.SearchFilter) ([]models.ABACenter, error) {
-	var centers []models.ABACenter
-	query := s.db.Model(&models.ABACenter{})
+// GetABACenters retrieves a page of ABA centers with filtering
+func (s *Service) GetABACenters(filter *models.SearchFilter, page PageRequest) (*Page[models.ABACenter], error) {
+	centers, err := listPage(s, s.abaCenterQuery(filter), abaCenterList, filter, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ABA centers: %w", err)
+	}
+	return centers, nil
+}
+
+// abaCenterQuery matches the ABA centers of filter, except for the radius
+// filter applied by listPage
+func (s *Service) abaCenterQuery(filter *models.SearchFilter) *gorm.DB {
+	query := s.db.Model(&models.ABACenter{}).Scopes(
+		textFilter(filter.City, "city"),
+		textFilter(filter.Insurance, "insurance_accepted"),
+		textFilter(filter.Search, "name", "street", "notes"),
+		acceptsInsurance(filter.CarrierIDs, filter.PlanIDs),
+	)
 
 	if filter.ServiceType != "" {
 		query = query.Where("service_type = ?", filter.ServiceType)
 	}
 
 	if filter.InsuranceRequired {
-		query = query.Where("insurance_accepted IS NOT NULL AND insurance_accepted != ''")
-	}
-
-	if filter.WaitlistOnly {
-		query = query.Where("waitlist_availability IS NOT NULL AND waitlist_availability != ''")
-	}
-
-	if err := query.Find(&centers).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch ABA centers: %w", err)
+		query = query.Where("EXISTS (SELECT 1 FROM aba_center_carriers WHERE aba_center_id = aba_centers.id)" +
+			" OR EXISTS (SELECT 1 FROM aba_center_plans WHERE aba_center_id = aba_centers.id)")
 	}
 
-	return centers, nil
+	return query.Scopes(waitlistFilter(filter))
 }
 
 // GetABACenterByID retrieves a single ABA center by ID
 func (s *Service) GetABACenterByID(id uuid.UUID) (*models.ABACenter, error) {
 	var center models.ABACenter
-	if err := s.db.First(&center, "id = ?", id).Error; err != nil {
+	if err := s.db.Preload("Carriers").Preload("Plans").First(&center, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("ABA center not found")
+			return nil, fmt.Errorf("ABA center %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to fetch ABA center: %w", err)
 	}
 	return &center, nil
 }
 
-// CreateABACenter creates a new ABA center
+// CreateABACenter creates a new ABA center. Accepted insurance is linked
+// separately with SetABACenterInsurance. A known waitlist status starts the
+// center's waitlist history.
 func (s *Service) CreateABACenter(center *models.ABACenter) error {
-	if err := s.db.Create(center).Error; err != nil {
-		return fmt.Errorf("failed to create ABA center: %w", err)
+	if center.WaitlistStatus == "" {
+		center.WaitlistStatus = models.WaitlistUnknown
+	}
+	if err := ValidateABACenter(center); err != nil {
+		return err
+	}
+	if center.Latitude == nil || center.Longitude == nil {
+		if err := s.GeocodeABACenter(s.requestContext(), center); err != nil {
+			log.Printf("[GEOCODE] Could not geocode new ABA center %q: %v", center.Name, err)
+		}
 	}
+
+	center.WaitlistUpdatedAt = nil
+	waitlistKnown := center.WaitlistStatus != models.WaitlistUnknown || center.WaitlistWeeks != nil
+	if waitlistKnown {
+		now := time.Now()
+		center.WaitlistUpdatedAt = &now
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(s.writeOmits()...).Create(center).Error; err != nil {
+			return fmt.Errorf("failed to create ABA center: %w", err)
+		}
+		if waitlistKnown {
+			return recordWaitlistChange(tx, center, models.WaitlistUnknown, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reload so the response carries the timestamps as stored
+	saved, err := s.GetABACenterByID(center.ID)
+	if err != nil {
+		return err
+	}
+	*center = *saved
 	return nil
 }
 
-// Resource Centers Services
+// abaCenterFields maps the writable JSON fields of an ABA center to their
+// columns. Coordinates are always written alongside them.
+var abaCenterFields = map[string]string{
+	"name":                  "name",
+	"street":                "street",
+	"city":                  "city",
+	"zip":                   "zip",
+	"phone":                 "phone",
+	"service_type":          "service_type",
+	"waitlist_availability": "waitlist_availability",
+	"waitlist_notes":        "waitlist_notes",
+	"waitlist_status":       "waitlist_status",
+	"waitlist_weeks":        "waitlist_weeks",
+	"dx_verification":       "dx_verification",
+	"insurance_accepted":    "insurance_accepted",
+	"medi_cal_plans":        "medi_cal_plans",
+	"notes":                 "notes",
+	"latitude":              "latitude",
+	"longitude":             "longitude",
+}
 
-// GetResourceCenters retrieves resource centers with filtering
-func (s *Service) GetResourceCenters(filter *models.SearchFilter) ([]models.ResourceCenter, error) {
-	var centers []models.ResourceCenter
-	query := s.db.Model(&models.ResourceCenter{}).Preload("Diagnoses")
+// UpdateABACenter replaces every writable field of an ABA center.
+// center.UpdatedAt must be the value the client last read, otherwise
+// ErrConflict is returned, or a ValidationError when it is missing. On
+// success center holds the stored record.
+func (s *Service) UpdateABACenter(id uuid.UUID, center *models.ABACenter) error {
+	current, err := s.GetABACenterByID(id)
+	if err != nil {
+		return err
+	}
+	if center.WaitlistStatus == "" {
+		center.WaitlistStatus = models.WaitlistUnknown
+	}
+	if err := ValidateABACenter(center); err != nil {
+		return err
+	}
 
-	if err := query.Find(&centers).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch resource centers: %w", err)
+	columns := make([]string, 0, len(abaCenterFields))
+	for _, column := range abaCenterFields {
+		columns = append(columns, column)
 	}
+	return s.saveABACenter(current, center, center.UpdatedAt, columns)
+}
 
-	// Filter by distance if location is provided
-	if filter.Latitude != 0 && filter.Longitude != 0 && filter.MaxDistance > 0 {
-		filteredCenters := make([]models.ResourceCenter, 0)
-		for _, center := range centers {
-			distance := calculateDistance(filter.Latitude, filter.Longitude, center.Latitude, center.Longitude)
-			if distance <= filter.MaxDistance {
-				filteredCenters = append(filteredCenters, center)
+// PatchABACenter updates the fields present in patch, keyed by JSON name.
+// patch must carry the updated_at value the client last read, otherwise
+// ErrConflict is returned, or a ValidationError when it is missing.
+func (s *Service) PatchABACenter(id uuid.UUID, patch map[string]json.RawMessage) (*models.ABACenter, error) {
+	current, err := s.GetABACenterByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var expected *time.Time
+	var verr ValidationError
+	columns := make([]string, 0, len(patch))
+	for field, value := range patch {
+		if field == "updated_at" {
+			if err := json.Unmarshal(value, &expected); err != nil {
+				verr.add(field, "must be an RFC 3339 timestamp")
 			}
+			continue
+		}
+		column, ok := abaCenterFields[field]
+		if !ok {
+			verr.add(field, "is not a writable field")
+			continue
+		}
+		columns = append(columns, column)
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	// Apply the patch on top of the current record so the result is
+	// validated as a whole
+	center := *current
+	data, _ := json.Marshal(patch)
+	if err := json.Unmarshal(data, &center); err != nil {
+		return nil, &ValidationError{Fields: map[string]string{"body": err.Error()}}
+	}
+	center.ID, center.CreatedAt, center.UpdatedAt = current.ID, current.CreatedAt, current.UpdatedAt
+
+	// A status without an estimated wait drops the previous estimate
+	_, weeksPatched := patch["waitlist_weeks"]
+	if _, statusPatched := patch["waitlist_status"]; statusPatched && !weeksPatched &&
+		center.WaitlistStatus != models.WaitlistShort && center.WaitlistStatus != models.WaitlistLong {
+		center.WaitlistWeeks = nil
+		columns = append(columns, "waitlist_weeks")
+	}
+	if err := ValidateABACenter(&center); err != nil {
+		return nil, err
+	}
+
+	if err := s.saveABACenter(current, &center, expected, columns); err != nil {
+		return nil, err
+	}
+	return &center, nil
+}
+
+// saveABACenter writes columns of center over current as long as current
+// still has the expected UpdatedAt, which may only be missing for legacy
+// rows that never had one. A changed address without new coordinates is
+// geocoded again, and a changed waitlist is stamped and added to the
+// history. UpdatedAt is set by gorm on every write.
+func (s *Service) saveABACenter(current, center *models.ABACenter, expected *time.Time, columns []string) error {
+	if current.UpdatedAt != nil {
+		if err := requireUpdatedAt(expected); err != nil {
+			return err
 		}
-		centers = filteredCenters
 	}
+	if !sameTime(current.UpdatedAt, expected) {
+		return fmt.Errorf("ABA center %w", ErrConflict)
+	}
+
+	coordinatesChanged := !sameFloat(current.Latitude, center.Latitude) || !sameFloat(current.Longitude, center.Longitude)
+	addressChanged := ABACenterAddress(current) != ABACenterAddress(center)
+	if center.Latitude == nil || center.Longitude == nil || (addressChanged && !coordinatesChanged) {
+		if err := s.GeocodeABACenter(s.requestContext(), center); err != nil {
+			log.Printf("[GEOCODE] Could not geocode ABA center %q: %v", center.Name, err)
+		}
+	}
+	center.Location = nil
+	if center.Latitude != nil && center.Longitude != nil {
+		center.Location = models.NewPoint(*center.Latitude, *center.Longitude)
+	}
+
+	columns = append(columns, "latitude", "longitude")
+	if s.postgis {
+		columns = append(columns, "location")
+	}
+
+	changed := waitlistChanged(current, center)
+	center.WaitlistUpdatedAt = current.WaitlistUpdatedAt
+	if changed {
+		now := time.Now()
+		center.WaitlistUpdatedAt = &now
+		columns = append(columns, "waitlist_updated_at")
+	}
+
+	center.ID = current.ID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(center).Select(columns)
+		if expected == nil {
+			query = query.Where("updated_at IS NULL")
+		} else {
+			query = query.Where("updated_at = ?", *expected)
+		}
+		result := query.Updates(center)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update ABA center: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("ABA center %w", ErrConflict)
+		}
+		if changed {
+			return recordWaitlistChange(tx, center, current.WaitlistStatus, current.WaitlistWeeks)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reload so the response carries the timestamps as stored
+	saved, err := s.GetABACenterByID(current.ID)
+	if err != nil {
+		return err
+	}
+	*center = *saved
+	return nil
+}
+
+// DeleteABACenter soft-deletes an ABA center; it no longer appears in
+// lists, lookups or searches
+func (s *Service) DeleteABACenter(id uuid.UUID) error {
+	result := s.db.Delete(&models.ABACenter{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete ABA center: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("ABA center %w", ErrNotFound)
+	}
+	return nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func sameFloat(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Resource Centers Services
 
+// GetResourceCenters retrieves a page of resource centers with filtering
+func (s *Service) GetResourceCenters(filter *models.SearchFilter, page PageRequest) (*Page[models.ResourceCenter], error) {
+	centers, err := listPage(s, s.resourceCenterQuery(filter), resourceCenterList, filter, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch resource centers: %w", err)
+	}
 	return centers, nil
 }
 
+// resourceCenterQuery matches the resource centers of filter, except for
+// the radius filter applied by listPage
+func (s *Service) resourceCenterQuery(filter *models.SearchFilter) *gorm.DB {
+	return s.db.Model(&models.ResourceCenter{}).Scopes(
+		textFilter(filter.Search, "name", "description", "address"),
+	)
+}
+
 // GetResourceCenterByID retrieves a single resource center by ID
 func (s *Service) GetResourceCenterByID(id uuid.UUID) (*models.ResourceCenter, error) {
 	var center models.ResourceCenter
 	if err := s.db.Preload("Diagnoses").First(&center, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("resource center not found")
+			return nil, fmt.Errorf("resource center %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to fetch resource center: %w", err)
 	}
@@ -111,35 +371,27 @@ func (s *Service) GetResourceCenterByID(id uuid.UUID) (*models.ResourceCenter, e
 
 // Resources Services
 
-// GetResources retrieves resources with filtering
-func (s *Service) GetResources(filter *models.SearchFilter) ([]models.Resource, error) {
-	var resources []models.Resource
-	query := s.db.Model(&models.Resource{})
-
-	// Filter by diagnosis
-	if len(filter.Diagnoses) > 0 {
-		for _, diagnosis := range filter.Diagnoses {
-			query = query.Where("? = ANY(diagnoses)", diagnosis)
-		}
-	}
-
-	if err := query.Find(&resources).Error; err != nil {
+// GetResources retrieves a page of resources with filtering
+func (s *Service) GetResources(filter *models.SearchFilter, page PageRequest) (*Page[models.Resource], error) {
+	resources, err := listPage(s, s.resourceQuery(filter), resourceList, filter, page)
+	if err != nil {
 		return nil, fmt.Errorf("failed to fetch resources: %w", err)
 	}
+	return resources, nil
+}
 
-	// Filter by distance if location is provided
-	if filter.Latitude != 0 && filter.Longitude != 0 && filter.MaxDistance > 0 {
-		filteredResources := make([]models.Resource, 0)
-		for _, resource := range resources {
-			distance := calculateDistance(filter.Latitude, filter.Longitude, resource.Latitude, resource.Longitude)
-			if distance <= filter.MaxDistance {
-				filteredResources = append(filteredResources, resource)
-			}
-		}
-		resources = filteredResources
-	}
+// resourceQuery matches the resources of filter, except for the radius
+// filter applied by listPage
+func (s *Service) resourceQuery(filter *models.SearchFilter) *gorm.DB {
+	query := s.db.Model(&models.Resource{}).Scopes(
+		textFilter(filter.Search, "name", "description", "address"),
+	)
 
-	return resources, nil
+	// Filter by diagnosis, matching resources with any of those given
+	if len(filter.Diagnoses) > 0 {
+		query = query.Where("diagnoses::text[] && ARRAY[?]::text[]", filter.Diagnoses)
+	}
+	return query
 }
 
 // GetResourceByID retrieves a single resource by ID
@@ -147,7 +399,7 @@ func (s *Service) GetResourceByID(id uuid.UUID) (*models.Resource, error) {
 	var resource models.Resource
 	if err := s.db.First(&resource, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("resource not found")
+			return nil, fmt.Errorf("resource %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to fetch resource: %w", err)
 	}
@@ -156,38 +408,40 @@ func (s *Service) GetResourceByID(id uuid.UUID) (*models.Resource, error) {
 
 // Regional Centers Services
 
-// GetRegionalCenters retrieves regional centers with filtering
-func (s *Service) GetRegionalCenters(filter *models.SearchFilter) ([]models.RegionalCenter, error) {
-	var centers []models.RegionalCenter
-	query := s.db.Model(&models.RegionalCenter{})
-
-	if err := query.Find(&centers).Error; err != nil {
+// GetRegionalCenters retrieves a page of regional centers with filtering
+func (s *Service) GetRegionalCenters(filter *models.SearchFilter, page PageRequest) (*Page[models.RegionalCenter], error) {
+	centers, err := listPage(s, s.regionalCenterQuery(filter), regionalCenterList, filter, page)
+	if err != nil {
 		return nil, fmt.Errorf("failed to fetch regional centers: %w", err)
 	}
-
-	// Filter by distance if location is provided
-	if filter.Latitude != 0 && filter.Longitude != 0 && filter.MaxDistance > 0 {
-		filteredCenters := make([]models.RegionalCenter, 0)
-		for _, center := range centers {
-			if center.Latitude != nil && center.Longitude != nil {
-				distance := calculateDistance(filter.Latitude, filter.Longitude, *center.Latitude, *center.Longitude)
-				if distance <= filter.MaxDistance {
-					filteredCenters = append(filteredCenters, center)
-				}
-			}
-		}
-		centers = filteredCenters
-	}
-
 	return centers, nil
 }
 
+// regionalCenterQuery matches the regional centers of filter, except for
+// the radius filter applied by listPage
+func (s *Service) regionalCenterQuery(filter *models.SearchFilter) *gorm.DB {
+	return s.db.Model(&models.RegionalCenter{}).Scopes(
+		textFilter(filter.County, "county_served"),
+		textFilter(filter.City, "city"),
+		textFilter(filter.Search, "regional_center", "address", "city"),
+	)
+}
+
 // Providers Services
 
-// GetProviders retrieves providers with filtering
-func (s *Service) GetProviders() ([]models.Provider, error) {
-	var providers []models.Provider
-	if err := s.db.Find(&providers).Error; err != nil {
+// GetProviders retrieves a page of providers with filtering
+func (s *Service) GetProviders(filter *models.SearchFilter, page PageRequest) (*Page[models.Provider], error) {
+	query := s.db.Model(&models.Provider{}).Scopes(
+		textFilter(filter.Search, "name", "coverage_areas", "center_based_services"),
+	)
+
+	// Filter by area
+	if filter.Area != "" {
+		query = query.Where("? = ANY(areas)", filter.Area)
+	}
+
+	providers, err := listPage(s, query, providerList, filter, page)
+	if err != nil {
 		return nil, fmt.Errorf("failed to fetch providers: %w", err)
 	}
 	return providers, nil
@@ -195,117 +449,71 @@ func (s *Service) GetProviders() ([]models.Provider, error) {
 
 // Diagnoses Services
 
-// GetDiagnoses retrieves all diagnoses
-func (s *Service) GetDiagnoses() ([]models.Diagnosis, error) {
-	var diagnoses []models.Diagnosis
-	if err := s.db.Find(&diagnoses).Error; err != nil {
+// GetDiagnoses retrieves a page of diagnoses
+func (s *Service) GetDiagnoses(filter *models.SearchFilter, page PageRequest) (*Page[models.Diagnosis], error) {
+	query := s.db.Model(&models.Diagnosis{}).Scopes(
+		textFilter(filter.Search, "name"),
+	)
+
+	diagnoses, err := listPage(s, query, diagnosisList, filter, page)
+	if err != nil {
 		return nil, fmt.Errorf("failed to fetch diagnoses: %w", err)
 	}
 	return diagnoses, nil
 }
 
-// Form Submissions Services
-
-// CreateFormSubmission creates a new form submission
-func (s *Service) CreateFormSubmission(submission *models.FormSubmission) error {
-	if err := s.db.Create(submission).Error; err != nil {
-		return fmt.Errorf("failed to create form submission: %w", err)
-	}
-	return nil
-}
-
 // Search Services
 
-// SearchNearby finds various entities within a radius
-func (s *Service) SearchNearby(lat, lng, radiusMiles float64, entityTypes []string) (map[string]interface{}, error) {
+// NearbyEntityTypes are the entity types searched by SearchNearby
+var NearbyEntityTypes = []string{"aba_centers", "resource_centers", "regional_centers", "resources"}
+
+// SearchNearby finds various entities within the filter's radius, returning
+// up to MaxPageLimit of each type
+func (s *Service) SearchNearby(filter *models.SearchFilter, entityTypes []string) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
 	// If no types specified, search all
 	if len(entityTypes) == 0 {
-		entityTypes = []string{"resource_centers", "regional_centers", "resources"}
+		entityTypes = NearbyEntityTypes
 	}
 
+	page := PageRequest{Limit: MaxPageLimit}
+
 	for _, entityType := range entityTypes {
 		switch entityType {
+		case "aba_centers":
+			centers, err := s.GetABACenters(filter, page)
+			if err != nil {
+				return nil, err
+			}
+			result["aba_centers"] = centers.Items
+
 		case "resource_centers":
-			var centers []models.ResourceCenter
-			s.db.Model(&models.ResourceCenter{}).Preload("Diagnoses").Find(&centers)
-			nearby := make([]models.ResourceCenter, 0)
-			for _, center := range centers {
-				distance := calculateDistance(lat, lng, center.Latitude, center.Longitude)
-				if distance <= radiusMiles {
-					nearby = append(nearby, center)
-				}
+			centers, err := s.GetResourceCenters(filter, page)
+			if err != nil {
+				return nil, err
 			}
-			result["resource_centers"] = nearby
+			result["resource_centers"] = centers.Items
 
 		case "regional_centers":
-			var centers []models.RegionalCenter
-			s.db.Find(&centers)
-			nearby := make([]models.RegionalCenter, 0)
-			for _, center := range centers {
-				if center.Latitude != nil && center.Longitude != nil {
-					distance := calculateDistance(lat, lng, *center.Latitude, *center.Longitude)
-					if distance <= radiusMiles {
-						nearby = append(nearby, center)
-					}
-				}
+			centers, err := s.GetRegionalCenters(filter, page)
+			if err != nil {
+				return nil, err
 			}
-			result["regional_centers"] = nearby
+			result["regional_centers"] = centers.Items
 
 		case "resources":
-			var resources []models.Resource
-			s.db.Find(&resources)
-			nearby := make([]models.Resource, 0)
-			for _, resource := range resources {
-				distance := calculateDistance(lat, lng, resource.Latitude, resource.Longitude)
-				if distance <= radiusMiles {
-					nearby = append(nearby, resource)
-				}
+			resources, err := s.GetResources(filter, page)
+			if err != nil {
+				return nil, err
 			}
-			result["resources"] = nearby
+			result["resources"] = resources.Items
 		}
 	}
 
 	return result, nil
 }
 
-// User Preferences Services
-
-// GetUserPreferences retrieves user preferences
-func (s *Service) GetUserPreferences(userID string) (*models.UserPreferences, error) {
-	var preferences models.UserPreferences
-	if err := s.db.Where("user_id = ?", userID).First(&preferences).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// Return default preferences
-			return &models.UserPreferences{
-				UserID:              userID,
-				MapType:             "roadmap",
-				DefaultZoom:         10,
-				ShowFacilities:      true,
-				ShowABACenters:      true,
-				ShowResourceCenters: true,
-				ShowRegionalCenters: true,
-				ShowProviders:       true,
-				PreferredRadius:     25,
-				RequireWaitlist:     false,
-				RequireInsurance:    false,
-			}, nil
-		}
-		return nil, fmt.Errorf("failed to fetch preferences: %w", err)
-	}
-	return &preferences, nil
-}
-
-// UpdateUserPreferences updates user preferences
-func (s *Service) UpdateUserPreferences(userID string, preferences *models.UserPreferences) error {
-	preferences.UserID = userID
-	if err := s.db.Where("user_id = ?", userID).Assign(preferences).FirstOrCreate(preferences).Error; err != nil {
-		return fmt.Errorf("failed to update preferences: %w", err)
-	}
-	return nil
-}
-
 // SeedSampleData - simplified for ABA database (no seeding needed since database exists)
 func (s *Service) SeedSampleData() error {
 	log.Println("Using existing database - no sample data needed")
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestABACenterWritesCheckUpdatedAt(t *testing.T) {
+	s := testService(t)
+	lat, lng := 33.68, -117.83
+	center := models.ABACenter{Name: "Center", Street: "1 Main St", City: "Irvine", Latitude: &lat, Longitude: &lng}
+	if err := s.CreateABACenter(&center); err != nil {
+		t.Fatalf("CreateABACenter: %v", err)
+	}
+	read := *center.UpdatedAt
+	stale := read.Add(-time.Hour)
+
+	patch := func(fields string) error {
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(fields), &body); err != nil {
+			t.Fatalf("decoding patch: %v", err)
+		}
+		_, err := s.PatchABACenter(center.ID, body)
+		return err
+	}
+	timestamp := func(at time.Time) string {
+		data, _ := json.Marshal(at)
+		return string(data)
+	}
+
+	tests := []struct {
+		name    string
+		write   func() error
+		invalid bool
+		err     error
+	}{
+		{"patch without updated_at", func() error { return patch(`{"name": "Edited"}`) }, true, nil},
+		{"patch with null updated_at", func() error { return patch(`{"name": "Edited", "updated_at": null}`) }, true, nil},
+		{"patch with stale updated_at", func() error { return patch(`{"name": "Edited", "updated_at": ` + timestamp(stale) + `}`) }, false, ErrConflict},
+		{"put without updated_at", func() error {
+			edit := center
+			edit.UpdatedAt = nil
+			return s.UpdateABACenter(center.ID, &edit)
+		}, true, nil},
+		{"put with stale updated_at", func() error {
+			edit := center
+			edit.UpdatedAt = &stale
+			return s.UpdateABACenter(center.ID, &edit)
+		}, false, ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.write()
+			var verr *ValidationError
+			switch {
+			case tt.invalid && (!errors.As(err, &verr) || verr.Fields["updated_at"] == ""):
+				t.Errorf("write = %v, want a validation error for updated_at", err)
+			case !tt.invalid && !errors.Is(err, tt.err):
+				t.Errorf("write = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if err := patch(`{"name": "Edited", "updated_at": ` + timestamp(read) + `}`); err != nil {
+		t.Fatalf("patch with the current updated_at: %v", err)
+	}
+	saved, err := s.GetABACenterByID(center.ID)
+	if err != nil {
+		t.Fatalf("GetABACenterByID: %v", err)
+	}
+	if saved.Name != "Edited" || !saved.UpdatedAt.After(read) {
+		t.Errorf("saved %q at %v, want the edit with a newer updated_at than %v", saved.Name, saved.UpdatedAt, read)
+	}
+}
+
+func TestDeleteABACenter(t *testing.T) {
+	s := testService(t)
+	center := models.ABACenter{Name: "Center", Street: "1 Main St", City: "Irvine"}
+	if err := s.CreateABACenter(&center); err != nil {
+		t.Fatalf("CreateABACenter: %v", err)
+	}
+
+	if err := s.DeleteABACenter(center.ID); err != nil {
+		t.Fatalf("DeleteABACenter: %v", err)
+	}
+	if _, err := s.GetABACenterByID(center.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetABACenterByID after delete = %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteABACenter(center.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteABACenter = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,122 @@
+// services/spatial.go
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// metersPerMile converts the API's mile radii to the meters used by geography
+const metersPerMile = 1609.344
+
+// spatialTables are the tables carrying a PostGIS location column alongside
+// plain latitude/longitude columns
+var spatialTables = []string{"aba_centers", "resource_centers", "resources", "regional_centers"}
+
+// InitSpatial detects PostGIS, fills in missing location columns from the
+// latitude/longitude columns and makes sure every location column has a GiST
+// index usable by ST_DWithin. When PostGIS is unavailable radius searches fall
+// back to filtering with the Haversine formula in Go.
+func (s *Service) InitSpatial() error {
+	var version string
+	if err := s.db.Raw("SELECT PostGIS_Version()").Scan(&version).Error; err != nil {
+		log.Printf("[SPATIAL] PostGIS unavailable, using Haversine filtering: %v", err)
+		s.postgis = false
+		return nil
+	}
+	log.Printf("[SPATIAL] PostGIS %s detected", version)
+	s.postgis = true
+
+	// aba_centers predates geocoding and may not have a location column yet
+	if !s.db.Migrator().HasColumn(&models.ABACenter{}, "location") {
+		if err := s.db.Migrator().AddColumn(&models.ABACenter{}, "location"); err != nil {
+			return fmt.Errorf("failed to add aba_centers.location: %w", err)
+		}
+	}
+
+	if _, err := s.backfillLocations(); err != nil {
+		return err
+	}
+
+	for _, table := range spatialTables {
+		// Indexing the geography cast lets geometry and geography columns share
+		// the same ST_DWithin expression
+		index := fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS idx_%s_location_geog ON %s USING GIST ((location::geography))",
+			table, table)
+		if err := s.db.Exec(index).Error; err != nil {
+			return fmt.Errorf("failed to create spatial index on %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// backfillLocations sets the location column from latitude/longitude on
+// every spatial table where it is missing, returning the number of rows set
+func (s *Service) backfillLocations() (int64, error) {
+	if !s.postgis {
+		return 0, nil
+	}
+
+	var total int64
+	for _, table := range spatialTables {
+		backfill := fmt.Sprintf(
+			"UPDATE %s SET location = ST_SetSRID(ST_MakePoint(longitude, latitude), 4326) "+
+				"WHERE location IS NULL AND latitude IS NOT NULL AND longitude IS NOT NULL", table)
+		result := s.db.Exec(backfill)
+		if result.Error != nil {
+			return total, fmt.Errorf("failed to backfill %s.location: %w", table, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("[SPATIAL] Backfilled location for %d %s rows", result.RowsAffected, table)
+		}
+		total += result.RowsAffected
+	}
+	return total, nil
+}
+
+// SpatialEnabled reports whether radius filters run in PostGIS
+func (s *Service) SpatialEnabled() bool {
+	return s.postgis
+}
+
+// WithinRadius scopes a query on table to rows whose location lies within
+// radiusMiles of (lat, lng). It is a no-op without PostGIS, in which case the
+// caller filters the loaded rows with calculateDistance.
+func (s *Service) WithinRadius(table string, lat, lng, radiusMiles float64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !s.postgis {
+			return db
+		}
+		return db.Where(
+			fmt.Sprintf("ST_DWithin(%s.location::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)", table),
+			lng, lat, radiusMiles*metersPerMile,
+		)
+	}
+}
+
+// WithDistance selects table.* plus a distance_miles column measured from
+// (lat, lng). Without PostGIS distances are computed in Go instead.
+func (s *Service) WithDistance(table string, lat, lng float64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !s.postgis {
+			return db
+		}
+		return db.Select(
+			fmt.Sprintf("%s.*, ST_Distance(%s.location::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography) / %f AS distance_miles",
+				table, table, metersPerMile),
+			lng, lat,
+		)
+	}
+}
+
+// sortByDistance reports whether rows filtered in Go still need to be ordered
+// by their computed distance
+func (s *Service) sortByDistance(sortOrder string) bool {
+	return !s.postgis && sortOrder != models.SortName
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// dryRunDB builds Postgres statements without connecting to a database
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=localhost dbname=test"), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("opening dry run database: %v", err)
+	}
+	return db
+}
+
+func TestWithinRadius(t *testing.T) {
+	db := dryRunDB(t)
+
+	s := &Service{db: db, postgis: true}
+	stmt := db.Model(&models.Resource{}).Scopes(s.WithinRadius("resources", 34.05, -118.24, 10)).
+		Find(&[]models.Resource{}).Statement
+	sql := stmt.SQL.String()
+	if !strings.Contains(sql, "ST_DWithin(resources.location::geography, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3)") {
+		t.Errorf("SQL = %s, want an ST_DWithin filter on resources.location", sql)
+	}
+	if want := []interface{}{-118.24, 34.05, 10 * metersPerMile}; !reflect.DeepEqual(stmt.Vars, want) {
+		t.Errorf("vars = %v, want longitude, latitude and the radius in meters %v", stmt.Vars, want)
+	}
+
+	// Without PostGIS the rows are filtered in Go instead
+	s.postgis = false
+	stmt = db.Model(&models.Resource{}).Scopes(s.WithinRadius("resources", 34.05, -118.24, 10)).
+		Find(&[]models.Resource{}).Statement
+	if sql := stmt.SQL.String(); strings.Contains(sql, "WHERE") {
+		t.Errorf("SQL without PostGIS = %s, want no filter", sql)
+	}
+}
+
+func TestWithDistance(t *testing.T) {
+	db := dryRunDB(t)
+	s := &Service{db: db, postgis: true}
+
+	stmt := db.Model(&models.Resource{}).Scopes(s.WithDistance("resources", 34.05, -118.24)).
+		Find(&[]models.Resource{}).Statement
+	if sql := stmt.SQL.String(); !strings.Contains(sql, "SELECT resources.*, ST_Distance(resources.location::geography") ||
+		!strings.Contains(sql, "AS distance_miles") {
+		t.Errorf("SQL = %s, want resources.* and a distance_miles column", sql)
+	}
+}
+
+func TestSortByDistance(t *testing.T) {
+	tests := []struct {
+		postgis   bool
+		sortOrder string
+		want      bool
+	}{
+		{false, "", true},
+		{false, models.SortDistance, true},
+		{false, models.SortName, false},
+		{true, "", false},
+	}
+
+	for _, tt := range tests {
+		s := &Service{postgis: tt.postgis}
+		if got := s.sortByDistance(tt.sortOrder); got != tt.want {
+			t.Errorf("sortByDistance(%q) with postgis=%t = %t, want %t", tt.sortOrder, tt.postgis, got, tt.want)
+		}
+	}
+}
+
+func TestWriteOmitsLocation(t *testing.T) {
+	db := dryRunDB(t)
+	lat, lng := 34.05, -118.24
+
+	for _, postgis := range []bool{true, false} {
+		s := &Service{db: db, postgis: postgis}
+		center := models.ABACenter{Name: "Center", Latitude: &lat, Longitude: &lng}
+		sql := db.Omit(s.writeOmits()...).Create(&center).Statement.SQL.String()
+		if got := strings.Contains(sql, `"location"`); got != postgis {
+			t.Errorf("INSERT with postgis=%t = %s, want location written only with PostGIS", postgis, sql)
+		}
+	}
+}
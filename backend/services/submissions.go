@@ -0,0 +1,382 @@
+// services/submissions.go
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+	"github.com/alexbeattie/medicalfacilities/notifier"
+)
+
+// DefaultFormMinFillTime is how long a person needs at least to fill in the
+// contact form
+const DefaultFormMinFillTime = 3 * time.Second
+
+// FormTokenMaxAge is how long a contact form token stays valid
+const FormTokenMaxAge = 24 * time.Hour
+
+// notifyTimeout bounds how long notifying about one submission may take
+const notifyTimeout = 30 * time.Second
+
+// ErrSpam is returned for submissions that tripped a bot check. Callers
+// should answer as if the submission succeeded so bots learn nothing.
+var ErrSpam = errors.New("submission looks automated")
+
+// SubmissionChecks carries the bot checks sent along with a form submission
+type SubmissionChecks struct {
+	// Honeypot is a field hidden from people; anything in it came from a bot
+	Honeypot string
+	// FormToken is the token from NewFormToken handed out when the form was
+	// shown. Our form always sends one, so a submission without one didn't
+	// come from it.
+	FormToken string
+}
+
+// formTokenPrefix keeps form tokens from being valid device tokens
+const formTokenPrefix = "form:"
+
+// NewFormToken returns a signed token recording when the contact form was
+// shown, to be sent back with the submission
+func (s *Service) NewFormToken() string {
+	issued := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	return issued + "." + s.sign(formTokenPrefix+issued)
+}
+
+// formTokenIssuedAt checks the signature of a form token and returns when
+// it was issued
+func (s *Service) formTokenIssuedAt(token string) (time.Time, error) {
+	issued, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(formTokenPrefix+issued))) {
+		return time.Time{}, ErrInvalidToken
+	}
+	ms, err := strconv.ParseInt(issued, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidToken
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// SetNotifier replaces the notifier told about new form submissions
+func (s *Service) SetNotifier(n notifier.Notifier) {
+	s.notifier = n
+}
+
+// CreateFormSubmission validates and stores a contact form submission, then
+// notifies staff in the background. Submissions failing a bot check are
+// dropped with ErrSpam.
+func (s *Service) CreateFormSubmission(submission *models.FormSubmission, checks SubmissionChecks) error {
+	if err := ValidateFormSubmission(submission); err != nil {
+		return err
+	}
+	if reason := s.spamReason(checks); reason != "" {
+		ip := ""
+		if submission.IPAddress != nil {
+			ip = *submission.IPAddress
+		}
+		log.Printf("[FORM_SUBMISSION] Dropped submission from %s: %s", ip, reason)
+		return ErrSpam
+	}
+
+	submission.ID = 0
+	if err := s.db.Create(submission).Error; err != nil {
+		return fmt.Errorf("failed to create form submission: %w", err)
+	}
+
+	stored := *submission
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := s.notifier.Notify(ctx, stored); err != nil {
+			log.Printf("[FORM_SUBMISSION] Failed to notify about submission %d: %v", stored.ID, err)
+		}
+	}()
+	return nil
+}
+
+// spamReason describes the bot check a submission failed, or returns ""
+func (s *Service) spamReason(checks SubmissionChecks) string {
+	if checks.Honeypot != "" {
+		return "honeypot field was filled in"
+	}
+	if checks.FormToken == "" {
+		return "form token was not sent"
+	}
+	issued, err := s.formTokenIssuedAt(checks.FormToken)
+	if err != nil {
+		return "form token is invalid"
+	}
+	minFillTime := s.cfg.FormMinFillTime
+	if minFillTime <= 0 {
+		minFillTime = DefaultFormMinFillTime
+	}
+	switch fillTime := time.Since(issued); {
+	case fillTime < minFillTime:
+		return fmt.Sprintf("form was filled in after %s", fillTime.Round(time.Millisecond))
+	case fillTime > FormTokenMaxAge:
+		return "form token expired"
+	}
+	return ""
+}
+
+// Inbox statuses accepted by SubmissionFilter.Status
+const (
+	SubmissionStatusUnread  = "unread"
+	SubmissionStatusOpen    = "open"
+	SubmissionStatusHandled = "handled"
+)
+
+// MaxSubmissionNoteLength caps internal notes, in characters
+const MaxSubmissionNoteLength = 5000
+
+// SubmissionFilter narrows the admin inbox
+type SubmissionFilter struct {
+	Search       string     // name, email and message
+	From         *time.Time // received at or after
+	To           *time.Time // received before
+	Status       string     // one of the SubmissionStatus constants, or "" for all
+	AssignedToID *int
+	Unassigned   bool
+}
+
+func (s *Service) submissionQuery(filter SubmissionFilter) *gorm.DB {
+	query := s.db.Model(&models.FormSubmission{}).Scopes(
+		textFilter(filter.Search, "name", "email", "message"),
+	)
+	if filter.From != nil {
+		query = query.Where("form_submissions.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("form_submissions.created_at < ?", *filter.To)
+	}
+	switch filter.Status {
+	case SubmissionStatusUnread:
+		query = query.Where("read_at IS NULL")
+	case SubmissionStatusOpen:
+		query = query.Where("handled_at IS NULL")
+	case SubmissionStatusHandled:
+		query = query.Where("handled_at IS NOT NULL")
+	}
+	if filter.AssignedToID != nil {
+		query = query.Where("assigned_to_id = ?", *filter.AssignedToID)
+	} else if filter.Unassigned {
+		query = query.Where("assigned_to_id IS NULL")
+	}
+	return query
+}
+
+// submissionKeyset lists the inbox newest first
+var submissionKeyset = Keyset{Table: "form_submissions", Desc: true}
+
+// GetFormSubmissions retrieves a page of the inbox, newest first
+func (s *Service) GetFormSubmissions(filter SubmissionFilter, page PageRequest) (*Page[models.FormSubmission], error) {
+	key := func(submission *models.FormSubmission) (interface{}, interface{}) { return nil, submission.ID }
+	preload := func(db *gorm.DB) *gorm.DB { return db.Preload("AssignedTo") }
+	submissions, err := Paginate(s.submissionQuery(filter), submissionKeyset, page, key, preload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch form submissions: %w", err)
+	}
+	return submissions, nil
+}
+
+// GetFormSubmissionByID retrieves a submission with its assignee and notes
+func (s *Service) GetFormSubmissionByID(id int) (*models.FormSubmission, error) {
+	var submission models.FormSubmission
+	err := s.db.Preload("AssignedTo").
+		Preload("Notes", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") }).
+		Preload("Notes.Author").
+		First(&submission, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("form submission %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to fetch form submission: %w", err)
+	}
+	if submission.Notes == nil {
+		submission.Notes = []models.SubmissionNote{}
+	}
+	return &submission, nil
+}
+
+// PatchFormSubmission updates the inbox state of a submission from a JSON
+// object with any of "read" and "handled" (booleans) and "assigned_to_id"
+// (a user id, or null to unassign). Handling a submission also marks it
+// read.
+func (s *Service) PatchFormSubmission(id int, patch map[string]json.RawMessage) (*models.FormSubmission, error) {
+	current, err := s.GetFormSubmissionByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var verr ValidationError
+	now := time.Now()
+	timestamps := map[string]*time.Time{"read": current.ReadAt, "handled": current.HandledAt}
+	updates := map[string]interface{}{}
+	for field, value := range patch {
+		switch field {
+		case "read", "handled":
+			var flag bool
+			if err := json.Unmarshal(value, &flag); err != nil {
+				verr.add(field, "must be true or false")
+				continue
+			}
+			// Keep the original time when it is already set
+			if !flag {
+				updates[field+"_at"] = nil
+			} else if timestamps[field] == nil {
+				updates[field+"_at"] = now
+			}
+		case "assigned_to_id":
+			var assignee *int
+			if err := json.Unmarshal(value, &assignee); err != nil {
+				verr.add(field, "must be a user id or null")
+				continue
+			}
+			if assignee != nil {
+				var user models.User
+				err := s.db.Where("disabled_at IS NULL").First(&user, *assignee).Error
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					verr.add(field, "must be an active user")
+					continue
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to fetch user: %w", err)
+				}
+			}
+			updates["assigned_to_id"] = assignee
+		default:
+			verr.add(field, "is not a writable field")
+		}
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+	if _, readChanged := updates["read_at"]; updates["handled_at"] != nil && current.ReadAt == nil && !readChanged {
+		updates["read_at"] = now
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&models.FormSubmission{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update form submission: %w", err)
+		}
+	}
+	return s.GetFormSubmissionByID(id)
+}
+
+// AddSubmissionNote adds an internal note by authorID to a submission
+func (s *Service) AddSubmissionNote(submissionID, authorID int, body string) (*models.SubmissionNote, error) {
+	body = strings.TrimSpace(body)
+	switch {
+	case body == "":
+		return nil, &ValidationError{Fields: map[string]string{"body": "is required"}}
+	case utf8.RuneCountInString(body) > MaxSubmissionNoteLength:
+		return nil, &ValidationError{Fields: map[string]string{
+			"body": fmt.Sprintf("must be at most %d characters", MaxSubmissionNoteLength),
+		}}
+	}
+
+	if err := s.db.First(&models.FormSubmission{}, submissionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("form submission %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to fetch form submission: %w", err)
+	}
+
+	note := models.SubmissionNote{SubmissionID: submissionID, AuthorID: &authorID, Body: body}
+	if err := s.db.Create(&note).Error; err != nil {
+		return nil, fmt.Errorf("failed to add note: %w", err)
+	}
+	if err := s.db.Preload("Author").First(&note, note.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch note: %w", err)
+	}
+	return &note, nil
+}
+
+// submissionCSVHeader names the columns of ExportFormSubmissions
+var submissionCSVHeader = []string{
+	"id", "received_at", "name", "email", "message", "ip_address",
+	"read_at", "handled_at", "assigned_to",
+}
+
+// ExportFormSubmissions writes the submissions matching filter to w as CSV,
+// newest first, loading them in batches
+func (s *Service) ExportFormSubmissions(filter SubmissionFilter, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(submissionCSVHeader); err != nil {
+		return err
+	}
+
+	// Walk the keyset in batches; FindInBatches only pages in id order
+	const batchSize = 500
+	var last *cursor
+	for {
+		query := s.submissionQuery(filter).Preload("AssignedTo").Scopes(submissionKeyset.Order)
+		if last != nil {
+			query = submissionKeyset.after(query, last)
+		}
+		var batch []models.FormSubmission
+		if err := query.Limit(batchSize).Find(&batch).Error; err != nil {
+			return fmt.Errorf("failed to export form submissions: %w", err)
+		}
+
+		for _, submission := range batch {
+			assignee := ""
+			if submission.AssignedTo != nil {
+				assignee = submission.AssignedTo.Email
+			}
+			row := []string{
+				strconv.Itoa(submission.ID),
+				submission.CreatedAt.Format(time.RFC3339),
+				submission.Name,
+				submission.Email,
+				submission.Message,
+				Deref(submission.IPAddress),
+				formatOptionalTime(submission.ReadAt),
+				formatOptionalTime(submission.HandledAt),
+				assignee,
+			}
+			if err := writer.Write(csvSafe(row)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+
+		if len(batch) < batchSize {
+			return nil
+		}
+		last = &cursor{ID: batch[len(batch)-1].ID}
+	}
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// csvSafe prefixes cells that spreadsheets would run as formulas with a
+// quote, since submissions are untrusted input
+func csvSafe(row []string) []string {
+	for i, cell := range row {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			row[i] = "'" + cell
+		}
+	}
+	return row
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexbeattie/medicalfacilities/config"
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestSpamReason(t *testing.T) {
+	s := &Service{cfg: &config.Config{DeviceCookieSecret: []byte("secret"), FormMinFillTime: 3 * time.Second}}
+	tokenAt := func(issued time.Time) string {
+		ms := strconv.FormatInt(issued.UnixMilli(), 10)
+		return ms + "." + s.sign(formTokenPrefix+ms)
+	}
+	now := time.Now()
+	_, deviceToken := s.NewDeviceToken()
+	forged := strings.Replace(tokenAt(now.Add(-time.Minute)), ".", "0.", 1)
+	other := &Service{cfg: &config.Config{DeviceCookieSecret: []byte("other")}}
+
+	tests := []struct {
+		name   string
+		checks SubmissionChecks
+		spam   bool
+	}{
+		{"person", SubmissionChecks{FormToken: tokenAt(now.Add(-time.Minute))}, false},
+		{"honeypot", SubmissionChecks{Honeypot: "https://spam.example", FormToken: tokenAt(now.Add(-time.Minute))}, true},
+		{"no token", SubmissionChecks{}, true},
+		{"too fast", SubmissionChecks{FormToken: tokenAt(now.Add(-time.Second))}, true},
+		{"expired", SubmissionChecks{FormToken: tokenAt(now.Add(-FormTokenMaxAge - time.Minute))}, true},
+		{"tampered", SubmissionChecks{FormToken: forged}, true},
+		{"other secret", SubmissionChecks{FormToken: other.NewFormToken()}, true},
+		{"device token", SubmissionChecks{FormToken: deviceToken}, true},
+		{"garbage", SubmissionChecks{FormToken: "5000"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := s.spamReason(tt.checks)
+			if (reason != "") != tt.spam {
+				t.Errorf("spamReason = %q, want spam %t", reason, tt.spam)
+			}
+		})
+	}
+}
+
+func TestNewFormToken(t *testing.T) {
+	s := &Service{cfg: &config.Config{DeviceCookieSecret: []byte("secret")}}
+	before := time.Now().Truncate(time.Millisecond)
+
+	issued, err := s.formTokenIssuedAt(s.NewFormToken())
+	if err != nil {
+		t.Fatalf("formTokenIssuedAt: %v", err)
+	}
+	if issued.Before(before) || issued.After(time.Now()) {
+		t.Errorf("issued at %v, want the time NewFormToken ran", issued)
+	}
+	if _, err := s.DeviceIDFromToken(s.NewFormToken()); err == nil {
+		t.Error("DeviceIDFromToken accepted a form token")
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	row := csvSafe([]string{"=SUM(A1)", "+1", "-2", "@cmd", "\tx", "Ann", "", "a=b"})
+	want := []string{"'=SUM(A1)", "'+1", "'-2", "'@cmd", "'\tx", "Ann", "", "a=b"}
+	if !reflect.DeepEqual(row, want) {
+		t.Errorf("csvSafe = %q, want %q", row, want)
+	}
+}
+
+// patchJSON decodes a JSON object into a patch
+func patchJSON(t *testing.T, object string) map[string]json.RawMessage {
+	t.Helper()
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(object), &patch); err != nil {
+		t.Fatal(err)
+	}
+	return patch
+}
+
+func TestFormSubmissionInbox(t *testing.T) {
+	s := testService(t)
+	staff, err := s.EnsureAdmin("staff@example.com", "correct horse")
+	if err != nil {
+		t.Fatalf("EnsureAdmin: %v", err)
+	}
+	older := models.FormSubmission{Name: "Ann", Email: "ann@example.com", Message: "=HYPERLINK(\"x\")"}
+	newer := models.FormSubmission{Name: "Bob", Email: "bob@example.com", Message: "Hi"}
+	for _, submission := range []*models.FormSubmission{&older, &newer} {
+		if err := s.db.Create(submission).Error; err != nil {
+			t.Fatalf("creating submission: %v", err)
+		}
+	}
+
+	// Handling marks read too, and assigning needs an active user
+	handled, err := s.PatchFormSubmission(older.ID, patchJSON(t, `{"handled": true}`))
+	if err != nil {
+		t.Fatalf("PatchFormSubmission: %v", err)
+	}
+	if handled.HandledAt == nil || handled.ReadAt == nil {
+		t.Errorf("handled submission = %+v, want it handled and read", handled)
+	}
+	if _, err := s.PatchFormSubmission(older.ID, patchJSON(t, `{"assigned_to_id": -1}`)); invalidFields(t, err)[0] != "assigned_to_id" {
+		t.Errorf("assigning an unknown user = %v, want an assigned_to_id error", err)
+	}
+	if _, err := s.PatchFormSubmission(older.ID, patchJSON(t, `{"name": "Eve"}`)); invalidFields(t, err)[0] != "name" {
+		t.Errorf("patching the name = %v, want a name error", err)
+	}
+	if _, err := s.PatchFormSubmission(newer.ID, patchJSON(t, fmt.Sprintf(`{"assigned_to_id": %d}`, staff.ID))); err != nil {
+		t.Fatalf("assigning: %v", err)
+	}
+
+	page, err := s.GetFormSubmissions(SubmissionFilter{Status: SubmissionStatusOpen}, PageRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetFormSubmissions: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != newer.ID || page.Items[0].AssignedTo == nil {
+		t.Errorf("open submissions = %+v, want the newer one with its assignee", page.Items)
+	}
+
+	if _, err := s.AddSubmissionNote(older.ID, staff.ID, "  "); invalidFields(t, err)[0] != "body" {
+		t.Errorf("empty note = %v, want a body error", err)
+	}
+	if _, err := s.AddSubmissionNote(-1, staff.ID, "Called back"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("note on a missing submission = %v, want ErrNotFound", err)
+	}
+	if _, err := s.AddSubmissionNote(older.ID, staff.ID, " Called back "); err != nil {
+		t.Fatalf("AddSubmissionNote: %v", err)
+	}
+	withNotes, err := s.GetFormSubmissionByID(older.ID)
+	if err != nil || len(withNotes.Notes) != 1 || withNotes.Notes[0].Body != "Called back" || withNotes.Notes[0].Author == nil {
+		t.Errorf("GetFormSubmissionByID = %+v, %v, want the trimmed note and its author", withNotes, err)
+	}
+
+	var out bytes.Buffer
+	if err := s.ExportFormSubmissions(SubmissionFilter{}, &out); err != nil {
+		t.Fatalf("ExportFormSubmissions: %v", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	if len(rows) != 3 || !reflect.DeepEqual(rows[0], submissionCSVHeader) {
+		t.Fatalf("CSV = %q, want the header and two rows", rows)
+	}
+	if rows[1][2] != "Bob" || rows[1][8] != "staff@example.com" || rows[2][4] != "'=HYPERLINK(\"x\")" {
+		t.Errorf("CSV rows = %q, want newest first, the assignee and formulas escaped", rows[1:])
+	}
+}
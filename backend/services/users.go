@@ -0,0 +1,436 @@
+// services/users.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/alexbeattie/medicalfacilities/mailer"
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// Lifetimes of the one-time links emailed to users
+const (
+	InviteTokenTTL        = 7 * 24 * time.Hour
+	PasswordResetTokenTTL = 24 * time.Hour
+)
+
+// SetMailer replaces the mailer used for invitations and password resets
+func (s *Service) SetMailer(m mailer.Mailer) {
+	s.mailer = m
+}
+
+var userList = listSpec[models.User]{
+	table:    "users",
+	nameExpr: "users.email",
+	preloads: []string{"Roles"},
+	name:     func(u *models.User) string { return u.Email },
+	id:       func(u *models.User) interface{} { return u.ID },
+}
+
+// Users
+
+// GetUsers retrieves a page of users with their roles
+func (s *Service) GetUsers(filter *models.SearchFilter, page PageRequest) (*Page[models.User], error) {
+	query := s.db.Model(&models.User{}).Scopes(
+		textFilter(filter.Search, "email", "COALESCE(name, '')", "COALESCE(first_name, '')", "COALESCE(last_name, '')"),
+	)
+	users, err := listPage(s, query, userList, filter, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+	return users, nil
+}
+
+// GetUserByID retrieves a single user with their roles
+func (s *Service) GetUserByID(id int) (*models.User, error) {
+	var user models.User
+	if err := s.db.Preload("Roles").First(&user, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	return &user, nil
+}
+
+// InviteUser creates a user without a password, grants them roleIDs and
+// emails them a link to set their password. If the email can't be sent the
+// user isn't created, so the invitation can be retried.
+func (s *Service) InviteUser(ctx context.Context, user *models.User, roleIDs []int) error {
+	address, err := mail.ParseAddress(strings.TrimSpace(user.Email))
+	if err != nil {
+		return &ValidationError{Fields: map[string]string{"email": "must be a valid email address"}}
+	}
+	user.Email = address.Address
+	user.PasswordHash = "" // no password matches until the invitation is accepted
+	user.DisabledAt = nil
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("LOWER(email) = LOWER(?)", user.Email).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check email: %w", err)
+		}
+		if count > 0 {
+			return &ValidationError{Fields: map[string]string{"email": "is already used by another user"}}
+		}
+
+		if err := tx.Omit(clause.Associations).Create(user).Error; err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		if err := setUserRoles(tx, user.ID, roleIDs); err != nil {
+			return err
+		}
+		return s.sendUserToken(ctx, tx, user, models.TokenPurposeInvite)
+	})
+	if err != nil {
+		return err
+	}
+
+	saved, err := s.GetUserByID(user.ID)
+	if err != nil {
+		return err
+	}
+	*user = *saved
+	return nil
+}
+
+// SetUserDisabled disables or re-enables a user. Disabling ends all of the
+// user's sessions.
+func (s *Service) SetUserDisabled(id int, disabled bool) (*models.User, error) {
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", id).Update("disabled_at", disabledAt)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update user: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("user %w", ErrNotFound)
+		}
+		if disabled {
+			if err := tx.Where("user_id = ?", id).Delete(&models.Session{}).Error; err != nil {
+				return fmt.Errorf("failed to end sessions: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[USERS] User %d disabled=%t", id, disabled)
+	return s.GetUserByID(id)
+}
+
+// ResetUserPassword ends all of a user's sessions and emails them a link to
+// choose a new password
+func (s *Service) ResetUserPassword(ctx context.Context, id int) error {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Where("user_id = ?", id).Delete(&models.Session{}).Error; err != nil {
+		return fmt.Errorf("failed to end sessions: %w", err)
+	}
+	return s.sendUserToken(ctx, s.db, user, models.TokenPurposePasswordReset)
+}
+
+// SetPasswordWithToken sets a user's password using a one-time invitation or
+// password reset token
+func (s *Service) SetPasswordWithToken(token, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var userToken models.UserToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(token), time.Now()).
+			First(&userToken).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch token: %w", err)
+		}
+
+		now := time.Now()
+		if err := tx.Model(&userToken).Update("used_at", now).Error; err != nil {
+			return fmt.Errorf("failed to use token: %w", err)
+		}
+		result := tx.Model(&models.User{}).Where("id = ? AND disabled_at IS NULL", userToken.UserID).
+			Update("password_hash", hash)
+		if result.Error != nil {
+			return fmt.Errorf("failed to set password: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidToken
+		}
+
+		log.Printf("[USERS] User %d set their password (%s)", userToken.UserID, userToken.Purpose)
+		return nil
+	})
+}
+
+// sendUserToken replaces any unused token of the same purpose and emails
+// the user a link carrying a new one. The token is stored with db, so a
+// caller's transaction can be rolled back when the email fails.
+func (s *Service) sendUserToken(ctx context.Context, db *gorm.DB, user *models.User, purpose string) error {
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	ttl, subject, intro := InviteTokenTTL, "You're invited to Medical Facilities",
+		"You have been invited to Medical Facilities. Choose a password to finish setting up your account:"
+	if purpose == models.TokenPurposePasswordReset {
+		ttl, subject, intro = PasswordResetTokenTTL, "Reset your Medical Facilities password",
+			"A password reset was requested for your account. Choose a new password:"
+	}
+
+	userToken := models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).
+			Delete(&models.UserToken{}).Error; err != nil {
+			return fmt.Errorf("failed to revoke old tokens: %w", err)
+		}
+		if err := tx.Create(&userToken).Error; err != nil {
+			return fmt.Errorf("failed to create token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.cfg.AppBaseURL, "/") + "/set-password?token=" + token
+	body := fmt.Sprintf("%s\n\n%s\n\nThis link can be used once and expires on %s.\n",
+		intro, link, userToken.ExpiresAt.Format("January 2, 2006 at 3:04 PM MST"))
+	if err := s.mailer.Send(ctx, mailer.Message{To: user.Email, Subject: subject, Body: body}); err != nil {
+		return fmt.Errorf("failed to email %s link: %w", purpose, err)
+	}
+	return nil
+}
+
+// SetUserRoles replaces the roles of a user
+func (s *Service) SetUserRoles(userID int, roleIDs []int) (*models.User, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.User{}, "id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("user %w", ErrNotFound)
+			}
+			return fmt.Errorf("failed to fetch user: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserRole{}).Error; err != nil {
+			return fmt.Errorf("failed to remove roles: %w", err)
+		}
+		return setUserRoles(tx, userID, roleIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.InvalidatePermissions(userID)
+	return s.GetUserByID(userID)
+}
+
+// AddUserRole grants a role to a user; granting it again is a no-op
+func (s *Service) AddUserRole(userID, roleID int) error {
+	if _, err := s.GetUserByID(userID); err != nil {
+		return err
+	}
+	if err := s.db.First(&models.Role{}, "id = ?", roleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("role %w", ErrNotFound)
+		}
+		return fmt.Errorf("failed to fetch role: %w", err)
+	}
+
+	grant := models.UserRole{UserID: userID, RoleID: roleID}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&grant).Error; err != nil {
+		return fmt.Errorf("failed to grant role: %w", err)
+	}
+	s.InvalidatePermissions(userID)
+	return nil
+}
+
+// RemoveUserRole takes a role away from a user
+func (s *Service) RemoveUserRole(userID, roleID int) error {
+	result := s.db.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&models.UserRole{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove role: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user role %w", ErrNotFound)
+	}
+	s.InvalidatePermissions(userID)
+	return nil
+}
+
+// setUserRoles grants roleIDs to a user, which must all exist
+func setUserRoles(tx *gorm.DB, userID int, roleIDs []int) error {
+	grants := make([]models.UserRole, 0, len(roleIDs))
+	seen := make(map[int]bool, len(roleIDs))
+	for _, roleID := range roleIDs {
+		if !seen[roleID] {
+			seen[roleID] = true
+			grants = append(grants, models.UserRole{UserID: userID, RoleID: roleID})
+		}
+	}
+	if len(grants) == 0 {
+		return nil
+	}
+
+	var found int64
+	if err := tx.Model(&models.Role{}).Where("id IN ?", roleIDs).Count(&found).Error; err != nil {
+		return fmt.Errorf("failed to check roles: %w", err)
+	}
+	if found != int64(len(grants)) {
+		return &ValidationError{Fields: map[string]string{"role_ids": "contains unknown roles"}}
+	}
+	if err := tx.Create(&grants).Error; err != nil {
+		return fmt.Errorf("failed to grant roles: %w", err)
+	}
+	return nil
+}
+
+// Roles and Permissions
+
+// GetRoles lists every role with its permissions
+func (s *Service) GetRoles() ([]models.Role, error) {
+	roles := []models.Role{}
+	if err := s.db.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch roles: %w", err)
+	}
+	return roles, nil
+}
+
+// CreateRole creates a role with a unique name
+func (s *Service) CreateRole(role *models.Role) error {
+	role.Name = strings.TrimSpace(role.Name)
+	if role.Name == "" || len(role.Name) > 50 {
+		return &ValidationError{Fields: map[string]string{"name": "is required and must be at most 50 characters"}}
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Role{}).Where("LOWER(name) = LOWER(?)", role.Name).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check role name: %w", err)
+	}
+	if count > 0 {
+		return &ValidationError{Fields: map[string]string{"name": "is already used by another role"}}
+	}
+
+	if err := s.db.Omit(clause.Associations).Create(role).Error; err != nil {
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+	role.Permissions = []models.Permission{}
+	return nil
+}
+
+// DeleteRole deletes a role, taking it away from every user. The admin role
+// can't be deleted.
+func (s *Service) DeleteRole(id int) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.First(&role, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("role %w", ErrNotFound)
+			}
+			return fmt.Errorf("failed to fetch role: %w", err)
+		}
+		if role.Name == models.RoleAdmin {
+			return &ValidationError{Fields: map[string]string{"name": "the admin role can't be deleted"}}
+		}
+
+		if err := tx.Where("role_id = ?", id).Delete(&models.UserRole{}).Error; err != nil {
+			return fmt.Errorf("failed to remove role from users: %w", err)
+		}
+		if err := tx.Where("role_id = ?", id).Delete(&models.RolePermission{}).Error; err != nil {
+			return fmt.Errorf("failed to remove role permissions: %w", err)
+		}
+		return deleteRecord(tx, &models.Role{}, "role", id)
+	})
+	if err != nil {
+		return err
+	}
+	s.InvalidateAllPermissions()
+	return nil
+}
+
+// SetRolePermissions replaces the permissions of a role
+func (s *Service) SetRolePermissions(roleID int, permissionIDs []int) (*models.Role, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Role{}, "id = ?", roleID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("role %w", ErrNotFound)
+			}
+			return fmt.Errorf("failed to fetch role: %w", err)
+		}
+
+		grants := make([]models.RolePermission, 0, len(permissionIDs))
+		seen := make(map[int]bool, len(permissionIDs))
+		for _, permissionID := range permissionIDs {
+			if !seen[permissionID] {
+				seen[permissionID] = true
+				grants = append(grants, models.RolePermission{RoleID: roleID, PermissionID: permissionID})
+			}
+		}
+
+		var found int64
+		if len(grants) > 0 {
+			if err := tx.Model(&models.Permission{}).Where("id IN ?", permissionIDs).Count(&found).Error; err != nil {
+				return fmt.Errorf("failed to check permissions: %w", err)
+			}
+		}
+		if found != int64(len(grants)) {
+			return &ValidationError{Fields: map[string]string{"permission_ids": "contains unknown permissions"}}
+		}
+
+		if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+			return fmt.Errorf("failed to remove role permissions: %w", err)
+		}
+		if len(grants) > 0 {
+			if err := tx.Create(&grants).Error; err != nil {
+				return fmt.Errorf("failed to grant permissions: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.InvalidateAllPermissions()
+
+	var role models.Role
+	if err := s.db.Preload("Permissions").First(&role, "id = ?", roleID).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch role: %w", err)
+	}
+	return &role, nil
+}
+
+// GetPermissions lists every permission
+func (s *Service) GetPermissions() ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	if err := s.db.Order("name").Find(&permissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch permissions: %w", err)
+	}
+	return permissions, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/mailer"
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// recordingMailer keeps the messages it is asked to send
+type recordingMailer struct {
+	sent []mailer.Message
+	err  error
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// emailedToken returns the token of the set-password link in msg
+func emailedToken(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+	for _, field := range strings.Fields(msg.Body) {
+		if link, err := url.Parse(field); err == nil && link.Query().Get("token") != "" {
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("no token link in %q", msg.Body)
+	return ""
+}
+
+func TestInviteUser(t *testing.T) {
+	s := testService(t)
+	mail := &recordingMailer{}
+	s.SetMailer(mail)
+	editor := models.Role{Name: "editor"}
+	if err := s.CreateRole(&editor); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+
+	user := models.User{Email: " New.User@example.com "}
+	if err := s.InviteUser(context.Background(), &user, []int{editor.ID}); err != nil {
+		t.Fatalf("InviteUser: %v", err)
+	}
+	if user.Email != "New.User@example.com" || len(user.Roles) != 1 || user.Roles[0].ID != editor.ID {
+		t.Errorf("invited user = %+v, want the trimmed email and the editor role", user)
+	}
+	if len(mail.sent) != 1 || mail.sent[0].To != user.Email {
+		t.Fatalf("sent = %+v, want one invitation to %s", mail.sent, user.Email)
+	}
+
+	// No password works until the invitation is accepted
+	if _, err := s.Login(user.Email, "", "test", "127.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login before accepting = %v, want ErrInvalidCredentials", err)
+	}
+	token := emailedToken(t, mail.sent[0])
+	if err := s.SetPasswordWithToken(token, "correct horse"); err != nil {
+		t.Fatalf("SetPasswordWithToken: %v", err)
+	}
+	if _, err := s.Login(user.Email, "correct horse", "test", "127.0.0.1"); err != nil {
+		t.Errorf("Login after accepting: %v", err)
+	}
+	if err := s.SetPasswordWithToken(token, "another password"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("reusing the invitation = %v, want ErrInvalidToken", err)
+	}
+
+	if err := s.InviteUser(context.Background(), &models.User{Email: "new.user@EXAMPLE.com"}, nil); invalidFields(t, err)[0] != "email" {
+		t.Errorf("inviting a taken email = %v, want an email error", err)
+	}
+	if err := s.InviteUser(context.Background(), &models.User{Email: "not an email"}, nil); invalidFields(t, err)[0] != "email" {
+		t.Errorf("inviting an invalid email = %v, want an email error", err)
+	}
+	if err := s.InviteUser(context.Background(), &models.User{Email: "other@example.com"}, []int{-1}); invalidFields(t, err)[0] != "role_ids" {
+		t.Errorf("inviting with an unknown role = %v, want a role_ids error", err)
+	}
+}
+
+func TestFailedInvitationRollsBack(t *testing.T) {
+	s := testService(t)
+	mail := &recordingMailer{err: errors.New("smtp down")}
+	s.SetMailer(mail)
+
+	if err := s.InviteUser(context.Background(), &models.User{Email: "new.user@example.com"}, nil); err == nil {
+		t.Fatal("InviteUser with a failing mailer succeeded")
+	}
+	var count int64
+	if err := s.db.Model(&models.User{}).Where("email = ?", "new.user@example.com").Count(&count).Error; err != nil || count != 0 {
+		t.Fatalf("users after a failed invitation = %d, %v, want none", count, err)
+	}
+
+	// Once mail works the invitation can be retried
+	mail.err = nil
+	if err := s.InviteUser(context.Background(), &models.User{Email: "new.user@example.com"}, nil); err != nil {
+		t.Errorf("retried InviteUser: %v", err)
+	}
+	if len(mail.sent) != 1 {
+		t.Errorf("sent = %+v, want the retried invitation", mail.sent)
+	}
+}
+
+func TestDisabledUsersCantSignIn(t *testing.T) {
+	s := testService(t)
+	user, err := s.EnsureAdmin("admin@example.com", "correct horse")
+	if err != nil {
+		t.Fatalf("EnsureAdmin: %v", err)
+	}
+	tokens, err := s.Login(user.Email, "correct horse", "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if _, err := s.SetUserDisabled(user.ID, true); err != nil {
+		t.Fatalf("SetUserDisabled: %v", err)
+	}
+	if _, err := s.Authenticate(tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Authenticate after disabling = %v, want ErrInvalidToken", err)
+	}
+	if _, err := s.Login(user.Email, "correct horse", "test", "127.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login while disabled = %v, want ErrInvalidCredentials", err)
+	}
+
+	if _, err := s.SetUserDisabled(user.ID, false); err != nil {
+		t.Fatalf("SetUserDisabled: %v", err)
+	}
+	if _, err := s.Login(user.Email, "correct horse", "test", "127.0.0.1"); err != nil {
+		t.Errorf("Login after re-enabling: %v", err)
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	s := testService(t)
+	if _, err := s.EnsureAdmin("admin@example.com", "correct horse"); err != nil {
+		t.Fatalf("EnsureAdmin: %v", err)
+	}
+	roles, err := s.GetRoles()
+	if err != nil || len(roles) != 1 {
+		t.Fatalf("GetRoles = %+v, %v, want the admin role", roles, err)
+	}
+
+	if err := s.DeleteRole(roles[0].ID); invalidFields(t, err)[0] != "name" {
+		t.Errorf("deleting the admin role = %v, want a validation error", err)
+	}
+	if err := s.CreateRole(&models.Role{Name: "ADMIN"}); invalidFields(t, err)[0] != "name" {
+		t.Errorf("creating a role with a taken name = %v, want a name error", err)
+	}
+}
@@ -0,0 +1,297 @@
+// services/validation.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// ErrConflict is returned when a record was modified after the client read it
+var ErrConflict = errors.New("record was modified by another request")
+
+// ValidationError lists the fields of a write that failed validation, keyed
+// by their JSON name
+type ValidationError struct {
+	Fields map[string]string `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make([]string, len(names))
+	for i, name := range names {
+		problems[i] = fmt.Sprintf("%s %s", name, e.Fields[name])
+	}
+	return "validation failed: " + strings.Join(problems, ", ")
+}
+
+func (e *ValidationError) add(field, problem string) {
+	if e.Fields == nil {
+		e.Fields = map[string]string{}
+	}
+	e.Fields[field] = problem
+}
+
+// err returns the ValidationError, or nil when no field failed
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// requireUpdatedAt checks that a replace carries the updated_at value the
+// client last read, which the stored row is compared against
+func requireUpdatedAt(updatedAt *time.Time) error {
+	if updatedAt == nil || updatedAt.IsZero() {
+		return &ValidationError{Fields: map[string]string{"updated_at": "is required"}}
+	}
+	return nil
+}
+
+var (
+	zipPattern      = regexp.MustCompile(`^\d{5}(-\d{4})?$`)
+	phoneCharacters = regexp.MustCompile(`^[0-9()+.\-\s]+$`)
+)
+
+// validPhone accepts US numbers of 10 digits, optionally prefixed with 1,
+// in any common punctuation
+func validPhone(phone string) bool {
+	if !phoneCharacters.MatchString(phone) {
+		return false
+	}
+	digits := 0
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits == 10 || (digits == 11 && strings.TrimLeft(phone, "+( ")[0] == '1')
+}
+
+// ValidateABACenter checks the fields of an ABA center before it is written
+func ValidateABACenter(center *models.ABACenter) error {
+	var verr ValidationError
+	if strings.TrimSpace(center.Name) == "" {
+		verr.add("name", "is required")
+	}
+	if strings.TrimSpace(center.Street) == "" {
+		verr.add("street", "is required")
+	}
+	if strings.TrimSpace(center.City) == "" {
+		verr.add("city", "is required")
+	}
+	if center.Zip != "" && !zipPattern.MatchString(strings.TrimSpace(center.Zip)) {
+		verr.add("zip", "must be a 5 digit or ZIP+4 code")
+	}
+	if center.Phone != "" && !validPhone(strings.TrimSpace(center.Phone)) {
+		verr.add("phone", "must be a 10 digit US phone number")
+	}
+	validateOptionalCoordinates(&verr, center.Latitude, center.Longitude)
+	if !slices.Contains(models.WaitlistStatuses, center.WaitlistStatus) {
+		verr.add("waitlist_status", "must be one of "+strings.Join(models.WaitlistStatuses, ", "))
+	}
+	if center.WaitlistWeeks != nil {
+		if center.WaitlistStatus != models.WaitlistShort && center.WaitlistStatus != models.WaitlistLong {
+			verr.add("waitlist_weeks", "only applies to short and long waitlists")
+		} else if *center.WaitlistWeeks < 0 || *center.WaitlistWeeks > MaxWaitlistWeeks {
+			verr.add("waitlist_weeks", fmt.Sprintf("must be between 0 and %d", MaxWaitlistWeeks))
+		}
+	}
+	return verr.err()
+}
+
+// validateOptionalCoordinates checks a latitude/longitude pair that may be
+// left out entirely
+func validateOptionalCoordinates(verr *ValidationError, lat, lng *float64) {
+	if (lat == nil) != (lng == nil) {
+		verr.add("latitude", "and longitude must be given together")
+	} else if lat != nil {
+		if err := validateCoordinates(*lat, *lng); err != nil {
+			verr.add("latitude", err.Error())
+		}
+	}
+}
+
+// ValidateResourceCenter checks the fields of a resource center before it is
+// written
+func ValidateResourceCenter(center *models.ResourceCenter) error {
+	var verr ValidationError
+	if strings.TrimSpace(center.Name) == "" {
+		verr.add("name", "is required")
+	}
+	if err := validateCoordinates(center.Latitude, center.Longitude); err != nil {
+		verr.add("latitude", err.Error())
+	}
+	return verr.err()
+}
+
+// ValidateResource checks the fields of a resource before it is written.
+// Diagnoses are checked against the diagnoses table by the caller.
+func ValidateResource(resource *models.Resource) error {
+	var verr ValidationError
+	if strings.TrimSpace(resource.Name) == "" {
+		verr.add("name", "is required")
+	}
+	if err := validateCoordinates(resource.Latitude, resource.Longitude); err != nil {
+		verr.add("latitude", err.Error())
+	}
+	return verr.err()
+}
+
+// ValidateRegionalCenter checks the fields of a regional center before it is
+// written
+func ValidateRegionalCenter(center *models.RegionalCenter) error {
+	var verr ValidationError
+	if center.RegionalCenter == nil || strings.TrimSpace(*center.RegionalCenter) == "" {
+		verr.add("regional_center", "is required")
+	}
+	if center.ZipCode != nil && *center.ZipCode != "" && !zipPattern.MatchString(strings.TrimSpace(*center.ZipCode)) {
+		verr.add("zip_code", "must be a 5 digit or ZIP+4 code")
+	}
+	if center.Telephone != nil && *center.Telephone != "" && !validPhone(strings.TrimSpace(*center.Telephone)) {
+		verr.add("telephone", "must be a 10 digit US phone number")
+	}
+	validateOptionalCoordinates(&verr, center.Latitude, center.Longitude)
+	return verr.err()
+}
+
+// ValidateProvider checks the fields of a provider before it is written
+func ValidateProvider(provider *models.Provider) error {
+	var verr ValidationError
+	if strings.TrimSpace(provider.Name) == "" {
+		verr.add("name", "is required")
+	}
+	if provider.Phone != nil && *provider.Phone != "" && !validPhone(strings.TrimSpace(*provider.Phone)) {
+		verr.add("phone", "must be a 10 digit US phone number")
+	}
+	return verr.err()
+}
+
+// ValidateDiagnosis checks the fields of a diagnosis before it is written
+func ValidateDiagnosis(diagnosis *models.Diagnosis) error {
+	var verr ValidationError
+	validateCatalogName(&verr, diagnosis.Name)
+	return verr.err()
+}
+
+// ValidateInsuranceCarrier checks an insurance carrier before it is written
+func ValidateInsuranceCarrier(carrier *models.InsuranceCarrier) error {
+	var verr ValidationError
+	validateCatalogName(&verr, carrier.Name)
+	return verr.err()
+}
+
+// ValidateInsurancePlan checks an insurance plan before it is written
+func ValidateInsurancePlan(plan *models.InsurancePlan) error {
+	var verr ValidationError
+	validateCatalogName(&verr, plan.Name)
+	return verr.err()
+}
+
+// validateCatalogName checks the name of a diagnosis, carrier or plan
+func validateCatalogName(verr *ValidationError, name string) {
+	if strings.TrimSpace(name) == "" {
+		verr.add("name", "is required")
+	} else if len(name) > 255 {
+		verr.add("name", "must be at most 255 characters")
+	}
+}
+
+// Map zoom levels accepted in preferences
+const (
+	MinZoom = 1
+	MaxZoom = 21
+)
+
+// MaxPreferredRadius bounds the default search radius, in miles
+const MaxPreferredRadius = 500
+
+// ValidateUserPreferences checks preferences before they are written.
+// Diagnosis IDs are only checked for form here; the service checks that
+// they exist.
+func ValidateUserPreferences(preferences *models.UserPreferences) error {
+	var verr ValidationError
+	validMapType := false
+	for _, mapType := range models.MapTypes {
+		validMapType = validMapType || preferences.MapType == mapType
+	}
+	if !validMapType {
+		verr.add("map_type", "must be one of "+strings.Join(models.MapTypes, ", "))
+	}
+	if preferences.DefaultZoom < MinZoom || preferences.DefaultZoom > MaxZoom {
+		verr.add("default_zoom", fmt.Sprintf("must be between %d and %d", MinZoom, MaxZoom))
+	}
+	if !(preferences.PreferredRadius > 0) || preferences.PreferredRadius > MaxPreferredRadius {
+		verr.add("preferred_radius", fmt.Sprintf("must be greater than 0 and at most %d", MaxPreferredRadius))
+	}
+	for _, id := range preferences.PreferredDiagnoses {
+		if _, err := uuid.Parse(id); err != nil {
+			verr.add("preferred_diagnoses", fmt.Sprintf("%q is not a diagnosis ID", id))
+			break
+		}
+	}
+	return verr.err()
+}
+
+// Length limits of form submission fields, in characters
+const (
+	MaxSubmissionNameLength    = 255
+	MaxSubmissionEmailLength   = 255
+	MaxSubmissionMessageLength = 5000
+)
+
+// ValidateFormSubmission checks a contact form submission before it is
+// stored, trimming its fields
+func ValidateFormSubmission(submission *models.FormSubmission) error {
+	var verr ValidationError
+	submission.Name = strings.TrimSpace(submission.Name)
+	submission.Email = strings.TrimSpace(submission.Email)
+	submission.Message = strings.TrimSpace(submission.Message)
+
+	switch {
+	case submission.Name == "":
+		verr.add("name", "is required")
+	case utf8.RuneCountInString(submission.Name) > MaxSubmissionNameLength:
+		verr.add("name", fmt.Sprintf("must be at most %d characters", MaxSubmissionNameLength))
+	}
+	switch {
+	case submission.Email == "":
+		verr.add("email", "is required")
+	case len(submission.Email) > MaxSubmissionEmailLength || !validEmail(submission.Email):
+		verr.add("email", "must be a valid email address")
+	}
+	switch {
+	case submission.Message == "":
+		verr.add("message", "is required")
+	case utf8.RuneCountInString(submission.Message) > MaxSubmissionMessageLength:
+		verr.add("message", fmt.Sprintf("must be at most %d characters", MaxSubmissionMessageLength))
+	}
+	return verr.err()
+}
+
+// validEmail accepts a bare address such as name@example.com, without a
+// display name
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return false
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	return strings.Contains(domain, ".")
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// invalidFields returns the sorted fields of the ValidationError err
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %v, want a ValidationError", err)
+	}
+	fields := make([]string, 0, len(verr.Fields))
+	for field := range verr.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestValidateABACenter(t *testing.T) {
+	lat, lng, far := 33.68, -117.83, 200.0
+	weeks, tooLong := 6, MaxWaitlistWeeks+1
+	valid := models.ABACenter{Name: "Center", Street: "1 Main St", City: "Irvine", Zip: "92618", Phone: "(949) 555-0100",
+		WaitlistStatus: models.WaitlistUnknown}
+
+	tests := []struct {
+		name   string
+		edit   func(c *models.ABACenter)
+		fields []string
+	}{
+		{"valid", func(c *models.ABACenter) {}, nil},
+		{"ZIP+4 and coordinates", func(c *models.ABACenter) { c.Zip, c.Latitude, c.Longitude = "92618-1234", &lat, &lng }, nil},
+		{"missing name, street and city", func(c *models.ABACenter) { c.Name, c.Street, c.City = " ", "", "" }, []string{"city", "name", "street"}},
+		{"bad zip", func(c *models.ABACenter) { c.Zip = "9261" }, []string{"zip"}},
+		{"bad phone", func(c *models.ABACenter) { c.Phone = "555-0100" }, []string{"phone"}},
+		{"latitude alone", func(c *models.ABACenter) { c.Latitude = &lat }, []string{"latitude"}},
+		{"coordinates out of range", func(c *models.ABACenter) { c.Latitude, c.Longitude = &far, &lng }, []string{"latitude"}},
+		{"short waitlist", func(c *models.ABACenter) { c.WaitlistStatus, c.WaitlistWeeks = models.WaitlistShort, &weeks }, nil},
+		{"unknown waitlist status", func(c *models.ABACenter) { c.WaitlistStatus = "maybe" }, []string{"waitlist_status"}},
+		{"weeks for an open waitlist", func(c *models.ABACenter) { c.WaitlistStatus, c.WaitlistWeeks = models.WaitlistOpen, &weeks }, []string{"waitlist_weeks"}},
+		{"weeks out of range", func(c *models.ABACenter) { c.WaitlistStatus, c.WaitlistWeeks = models.WaitlistLong, &tooLong }, []string{"waitlist_weeks"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			center := valid
+			tt.edit(&center)
+			err := ValidateABACenter(&center)
+			if tt.fields == nil {
+				if err != nil {
+					t.Errorf("ValidateABACenter = %v, want nil", err)
+				}
+				return
+			}
+
+			if fields := invalidFields(t, err); !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidPhone(t *testing.T) {
+	tests := map[string]bool{
+		"949-555-0100":    true,
+		"(949) 555-0100":  true,
+		"+1 949.555.0100": true,
+		"19495550100":     true,
+		"29495550100":     false,
+		"555-0100":        false,
+		"949-555-O100":    false,
+	}
+	for phone, want := range tests {
+		if got := validPhone(phone); got != want {
+			t.Errorf("validPhone(%q) = %t, want %t", phone, got, want)
+		}
+	}
+}
+
+func TestValidateWrites(t *testing.T) {
+	blank, badZip, badPhone := " ", "1234", "555-0100"
+	name := "North LA County"
+
+	tests := []struct {
+		name   string
+		err    error
+		fields []string
+	}{
+		{"resource center", ValidateResourceCenter(&models.ResourceCenter{Name: "Center", Latitude: 34, Longitude: -118}), nil},
+		{"resource center without name or coordinates", ValidateResourceCenter(&models.ResourceCenter{}), []string{"latitude", "name"}},
+		{"resource", ValidateResource(&models.Resource{Name: "Resource", Latitude: 34, Longitude: -118}), nil},
+		{"resource out of range", ValidateResource(&models.Resource{Name: "Resource", Latitude: 91, Longitude: -118}), []string{"latitude"}},
+		{"regional center", ValidateRegionalCenter(&models.RegionalCenter{RegionalCenter: &name}), nil},
+		{"regional center without name", ValidateRegionalCenter(&models.RegionalCenter{RegionalCenter: &blank}), []string{"regional_center"}},
+		{"regional center with bad zip and phone", ValidateRegionalCenter(&models.RegionalCenter{RegionalCenter: &name, ZipCode: &badZip, Telephone: &badPhone}), []string{"telephone", "zip_code"}},
+		{"provider", ValidateProvider(&models.Provider{Name: "Provider"}), nil},
+		{"provider with bad phone", ValidateProvider(&models.Provider{Name: "Provider", Phone: &badPhone}), []string{"phone"}},
+		{"diagnosis", ValidateDiagnosis(&models.Diagnosis{Name: "Autism"}), nil},
+		{"diagnosis name too long", ValidateDiagnosis(&models.Diagnosis{Name: strings.Repeat("a", 256)}), []string{"name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fields == nil {
+				if tt.err != nil {
+					t.Errorf("validation = %v, want nil", tt.err)
+				}
+				return
+			}
+
+			if fields := invalidFields(t, tt.err); !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateFormSubmission(t *testing.T) {
+	tests := []struct {
+		name       string
+		submission models.FormSubmission
+		fields     []string
+	}{
+		{"valid", models.FormSubmission{Name: " Ann ", Email: " ann@example.com ", Message: " Hi "}, nil},
+		{"empty", models.FormSubmission{Name: " ", Email: "", Message: "\n"}, []string{"email", "message", "name"}},
+		{"display name", models.FormSubmission{Name: "Ann", Email: "Ann <ann@example.com>", Message: "Hi"}, []string{"email"}},
+		{"no domain dot", models.FormSubmission{Name: "Ann", Email: "ann@localhost", Message: "Hi"}, []string{"email"}},
+		{"long name", models.FormSubmission{Name: strings.Repeat("é", MaxSubmissionNameLength+1), Email: "ann@example.com", Message: "Hi"}, []string{"name"}},
+		{"long message", models.FormSubmission{Name: "Ann", Email: "ann@example.com", Message: strings.Repeat("x", MaxSubmissionMessageLength+1)}, []string{"message"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submission := tt.submission
+			err := ValidateFormSubmission(&submission)
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatalf("ValidateFormSubmission = %v, want nil", err)
+				}
+				if submission.Name != "Ann" || submission.Email != "ann@example.com" || submission.Message != "Hi" {
+					t.Errorf("submission = %+v, want trimmed fields", submission)
+				}
+				return
+			}
+			if fields := invalidFields(t, err); !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// services/waitlist.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// Waitlist length limits, in weeks
+const (
+	ShortWaitlistMaxWeeks = 8 // longer estimates count as a long waitlist
+	MaxWaitlistWeeks      = 104
+)
+
+// takingClients are the waitlist statuses of centers that take new clients
+var takingClients = []string{models.WaitlistOpen, models.WaitlistShort, models.WaitlistLong}
+
+// waitlistFilter matches ABA centers by the waitlist filters of filter
+func waitlistFilter(filter *models.SearchFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.WaitlistOnly {
+			db = db.Where("aba_centers.waitlist_status IN ?", takingClients)
+		}
+		if len(filter.WaitlistStatuses) > 0 {
+			db = db.Where("aba_centers.waitlist_status IN ?", filter.WaitlistStatuses)
+		}
+		if filter.MaxWaitWeeks != nil {
+			db = db.Where("aba_centers.waitlist_status = ? OR (aba_centers.waitlist_status IN ? AND aba_centers.waitlist_weeks <= ?)",
+				models.WaitlistOpen, []string{models.WaitlistShort, models.WaitlistLong}, *filter.MaxWaitWeeks)
+		}
+		return db
+	}
+}
+
+// waitlistChanged reports whether center's waitlist differs from current's
+func waitlistChanged(current, center *models.ABACenter) bool {
+	return current.WaitlistStatus != center.WaitlistStatus || !sameInt(current.WaitlistWeeks, center.WaitlistWeeks)
+}
+
+// recordWaitlistChange adds the change from previous to center's waitlist to
+// the history, dated center.WaitlistUpdatedAt
+func recordWaitlistChange(tx *gorm.DB, center *models.ABACenter, previousStatus string, previousWeeks *int) error {
+	change := models.WaitlistChange{
+		ABACenterID:    center.ID,
+		Status:         center.WaitlistStatus,
+		Weeks:          center.WaitlistWeeks,
+		PreviousStatus: previousStatus,
+		PreviousWeeks:  previousWeeks,
+		ChangedAt:      *center.WaitlistUpdatedAt,
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return fmt.Errorf("failed to record waitlist change: %w", err)
+	}
+	return nil
+}
+
+var waitlistChangeKeyset = Keyset{Table: "waitlist_changes", Desc: true}
+
+// GetWaitlistHistory retrieves a page of an ABA center's waitlist changes,
+// newest first
+func (s *Service) GetWaitlistHistory(centerID uuid.UUID, page PageRequest) (*Page[models.WaitlistChange], error) {
+	if err := s.db.Select("id").First(&models.ABACenter{}, "id = ?", centerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("ABA center %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to fetch ABA center: %w", err)
+	}
+
+	query := s.db.Model(&models.WaitlistChange{}).Where("aba_center_id = ?", centerID)
+	key := func(change *models.WaitlistChange) (interface{}, interface{}) { return nil, change.ID }
+	history, err := Paginate(query, waitlistChangeKeyset, page, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch waitlist history: %w", err)
+	}
+	return history, nil
+}
+
+func sameInt(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// waitlistEstimate finds estimates such as "3 weeks", "2-4 months" or
+// "6 mo"; ranges are read by their upper end
+var waitlistEstimate = regexp.MustCompile(`\b(\d+)(?:\s*(?:-|–|to)\s*(\d+))?\s*\+?\s*(days?|weeks?|wks?|months?|mos?|years?|yrs?)\b`)
+
+// Phrases of waitlist text meaning a center is closed, open, or has a short
+// or long waitlist
+var (
+	waitlistClosedText = words("not accepting", "not taking", "not open", "closed", "no openings", "no availability", "full")
+	waitlistOpenText   = words("no wait", "no waitlist", "no wait list", "immediate", "immediately",
+		"available now", "accepting new", "openings available", "open")
+	waitlistShortText = words("short")
+	waitlistLongText  = words("long")
+)
+
+// ParseWaitlistText reads a waitlist status and estimated wait in weeks from
+// free text such as "No waitlist", "2-3 months" or "Not accepting clients".
+// An estimated wait wins over open or closed wording, so "Open, 6 month
+// waitlist" is a long waitlist. Text it can't interpret is WaitlistUnknown.
+func ParseWaitlistText(text string) (status string, weeks *int) {
+	lower := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	if lower == "" {
+		return models.WaitlistUnknown, nil
+	}
+
+	if match := waitlistEstimate.FindStringSubmatch(lower); match != nil {
+		amount, _ := strconv.Atoi(match[1])
+		if match[2] != "" {
+			amount, _ = strconv.Atoi(match[2])
+		}
+		var estimate float64
+		switch strings.TrimSuffix(match[3], "s") {
+		case "day":
+			estimate = float64(amount) / 7
+		case "week", "wk":
+			estimate = float64(amount)
+		case "month", "mo":
+			estimate = float64(amount) * 52 / 12
+		default:
+			estimate = float64(amount) * 52
+		}
+		w := int(math.Min(math.Ceil(estimate), MaxWaitlistWeeks))
+		if w == 0 {
+			return models.WaitlistOpen, nil
+		}
+		if w <= ShortWaitlistMaxWeeks {
+			return models.WaitlistShort, &w
+		}
+		return models.WaitlistLong, &w
+	}
+
+	switch {
+	case waitlistClosedText.MatchString(lower):
+		return models.WaitlistClosed, nil
+	case waitlistOpenText.MatchString(lower), exactly("none", "no")(lower):
+		return models.WaitlistOpen, nil
+	case waitlistShortText.MatchString(lower):
+		return models.WaitlistShort, nil
+	case waitlistLongText.MatchString(lower):
+		return models.WaitlistLong, nil
+	}
+	return models.WaitlistUnknown, nil
+}
+
+// words matches any of phrases as whole words of lowercase text. Hyphens
+// join words, so "full" doesn't match "full-time".
+func words(phrases ...string) *regexp.Regexp {
+	quoted := make([]string, len(phrases))
+	for i, phrase := range phrases {
+		quoted[i] = regexp.QuoteMeta(phrase)
+	}
+	return regexp.MustCompile(`(?:^|[^a-z0-9-])(?:` + strings.Join(quoted, "|") + `)(?:$|[^a-z0-9-])`)
+}
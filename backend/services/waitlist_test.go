@@ -0,0 +1,143 @@
+package services
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestParseWaitlistText(t *testing.T) {
+	weeks := func(w int) *int { return &w }
+
+	tests := []struct {
+		text   string
+		status string
+		weeks  *int
+	}{
+		{"", models.WaitlistUnknown, nil},
+		{"   ", models.WaitlistUnknown, nil},
+		{"Call for details", models.WaitlistUnknown, nil},
+		{"No info", models.WaitlistUnknown, nil},
+
+		{"No waitlist", models.WaitlistOpen, nil},
+		{"None", models.WaitlistOpen, nil},
+		{"no", models.WaitlistOpen, nil},
+		{"Open", models.WaitlistOpen, nil},
+		{"Immediate availability", models.WaitlistOpen, nil},
+		{"Accepting new clients, full-time only", models.WaitlistOpen, nil},
+		{"0 weeks", models.WaitlistOpen, nil},
+
+		{"Not accepting clients", models.WaitlistClosed, nil},
+		{"Currently full", models.WaitlistClosed, nil},
+		{"Closed to new clients", models.WaitlistClosed, nil},
+		{"Not open at this time", models.WaitlistClosed, nil},
+
+		{"2 weeks", models.WaitlistShort, weeks(2)},
+		{"3 days", models.WaitlistShort, weeks(1)},
+		{"4-6 wks", models.WaitlistShort, weeks(6)},
+		{"Short waitlist", models.WaitlistShort, nil},
+
+		{"2-3 months", models.WaitlistLong, weeks(13)},
+		{"6 mo", models.WaitlistLong, weeks(26)},
+		{"1 year+", models.WaitlistLong, weeks(52)},
+		{"5 years", models.WaitlistLong, weeks(MaxWaitlistWeeks)},
+		{"Next opening in 3 months", models.WaitlistLong, weeks(13)},
+		{"Open, 6 month waitlist", models.WaitlistLong, weeks(26)},
+		{"Long waitlist", models.WaitlistLong, nil},
+
+		// Numbers without a unit, or with a unit only as a word prefix,
+		// aren't estimates
+		{"Serves 5 most counties", models.WaitlistUnknown, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			status, weeks := ParseWaitlistText(tt.text)
+			if status != tt.status {
+				t.Errorf("status = %q, want %q", status, tt.status)
+			}
+			if !sameInt(weeks, tt.weeks) {
+				t.Errorf("weeks = %v, want %v", weeksText(weeks), weeksText(tt.weeks))
+			}
+		})
+	}
+}
+
+func weeksText(weeks *int) string {
+	if weeks == nil {
+		return "nil"
+	}
+	return strconv.Itoa(*weeks)
+}
+
+func TestWaitlistHistory(t *testing.T) {
+	s := testService(t)
+	six := 6
+	center := models.ABACenter{Name: "Center", Street: "1 Main St", City: "Irvine", WaitlistStatus: models.WaitlistShort, WaitlistWeeks: &six}
+	if err := s.CreateABACenter(&center); err != nil {
+		t.Fatalf("CreateABACenter: %v", err)
+	}
+	if center.WaitlistUpdatedAt == nil {
+		t.Error("WaitlistUpdatedAt = nil, want it stamped for a known waitlist")
+	}
+	unknown := models.ABACenter{Name: "Other", Street: "2 Main St", City: "Irvine"}
+	if err := s.CreateABACenter(&unknown); err != nil {
+		t.Fatalf("CreateABACenter: %v", err)
+	}
+
+	patch := func(fields string) *models.ABACenter {
+		t.Helper()
+		current, err := s.GetABACenterByID(center.ID)
+		if err != nil {
+			t.Fatalf("GetABACenterByID: %v", err)
+		}
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(fields), &body); err != nil {
+			t.Fatal(err)
+		}
+		body["updated_at"], _ = json.Marshal(current.UpdatedAt)
+		patched, err := s.PatchABACenter(center.ID, body)
+		if err != nil {
+			t.Fatalf("PatchABACenter(%s): %v", fields, err)
+		}
+		return patched
+	}
+
+	patch(`{"name": "Renamed"}`) // not a waitlist change
+	// An open status drops the estimate
+	if patched := patch(`{"waitlist_status": "open"}`); patched.WaitlistWeeks != nil {
+		t.Errorf("waitlist_weeks = %d, want the estimate dropped", *patched.WaitlistWeeks)
+	}
+
+	history, err := s.GetWaitlistHistory(center.ID, PageRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetWaitlistHistory: %v", err)
+	}
+	if len(history.Items) != 2 {
+		t.Fatalf("history = %+v, want the creation and the status change", history.Items)
+	}
+	latest := history.Items[0]
+	if latest.Status != models.WaitlistOpen || latest.PreviousStatus != models.WaitlistShort || !sameInt(latest.PreviousWeeks, &six) {
+		t.Errorf("latest change = %+v, want short (6 weeks) to open", latest)
+	}
+	if first := history.Items[1]; first.PreviousStatus != models.WaitlistUnknown || first.Status != models.WaitlistShort {
+		t.Errorf("first change = %+v, want unknown to short", first)
+	}
+
+	for _, tt := range []struct {
+		filter models.SearchFilter
+		want   int
+	}{
+		{models.SearchFilter{WaitlistOnly: true}, 1},
+		{models.SearchFilter{WaitlistStatuses: []string{models.WaitlistUnknown}}, 1},
+		{models.SearchFilter{MaxWaitWeeks: &six}, 1},
+		{models.SearchFilter{WaitlistStatuses: []string{models.WaitlistClosed}}, 0},
+	} {
+		page, err := s.GetABACenters(&tt.filter, PageRequest{Limit: 10})
+		if err != nil || len(page.Items) != tt.want {
+			t.Errorf("GetABACenters(%+v) = %d centers, %v, want %d", tt.filter, len(page.Items), err, tt.want)
+		}
+	}
+}
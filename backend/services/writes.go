@@ -0,0 +1,524 @@
+// services/writes.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+// writeOmits lists the fields skipped on every write: associations, which
+// are managed by their own methods, verification, which only VerifyRecord
+// sets, and location when PostGIS is unavailable
+func (s *Service) writeOmits(extra ...string) []string {
+	omits := append([]string{clause.Associations, "LastVerifiedAt", "VerifiedByID"}, extra...)
+	if !s.postgis {
+		omits = append(omits, "Location")
+	}
+	return omits
+}
+
+// replaceRecord overwrites every column of record except its id and
+// creation time. When expected is non-nil the row's updated_at must still
+// equal it, otherwise ErrConflict is returned; only tables without an
+// updated_at column pass nil.
+func (s *Service) replaceRecord(tx *gorm.DB, table, entity string, record, id interface{}, expected *time.Time) error {
+	query := tx.Model(record).Select("*").Omit(s.writeOmits("ID", "CreatedAt")...)
+	if expected != nil {
+		query = query.Where("updated_at = ?", *expected)
+	}
+	result := query.Updates(record)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update %s: %w", entity, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Table(table).Where("id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to update %s: %w", entity, err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%s %w", entity, ErrNotFound)
+	}
+	return fmt.Errorf("%s %w", entity, ErrConflict)
+}
+
+// deleteRecord deletes the row of model with the given id
+func deleteRecord(tx *gorm.DB, model interface{}, entity string, id interface{}) error {
+	result := tx.Delete(model, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete %s: %w", entity, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%s %w", entity, ErrNotFound)
+	}
+	return nil
+}
+
+// Resource Centers
+
+// CreateResourceCenter creates a new resource center. Diagnoses are linked
+// separately with SetResourceCenterDiagnoses.
+func (s *Service) CreateResourceCenter(center *models.ResourceCenter) error {
+	if err := ValidateResourceCenter(center); err != nil {
+		return err
+	}
+	center.Location = models.NewPoint(center.Latitude, center.Longitude)
+
+	if err := s.db.Omit(s.writeOmits()...).Create(center).Error; err != nil {
+		return fmt.Errorf("failed to create resource center: %w", err)
+	}
+
+	// Reload so the response carries the timestamps as stored
+	saved, err := s.GetResourceCenterByID(center.ID)
+	if err != nil {
+		return err
+	}
+	*center = *saved
+	return nil
+}
+
+// UpdateResourceCenter replaces a resource center. center.UpdatedAt must be
+// the value the client last read, otherwise ErrConflict is returned. On
+// success center holds the stored record.
+func (s *Service) UpdateResourceCenter(id uuid.UUID, center *models.ResourceCenter) error {
+	if err := requireUpdatedAt(center.UpdatedAt); err != nil {
+		return err
+	}
+	if err := ValidateResourceCenter(center); err != nil {
+		return err
+	}
+	center.ID = id
+	center.Location = models.NewPoint(center.Latitude, center.Longitude)
+
+	if err := s.replaceRecord(s.db, "resource_centers", "resource center", center, id, center.UpdatedAt); err != nil {
+		return err
+	}
+
+	saved, err := s.GetResourceCenterByID(id)
+	if err != nil {
+		return err
+	}
+	*center = *saved
+	return nil
+}
+
+// DeleteResourceCenter deletes a resource center and its diagnosis links
+func (s *Service) DeleteResourceCenter(id uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("center_id = ?", id).Delete(&models.CenterDiagnosis{}).Error; err != nil {
+			return fmt.Errorf("failed to unlink resource center diagnoses: %w", err)
+		}
+		return deleteRecord(tx, &models.ResourceCenter{}, "resource center", id)
+	})
+}
+
+// SetResourceCenterDiagnoses replaces the diagnoses linked to a resource
+// center and returns the updated center
+func (s *Service) SetResourceCenterDiagnoses(centerID uuid.UUID, diagnosisIDs []uuid.UUID) (*models.ResourceCenter, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.ResourceCenter{}, "id = ?", centerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("resource center %w", ErrNotFound)
+			}
+			return fmt.Errorf("failed to fetch resource center: %w", err)
+		}
+
+		links := make([]models.CenterDiagnosis, 0, len(diagnosisIDs))
+		seen := make(map[uuid.UUID]bool, len(diagnosisIDs))
+		for _, diagnosisID := range diagnosisIDs {
+			if !seen[diagnosisID] {
+				seen[diagnosisID] = true
+				links = append(links, models.CenterDiagnosis{CenterID: centerID, DiagnosisID: diagnosisID})
+			}
+		}
+
+		var found int64
+		if len(seen) > 0 {
+			if err := tx.Model(&models.Diagnosis{}).Where("id IN ?", diagnosisIDs).Count(&found).Error; err != nil {
+				return fmt.Errorf("failed to check diagnoses: %w", err)
+			}
+		}
+		if found != int64(len(seen)) {
+			return &ValidationError{Fields: map[string]string{"diagnosis_ids": "contains unknown diagnoses"}}
+		}
+
+		if err := tx.Where("center_id = ?", centerID).Delete(&models.CenterDiagnosis{}).Error; err != nil {
+			return fmt.Errorf("failed to unlink resource center diagnoses: %w", err)
+		}
+		if len(links) > 0 {
+			if err := tx.Create(&links).Error; err != nil {
+				return fmt.Errorf("failed to link resource center diagnoses: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetResourceCenterByID(centerID)
+}
+
+// AddResourceCenterDiagnosis links a diagnosis to a resource center; linking
+// it again is a no-op
+func (s *Service) AddResourceCenterDiagnosis(centerID, diagnosisID uuid.UUID) error {
+	if err := s.db.First(&models.ResourceCenter{}, "id = ?", centerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("resource center %w", ErrNotFound)
+		}
+		return fmt.Errorf("failed to fetch resource center: %w", err)
+	}
+	if err := s.db.First(&models.Diagnosis{}, "id = ?", diagnosisID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("diagnosis %w", ErrNotFound)
+		}
+		return fmt.Errorf("failed to fetch diagnosis: %w", err)
+	}
+
+	link := models.CenterDiagnosis{CenterID: centerID, DiagnosisID: diagnosisID}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error; err != nil {
+		return fmt.Errorf("failed to link resource center diagnosis: %w", err)
+	}
+	return nil
+}
+
+// RemoveResourceCenterDiagnosis unlinks a diagnosis from a resource center
+func (s *Service) RemoveResourceCenterDiagnosis(centerID, diagnosisID uuid.UUID) error {
+	result := s.db.Where("center_id = ? AND diagnosis_id = ?", centerID, diagnosisID).Delete(&models.CenterDiagnosis{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to unlink resource center diagnosis: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("resource center diagnosis %w", ErrNotFound)
+	}
+	return nil
+}
+
+// Resources
+
+// CreateResource creates a new resource. Its diagnoses must name existing
+// diagnoses and are stored in their canonical spelling.
+func (s *Service) CreateResource(resource *models.Resource) error {
+	if err := ValidateResource(resource); err != nil {
+		return err
+	}
+	diagnoses, err := s.canonicalDiagnoses(s.db, resource.Diagnoses)
+	if err != nil {
+		return err
+	}
+	resource.Diagnoses = diagnoses
+	resource.Location = models.NewPoint(resource.Latitude, resource.Longitude)
+
+	if err := s.db.Omit(s.writeOmits()...).Create(resource).Error; err != nil {
+		return fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	// Reload so the response carries the timestamps as stored
+	saved, err := s.GetResourceByID(resource.ID)
+	if err != nil {
+		return err
+	}
+	*resource = *saved
+	return nil
+}
+
+// UpdateResource replaces a resource. resource.UpdatedAt must be the value
+// the client last read, otherwise ErrConflict is returned. On success
+// resource holds the stored record.
+func (s *Service) UpdateResource(id uuid.UUID, resource *models.Resource) error {
+	if err := requireUpdatedAt(&resource.UpdatedAt); err != nil {
+		return err
+	}
+	if err := ValidateResource(resource); err != nil {
+		return err
+	}
+	diagnoses, err := s.canonicalDiagnoses(s.db, resource.Diagnoses)
+	if err != nil {
+		return err
+	}
+	resource.ID = id
+	resource.Diagnoses = diagnoses
+	resource.Location = models.NewPoint(resource.Latitude, resource.Longitude)
+
+	if err := s.replaceRecord(s.db, "resources", "resource", resource, id, &resource.UpdatedAt); err != nil {
+		return err
+	}
+
+	saved, err := s.GetResourceByID(id)
+	if err != nil {
+		return err
+	}
+	*resource = *saved
+	return nil
+}
+
+// DeleteResource deletes a resource
+func (s *Service) DeleteResource(id uuid.UUID) error {
+	return deleteRecord(s.db, &models.Resource{}, "resource", id)
+}
+
+// canonicalDiagnoses checks names against the diagnoses table, ignoring
+// case, and returns them as stored without duplicates
+func (s *Service) canonicalDiagnoses(tx *gorm.DB, names []string) ([]string, error) {
+	result := []string{}
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	lowered := make([]string, len(names))
+	for i, name := range names {
+		lowered[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+	var diagnoses []models.Diagnosis
+	if err := tx.Where("LOWER(name) IN ?", lowered).Find(&diagnoses).Error; err != nil {
+		return nil, fmt.Errorf("failed to check diagnoses: %w", err)
+	}
+	canonical := make(map[string]string, len(diagnoses))
+	for _, diagnosis := range diagnoses {
+		canonical[strings.ToLower(diagnosis.Name)] = diagnosis.Name
+	}
+
+	var unknown []string
+	seen := make(map[string]bool, len(names))
+	for i, key := range lowered {
+		name, ok := canonical[key]
+		if !ok {
+			unknown = append(unknown, names[i])
+			continue
+		}
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, &ValidationError{Fields: map[string]string{
+			"diagnoses": "contains unknown diagnoses: " + strings.Join(unknown, ", "),
+		}}
+	}
+	return result, nil
+}
+
+// Regional Centers
+
+// CreateRegionalCenter creates a new regional center, geocoding its address
+// when no coordinates are given
+func (s *Service) CreateRegionalCenter(center *models.RegionalCenter) error {
+	if err := ValidateRegionalCenter(center); err != nil {
+		return err
+	}
+	s.locateRegionalCenter(center)
+
+	if err := s.db.Omit(s.writeOmits()...).Create(center).Error; err != nil {
+		return fmt.Errorf("failed to create regional center: %w", err)
+	}
+	return nil
+}
+
+// UpdateRegionalCenter replaces a regional center. center.UpdatedAt must be
+// the value the client last read, otherwise ErrConflict is returned. On
+// success center holds the stored record.
+func (s *Service) UpdateRegionalCenter(id int, center *models.RegionalCenter) error {
+	if err := requireUpdatedAt(center.UpdatedAt); err != nil {
+		return err
+	}
+	if err := ValidateRegionalCenter(center); err != nil {
+		return err
+	}
+	center.ID = id
+	s.locateRegionalCenter(center)
+
+	if err := s.replaceRecord(s.db, "regional_centers", "regional center", center, id, center.UpdatedAt); err != nil {
+		return err
+	}
+	if err := s.db.First(center, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to fetch regional center: %w", err)
+	}
+	return nil
+}
+
+// DeleteRegionalCenter deletes a regional center
+func (s *Service) DeleteRegionalCenter(id int) error {
+	return deleteRecord(s.db, &models.RegionalCenter{}, "regional center", id)
+}
+
+// locateRegionalCenter fills in coordinates from location_coordinates or the
+// address when they are missing, and sets location from them
+func (s *Service) locateRegionalCenter(center *models.RegionalCenter) {
+	if center.Latitude == nil || center.Longitude == nil {
+		if lat, lng, err := regionalCenterCoordinates(center); err == nil {
+			center.Latitude, center.Longitude = &lat, &lng
+		} else if err := s.GeocodeRegionalCenter(s.requestContext(), center); err != nil {
+			log.Printf("[GEOCODE] Could not geocode regional center %q: %v", Deref(center.RegionalCenter), err)
+		}
+	}
+	center.Location = nil
+	if center.Latitude != nil && center.Longitude != nil {
+		center.Location = models.NewPoint(*center.Latitude, *center.Longitude)
+	}
+}
+
+// Providers
+
+// CreateProvider creates a new provider and geocodes its coverage areas
+func (s *Service) CreateProvider(provider *models.Provider) error {
+	if err := ValidateProvider(provider); err != nil {
+		return err
+	}
+	if err := s.db.Omit(s.writeOmits()...).Create(provider).Error; err != nil {
+		return fmt.Errorf("failed to create provider: %w", err)
+	}
+	s.locateProvider(provider)
+	return nil
+}
+
+// UpdateProvider replaces a provider and geocodes its coverage areas again.
+// provider.UpdatedAt must be the value the client last read, otherwise
+// ErrConflict is returned. On success provider holds the stored record.
+func (s *Service) UpdateProvider(id int, provider *models.Provider) error {
+	if err := requireUpdatedAt(provider.UpdatedAt); err != nil {
+		return err
+	}
+	if err := ValidateProvider(provider); err != nil {
+		return err
+	}
+	provider.ID = id
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.replaceRecord(tx, "providers", "provider", provider, id, provider.UpdatedAt); err != nil {
+			return err
+		}
+		if err := tx.Where("provider_id = ?", id).Delete(&models.ProviderArea{}).Error; err != nil {
+			return fmt.Errorf("failed to clear provider areas: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.locateProvider(provider)
+
+	if err := s.db.First(provider, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to fetch provider: %w", err)
+	}
+	return nil
+}
+
+// DeleteProvider deletes a provider and its geocoded coverage areas
+func (s *Service) DeleteProvider(id int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("provider_id = ?", id).Delete(&models.ProviderArea{}).Error; err != nil {
+			return fmt.Errorf("failed to clear provider areas: %w", err)
+		}
+		return deleteRecord(tx, &models.Provider{}, "provider", id)
+	})
+}
+
+// locateProvider geocodes a provider's coverage areas. Failures are logged
+// and left to the backfill worker.
+func (s *Service) locateProvider(provider *models.Provider) {
+	if err := s.GeocodeProviderAreas(s.requestContext(), provider); err != nil && !errors.Is(err, ErrGeocodingDisabled) {
+		log.Printf("[GEOCODE] Could not geocode areas of provider %q: %v", provider.Name, err)
+	}
+}
+
+// Diagnoses
+
+// CreateDiagnosis creates a new diagnosis with a unique name
+func (s *Service) CreateDiagnosis(diagnosis *models.Diagnosis) error {
+	diagnosis.Name = strings.TrimSpace(diagnosis.Name)
+	if err := ValidateDiagnosis(diagnosis); err != nil {
+		return err
+	}
+	if err := s.checkDiagnosisName(s.db, diagnosis.Name, uuid.Nil); err != nil {
+		return err
+	}
+	if err := s.db.Create(diagnosis).Error; err != nil {
+		return fmt.Errorf("failed to create diagnosis: %w", err)
+	}
+	return nil
+}
+
+// UpdateDiagnosis renames a diagnosis, including in the diagnoses of every
+// resource that lists it. The array updates go through the model so each
+// changed row is audited, and UpdateColumn so they don't count as edits of
+// the rows: a client still sending the old name gets a validation error.
+func (s *Service) UpdateDiagnosis(id uuid.UUID, diagnosis *models.Diagnosis) error {
+	diagnosis.Name = strings.TrimSpace(diagnosis.Name)
+	if err := ValidateDiagnosis(diagnosis); err != nil {
+		return err
+	}
+	diagnosis.ID = id
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Diagnosis
+		if err := tx.First(&current, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("diagnosis %w", ErrNotFound)
+			}
+			return fmt.Errorf("failed to fetch diagnosis: %w", err)
+		}
+		if err := s.checkDiagnosisName(tx, diagnosis.Name, id); err != nil {
+			return err
+		}
+
+		if err := s.replaceRecord(tx, "diagnoses", "diagnosis", diagnosis, id, nil); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Resource{}).Where("? = ANY(diagnoses)", current.Name).
+			UpdateColumn("diagnoses", gorm.Expr("array_replace(diagnoses, ?, ?)", current.Name, diagnosis.Name)).Error; err != nil {
+			return fmt.Errorf("failed to rename diagnosis on resources: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteDiagnosis deletes a diagnosis, unlinking it from resource centers
+// and removing it from the diagnoses of resources and preferences
+func (s *Service) DeleteDiagnosis(id uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Diagnosis
+		if err := tx.First(&current, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("diagnosis %w", ErrNotFound)
+			}
+			return fmt.Errorf("failed to fetch diagnosis: %w", err)
+		}
+
+		if err := tx.Where("diagnosis_id = ?", id).Delete(&models.CenterDiagnosis{}).Error; err != nil {
+			return fmt.Errorf("failed to unlink diagnosis from resource centers: %w", err)
+		}
+		if err := tx.Model(&models.Resource{}).Where("? = ANY(diagnoses)", current.Name).
+			UpdateColumn("diagnoses", gorm.Expr("array_remove(diagnoses, ?)", current.Name)).Error; err != nil {
+			return fmt.Errorf("failed to remove diagnosis from resources: %w", err)
+		}
+		if err := tx.Model(&models.UserPreferences{}).Where("? = ANY(preferred_diagnoses)", id).
+			UpdateColumn("preferred_diagnoses", gorm.Expr("array_remove(preferred_diagnoses, ?)", id)).Error; err != nil {
+			return fmt.Errorf("failed to remove diagnosis from preferences: %w", err)
+		}
+		return deleteRecord(tx, &models.Diagnosis{}, "diagnosis", id)
+	})
+}
+
+// checkDiagnosisName reports a validation error when another diagnosis
+// already uses name, ignoring case
+func (s *Service) checkDiagnosisName(tx *gorm.DB, name string, id uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.Diagnosis{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check diagnosis name: %w", err)
+	}
+	if count > 0 {
+		return &ValidationError{Fields: map[string]string{"name": "is already used by another diagnosis"}}
+	}
+	return nil
+}
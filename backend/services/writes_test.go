@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/alexbeattie/medicalfacilities/models"
+)
+
+func TestUpdateResourceCenterRejectsStaleVersion(t *testing.T) {
+	s := testService(t)
+	center := models.ResourceCenter{Name: "Center", Latitude: 34, Longitude: -118}
+	if err := s.CreateResourceCenter(&center); err != nil {
+		t.Fatalf("CreateResourceCenter: %v", err)
+	}
+	read, err := s.GetResourceCenterByID(center.ID)
+	if err != nil {
+		t.Fatalf("GetResourceCenterByID: %v", err)
+	}
+
+	stale := read.UpdatedAt.Add(-time.Hour)
+	edit := *read
+	edit.Name, edit.UpdatedAt = "Edited", &stale
+	if err := s.UpdateResourceCenter(center.ID, &edit); !errors.Is(err, ErrConflict) {
+		t.Fatalf("stale UpdateResourceCenter = %v, want ErrConflict", err)
+	}
+	edit.UpdatedAt = read.UpdatedAt
+	if err := s.UpdateResourceCenter(center.ID, &edit); err != nil {
+		t.Fatalf("UpdateResourceCenter: %v", err)
+	}
+	if edit.Name != "Edited" || !edit.UpdatedAt.After(*read.UpdatedAt) {
+		t.Errorf("saved %q at %v, want the edit with a newer updated_at than %v", edit.Name, edit.UpdatedAt, read.UpdatedAt)
+	}
+
+	missing := *read
+	if err := s.UpdateResourceCenter(uuid.New(), &missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateResourceCenter of a missing center = %v, want ErrNotFound", err)
+	}
+}
+
+func TestResourceCenterDiagnoses(t *testing.T) {
+	s := testService(t)
+	center := models.ResourceCenter{Name: "Center", Latitude: 34, Longitude: -118}
+	if err := s.CreateResourceCenter(&center); err != nil {
+		t.Fatalf("CreateResourceCenter: %v", err)
+	}
+	autism := models.Diagnosis{Name: "Autism"}
+	if err := s.CreateDiagnosis(&autism); err != nil {
+		t.Fatalf("CreateDiagnosis: %v", err)
+	}
+
+	updated, err := s.SetResourceCenterDiagnoses(center.ID, []uuid.UUID{autism.ID, autism.ID})
+	if err != nil {
+		t.Fatalf("SetResourceCenterDiagnoses: %v", err)
+	}
+	if len(updated.Diagnoses) != 1 || updated.Diagnoses[0].ID != autism.ID {
+		t.Errorf("diagnoses = %+v, want Autism once", updated.Diagnoses)
+	}
+
+	if _, err := s.SetResourceCenterDiagnoses(center.ID, []uuid.UUID{uuid.New()}); invalidFields(t, err)[0] != "diagnosis_ids" {
+		t.Errorf("SetResourceCenterDiagnoses with an unknown diagnosis = %v, want a diagnosis_ids error", err)
+	}
+	if err := s.RemoveResourceCenterDiagnosis(center.ID, autism.ID); err != nil {
+		t.Fatalf("RemoveResourceCenterDiagnosis: %v", err)
+	}
+	if err := s.RemoveResourceCenterDiagnosis(center.ID, autism.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second RemoveResourceCenterDiagnosis = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDiagnosisNamesAreUnique(t *testing.T) {
+	s := testService(t)
+	autism := models.Diagnosis{Name: "Autism"}
+	if err := s.CreateDiagnosis(&autism); err != nil {
+		t.Fatalf("CreateDiagnosis: %v", err)
+	}
+
+	if err := s.CreateDiagnosis(&models.Diagnosis{Name: " autism "}); invalidFields(t, err)[0] != "name" {
+		t.Errorf("CreateDiagnosis of a taken name = %v, want a name error", err)
+	}
+	adhd := models.Diagnosis{Name: "ADHD"}
+	if err := s.CreateDiagnosis(&adhd); err != nil {
+		t.Fatalf("CreateDiagnosis: %v", err)
+	}
+	if err := s.UpdateDiagnosis(adhd.ID, &models.Diagnosis{Name: "AUTISM"}); invalidFields(t, err)[0] != "name" {
+		t.Errorf("renaming to a taken name = %v, want a name error", err)
+	}
+	if err := s.UpdateDiagnosis(autism.ID, &models.Diagnosis{Name: "autism"}); err != nil {
+		t.Errorf("changing the case of a diagnosis' own name: %v", err)
+	}
+}
+
+func TestUpdatesRequireUpdatedAt(t *testing.T) {
+	s := &Service{}
+	regionalCenter := "North LA County"
+	tests := []struct {
+		name   string
+		update func() error
+	}{
+		{"resource center", func() error {
+			return s.UpdateResourceCenter(uuid.New(), &models.ResourceCenter{Name: "Center", Latitude: 34, Longitude: -118})
+		}},
+		{"resource", func() error {
+			return s.UpdateResource(uuid.New(), &models.Resource{Name: "Resource", Latitude: 34, Longitude: -118})
+		}},
+		{"regional center", func() error {
+			return s.UpdateRegionalCenter(1, &models.RegionalCenter{RegionalCenter: &regionalCenter})
+		}},
+		{"provider", func() error {
+			return s.UpdateProvider(1, &models.Provider{Name: "Provider"})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verr *ValidationError
+			if err := tt.update(); !errors.As(err, &verr) || verr.Fields["updated_at"] == "" {
+				t.Errorf("update without updated_at = %v, want a validation error for updated_at", err)
+			}
+		})
+	}
+}
+
+func TestUpdatesRejectStaleVersions(t *testing.T) {
+	s := testService(t)
+	stale := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("resource", func(t *testing.T) {
+		resource := models.Resource{Name: "Resource", Latitude: 34, Longitude: -118}
+		if err := s.CreateResource(&resource); err != nil {
+			t.Fatalf("CreateResource: %v", err)
+		}
+		read := resource.UpdatedAt
+
+		edit := resource
+		edit.Name, edit.UpdatedAt = "Edited", stale
+		if err := s.UpdateResource(resource.ID, &edit); !errors.Is(err, ErrConflict) {
+			t.Fatalf("stale UpdateResource = %v, want ErrConflict", err)
+		}
+		edit.UpdatedAt = read
+		if err := s.UpdateResource(resource.ID, &edit); err != nil {
+			t.Fatalf("UpdateResource: %v", err)
+		}
+		if edit.Name != "Edited" || !edit.UpdatedAt.After(read) {
+			t.Errorf("saved %q at %v, want the edit with a newer updated_at than %v", edit.Name, edit.UpdatedAt, read)
+		}
+		// The version read before the first save is now stale as well
+		edit.UpdatedAt = read
+		if err := s.UpdateResource(resource.ID, &edit); !errors.Is(err, ErrConflict) {
+			t.Errorf("second UpdateResource with the old version = %v, want ErrConflict", err)
+		}
+	})
+
+	t.Run("resource center", func(t *testing.T) {
+		center := models.ResourceCenter{Name: "Center", Latitude: 34, Longitude: -118}
+		if err := s.CreateResourceCenter(&center); err != nil {
+			t.Fatalf("CreateResourceCenter: %v", err)
+		}
+		edit := center
+		edit.UpdatedAt = &stale
+		if err := s.UpdateResourceCenter(center.ID, &edit); !errors.Is(err, ErrConflict) {
+			t.Fatalf("stale UpdateResourceCenter = %v, want ErrConflict", err)
+		}
+		edit.UpdatedAt = center.UpdatedAt
+		if err := s.UpdateResourceCenter(center.ID, &edit); err != nil {
+			t.Errorf("UpdateResourceCenter: %v", err)
+		}
+	})
+
+	t.Run("regional center", func(t *testing.T) {
+		name := "North LA County"
+		lat, lng := 34.2, -118.5
+		center := models.RegionalCenter{RegionalCenter: &name, Latitude: &lat, Longitude: &lng}
+		if err := s.CreateRegionalCenter(&center); err != nil {
+			t.Fatalf("CreateRegionalCenter: %v", err)
+		}
+		if err := s.db.First(&center, "id = ?", center.ID).Error; err != nil {
+			t.Fatalf("reading regional center: %v", err)
+		}
+		edit := center
+		edit.UpdatedAt = &stale
+		if err := s.UpdateRegionalCenter(center.ID, &edit); !errors.Is(err, ErrConflict) {
+			t.Fatalf("stale UpdateRegionalCenter = %v, want ErrConflict", err)
+		}
+		edit.UpdatedAt = center.UpdatedAt
+		if err := s.UpdateRegionalCenter(center.ID, &edit); err != nil {
+			t.Errorf("UpdateRegionalCenter: %v", err)
+		}
+	})
+
+	t.Run("provider", func(t *testing.T) {
+		provider := models.Provider{Name: "Provider"}
+		if err := s.CreateProvider(&provider); err != nil {
+			t.Fatalf("CreateProvider: %v", err)
+		}
+		if err := s.db.First(&provider, "id = ?", provider.ID).Error; err != nil {
+			t.Fatalf("reading provider: %v", err)
+		}
+		edit := provider
+		edit.UpdatedAt = &stale
+		if err := s.UpdateProvider(provider.ID, &edit); !errors.Is(err, ErrConflict) {
+			t.Fatalf("stale UpdateProvider = %v, want ErrConflict", err)
+		}
+		edit.UpdatedAt = provider.UpdatedAt
+		if err := s.UpdateProvider(provider.ID, &edit); err != nil {
+			t.Errorf("UpdateProvider: %v", err)
+		}
+	})
+}
+
+func TestDiagnosisChangesAreAudited(t *testing.T) {
+	s := testService(t).WithContext(WithAuditActor(context.Background(), AuditActor{RequestID: "req-1"}))
+	autism, adhd := models.Diagnosis{Name: "Autism"}, models.Diagnosis{Name: "ADHD"}
+	if err := s.CreateDiagnosis(&autism); err != nil {
+		t.Fatalf("CreateDiagnosis: %v", err)
+	}
+	if err := s.CreateDiagnosis(&adhd); err != nil {
+		t.Fatalf("CreateDiagnosis: %v", err)
+	}
+	resource := models.Resource{Name: "Resource", Latitude: 34, Longitude: -118, Diagnoses: []string{"Autism", "ADHD"}}
+	if err := s.CreateResource(&resource); err != nil {
+		t.Fatalf("CreateResource: %v", err)
+	}
+	preferences := DefaultUserPreferences()
+	preferences.PreferredDiagnoses = []string{autism.ID.String()}
+	if err := s.UpdateUserPreferences(7, &preferences); err != nil {
+		t.Fatalf("UpdateUserPreferences: %v", err)
+	}
+
+	// updates returns the audited updates of a row, oldest first
+	updates := func(table, id string) []models.AuditLog {
+		t.Helper()
+		var entries []models.AuditLog
+		if err := s.db.Where("entity_type = ? AND entity_id = ? AND action = ?", table, id, models.AuditUpdate).
+			Order("id").Find(&entries).Error; err != nil {
+			t.Fatalf("reading audit log: %v", err)
+		}
+		return entries
+	}
+	resourceDiagnoses := func() models.StringArray {
+		t.Helper()
+		saved, err := s.GetResourceByID(resource.ID)
+		if err != nil {
+			t.Fatalf("GetResourceByID: %v", err)
+		}
+		return saved.Diagnoses
+	}
+
+	renamed := models.Diagnosis{Name: "Autism spectrum disorder"}
+	if err := s.UpdateDiagnosis(autism.ID, &renamed); err != nil {
+		t.Fatalf("UpdateDiagnosis: %v", err)
+	}
+	if got := resourceDiagnoses(); !reflect.DeepEqual(got, models.StringArray{"Autism spectrum disorder", "ADHD"}) {
+		t.Errorf("diagnoses after rename = %q", got)
+	}
+	entries := updates("resources", resource.ID.String())
+	if len(entries) != 1 {
+		t.Fatalf("resource has %d audited updates after the rename, want 1", len(entries))
+	}
+	change, ok := entries[0].Changes["diagnoses"]
+	if !ok || entries[0].RequestID == nil || *entries[0].RequestID != "req-1" {
+		t.Fatalf("audit entry = %+v, want a diagnoses change of request req-1", entries[0])
+	}
+	if !reflect.DeepEqual(change.Before, []interface{}{"Autism", "ADHD"}) ||
+		!reflect.DeepEqual(change.After, []interface{}{"Autism spectrum disorder", "ADHD"}) {
+		t.Errorf("diagnoses change = %v -> %v", change.Before, change.After)
+	}
+
+	if err := s.DeleteDiagnosis(autism.ID); err != nil {
+		t.Fatalf("DeleteDiagnosis: %v", err)
+	}
+	if got := resourceDiagnoses(); !reflect.DeepEqual(got, models.StringArray{"ADHD"}) {
+		t.Errorf("diagnoses after delete = %q", got)
+	}
+	if entries := updates("resources", resource.ID.String()); len(entries) != 2 {
+		t.Errorf("resource has %d audited updates after the delete, want 2", len(entries))
+	}
+	saved, err := s.GetUserPreferences(7)
+	if err != nil {
+		t.Fatalf("GetUserPreferences: %v", err)
+	}
+	if len(saved.PreferredDiagnoses) != 0 {
+		t.Errorf("preferred diagnoses after delete = %q, want none", saved.PreferredDiagnoses)
+	}
+	if entries := updates("user_preferences", fmt.Sprint(saved.ID)); len(entries) != 1 || entries[0].Changes["preferred_diagnoses"].Before == nil {
+		t.Errorf("preferences audit = %+v, want the removed diagnosis recorded", entries)
+	}
+}
@@ -0,0 +1,37 @@
+// spreadsheet/csv.go
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// utf8BOM is written at the start of CSV files by Excel
+const utf8BOM = "\uFEFF"
+
+// ReadCSV returns the records of a CSV file, numbered by the line they start
+// on. Records may have different numbers of fields.
+func ReadCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var rows []Row
+	for {
+		cells, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(rows) == 0 {
+			cells[0] = strings.TrimPrefix(cells[0], utf8BOM)
+		}
+		rows = append(rows, Row{Number: line, Cells: cells})
+	}
+	return rows, nil
+}
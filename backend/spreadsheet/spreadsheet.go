@@ -0,0 +1,57 @@
+// Package spreadsheet reads the rows of CSV and XLSX files
+package spreadsheet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Formats of files Read accepts
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ErrUnknownFormat is returned for files that are neither CSV nor XLSX
+var ErrUnknownFormat = errors.New("file must be CSV or XLSX")
+
+// xlsxSignature starts every zip archive, and so every XLSX file
+var xlsxSignature = []byte("PK\x03\x04")
+
+// DetectFormat tells CSV and XLSX files apart by their content, falling back
+// on the file name's extension. It returns "" when neither matches.
+func DetectFormat(name string, data []byte) string {
+	if bytes.HasPrefix(data, xlsxSignature) {
+		return FormatXLSX
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".txt":
+		return FormatCSV
+	case ".xlsx", ".xls":
+		return ""
+	}
+	if len(data) > 0 && !bytes.ContainsRune(data[:min(len(data), 512)], 0) {
+		return FormatCSV
+	}
+	return ""
+}
+
+// Row is one non-empty row of a spreadsheet
+type Row struct {
+	Number int      // 1-based row number in the sheet, or line number in a CSV file
+	Cells  []string // as text; trailing empty cells may be missing
+}
+
+// Read returns the rows of a CSV file or of the first sheet of an XLSX file
+func Read(name string, data []byte) ([]Row, error) {
+	switch DetectFormat(name, data) {
+	case FormatCSV:
+		return ReadCSV(bytes.NewReader(data))
+	case FormatXLSX:
+		return ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrUnknownFormat)
+}
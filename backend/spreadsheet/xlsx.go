@@ -0,0 +1,211 @@
+// spreadsheet/xlsx.go
+package spreadsheet
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxXLSXPartSize bounds how much of one part of an XLSX archive is read, so
+// a small upload can't unpack into gigabytes
+const maxXLSXPartSize = 64 << 20
+
+// ReadXLSX returns the non-empty rows of the first sheet of an XLSX file.
+// Cells hold their text as shown without formatting: numbers as stored,
+// booleans as TRUE or FALSE and formulas as their last computed value.
+func ReadXLSX(r io.ReaderAt, size int64) ([]Row, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open XLSX: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	if file, ok := files["xl/sharedStrings.xml"]; ok {
+		if shared, err = readSharedStrings(file); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Number int `xml:"r,attr"`
+			Cells  []struct {
+				Ref    string     `xml:"r,attr"`
+				Type   string     `xml:"t,attr"`
+				Value  string     `xml:"v"`
+				Inline richString `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodePart(files[sheetPath], &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, 0, len(sheet.Rows))
+	for i, xmlRow := range sheet.Rows {
+		row := Row{Number: xmlRow.Number}
+		if row.Number == 0 {
+			row.Number = i + 1
+		}
+		empty := true
+		for j, cell := range xmlRow.Cells {
+			column := j
+			if cell.Ref != "" {
+				if column, err = columnIndex(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+
+			var text string
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(shared) {
+					return nil, fmt.Errorf("XLSX cell %s refers to a missing shared string", cell.Ref)
+				}
+				text = shared[index]
+			case "inlineStr":
+				text = cell.Inline.String()
+			case "b":
+				text = map[string]string{"1": "TRUE", "0": "FALSE"}[cell.Value]
+			default:
+				text = cell.Value
+			}
+			if text == "" {
+				continue
+			}
+
+			for len(row.Cells) <= column {
+				row.Cells = append(row.Cells, "")
+			}
+			row.Cells[column] = text
+			empty = false
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// richString is text that may be split into formatted runs
+type richString struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (s richString) String() string {
+	text := s.Text
+	for _, run := range s.Runs {
+		text += run.Text
+	}
+	return text
+}
+
+// firstSheetPath finds the part holding the workbook's first sheet
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			RelationID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var relations struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodePart(files["xl/workbook.xml"], &workbook); err == nil && len(workbook.Sheets) > 0 {
+		if err := decodePart(files["xl/_rels/workbook.xml.rels"], &relations); err == nil {
+			for _, relation := range relations.Items {
+				if relation.ID != workbook.Sheets[0].RelationID {
+					continue
+				}
+				target := strings.TrimPrefix(relation.Target, "/")
+				if !strings.HasPrefix(target, "xl/") {
+					target = path.Join("xl", target)
+				}
+				if _, ok := files[target]; ok {
+					return target, nil
+				}
+			}
+		}
+	}
+
+	// Workbooks written by other tools may skip the relationships
+	var sheets []string
+	for name := range files {
+		if strings.HasPrefix(name, "xl/worksheets/") && strings.HasSuffix(name, ".xml") {
+			sheets = append(sheets, name)
+		}
+	}
+	if len(sheets) == 0 {
+		return "", errors.New("XLSX file has no sheets")
+	}
+	sort.Strings(sheets)
+	return sheets[0], nil
+}
+
+// readSharedStrings reads the table of strings cells of type "s" refer to
+func readSharedStrings(file *zip.File) ([]string, error) {
+	var table struct {
+		Items []richString `xml:"si"`
+	}
+	if err := decodePart(file, &table); err != nil {
+		return nil, err
+	}
+	shared := make([]string, len(table.Items))
+	for i, item := range table.Items {
+		shared[i] = item.String()
+	}
+	return shared, nil
+}
+
+// decodePart decodes an XML part of the archive into v
+func decodePart(file *zip.File, v interface{}) error {
+	if file == nil {
+		return errors.New("XLSX file is missing a required part")
+	}
+	part, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open XLSX part %s: %w", file.Name, err)
+	}
+	defer part.Close()
+	if err := xml.NewDecoder(io.LimitReader(part, maxXLSXPartSize)).Decode(v); err != nil {
+		return fmt.Errorf("failed to read XLSX part %s: %w", file.Name, err)
+	}
+	return nil
+}
+
+// columnIndex returns the 0-based column of a cell reference such as "C7"
+func columnIndex(ref string) (int, error) {
+	column := 0
+	for i, r := range ref {
+		if r >= 'A' && r <= 'Z' {
+			column = column*26 + int(r-'A') + 1
+			continue
+		}
+		if i == 0 {
+			break
+		}
+		return column - 1, nil
+	}
+	return 0, fmt.Errorf("invalid XLSX cell reference %q", ref)
+}
@@ -0,0 +1,162 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    int
+		wantErr bool
+	}{
+		{"A1", 0, false},
+		{"C7", 2, false},
+		{"Z10", 25, false},
+		{"AA1", 26, false},
+		{"AZ3", 51, false},
+		{"XFD1048576", 16383, false},
+		{"", 0, true},
+		{"7", 0, true},
+		{"C", 0, true},
+		{"c7", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := columnIndex(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("columnIndex(%q) = %d, want an error", tt.ref, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("columnIndex(%q) = %d, %v, want %d", tt.ref, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// xlsx zips parts into an XLSX file
+func xlsx(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range parts {
+		part, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := part.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const (
+	testWorkbook = `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+		xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+		<sheets><sheet name="Centers" sheetId="1" r:id="rId2"/><sheet name="Other" sheetId="2" r:id="rId1"/></sheets>
+	</workbook>`
+	testRelations = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+		<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+		<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/>
+	</Relationships>`
+	testSharedStrings = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+		<si><t>name</t></si>
+		<si><t>city</t></si>
+		<si><r><t>Bright </t></r><r><t>Futures</t></r></si>
+	</sst>`
+	testSheet = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+		<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="D1" t="inlineStr"><is><t>open</t></is></c></row>
+		<row r="2"><c r="A2"/><c r="B2" s="1"/></row>
+		<row r="3"><c r="A3" t="s"><v>2</v></c><c r="B3" t="inlineStr"><is><r><t>Irv</t></r><r><t>ine</t></r></is></c><c r="C3"><v>42.5</v></c><c r="D3" t="b"><v>1</v></c></row>
+		<row r="5"><c r="B5" t="str"><v>Tustin</v></c><c r="D5" t="b"><v>0</v></c></row>
+	</sheetData></worksheet>`
+)
+
+func TestReadXLSX(t *testing.T) {
+	data := xlsx(t, map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testRelations,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData><row r="1"><c><v>wrong sheet</v></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml":   testSheet,
+	})
+
+	rows, err := Read("centers.xlsx", data)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := []Row{
+		{Number: 1, Cells: []string{"name", "city", "", "open"}},
+		{Number: 3, Cells: []string{"Bright Futures", "Irvine", "42.5", "TRUE"}},
+		{Number: 5, Cells: []string{"", "Tustin", "", "FALSE"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Read = %q, want %q", rows, want)
+	}
+}
+
+func TestReadXLSXWithoutRelationships(t *testing.T) {
+	data := xlsx(t, map[string]string{
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData><row><c><v>second</v></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c><v>a</v></c><c><v>b</v></c></row><row><c t="inlineStr"><is><t>c</t></is></c></row></sheetData></worksheet>`,
+	})
+
+	rows, err := ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("ReadXLSX: %v", err)
+	}
+	want := []Row{{Number: 1, Cells: []string{"a", "b"}}, {Number: 2, Cells: []string{"c"}}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("ReadXLSX = %q, want %q", rows, want)
+	}
+}
+
+func TestReadXLSXErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts map[string]string
+		want  string
+	}{
+		{"no sheets", map[string]string{"xl/styles.xml": "<styleSheet/>"}, "no sheets"},
+		{
+			"missing shared string",
+			map[string]string{
+				"xl/sharedStrings.xml":     `<sst><si><t>only</t></si></sst>`,
+				"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="s"><v>3</v></c></row></sheetData></worksheet>`,
+			},
+			"A1 refers to a missing shared string",
+		},
+		{
+			"invalid cell reference",
+			map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="1A"><v>x</v></c></row></sheetData></worksheet>`},
+			`invalid XLSX cell reference "1A"`,
+		},
+		{
+			"malformed sheet",
+			map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row>`},
+			"failed to read XLSX part xl/worksheets/sheet1.xml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := xlsx(t, tt.parts)
+			_, err := ReadXLSX(bytes.NewReader(data), int64(len(data)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ReadXLSX error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
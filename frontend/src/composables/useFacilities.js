@@ -262,6 +262,12 @@ export function useFacilities() {
     }
   }
 
+  // Token recording when the contact form was shown, sent back with it
+  const getFormToken = async () => {
+    const data = await apiService.getFormToken()
+    return data.form_token
+  }
+
   // ===== UTILITY FUNCTIONS =====
 
   const calculateDistance = (lat1, lng1, lat2, lng2) => {
@@ -341,6 +347,7 @@ export function useFacilities() {
 
     // Forms
     submitContactForm,
+    getFormToken,
 
     // Utilities
     calculateDistance,
@@ -1,5 +1,8 @@
 // src/services/api.js
 
+// Page size used when fetching whole lists; the API's maximum
+const LIST_PAGE_LIMIT = 500;
+
 class ApiService {
   constructor() {
     // Environment-based API configuration
@@ -44,6 +47,34 @@ class ApiService {
     return response.json();
   }
 
+  // List endpoints return { items, next_cursor, total }. Callers asking for
+  // a specific page (with limit or cursor) get its items; otherwise
+  // next_cursor is followed until the list is exhausted so maps and lists
+  // see every match, not just the first page.
+  async fetchList(url, filters = {}) {
+    const singlePage = Boolean(filters.limit || filters.cursor);
+    const pageUrl = new URL(url, window.location.origin);
+    if (!singlePage) pageUrl.searchParams.set('limit', LIST_PAGE_LIMIT);
+
+    const items = [];
+    for (;;) {
+      const response = await fetch(pageUrl.toString(), {
+        method: 'GET',
+        headers: this.headers
+      });
+      const page = await this.handleResponse(response);
+      items.push(...(page.items || []));
+      if (singlePage || !page.next_cursor) return items;
+      pageUrl.searchParams.set('cursor', page.next_cursor);
+    }
+  }
+
+  appendPageParams(params, filters) {
+    if (filters.limit) params.append('limit', filters.limit);
+    if (filters.cursor) params.append('cursor', filters.cursor);
+    if (filters.sort) params.append('sort', filters.sort);
+  }
+
   // ===== ABA CENTERS =====
 
   // Get all ABA centers with optional filtering
@@ -57,16 +88,13 @@ class ApiService {
       if (filters.waitlist) params.append('waitlist', 'true');
       if (filters.search) params.append('search', filters.search);
 
+      this.appendPageParams(params, filters);
+
       const queryString = params.toString();
       const url = `${this.baseUrl}/api/v1/aba-centers${queryString ? '?' + queryString : ''}`;
 
       console.log(`Fetching ABA centers from: ${url}`);
-      const response = await fetch(url, {
-        method: 'GET',
-        headers: this.headers
-      });
-
-      return this.handleResponse(response);
+      return this.fetchList(url, filters);
     } catch (error) {
       console.error('Error fetching ABA centers:', error);
       throw error;
@@ -114,16 +142,13 @@ class ApiService {
       if (filters.lng) params.append('lng', filters.lng);
       if (filters.radius) params.append('radius', filters.radius);
 
+      this.appendPageParams(params, filters);
+
       const queryString = params.toString();
       const url = `${this.baseUrl}/api/v1/resource-centers${queryString ? '?' + queryString : ''}`;
 
       console.log(`Fetching resource centers from: ${url}`);
-      const response = await fetch(url, {
-        method: 'GET',
-        headers: this.headers
-      });
-
-      return this.handleResponse(response);
+      return this.fetchList(url, filters);
     } catch (error) {
       console.error('Error fetching resource centers:', error);
       throw error;
@@ -157,16 +182,13 @@ class ApiService {
       if (filters.lng) params.append('lng', filters.lng);
       if (filters.radius) params.append('radius', filters.radius);
 
+      this.appendPageParams(params, filters);
+
       const queryString = params.toString();
       const url = `${this.baseUrl}/api/v1/resources${queryString ? '?' + queryString : ''}`;
 
       console.log(`Fetching resources from: ${url}`);
-      const response = await fetch(url, {
-        method: 'GET',
-        headers: this.headers
-      });
-
-      return this.handleResponse(response);
+      return this.fetchList(url, filters);
     } catch (error) {
       console.error('Error fetching resources:', error);
       throw error;
@@ -200,16 +222,13 @@ class ApiService {
       if (filters.lng) params.append('lng', filters.lng);
       if (filters.radius) params.append('radius', filters.radius);
 
+      this.appendPageParams(params, filters);
+
       const queryString = params.toString();
       const url = `${this.baseUrl}/api/v1/regional-centers${queryString ? '?' + queryString : ''}`;
 
       console.log(`Fetching regional centers from: ${url}`);
-      const response = await fetch(url, {
-        method: 'GET',
-        headers: this.headers
-      });
-
-      return this.handleResponse(response);
+      return this.fetchList(url, filters);
     } catch (error) {
       console.error('Error fetching regional centers:', error);
       throw error;
@@ -226,16 +245,13 @@ class ApiService {
       if (filters.search) params.append('search', filters.search);
       if (filters.area) params.append('area', filters.area);
 
+      this.appendPageParams(params, filters);
+
       const queryString = params.toString();
       const url = `${this.baseUrl}/api/v1/providers${queryString ? '?' + queryString : ''}`;
 
       console.log(`Fetching providers from: ${url}`);
-      const response = await fetch(url, {
-        method: 'GET',
-        headers: this.headers
-      });
-
-      return this.handleResponse(response);
+      return this.fetchList(url, filters);
     } catch (error) {
       console.error('Error fetching providers:', error);
       throw error;
@@ -247,11 +263,7 @@ class ApiService {
   // Get all diagnoses
   async getDiagnoses() {
     try {
-      const response = await fetch(`${this.baseUrl}/api/v1/diagnoses`, {
-        method: 'GET',
-        headers: this.headers
-      });
-      return this.handleResponse(response);
+      return this.fetchList(`${this.baseUrl}/api/v1/diagnoses`);
     } catch (error) {
       console.error('Error fetching diagnoses:', error);
       throw error;
@@ -290,6 +302,20 @@ class ApiService {
 
   // ===== FORM SUBMISSIONS =====
 
+  // Get the token a contact form sends back with its submission
+  async getFormToken() {
+    try {
+      const response = await fetch(`${this.baseUrl}/api/v1/form-token`, {
+        method: 'GET',
+        headers: this.headers
+      });
+      return this.handleResponse(response);
+    } catch (error) {
+      console.error('Error fetching form token:', error);
+      throw error;
+    }
+  }
+
   // Submit a contact form
   async submitForm(formData) {
     try {
@@ -307,31 +333,34 @@ class ApiService {
 
   // ===== USER PREFERENCES =====
 
-  // Get user preferences
-  async getUserPreferences(userId) {
+  // Preferences belong to the signed-in user, or to this browser through
+  // the signed device cookie, so requests must carry credentials
+  async getUserPreferences() {
     try {
-      const response = await fetch(`${this.baseUrl}/api/v1/preferences/${userId}`, {
+      const response = await fetch(`${this.baseUrl}/api/v1/preferences/me`, {
         method: 'GET',
-        headers: this.headers
+        headers: this.headers,
+        credentials: 'include'
       });
       return this.handleResponse(response);
     } catch (error) {
-      console.error(`Error fetching preferences for user ${userId}:`, error);
+      console.error('Error fetching preferences:', error);
       throw error;
     }
   }
 
-  // Update user preferences
-  async updateUserPreferences(userId, preferences) {
+  // Replace the preferences
+  async updateUserPreferences(preferences) {
     try {
-      const response = await fetch(`${this.baseUrl}/api/v1/preferences/${userId}`, {
+      const response = await fetch(`${this.baseUrl}/api/v1/preferences/me`, {
         method: 'PUT',
         headers: this.headers,
+        credentials: 'include',
         body: JSON.stringify(preferences)
       });
       return this.handleResponse(response);
     } catch (error) {
-      console.error(`Error updating preferences for user ${userId}:`, error);
+      console.error('Error updating preferences:', error);
       throw error;
     }
   }